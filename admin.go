@@ -0,0 +1,215 @@
+package gapp
+
+import (
+	"cmp"
+	"embed"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+//go:embed admin.html
+var adminFS embed.FS
+
+var adminTemplate = template.Must(template.ParseFS(adminFS, "admin.html"))
+
+// maxRecentErrors is the number of failed calls kept for the admin dashboard.
+const maxRecentErrors = 50
+
+// RpcStats are the call statistics of a registered RPC method.
+type RpcStats struct {
+	Method        string
+	Kind          string // "unary", "stream", "upload" or "download"
+	Streaming     bool
+	Calls         int64
+	Errors        int64
+	TotalDuration time.Duration
+	MaxDuration   time.Duration
+	LastCalled    time.Time
+}
+
+// AvgDuration returns the mean call duration.
+func (s RpcStats) AvgDuration() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.TotalDuration / time.Duration(s.Calls)
+}
+
+// RpcErrorEntry is a failed RPC call.
+type RpcErrorEntry struct {
+	Time   time.Time
+	Method string
+	Code   string
+	Error  string
+}
+
+type rpcStats struct {
+	mu      sync.Mutex
+	methods map[string]*RpcStats
+	errors  []RpcErrorEntry
+}
+
+func newRpcStats() *rpcStats {
+	return &rpcStats{methods: make(map[string]*RpcStats)}
+}
+
+// record updates the stats of a registered method and keeps the error, if any.
+func (d *Dispatcher) record(method string, duration time.Duration, err error) {
+	if d.stats == nil {
+		return
+	}
+	kind := d.methodKind(method)
+
+	d.stats.mu.Lock()
+	defer d.stats.mu.Unlock()
+
+	if kind != "" {
+		s, ok := d.stats.methods[method]
+		if !ok {
+			s = &RpcStats{Method: method, Kind: kind, Streaming: kind == "stream"}
+			d.stats.methods[method] = s
+		}
+		s.Calls++
+		s.TotalDuration += duration
+		s.MaxDuration = max(s.MaxDuration, duration)
+		s.LastCalled = time.Now()
+		if err != nil {
+			s.Errors++
+		}
+	}
+
+	if err != nil {
+		entry := RpcErrorEntry{Time: time.Now(), Method: method, Code: CodeInternal, Error: err.Error()}
+		var rpcErr *RpcError
+		if errors.As(err, &rpcErr) {
+			entry.Code = rpcErr.Code
+		}
+		d.stats.errors = append(d.stats.errors, entry)
+		if len(d.stats.errors) > maxRecentErrors {
+			d.stats.errors = d.stats.errors[len(d.stats.errors)-maxRecentErrors:]
+		}
+	}
+}
+
+// methodKind returns the kind of handler registered for method, or "" if
+// there is none.
+func (d *Dispatcher) methodKind(method string) string {
+	if _, ok := d.Streaming[method]; ok {
+		return "stream"
+	}
+	if _, ok := d.Unary[method]; ok {
+		return "unary"
+	}
+	if _, ok := d.Uploads[method]; ok {
+		return "upload"
+	}
+	if _, ok := d.Downloads[method]; ok {
+		return "download"
+	}
+	return ""
+}
+
+// Stats returns the call statistics of every registered method, sorted by name.
+func (d *Dispatcher) Stats() []RpcStats {
+	var stats []RpcStats
+	for method := range d.Unary {
+		stats = append(stats, RpcStats{Method: method, Kind: "unary"})
+	}
+	for method := range d.Streaming {
+		stats = append(stats, RpcStats{Method: method, Kind: "stream", Streaming: true})
+	}
+	for method := range d.Uploads {
+		stats = append(stats, RpcStats{Method: method, Kind: "upload"})
+	}
+	for method := range d.Downloads {
+		stats = append(stats, RpcStats{Method: method, Kind: "download"})
+	}
+
+	if d.stats != nil {
+		d.stats.mu.Lock()
+		for i, s := range stats {
+			if recorded, ok := d.stats.methods[s.Method]; ok {
+				stats[i] = *recorded
+			}
+		}
+		d.stats.mu.Unlock()
+	}
+
+	slices.SortFunc(stats, func(a, b RpcStats) int { return cmp.Compare(a.Method, b.Method) })
+	return stats
+}
+
+// RecentErrors returns the most recent failed calls, newest first.
+func (d *Dispatcher) RecentErrors() []RpcErrorEntry {
+	if d.stats == nil {
+		return nil
+	}
+	d.stats.mu.Lock()
+	defer d.stats.mu.Unlock()
+
+	entries := slices.Clone(d.stats.errors)
+	slices.Reverse(entries)
+	return entries
+}
+
+// AdminConfig configures AdminHandler.
+type AdminConfig struct {
+	Dispatcher *Dispatcher
+	Preload    *PreloadEngine
+
+	// Validate authenticates dashboard requests like AuthMiddleware does for
+	// RPCs: a nil token is rejected with 401. It is required, since the page
+	// exposes internals of the running server.
+	Validate func(r *http.Request) any
+}
+
+type adminPageData struct {
+	Rpcs    []RpcStats
+	Errors  []RpcErrorEntry
+	Routes  []RouteSpec
+	Assets  Assets
+	Version AppVersion
+	Health  HealthReport
+	Now     time.Time
+}
+
+// AdminHandler serves an HTML dashboard listing the registered RPC methods
+// with live call stats, recent errors, the preload routes and their RPCs, and
+// the loaded assets. Mount it on a path of your choice, e.g. "/__admin".
+func AdminHandler(config AdminConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if config.Validate == nil || config.Validate(r) == nil {
+			http.Error(w, "authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		data := adminPageData{
+			Health: Health().Check(r.Context()),
+			Now:    time.Now(),
+		}
+		if d := config.Dispatcher; d != nil {
+			data.Rpcs = d.Stats()
+			data.Errors = d.RecentErrors()
+		}
+		if p := config.Preload; p != nil {
+			data.Routes = p.Routes
+			data.Assets = p.currentAssets()
+			data.Version = p.version
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := adminTemplate.Execute(w, data); err != nil {
+			logger := slog.Default()
+			if config.Dispatcher != nil {
+				logger = config.Dispatcher.log()
+			}
+			logger.Error("Failed to render admin dashboard", "error", err)
+		}
+	})
+}
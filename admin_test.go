@@ -0,0 +1,75 @@
+package gapp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStatsCountUploadsAndDownloads(t *testing.T) {
+	d := NewDispatcher()
+	d.Uploads["Import"] = func(w http.ResponseWriter, r *http.Request, method string, upload *Upload) ([]byte, error) {
+		return []byte{}, nil
+	}
+	d.Downloads["Export"] = func(r *http.Request, method string, request []byte) (*Download, error) {
+		return &Download{Name: "export.csv", Content: strings.NewReader("a,b\n")}, nil
+	}
+
+	for _, method := range []string{"Import", "Export", "Export"} {
+		r := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader("data"))
+		r.Header.Set("X-Rpc-Method", method)
+		rec := httptest.NewRecorder()
+		d.ServeHTTP(rec, r)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s = %d, want 200", method, rec.Code)
+		}
+	}
+
+	stats := d.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("Stats = %+v, want Export and Import", stats)
+	}
+	for i, want := range []RpcStats{{Method: "Export", Kind: "download", Calls: 2}, {Method: "Import", Kind: "upload", Calls: 1}} {
+		got := stats[i]
+		if got.Method != want.Method || got.Kind != want.Kind || got.Calls != want.Calls {
+			t.Errorf("Stats[%d] = %s %s with %d calls, want %s %s with %d", i, got.Method, got.Kind, got.Calls, want.Method, want.Kind, want.Calls)
+		}
+		if got.LastCalled.IsZero() {
+			t.Errorf("Stats[%d].LastCalled not set", i)
+		}
+	}
+}
+
+func TestStatsIgnoreClientDisconnects(t *testing.T) {
+	d := NewDispatcher()
+	// Streams end on a disconnect either with Send's error or their context's
+	d.Streaming["Sends"] = func(w http.ResponseWriter, r *http.Request, method string, body []byte) error {
+		stream := NewRequestStreamAdapter(w, r)
+		<-stream.Done()
+		return stream.Send([]byte("late"))
+	}
+	d.Streaming["Waits"] = func(w http.ResponseWriter, r *http.Request, method string, body []byte) error {
+		stream := NewRequestStreamAdapter(w, r)
+		<-stream.Done()
+		return stream.Context().Err()
+	}
+
+	for _, method := range []string{"Sends", "Waits"} {
+		ctx, cancel := context.WithCancel(t.Context())
+		r := httptest.NewRequestWithContext(ctx, http.MethodPost, "/rpc", strings.NewReader(""))
+		r.Header.Set("X-Rpc-Method", method)
+		cancel()
+		d.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	for _, s := range d.Stats() {
+		if s.Calls != 1 || s.Errors != 0 {
+			t.Errorf("%s has %d calls and %d errors, want 1 call and no errors", s.Method, s.Calls, s.Errors)
+		}
+	}
+	if errs := d.RecentErrors(); len(errs) != 0 {
+		t.Errorf("RecentErrors = %+v, want none", errs)
+	}
+}
@@ -0,0 +1,168 @@
+package gapp
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// hashedAssetPattern matches Vite's content-hashed filenames such as
+// "index-BrT0Xz1a.js" or "logo.4f3a9c1d.svg". It also matches plain names
+// like "apple-touch-icon.png", so see immutableAsset.
+var hashedAssetPattern = regexp.MustCompile(`[-.][A-Za-z0-9_-]{8,}\.[A-Za-z0-9]+$`)
+
+// AssetHandler serves the built client assets in dir (typically "public").
+// See AssetHandlerFS.
+func AssetHandler(dir string) http.Handler {
+	return AssetHandlerFS(os.DirFS(dir))
+}
+
+// AssetHandlerFS serves files from fsys at the request path, so mounting it
+// at "/assets/" serves fsys/assets/. Unlike http.FileServer it:
+//   - marks content-hashed files as immutable and revalidates everything else
+//   - serves .br/.gz precompressed variants when the client accepts them
+//   - refuses directory listings, dotfiles and paths escaping fsys
+func AssetHandlerFS(fsys fs.FS) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		name, ok := assetName(r.URL.Path)
+		if !ok || !serveAsset(w, r, fsys, name) {
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// SPAHandler serves a client-side routed app built into dir (typically
+// "public"). See SPAHandlerFS.
+func SPAHandler(dir string) http.Handler {
+	return SPAHandlerFS(os.DirFS(dir))
+}
+
+// SPAHandlerFS serves static files from fsys like AssetHandlerFS and falls
+// back to index.html for any other path without a file extension, so the
+// client router can handle it. Use it instead of PreloadEngine.ServeHTML
+// when the app doesn't need server-side preloading. index.html is always
+// revalidated; missing files with an extension still 404.
+func SPAHandlerFS(fsys fs.FS) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if name, ok := assetName(r.URL.Path); ok && serveAsset(w, r, fsys, name) {
+			return
+		}
+		if path.Ext(r.URL.Path) != "" || !serveAsset(w, r, fsys, "index.html") {
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// serveAsset serves the file name from fsys, or a precompressed variant of
+// it, with caching headers. It reports false if name doesn't exist.
+func serveAsset(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string) bool {
+	info, err := fs.Stat(fsys, name)
+	if err != nil || info.IsDir() {
+		return false
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+	if immutableAsset(name) {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	accept := r.Header.Get("Accept-Encoding")
+	for _, variant := range []struct{ encoding, ext string }{{"br", ".br"}, {"gzip", ".gz"}} {
+		if !acceptsEncoding(accept, variant.encoding) {
+			continue
+		}
+		if serveAssetFile(w, r, fsys, name, name+variant.ext, variant.encoding) {
+			return true
+		}
+	}
+	return serveAssetFile(w, r, fsys, name, name, "")
+}
+
+// immutableAsset reports whether name is a content-hashed file Vite built
+// into its assets/ directory, which can be cached forever. Files copied from
+// the client's public/ directory keep their names and are revalidated.
+func immutableAsset(name string) bool {
+	return strings.HasPrefix(name, "assets/") && hashedAssetPattern.MatchString(name)
+}
+
+// assetName converts a URL path into an fs.FS name, rejecting traversal
+// attempts and hidden files (e.g. the .vite/ manifest directory). The
+// top-level .well-known/ directory is allowed, for files such as ACME
+// challenges and assetlinks.json.
+func assetName(urlPath string) (string, bool) {
+	for i, segment := range strings.Split(strings.TrimPrefix(urlPath, "/"), "/") {
+		if i == 0 && segment == ".well-known" {
+			continue
+		}
+		if segment == ".." || strings.HasPrefix(segment, ".") {
+			return "", false
+		}
+	}
+	name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	if name == "" || !fs.ValidPath(name) {
+		return "", false
+	}
+	return name, true
+}
+
+// serveAssetFile serves file under the content type of name. It reports
+// false if file can't be opened, so the caller can try another variant.
+func serveAssetFile(w http.ResponseWriter, r *http.Request, fsys fs.FS, name, file, encoding string) bool {
+	f, err := fsys.Open(file)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return false
+	}
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return false
+		}
+		content = bytes.NewReader(data)
+	}
+
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	http.ServeContent(w, r, name, info.ModTime(), content)
+	return true
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header allows encoding.
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), encoding) {
+			continue
+		}
+		q := strings.ReplaceAll(params, " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package gapp
+
+import (
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAssetHandlerCaching(t *testing.T) {
+	fsys := fstest.MapFS{
+		"assets/index-BrT0Xz1a.js": {Data: []byte("console.log(1)")},
+		"assets/logo.4f3a9c1d.svg": {Data: []byte("<svg/>")},
+		"apple-touch-icon.png":     {Data: []byte("png")},
+		"logo-dark-mode.svg":       {Data: []byte("<svg/>")},
+	}
+	handler := AssetHandlerFS(fsys)
+
+	for path, want := range map[string]string{
+		"/assets/index-BrT0Xz1a.js": "public, max-age=31536000, immutable",
+		"/assets/logo.4f3a9c1d.svg": "public, max-age=31536000, immutable",
+		"/apple-touch-icon.png":     "no-cache",
+		"/logo-dark-mode.svg":       "no-cache",
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != 200 {
+			t.Errorf("GET %s = %d, want 200", path, rec.Code)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != want {
+			t.Errorf("GET %s Cache-Control = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestAssetHandlerHiddenFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		".well-known/assetlinks.json":        {Data: []byte("[]")},
+		".well-known/acme-challenge/token":   {Data: []byte("token")},
+		".well-known/.secret":                {Data: []byte("secret")},
+		".vite/manifest.json":                {Data: []byte("{}")},
+		"assets/.well-known/assetlinks.json": {Data: []byte("[]")},
+	}
+	handler := AssetHandlerFS(fsys)
+
+	for path, want := range map[string]int{
+		"/.well-known/assetlinks.json":        200,
+		"/.well-known/acme-challenge/token":   200,
+		"/.well-known/.secret":                404,
+		"/.vite/manifest.json":                404,
+		"/assets/.well-known/assetlinks.json": 404,
+		"/.well-known/../.vite/manifest.json": 404,
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != want {
+			t.Errorf("GET %s = %d, want %d", path, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package gapp
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	embeddedMu     sync.RWMutex
+	embeddedPublic fs.FS
+)
+
+// EmbeddedAssets returns the built client assets embedded under "public/"
+// in the server binary. Pass the result to UseEmbeddedAssets or to
+// PreloadEngineConfig.AssetsFS.
+func EmbeddedAssets(fsys embed.FS) fs.FS {
+	sub, err := fs.Sub(fsys, "public")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// UseEmbeddedAssets registers embedded client assets as the default for
+// PublicFS and NewPreloadEngine. It is called from the init function of the
+// file generated by `gapp build --embed`.
+func UseEmbeddedAssets(fsys fs.FS) {
+	embeddedMu.Lock()
+	defer embeddedMu.Unlock()
+	embeddedPublic = fsys
+}
+
+// PublicFS returns the client assets: the embedded ones registered with
+// UseEmbeddedAssets, or the public/ directory on disk.
+func PublicFS() fs.FS {
+	embeddedMu.RLock()
+	defer embeddedMu.RUnlock()
+	if embeddedPublic != nil {
+		return embeddedPublic
+	}
+	return os.DirFS("public")
+}
+
+func registeredEmbeddedAssets() fs.FS {
+	embeddedMu.RLock()
+	defer embeddedMu.RUnlock()
+	return embeddedPublic
+}
+
+// defaultAssets are used when no Vite manifest is available.
+func defaultAssets() Assets {
+	return Assets{
+		JS:      "/assets/index.js",
+		CSS:     "/assets/index.css",
+		Scripts: []string{"/assets/index.js"},
+		Styles:  []string{"/assets/index.css"},
+	}
+}
+
+// LoadViteManifest reads and parses a Vite build manifest.
+func LoadViteManifest(manifestPath string) (ViteManifest, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	return parseViteManifest(data)
+}
+
+// LoadViteManifestFS reads and parses a Vite build manifest from fsys.
+func LoadViteManifestFS(fsys fs.FS, manifestPath string) (ViteManifest, error) {
+	data, err := fs.ReadFile(fsys, manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	return parseViteManifest(data)
+}
+
+func parseViteManifest(data []byte) (ViteManifest, error) {
+	var manifest ViteManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Resolve collects the assets for the given manifest keys (entries or
+// dynamic-import chunks), following their static imports so code-split CSS
+// is included. The first key and any other entries become scripts; chunks
+// are only preloaded. Unknown keys are skipped.
+func (m ViteManifest) Resolve(keys ...string) Assets {
+	var assets Assets
+	seen := make(map[string]bool)
+	seenFiles := make(map[string]bool)
+
+	addFile := func(list *[]string, file string) {
+		file = "/" + file
+		if !seenFiles[file] {
+			seenFiles[file] = true
+			*list = append(*list, file)
+		}
+	}
+
+	var visit func(key string, script bool)
+	visit = func(key string, script bool) {
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+
+		entry, ok := m[key]
+		if !ok {
+			return
+		}
+		if script {
+			addFile(&assets.Scripts, entry.File)
+		} else {
+			addFile(&assets.Preloads, entry.File)
+		}
+		for _, css := range entry.CSS {
+			addFile(&assets.Styles, css)
+		}
+		for _, imp := range entry.Imports {
+			visit(imp, false)
+		}
+	}
+
+	for i, key := range keys {
+		visit(key, i == 0 || m[key].IsEntry)
+	}
+
+	if len(assets.Scripts) > 0 {
+		assets.JS = assets.Scripts[0]
+	}
+	if len(assets.Styles) > 0 {
+		assets.CSS = assets.Styles[0]
+	}
+	return assets
+}
+
+// loadEntryAssets resolves the assets of a single manifest entry, falling
+// back to the default asset paths when the manifest or entry is missing.
+// The manifest is read from fsys when it's non-nil.
+func loadEntryAssets(logger *slog.Logger, fsys fs.FS, manifestPath, entry string) (Assets, ViteManifest) {
+	var manifest ViteManifest
+	var err error
+	if fsys != nil {
+		manifest, err = LoadViteManifestFS(fsys, manifestPath)
+	} else {
+		manifest, err = LoadViteManifest(manifestPath)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		logger.Info("Vite manifest not found, using default assets", "error", err)
+		return defaultAssets(), nil
+	}
+	if err != nil {
+		logger.Error("Failed to parse Vite manifest", "error", err)
+		return defaultAssets(), nil
+	}
+
+	if _, ok := manifest[entry]; !ok {
+		logger.Warn("Vite manifest has no entry, using default assets", "entry", entry)
+		return defaultAssets(), manifest
+	}
+
+	assets := manifest.Resolve(entry)
+	logger.Info("Loaded assets from Vite manifest", "js", assets.JS, "css", assets.CSS)
+	return assets, manifest
+}
+
+// ReloadAssets re-reads the Vite manifest and swaps in the resolved assets.
+// Call it after deploying new hashed bundles behind a running server.
+func (p *PreloadEngine) ReloadAssets() Assets {
+	var modTime time.Time
+	if info, err := p.statManifest(); err == nil {
+		modTime = info.ModTime()
+	}
+	assets, manifest := loadEntryAssets(p.log(), p.assetsFS, p.manifestPath, p.entry)
+
+	p.assetsMu.Lock()
+	p.assets = assets
+	p.manifest = manifest
+	p.manifestModTime = modTime
+	p.manifestCheckedAt = time.Now()
+	p.assetsMu.Unlock()
+
+	return assets
+}
+
+// assetsFor returns the assets for a route: its own entry and chunks when it
+// declares them, the engine's default entry otherwise.
+func (p *PreloadEngine) assetsFor(route *RouteSpec) Assets {
+	assets := p.currentAssets()
+	if route == nil || (route.Entry == "" && len(route.Chunks) == 0) {
+		return assets
+	}
+
+	p.assetsMu.RLock()
+	manifest := p.manifest
+	p.assetsMu.RUnlock()
+	if manifest == nil {
+		return assets
+	}
+
+	entry := route.Entry
+	if entry == "" {
+		entry = p.entry
+	}
+	return manifest.Resolve(append([]string{entry}, route.Chunks...)...)
+}
+
+// currentAssets returns the resolved assets, reloading the manifest when its
+// mtime changed. The manifest is stat'ed at most once per check interval.
+func (p *PreloadEngine) currentAssets() Assets {
+	p.assetsMu.RLock()
+	assets := p.assets
+	due := p.manifestCheckInterval >= 0 && time.Since(p.manifestCheckedAt) >= p.manifestCheckInterval
+	modTime := p.manifestModTime
+	p.assetsMu.RUnlock()
+
+	if !due {
+		return assets
+	}
+
+	info, err := p.statManifest()
+
+	p.assetsMu.Lock()
+	p.manifestCheckedAt = time.Now()
+	p.assetsMu.Unlock()
+
+	if err != nil || info.ModTime().Equal(modTime) {
+		return assets
+	}
+
+	p.log().Info("Vite manifest changed, reloading assets", "path", p.manifestPath)
+	return p.ReloadAssets()
+}
+
+func (p *PreloadEngine) statManifest() (fs.FileInfo, error) {
+	if p.assetsFS != nil {
+		return fs.Stat(p.assetsFS, p.manifestPath)
+	}
+	return os.Stat(p.manifestPath)
+}
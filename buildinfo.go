@@ -0,0 +1,94 @@
+package gapp
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Stamped by `gapp build` with -ldflags "-X github.com/germtb/gapp.buildCommit=...".
+var (
+	buildVersion string
+	buildCommit  string
+	buildTime    string
+)
+
+// BuildMetadata identifies the source a binary was built from.
+type BuildMetadata struct {
+	Version string `json:"version,omitempty"` // git tag, e.g. "v1.4.0" or "v1.4.0-3-gabc1234-dirty"
+	Commit  string `json:"commit,omitempty"`
+	Time    string `json:"time,omitempty"` // RFC 3339
+}
+
+// IsZero reports whether nothing is known about the build.
+func (b BuildMetadata) IsZero() bool {
+	return b == BuildMetadata{}
+}
+
+// BuildInfo is the running binary's build metadata. `gapp build` stamps it
+// from git; plain `go build` binaries fall back to the VCS information the
+// Go toolchain records. It's reported by /health, embedded in the rendered
+// HTML and served by the GetBuildInfo RPC when registered.
+var BuildInfo = readBuildInfo()
+
+func readBuildInfo() BuildMetadata {
+	info := BuildMetadata{Version: buildVersion, Commit: buildCommit, Time: buildTime}
+	if info.Commit != "" {
+		return info
+	}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	modified := false
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Commit = setting.Value
+		case "vcs.time":
+			if info.Time == "" {
+				info.Time = setting.Value
+			}
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if modified && info.Commit != "" {
+		info.Commit += "-dirty"
+	}
+	return info
+}
+
+// RegisterBuildInfo registers a GetBuildInfo RPC returning BuildInfo. Its
+// response is encoded as
+//
+//	message BuildInfo {
+//	  string version = 1;
+//	  string commit = 2;
+//	  string time = 3;
+//	}
+//
+// so declaring `rpc GetBuildInfo(google.protobuf.Empty) returns (BuildInfo)`
+// in the service gives clients a typed method for it.
+func (d *Dispatcher) RegisterBuildInfo() {
+	d.Unary["GetBuildInfo"] = func(w http.ResponseWriter, r *http.Request, method string, body []byte) ([]byte, error) {
+		return BuildInfo.marshal(), nil
+	}
+}
+
+func (b BuildMetadata) marshal() []byte {
+	var out []byte
+	for i, value := range []string{b.Version, b.Commit, b.Time} {
+		if value == "" {
+			continue
+		}
+		out = protowire.AppendTag(out, protowire.Number(i+1), protowire.BytesType)
+		out = protowire.AppendString(out, value)
+	}
+	if out == nil {
+		// A nil response means the handler wrote it itself
+		out = []byte{}
+	}
+	return out
+}
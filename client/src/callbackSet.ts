@@ -21,5 +21,9 @@ export function createCallbackSet<T>() {
     set.clear();
   }
 
-  return { add, call, clear };
+  function size() {
+    return set.size;
+  }
+
+  return { add, call, clear, size };
 }
@@ -0,0 +1,170 @@
+import { createCallbackSet } from "./callbackSet";
+import { RpcError } from "./rpcError";
+
+export type HubClientConfig = {
+  // WebSocket URL of the server's Hub, e.g. "/ws". Relative URLs resolve
+  // against the page, switching http(s) to ws(s).
+  url: string | (() => string);
+  // Reconnect delay bounds in ms, doubling between attempts. Default 500 / 30000.
+  minReconnectDelay?: number;
+  maxReconnectDelay?: number;
+  // Called when a subscription is rejected by the server's Authorize check.
+  onError?: (topic: string, error: RpcError) => void;
+};
+
+export interface HubClient {
+  /**
+   * Subscribes to a topic, decoding each published message with `decode`.
+   * Returns a function that unsubscribes.
+   */
+  subscribe<T>(
+    topic: string,
+    decode: (bytes: Uint8Array) => T,
+    onMessage: (message: T) => void
+  ): () => void;
+  /**
+   * Called with the RPC methods the server invalidated with gapp.Invalidate.
+   * Keeps the connection open even without subscriptions.
+   */
+  onInvalidate(callback: (methods: string[]) => void): () => void;
+  /** Called with true when connected and false when the connection drops. */
+  onConnectionChange(callback: (connected: boolean) => void): () => void;
+  close(): void;
+}
+
+type HubControl = {
+  op: string;
+  topic?: string;
+  code?: string;
+  message?: string;
+  methods?: string[];
+};
+
+function resolveUrl(url: string): string {
+  const resolved = new URL(url, window.location.href);
+  if (resolved.protocol === "http:") resolved.protocol = "ws:";
+  if (resolved.protocol === "https:") resolved.protocol = "wss:";
+  return resolved.toString();
+}
+
+/**
+ * Connects to a gapp Hub. The connection is opened lazily on the first
+ * subscription, reconnects with backoff when dropped, and resubscribes to
+ * every active topic. Messages published while disconnected are lost, so
+ * refetch on reconnect if that matters.
+ */
+export function createHubClient(config: HubClientConfig): HubClient {
+  const getUrl = typeof config.url === "function" ? config.url : () => config.url as string;
+  const minDelay = config.minReconnectDelay ?? 500;
+  const maxDelay = config.maxReconnectDelay ?? 30000;
+
+  const topics = new Map<string, ReturnType<typeof createCallbackSet<Uint8Array>>>();
+  const invalidations = createCallbackSet<string[]>();
+  const connection = createCallbackSet<boolean>();
+  const decoder = new TextDecoder();
+
+  let socket: WebSocket | null = null;
+  let closed = false;
+  let delay = minDelay;
+  let reconnectTimer: ReturnType<typeof setTimeout> | null = null;
+
+  function send(control: HubControl) {
+    if (socket?.readyState === WebSocket.OPEN) {
+      socket.send(JSON.stringify(control));
+    }
+  }
+
+  function wanted() {
+    return topics.size > 0 || invalidations.size() > 0;
+  }
+
+  function connect() {
+    if (socket || closed) return;
+
+    const ws = new WebSocket(resolveUrl(getUrl()));
+    ws.binaryType = "arraybuffer";
+    socket = ws;
+
+    ws.onopen = () => {
+      delay = minDelay;
+      for (const topic of topics.keys()) {
+        send({ op: "subscribe", topic });
+      }
+      connection.call(true);
+    };
+
+    ws.onmessage = (event) => {
+      if (typeof event.data === "string") {
+        const control = JSON.parse(event.data) as HubControl;
+        if (control.op === "invalidate" && control.methods) {
+          invalidations.call(control.methods);
+        } else if (control.op === "error" && control.topic) {
+          config.onError?.(
+            control.topic,
+            new RpcError(control.code ?? "UNKNOWN", control.message ?? "", 0)
+          );
+        }
+        return;
+      }
+
+      // 2-byte big-endian topic length, the topic, then the message
+      const frame = new Uint8Array(event.data as ArrayBuffer);
+      if (frame.length < 2) return;
+      const topicLength = (frame[0]! << 8) | frame[1]!;
+      const topic = decoder.decode(frame.subarray(2, 2 + topicLength));
+      topics.get(topic)?.call(frame.subarray(2 + topicLength));
+    };
+
+    ws.onclose = () => {
+      socket = null;
+      connection.call(false);
+      if (closed || !wanted()) return;
+      reconnectTimer = setTimeout(() => {
+        reconnectTimer = null;
+        connect();
+      }, delay);
+      delay = Math.min(delay * 2, maxDelay);
+    };
+  }
+
+  return {
+    subscribe(topic, decode, onMessage) {
+      let callbacks = topics.get(topic);
+      if (!callbacks) {
+        callbacks = createCallbackSet<Uint8Array>();
+        topics.set(topic, callbacks);
+        send({ op: "subscribe", topic });
+      }
+      const remove = callbacks.add((bytes) => onMessage(decode(bytes)));
+      connect();
+
+      return () => {
+        remove();
+        const current = topics.get(topic);
+        if (current === callbacks && callbacks.size() === 0) {
+          topics.delete(topic);
+          send({ op: "unsubscribe", topic });
+        }
+      };
+    },
+
+    onInvalidate(callback) {
+      const remove = invalidations.add(callback);
+      connect();
+      return remove;
+    },
+
+    onConnectionChange(callback) {
+      return connection.add(callback);
+    },
+
+    close() {
+      closed = true;
+      if (reconnectTimer) clearTimeout(reconnectTimer);
+      socket?.close();
+      socket = null;
+      topics.clear();
+      invalidations.clear();
+    },
+  };
+}
@@ -22,7 +22,18 @@ export {
   createRpcTransport,
   type RpcTransportConfig,
   type RpcTransport,
+  type UploadOptions,
+  type StreamDecompressor,
 } from "./rpcTransport";
+export {
+  createHubClient,
+  type HubClient,
+  type HubClientConfig,
+} from "./hub";
+export {
+  createInvalidationSync,
+  type InvalidationSyncConfig,
+} from "./invalidation";
 export {
   RpcError,
   RpcErrorCode,
@@ -30,6 +41,10 @@ export {
 } from "./rpcError";
 export {
   decodeAllPreloaded,
+  checkHydrationVersion,
+  checkForNewBuild,
+  type AppVersion,
+  type BuildInfo,
   type PreloadedData,
   type RpcDeclaration,
   type DecoderMap,
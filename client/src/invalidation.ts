@@ -0,0 +1,48 @@
+import type { HubClient } from "./hub";
+import type { StoreRegistry } from "./registry";
+
+export type InvalidationSyncConfig = {
+  hub: HubClient;
+  registry: StoreRegistry;
+  // The RPC client wrapped with createRpcProxy, so refetched results are
+  // dispatched to the registry's stores.
+  rpc: object;
+};
+
+/**
+ * Refetches RPC methods when the server calls gapp.Invalidate for them,
+ * repeating the latest request made for each method so the stores that
+ * reduce its results update. After a reconnect every method is refetched,
+ * since invalidations sent while disconnected are lost.
+ * Returns a function that stops syncing.
+ */
+export function createInvalidationSync(config: InvalidationSyncConfig): () => void {
+  const { hub, registry, rpc } = config;
+
+  function refetch(methods: string[]) {
+    for (const method of methods) {
+      const last = registry.lastRequest(method);
+      const call = (rpc as Record<string, unknown>)[method];
+      if (!last || typeof call !== "function") continue;
+      // Errors are dispatched to the stores by the proxy
+      (call as (request: unknown) => Promise<unknown>)
+        .call(rpc, last.request)
+        .catch(() => {});
+    }
+  }
+
+  let connectedBefore = false;
+  const removeConnection = hub.onConnectionChange((connected) => {
+    if (!connected) return;
+    if (connectedBefore) {
+      refetch(registry.calledMethods());
+    }
+    connectedBefore = true;
+  });
+  const removeInvalidate = hub.onInvalidate(refetch);
+
+  return () => {
+    removeConnection();
+    removeInvalidate();
+  };
+}
@@ -6,10 +6,25 @@ export type PreloadedData = {
   };
 };
 
+// Source the server binary was built from, stamped by `gapp build`
+export type BuildInfo = {
+  version?: string;
+  commit?: string;
+  time?: string;
+};
+
+// Schema and build identifiers embedded by the server next to the preloaded data
+export type AppVersion = {
+  schemaHash: string;
+  buildId: string;
+  build?: BuildInfo;
+};
+
 declare global {
   interface Window {
     __PRELOADED__?: PreloadedData;
     __PRELOAD_TIMESTAMP__?: number;
+    __GAPP_VERSION__?: AppVersion;
   }
 }
 
@@ -65,6 +80,69 @@ async function decompressGzip(compressed: Uint8Array): Promise<Uint8Array> {
   return result;
 }
 
+const RELOAD_KEY = "__gapp_version_reload__";
+
+/**
+ * Compare the schema hash this bundle was generated with against the one the
+ * server embedded in the page. On mismatch the bundle is stale (e.g. cached
+ * across a deploy), so the page is reloaded once per server hash; if the
+ * mismatch persists after reloading, onMismatch is called instead.
+ * Returns true when the versions match or the server didn't embed one.
+ */
+export function checkHydrationVersion(
+  schemaHash: string,
+  onMismatch?: (server: AppVersion) => void
+): boolean {
+  const server = window.__GAPP_VERSION__;
+  if (!server || !server.schemaHash || server.schemaHash === schemaHash) {
+    return true;
+  }
+
+  console.warn(
+    `[Preload] Schema mismatch: bundle ${schemaHash}, server ${server.schemaHash}`
+  );
+
+  let reloaded: string | null = null;
+  try {
+    reloaded = sessionStorage.getItem(RELOAD_KEY);
+    sessionStorage.setItem(RELOAD_KEY, server.schemaHash);
+  } catch {
+    // sessionStorage unavailable, fall through to onMismatch
+    reloaded = server.schemaHash;
+  }
+
+  if (onMismatch) {
+    onMismatch(server);
+  } else if (reloaded !== server.schemaHash) {
+    window.location.reload();
+  }
+  return false;
+}
+
+/**
+ * Ask the server which build it is running, e.g. periodically to offer a
+ * reload after a deploy. Resolves to the server's build when it differs from
+ * the one that rendered this page, and null otherwise or if unknown.
+ */
+export async function checkForNewBuild(
+  healthUrl = "/health"
+): Promise<BuildInfo | null> {
+  const current = window.__GAPP_VERSION__?.build;
+  if (!current?.commit) {
+    return null;
+  }
+  try {
+    const response = await fetch(healthUrl, { cache: "no-store" });
+    const report = (await response.json()) as { build?: BuildInfo };
+    if (report.build?.commit && report.build.commit !== current.commit) {
+      return report.build;
+    }
+  } catch {
+    // Server unreachable, e.g. mid-deploy; try again later
+  }
+  return null;
+}
+
 /**
  * Decode and dispatch all preloaded RPCs.
  * Returns array of { method, request, response } for dispatching to stores.
@@ -72,7 +150,8 @@ async function decompressGzip(compressed: Uint8Array): Promise<Uint8Array> {
  */
 export async function decodeAllPreloaded(
   requestDecoders: DecoderMap,
-  responseDecoders: DecoderMap
+  responseDecoders: DecoderMap,
+  options?: { schemaHash?: string }
 ): Promise<
   Array<{
     method: string;
@@ -85,6 +164,12 @@ export async function decodeAllPreloaded(
     return [];
   }
 
+  // Preloaded bytes from a different schema can't be decoded safely
+  if (options?.schemaHash && !checkHydrationVersion(options.schemaHash)) {
+    delete window.__PRELOADED__;
+    return [];
+  }
+
   const results: Array<{
     method: string;
     request: unknown;
@@ -3,6 +3,8 @@ import { ok } from "./result";
 
 export class StoreRegistry {
   private stores = new Set<Store<any>>();
+  private lastRequests = new Map<string, unknown>();
+  private preloadedRpcs = new Map<string, DecodedRpc>();
 
   register<S extends Store<any>>(store: S): S {
     this.stores.add(store);
@@ -10,6 +12,10 @@ export class StoreRegistry {
   }
 
   dispatchRpc(event: unknown): void {
+    const { method, request } = event as { method?: unknown; request?: unknown };
+    if (typeof method === "string") {
+      this.lastRequests.set(method, request);
+    }
     for (const store of this.stores) {
       const current = store.getState();
       const next = store.reduceRpc(current, event);
@@ -25,8 +31,27 @@ export class StoreRegistry {
     }
   }
 
+  // The request of the latest call to each method, used to refetch it when
+  // the server invalidates it.
+  lastRequest(method: string): { request: unknown } | undefined {
+    return this.lastRequests.has(method)
+      ? { request: this.lastRequests.get(method) }
+      : undefined;
+  }
+
+  calledMethods(): string[] {
+    return Array.from(this.lastRequests.keys());
+  }
+
+  // The RPC the server preloaded for method, which query hooks generated by
+  // `gapp codegen --hooks` start from.
+  preloaded(method: string): DecodedRpc | undefined {
+    return this.preloadedRpcs.get(method);
+  }
+
   hydrate(decoded: DecodedRpc[]): void {
     for (const event of decoded) {
+      this.preloadedRpcs.set(event.method, event);
       this.dispatchRpc({
         method: event.method,
         request: event.request,
@@ -5,6 +5,8 @@ export const RpcErrorCode = {
   UNAUTHENTICATED: "UNAUTHENTICATED",
   PERMISSION_DENIED: "PERMISSION_DENIED",
   RATE_LIMITED: "RATE_LIMITED",
+  PAYLOAD_TOO_LARGE: "PAYLOAD_TOO_LARGE",
+  DEADLINE_EXCEEDED: "DEADLINE_EXCEEDED",
   INTERNAL: "INTERNAL",
 } as const;
 
@@ -57,3 +59,16 @@ export async function parseRpcError(
     res.status
   );
 }
+
+/**
+ * Decodes the error trailer that ends a stream whose handler failed after
+ * sending messages. The response was already a 200, so httpStatus is 0.
+ */
+export function parseStreamTrailer(data: Uint8Array): RpcError {
+  try {
+    const body = JSON.parse(new TextDecoder().decode(data));
+    return new RpcError(body.code ?? "UNKNOWN", body.message ?? "", 0, body.details);
+  } catch {
+    return new RpcError("UNKNOWN", "Invalid stream error trailer", 0);
+  }
+}
@@ -1,11 +1,38 @@
 import { Observable } from "rxjs";
-import { parseRpcError } from "./rpcError";
+import { RpcError, parseRpcError, parseStreamTrailer } from "./rpcError";
+
+export type UploadOptions = {
+  // File name reported to the server, defaults to the File's name
+  filename?: string;
+  // Proto-encoded request message sent alongside the file
+  request?: Uint8Array;
+};
+
+export type StreamDecompressor = (data: Uint8Array) => Promise<Uint8Array>;
 
 export type RpcTransportConfig = {
   url: string | (() => string);
   credentials?: RequestCredentials; // default: "include"
+  // Compressions accepted for server streams, in order of preference. Large
+  // messages are compressed individually by the server. Default: gzip where
+  // the browser supports DecompressionStream. Pass {} to disable.
+  streamDecompressors?: Record<string, StreamDecompressor>;
+  // Reconnect attempts for a dropped server stream that sent a resume
+  // token, resuming from the last token. Default: 3.
+  maxStreamRetries?: number;
 };
 
+async function gunzip(data: Uint8Array): Promise<Uint8Array> {
+  const stream = new Blob([data as unknown as BlobPart])
+    .stream()
+    .pipeThrough(new DecompressionStream("gzip"));
+  return new Uint8Array(await new Response(stream).arrayBuffer());
+}
+
+function defaultDecompressors(): Record<string, StreamDecompressor> {
+  return typeof DecompressionStream === "undefined" ? {} : { gzip: gunzip };
+}
+
 /**
  * The Rpc interface expected by ts-proto generated clients.
  */
@@ -30,11 +57,36 @@ export interface RpcTransport {
     method: string,
     data: Observable<Uint8Array>
   ): Observable<Uint8Array>;
+  /**
+   * Sends a file to an upload RPC (registered in Dispatcher.Uploads) as the
+   * raw request body, so large files are never buffered as a proto field.
+   */
+  upload(method: string, file: Blob, options?: UploadOptions): Promise<Uint8Array>;
+  /**
+   * Returns a GET URL for a download RPC (registered in Dispatcher.Downloads),
+   * for use as a link's href so the browser streams the file to disk.
+   */
+  downloadUrl(method: string, request?: Uint8Array): string;
+}
+
+function bytesToBase64Url(bytes: Uint8Array): string {
+  return bytesToBase64(bytes).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+function bytesToBase64(bytes: Uint8Array): string {
+  let binary = "";
+  for (let i = 0; i < bytes.length; i++) {
+    binary += String.fromCharCode(bytes[i]!);
+  }
+  return btoa(binary);
 }
 
 export function createRpcTransport(config: RpcTransportConfig): RpcTransport {
   const getUrl = typeof config.url === "function" ? config.url : () => config.url as string;
   const credentials = config.credentials ?? "include";
+  const decompressors = config.streamDecompressors ?? defaultDecompressors();
+  const acceptCompression = Object.keys(decompressors).join(", ");
+  const maxStreamRetries = config.maxStreamRetries ?? 3;
 
   return {
     request(_service, method, data) {
@@ -120,29 +172,66 @@ export function createRpcTransport(config: RpcTransportConfig): RpcTransport {
     ): Observable<Uint8Array> {
       return new Observable<Uint8Array>((subscriber) => {
         let aborted = false;
+        // Last resume token from the server, sent back on reconnect so the
+        // stream continues instead of restarting
+        let resumeToken: string | null = null;
+        let retries = 0;
+
+        function fail(error: unknown) {
+          if (aborted) return;
+          // Streams with a resume token survive dropped connections, but
+          // not errors the server reported
+          if (resumeToken !== null && !(error instanceof RpcError) && retries < maxStreamRetries) {
+            const delay = 500 * 2 ** retries;
+            retries++;
+            setTimeout(connect, delay);
+            return;
+          }
+          subscriber.error(error);
+        }
 
-        fetch(getUrl(), {
-          method: "POST",
-          headers: {
+        function connect() {
+          if (aborted) return;
+
+          const headers: Record<string, string> = {
             "Content-Type": "application/x-protobuf",
             "X-Rpc-Method": method,
-          },
-          credentials,
-          body: data as unknown as BodyInit,
-        })
-          .then(async (response) => {
-            if (!response.ok) {
-              throw await parseRpcError(response);
-            }
+          };
+          if (acceptCompression) {
+            headers["X-Stream-Accept-Compression"] = acceptCompression;
+          }
+          if (resumeToken !== null) {
+            headers["X-Rpc-Resume-Token"] = resumeToken;
+          }
 
-            const reader = response.body?.getReader();
-            if (!reader) {
-              throw new Error("No response body");
-            }
+          fetch(getUrl(), {
+            method: "POST",
+            headers,
+            credentials,
+            body: data as unknown as BodyInit,
+          })
+            .then(async (response) => {
+              if (!response.ok) {
+                throw await parseRpcError(response);
+              }
+
+              // Messages of a compressed stream start with a flag byte:
+              // 0 for raw, 1 for compressed
+              const compression = response.headers.get("X-Stream-Compression");
+              const decompress = compression ? decompressors[compression] : undefined;
+              if (compression && !decompress) {
+                throw new Error(`Unsupported stream compression: ${compression}`);
+              }
+
+              const reader = response.body?.getReader();
+              if (!reader) {
+                throw new Error("No response body");
+              }
 
-            let buffer = new Uint8Array(0);
+              // Zero-length frames are keepalives when the server sends heartbeats
+              const skipEmpty = response.headers.has("X-Stream-Heartbeat");
+              let buffer = new Uint8Array(0);
 
-            try {
               while (!aborted) {
                 const { done, value } = await reader.read();
 
@@ -158,12 +247,18 @@ export function createRpcTransport(config: RpcTransportConfig): RpcTransport {
 
                 // Parse complete messages from buffer
                 while (buffer.length >= 4) {
-                  // Read 4-byte length prefix (big endian)
-                  const length =
-                    (buffer[0]! << 24) |
-                    (buffer[1]! << 16) |
-                    (buffer[2]! << 8) |
-                    buffer[3]!;
+                  // Read 4-byte length prefix (big endian). Its high bit marks
+                  // the error trailer that ends a failed stream, and the next
+                  // bit a resume token for the messages before it.
+                  const prefix =
+                    ((buffer[0]! << 24) |
+                      (buffer[1]! << 16) |
+                      (buffer[2]! << 8) |
+                      buffer[3]!) >>>
+                    0;
+                  const trailer = (prefix & 0x80000000) !== 0;
+                  const token = (prefix & 0x40000000) !== 0;
+                  const length = prefix & 0x3fffffff;
 
                   // Check if we have the complete message
                   if (buffer.length < 4 + length) {
@@ -176,23 +271,37 @@ export function createRpcTransport(config: RpcTransportConfig): RpcTransport {
                   // Remove processed data from buffer
                   buffer = buffer.slice(4 + length);
 
+                  if (trailer) {
+                    aborted = true;
+                    reader.cancel();
+                    subscriber.error(parseStreamTrailer(message));
+                    return;
+                  }
+                  if (token) {
+                    resumeToken = new TextDecoder().decode(message);
+                    retries = 0;
+                    continue;
+                  }
+
                   // Emit the message
+                  if (skipEmpty && length === 0) continue;
+                  if (decompress) {
+                    const payload = message.subarray(1);
+                    subscriber.next(message[0] === 1 ? await decompress(payload) : payload);
+                    continue;
+                  }
                   subscriber.next(message);
                 }
               }
 
-              subscriber.complete();
-            } catch (error) {
               if (!aborted) {
-                subscriber.error(error);
+                subscriber.complete();
               }
-            }
-          })
-          .catch((error) => {
-            if (!aborted) {
-              subscriber.error(error);
-            }
-          });
+            })
+            .catch(fail);
+        }
+
+        connect();
 
         // Cleanup function
         return () => {
@@ -208,5 +317,42 @@ export function createRpcTransport(config: RpcTransportConfig): RpcTransport {
     ): Observable<Uint8Array> {
       throw new Error("Bidirectional streaming not implemented");
     },
+
+    upload(method: string, file: Blob, options: UploadOptions = {}): Promise<Uint8Array> {
+      const headers: Record<string, string> = {
+        "Content-Type": file.type || "application/octet-stream",
+        "X-Rpc-Method": method,
+      };
+      const filename = options.filename ?? (file instanceof File ? file.name : "");
+      if (filename) {
+        headers["X-Upload-Filename"] = encodeURIComponent(filename);
+      }
+      if (options.request) {
+        headers["X-Rpc-Request"] = bytesToBase64(options.request);
+      }
+
+      return fetch(getUrl(), {
+        method: "POST",
+        headers,
+        credentials,
+        body: file,
+      })
+        .then(async (res) => {
+          if (!res.ok) {
+            throw await parseRpcError(res);
+          }
+          return res.arrayBuffer();
+        })
+        .then((buffer) => new Uint8Array(buffer));
+    },
+
+    downloadUrl(method: string, request?: Uint8Array): string {
+      const url = new URL(getUrl(), window.location.href);
+      url.searchParams.set("method", method);
+      if (request && request.length > 0) {
+        url.searchParams.set("request", bytesToBase64Url(request));
+      }
+      return url.toString();
+    },
   };
 }
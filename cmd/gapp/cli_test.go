@@ -1,11 +1,12 @@
 package main
 
 import (
+	"encoding/json"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/germtb/gapp/cmd/gapp/internal/codegen"
 	"github.com/germtb/gapp/cmd/gapp/scaffold"
@@ -45,6 +46,7 @@ func TestInitGeneratesReactProject(t *testing.T) {
 		"client/src/preload.ts",
 		"client/src/stores/ItemStore.ts",
 		"client/src/routes/HomeRoute.tsx",
+		"client/src/components/ItemFeed.tsx",
 	}
 
 	for _, f := range expectedFiles {
@@ -95,6 +97,12 @@ func TestInitGeneratesReactProject(t *testing.T) {
 	if !strings.Contains(string(mainContent), `pb "testapp/server/generated"`) {
 		t.Error("server/main.go does not contain correct import path")
 	}
+	if !strings.Contains(string(mainContent), `dispatcher.Streaming["WatchItems"]`) {
+		t.Error("server/main.go should register the WatchItems stream")
+	}
+	if !strings.Contains(string(protoContent), "rpc WatchItems(WatchItemsRequest) returns (stream Item);") {
+		t.Error("Proto file should declare the WatchItems stream")
+	}
 
 	// Verify react deps in package.json
 	pkgContent, err := os.ReadFile(filepath.Join(projectDir, "client/package.json"))
@@ -201,6 +209,408 @@ func TestInitGeneratesVanillaProject(t *testing.T) {
 	}
 }
 
+func TestInitGeneratesOtherFrameworkProjects(t *testing.T) {
+	tests := []struct {
+		framework scaffold.Framework
+		files     []string
+	}{
+		{scaffold.FrameworkSvelte, []string{"client/src/main.ts", "client/src/App.svelte", "client/src/pages/HomePage.svelte", "client/svelte.config.js"}},
+		{scaffold.FrameworkVue, []string{"client/src/main.ts", "client/src/App.vue", "client/src/pages/HomePage.vue"}},
+		{scaffold.FrameworkSolid, []string{"client/src/main.tsx", "client/src/routes/HomeRoute.tsx"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.framework), func(t *testing.T) {
+			projectDir := filepath.Join(t.TempDir(), "testapp")
+			config := scaffold.ProjectConfig{
+				Name:      "testapp",
+				Module:    "testapp",
+				Framework: tt.framework,
+			}
+			if _, err := scaffold.Generate(config, projectDir); err != nil {
+				t.Fatalf("Generate failed: %v", err)
+			}
+
+			for _, f := range append(tt.files, "client/src/lib/gapp.ts", "client/src/stores/ItemStore.ts", "server/main.go") {
+				if _, err := os.Stat(filepath.Join(projectDir, f)); os.IsNotExist(err) {
+					t.Errorf("Expected file not found: %s", f)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(projectDir, scaffold.MainFile(tt.framework))); err != nil {
+				t.Errorf("MainFile: %v", err)
+			}
+
+			detected, err := scaffold.DetectConfig(projectDir)
+			if err != nil {
+				t.Fatalf("DetectConfig failed: %v", err)
+			}
+			if detected.Framework != tt.framework {
+				t.Errorf("DetectConfig framework = %s, want %s", detected.Framework, tt.framework)
+			}
+		})
+	}
+}
+
+func TestInitGeneratesAuthProject(t *testing.T) {
+	projectDir := filepath.Join(t.TempDir(), "testapp")
+	config := scaffold.ProjectConfig{
+		Name:      "testapp",
+		Module:    "testapp",
+		Framework: scaffold.FrameworkReact,
+		Auth:      true,
+	}
+	if _, err := scaffold.Generate(config, projectDir); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	for _, f := range []string{"client/src/pages/LoginPage.tsx", "client/src/stores/AuthStore.ts"} {
+		if _, err := os.Stat(filepath.Join(projectDir, f)); os.IsNotExist(err) {
+			t.Errorf("Expected file not found: %s", f)
+		}
+	}
+	for f, want := range map[string][]string{
+		"server/main.go":      {"siauth.InitWithRoot", "gapp.AuthMiddleware", "gapp.RequireAuth", `"/rpc/auth"`},
+		"server/go.mod":       {"github.com/germtb/siauth"},
+		"client/src/rpc.ts":   {"AuthClientImpl", "export const authRpc"},
+		"client/package.json": {`"siauth-ts"`},
+	} {
+		content, err := os.ReadFile(filepath.Join(projectDir, f))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", f, err)
+		}
+		for _, s := range want {
+			if !strings.Contains(string(content), s) {
+				t.Errorf("%s should contain %s", f, s)
+			}
+		}
+	}
+
+	detected, err := scaffold.DetectConfig(projectDir)
+	if err != nil {
+		t.Fatalf("DetectConfig failed: %v", err)
+	}
+	if !detected.Auth {
+		t.Error("DetectConfig should detect auth from siauth-ts")
+	}
+}
+
+func TestInitGeneratesTests(t *testing.T) {
+	tests := []struct {
+		framework scaffold.Framework
+		test      string
+		library   string
+	}{
+		{scaffold.FrameworkReact, "client/src/routes/HomeRoute.test.tsx", "@testing-library/react"},
+		{scaffold.FrameworkVanilla, "client/src/routes/HomeRoute.test.ts", ""},
+		{scaffold.FrameworkSvelte, "client/src/pages/HomePage.test.ts", "@testing-library/svelte"},
+		{scaffold.FrameworkVue, "client/src/pages/HomePage.test.ts", "@testing-library/vue"},
+		{scaffold.FrameworkSolid, "client/src/routes/HomeRoute.test.tsx", "@solidjs/testing-library"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.framework), func(t *testing.T) {
+			projectDir := filepath.Join(t.TempDir(), "testapp")
+			config := scaffold.ProjectConfig{
+				Name:      "testapp",
+				Module:    "testapp",
+				Framework: tt.framework,
+				Auth:      true,
+			}
+			if _, err := scaffold.Generate(config, projectDir); err != nil {
+				t.Fatalf("Generate failed: %v", err)
+			}
+
+			for _, f := range []string{tt.test, "client/src/stores/ItemStore.test.ts", "client/vitest.config.ts"} {
+				if _, err := os.Stat(filepath.Join(projectDir, f)); os.IsNotExist(err) {
+					t.Errorf("Expected file not found: %s", f)
+				}
+			}
+			pkg, err := os.ReadFile(filepath.Join(projectDir, "client/package.json"))
+			if err != nil {
+				t.Fatalf("Failed to read client/package.json: %v", err)
+			}
+			for _, dep := range []string{"vitest", "jsdom", tt.library} {
+				if dep != "" && !strings.Contains(string(pkg), `"`+dep+`"`) {
+					t.Errorf("client/package.json should depend on %s", dep)
+				}
+			}
+			vitestConfig, err := os.ReadFile(filepath.Join(projectDir, "client/vitest.config.ts"))
+			if err != nil {
+				t.Fatalf("Failed to read client/vitest.config.ts: %v", err)
+			}
+			if got := strings.Contains(string(vitestConfig), "svelteTesting()"); got != (tt.framework == scaffold.FrameworkSvelte) {
+				t.Errorf("client/vitest.config.ts uses svelteTesting() = %v", got)
+			}
+
+			serverTest, err := os.ReadFile(filepath.Join(projectDir, "server/main_test.go"))
+			if err != nil {
+				t.Fatalf("Failed to read server/main_test.go: %v", err)
+			}
+			for _, s := range []string{"gaptest.NewClient", "WithAuthToken", "func TestCreateItemRequiresAuth"} {
+				if !strings.Contains(string(serverTest), s) {
+					t.Errorf("server/main_test.go should contain %s", s)
+				}
+			}
+		})
+	}
+}
+
+func TestInitGeneratesCSSSetups(t *testing.T) {
+	tests := []struct {
+		css   scaffold.CSS
+		files map[string][]string
+	}{
+		{scaffold.CSSPlain, map[string][]string{
+			"client/src/styles.css":           {".page {"},
+			"client/src/main.tsx":             {`import "./styles.css";`},
+			"client/src/routes/HomeRoute.tsx": {`className="page"`},
+		}},
+		{scaffold.CSSTailwind, map[string][]string{
+			"client/src/styles.css":           {`@import "tailwindcss";`},
+			"client/vite.config.ts":           {"tailwindcss()"},
+			"client/package.json":             {`"@tailwindcss/vite"`},
+			"client/src/routes/HomeRoute.tsx": {`className="mx-auto`},
+		}},
+		{scaffold.CSSVanillaExtract, map[string][]string{
+			"client/src/styles.css.ts":        {"export const page = style("},
+			"client/vite.config.ts":           {"vanillaExtractPlugin()"},
+			"client/package.json":             {`"@vanilla-extract/css"`},
+			"client/src/routes/HomeRoute.tsx": {`import * as styles from "../styles.css";`, "className={styles.page}"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.css), func(t *testing.T) {
+			projectDir := filepath.Join(t.TempDir(), "testapp")
+			config := scaffold.ProjectConfig{
+				Name:      "testapp",
+				Module:    "testapp",
+				Framework: scaffold.FrameworkReact,
+				CSS:       tt.css,
+			}
+			if _, err := scaffold.Generate(config, projectDir); err != nil {
+				t.Fatalf("Generate failed: %v", err)
+			}
+
+			for f, want := range tt.files {
+				content, err := os.ReadFile(filepath.Join(projectDir, f))
+				if err != nil {
+					t.Fatalf("Failed to read %s: %v", f, err)
+				}
+				for _, s := range want {
+					if !strings.Contains(string(content), s) {
+						t.Errorf("%s should contain %s", f, s)
+					}
+				}
+			}
+
+			detected, err := scaffold.DetectConfig(projectDir)
+			if err != nil {
+				t.Fatalf("DetectConfig failed: %v", err)
+			}
+			if detected.CSS != tt.css {
+				t.Errorf("DetectConfig css = %s, want %s", detected.CSS, tt.css)
+			}
+		})
+	}
+}
+
+func TestInitPackageManagers(t *testing.T) {
+	for _, pm := range scaffold.PackageManagers {
+		t.Run(string(pm), func(t *testing.T) {
+			projectDir := filepath.Join(t.TempDir(), "testapp")
+			config := scaffold.ProjectConfig{
+				Name:           "testapp",
+				Module:         "testapp",
+				Framework:      scaffold.FrameworkReact,
+				PackageManager: pm,
+			}
+			if _, err := scaffold.Generate(config, projectDir); err != nil {
+				t.Fatalf("Generate failed: %v", err)
+			}
+
+			dockerfile, err := os.ReadFile(filepath.Join(projectDir, "Dockerfile"))
+			if err != nil {
+				t.Fatalf("Failed to read Dockerfile: %v", err)
+			}
+			for _, want := range []string{"client/" + pm.Lockfile(), "RUN " + string(pm) + " run build"} {
+				if !strings.Contains(string(dockerfile), want) {
+					t.Errorf("Dockerfile should contain %q:\n%s", want, dockerfile)
+				}
+			}
+			_, err = os.Stat(filepath.Join(projectDir, "client/.yarnrc.yml"))
+			if hasYarnrc := err == nil; hasYarnrc != (pm == scaffold.PackageManagerYarn) {
+				t.Errorf("client/.yarnrc.yml exists = %v", hasYarnrc)
+			}
+
+			// Until the first install, the client is taken to use npm
+			clientDir := filepath.Join(projectDir, "client")
+			if got := scaffold.DetectPackageManager(clientDir); got != scaffold.PackageManagerNpm {
+				t.Errorf("DetectPackageManager without a lockfile = %s, want npm", got)
+			}
+			if err := os.WriteFile(filepath.Join(clientDir, pm.Lockfile()), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if got := scaffold.DetectPackageManager(clientDir); got != pm {
+				t.Errorf("DetectPackageManager = %s, want %s", got, pm)
+			}
+		})
+	}
+
+	t.Run("workspace", func(t *testing.T) {
+		root := filepath.Join(t.TempDir(), "mono")
+		config := scaffold.ProjectConfig{Name: "mono", Module: "example.com/mono", PackageManager: scaffold.PackageManagerPnpm}
+		if _, err := scaffold.GenerateWorkspace(config, root, scaffold.WorkspaceApp); err != nil {
+			t.Fatalf("GenerateWorkspace failed: %v", err)
+		}
+		workspace, err := os.ReadFile(filepath.Join(root, "pnpm-workspace.yaml"))
+		if err != nil {
+			t.Fatalf("Failed to read pnpm-workspace.yaml: %v", err)
+		}
+		if !strings.Contains(string(workspace), "apps/*/client") {
+			t.Errorf("pnpm-workspace.yaml should list the apps' clients:\n%s", workspace)
+		}
+		// The workspace's lockfile is at its root, above the apps
+		if err := os.WriteFile(filepath.Join(root, "pnpm-lock.yaml"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		detected, err := scaffold.DetectConfig(filepath.Join(root, "apps/web"))
+		if err != nil {
+			t.Fatalf("DetectConfig failed: %v", err)
+		}
+		if detected.PackageManager != scaffold.PackageManagerPnpm {
+			t.Errorf("DetectConfig package manager = %s, want pnpm", detected.PackageManager)
+		}
+	})
+}
+
+func TestInitPinsDependencyVersions(t *testing.T) {
+	versions := scaffold.Versions()
+	for _, fw := range scaffold.Frameworks {
+		for _, css := range scaffold.CSSOptions {
+			t.Run(string(fw)+"/"+string(css), func(t *testing.T) {
+				projectDir := filepath.Join(t.TempDir(), "testapp")
+				config := scaffold.ProjectConfig{Name: "testapp", Module: "testapp", Framework: fw, CSS: css, Auth: true}
+				if _, err := scaffold.Generate(config, projectDir); err != nil {
+					t.Fatalf("Generate failed: %v", err)
+				}
+
+				data, err := os.ReadFile(filepath.Join(projectDir, "client/package.json"))
+				if err != nil {
+					t.Fatalf("Failed to read package.json: %v", err)
+				}
+				var pkg struct {
+					Dependencies    map[string]string `json:"dependencies"`
+					DevDependencies map[string]string `json:"devDependencies"`
+				}
+				if err := json.Unmarshal(data, &pkg); err != nil {
+					t.Fatalf("package.json isn't valid JSON: %v\n%s", err, data)
+				}
+				for _, deps := range []map[string]string{pkg.Dependencies, pkg.DevDependencies} {
+					for name, version := range deps {
+						if version != versions.Npm[name] {
+							t.Errorf("package.json has %s@%s, want the pinned %q", name, version, versions.Npm[name])
+						}
+					}
+				}
+
+				goMod, err := os.ReadFile(filepath.Join(projectDir, "server/go.mod"))
+				if err != nil {
+					t.Fatalf("Failed to read go.mod: %v", err)
+				}
+				for module, version := range versions.Go {
+					if !strings.Contains(string(goMod), "\t"+module+" "+version+"\n") {
+						t.Errorf("go.mod should require %s %s:\n%s", module, version, goMod)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestInitFromCustomTemplate(t *testing.T) {
+	template := fstest.MapFS{
+		"README.md":                         {Data: []byte("# acme template\n")},
+		"template/client/package.json.tmpl": {Data: []byte(`{"name": "<<.Name>>-client"}`)},
+		"template/server/go.mod.tmpl":       {Data: []byte("module <<.Module>>/server\n")},
+		"template/server/main.go":           {Data: []byte("package main\n\nfunc main() {}\n")},
+		"template/proto/service.proto.tmpl": {Data: []byte("package <<.ProtoPackage>>;\n")},
+		"template/scripts/deploy.sh":        {Data: []byte("#!/bin/sh\n"), Mode: 0755},
+	}
+	config := scaffold.ProjectConfig{Name: "my-app", Module: "example.com/my-app"}
+
+	projectDir := filepath.Join(t.TempDir(), "my-app")
+	if _, err := scaffold.GenerateFromTemplate(config, template, projectDir); err != nil {
+		t.Fatalf("GenerateFromTemplate failed: %v", err)
+	}
+	proto, err := os.ReadFile(filepath.Join(projectDir, "proto/service.proto"))
+	if err != nil {
+		t.Fatalf("Failed to read rendered proto: %v", err)
+	}
+	if string(proto) != "package my_app;\n" {
+		t.Errorf("Rendered proto = %q", proto)
+	}
+	if _, err := os.Stat(filepath.Join(projectDir, "README.md")); err == nil {
+		t.Error("Files outside template/ should not be copied")
+	}
+	if info, err := os.Stat(filepath.Join(projectDir, "scripts/deploy.sh")); err != nil || info.Mode().Perm()&0100 == 0 {
+		t.Errorf("deploy.sh should be copied executable: %v", err)
+	}
+	detected, err := scaffold.DetectConfig(projectDir)
+	if err != nil || detected.Module != config.Module {
+		t.Errorf("DetectConfig = %+v, %v", detected, err)
+	}
+
+	delete(template, "template/server/main.go")
+	incompleteDir := filepath.Join(t.TempDir(), "incomplete")
+	if _, err := scaffold.GenerateFromTemplate(config, template, incompleteDir); err == nil {
+		t.Error("Expected an error for a template without server/main.go")
+	}
+	if _, err := os.Stat(incompleteDir); err == nil {
+		t.Error("Nothing should be written for an incomplete template")
+	}
+}
+
+func TestInitGeneratesMonorepo(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "mono")
+	config := scaffold.ProjectConfig{Name: "mono", Module: "example.com/mono"}
+	if _, err := scaffold.GenerateWorkspace(config, root, scaffold.WorkspaceApp); err != nil {
+		t.Fatalf("GenerateWorkspace failed: %v", err)
+	}
+	if _, err := scaffold.AddWorkspaceApp(config, root, "admin"); err != nil {
+		t.Fatalf("AddWorkspaceApp failed: %v", err)
+	}
+
+	for _, f := range []string{"go.work", "package.json", "proto/service.proto", "apps/web/client/package.json", "apps/admin/server/main.go"} {
+		if _, err := os.Stat(filepath.Join(root, f)); os.IsNotExist(err) {
+			t.Errorf("Expected file not found: %s", f)
+		}
+	}
+	// The apps share the workspace's proto
+	if _, err := os.Stat(filepath.Join(root, "apps/web/proto")); err == nil {
+		t.Error("Apps should not have a proto of their own")
+	}
+	gappToml, err := os.ReadFile(filepath.Join(root, "apps/admin/gapp.toml"))
+	if err != nil {
+		t.Fatalf("Failed to read apps/admin/gapp.toml: %v", err)
+	}
+	if !strings.Contains(string(gappToml), "\nproto = \"../../proto/service.proto\"") {
+		t.Errorf("apps/admin/gapp.toml should point codegen at the shared proto:\n%s", gappToml)
+	}
+	goWork, err := os.ReadFile(filepath.Join(root, "go.work"))
+	if err != nil {
+		t.Fatalf("Failed to read go.work: %v", err)
+	}
+	if !strings.Contains(string(goWork), "use ./apps/web/server") {
+		t.Errorf("go.work should use the first app's server:\n%s", goWork)
+	}
+
+	detected, err := scaffold.DetectConfig(filepath.Join(root, "apps/admin"))
+	if err != nil {
+		t.Fatalf("DetectConfig failed: %v", err)
+	}
+	if detected.Module != "example.com/mono/apps/admin" {
+		t.Errorf("Module = %q, want example.com/mono/apps/admin", detected.Module)
+	}
+}
+
 func TestCodegenGoFromScaffoldedProject(t *testing.T) {
 	// Scaffold a project
 	dir := t.TempDir()
@@ -233,16 +643,7 @@ func TestCodegenGoFromScaffoldedProject(t *testing.T) {
 		t.Errorf("FileToGenerate = %v, want [service.proto]", req.FileToGenerate)
 	}
 
-	// Generate Go code (requires protoc-gen-go in PATH or via `go run`)
-	_, err = exec.LookPath("protoc-gen-go")
-	if err != nil {
-		// Also check if `go` is available for `go run` fallback
-		_, err = exec.LookPath("go")
-		if err != nil {
-			t.Skip("Neither protoc-gen-go nor go in PATH, skipping Go codegen test")
-		}
-	}
-
+	// Generate Go code with the built-in protoc-gen-go
 	goOut := filepath.Join(projectDir, "server", "generated")
 	goResp, err := codegen.RunGoPlugin(req, "paths=source_relative")
 	if err != nil {
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"flag"
+	"path/filepath"
+	"strings"
+
+	"github.com/germtb/gapp/cmd/gapp/internal/codegen"
+)
+
+// splitArgs separates positional args from flags so they can be mixed, as in
+// `gapp run ./app --log-dir logs`. Values of non-boolean flags given as
+// separate args stay with their flag.
+func splitArgs(fs *flag.FlagSet, args []string) (positional, flagArgs []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			positional = append(positional, arg)
+			continue
+		}
+		flagArgs = append(flagArgs, arg)
+		name := strings.TrimLeft(arg, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		f := fs.Lookup(name)
+		if f == nil {
+			continue
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			continue
+		}
+		if i+1 < len(args) {
+			i++
+			flagArgs = append(flagArgs, args[i])
+		}
+	}
+	return positional, flagArgs
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// projectCodegenArgs returns the codegen flags for the project in projectDir,
+// whose gapp.toml sets anything but the standard layout.
+func projectCodegenArgs(projectDir string) []string {
+	return []string{"--project", projectDir}
+}
+
+// projectCodegenPaths returns the proto file, directory or glob and the
+// routes directory of the project in projectDir, from the [codegen] table of
+// its gapp.toml or the standard layout.
+func projectCodegenPaths(projectDir string) (protoFile, routesDir string, err error) {
+	config, err := loadProjectConfig(projectDir)
+	if err != nil {
+		return "", "", err
+	}
+	protoFile, routesDir = "proto/service.proto", "client/src/routes"
+	if s, ok := config["codegen"]["proto"].(string); ok {
+		protoFile = s
+	}
+	if s, ok := config["codegen"]["routes_dir"].(string); ok {
+		routesDir = s
+	}
+	if !filepath.IsAbs(protoFile) {
+		protoFile = filepath.Join(projectDir, protoFile)
+	}
+	if !filepath.IsAbs(routesDir) {
+		routesDir = filepath.Join(projectDir, routesDir)
+	}
+	return protoFile, routesDir, nil
+}
+
+// projectProtos returns the proto files codegen compiles for the project in
+// projectDir, with the import paths its gapp.toml adds.
+func projectProtos(projectDir string) (codegen.ProtoSet, error) {
+	proto, _, err := projectCodegenPaths(projectDir)
+	if err != nil {
+		return codegen.ProtoSet{}, err
+	}
+	config, err := loadProjectConfig(projectDir)
+	if err != nil {
+		return codegen.ProtoSet{}, err
+	}
+	var importPaths []string
+	if value, ok := config["codegen"]["proto_path"]; ok {
+		list, err := configValue(value)
+		if err != nil {
+			return codegen.ProtoSet{}, err
+		}
+		for _, path := range splitList(list) {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(projectDir, path)
+			}
+			importPaths = append(importPaths, path)
+		}
+	}
+	return codegen.FindProtos(proto, importPaths)
+}
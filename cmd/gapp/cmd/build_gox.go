@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io"
@@ -8,9 +9,16 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
 
 	"github.com/germtb/goli"
 	"github.com/germtb/gox"
+
+	"github.com/germtb/gapp/cmd/gapp/internal/codegen"
+	"github.com/germtb/gapp/cmd/gapp/internal/pwa"
+	"github.com/germtb/gapp/cmd/gapp/scaffold"
 )
 
 type BuildStepProps struct {
@@ -39,74 +47,213 @@ func BuildStep(props BuildStepProps) gox.VNode {
 
 func RunBuild(args []string) error {
 	fs := flag.NewFlagSet("build", flag.ExitOnError)
-	outputFlag := fs.String("o", "build", "Output directory")
-	if err := fs.Parse(args); err != nil {
+	outputFlag := fs.String("o", "", "Output directory")
+	embedFlag := fs.Bool("embed", false, "Embed client assets into the server binary")
+	pwaFlag := fs.Bool("pwa", false, "Generate a service worker and web app manifest")
+	skipCodegenFlag := fs.Bool("skip-codegen", false, "Build the generated code as it is, without running codegen first")
+	verifyFlag := fs.Bool("verify", false, "Boot the built server and check /health and / before finishing")
+	targetFlag := fs.String("target", "", "Package for deployment: systemd or tar")
+	var dockerFlag optionalValue
+	fs.Var(&dockerFlag, "docker", "Build a container image, tagged with the given value or <project>:latest")
+	positional, flagArgs := splitArgs(fs, args)
+	if err := fs.Parse(flagArgs); err != nil {
+		return err
+	}
+
+	// Optional project directory, whose gapp.toml [build] table sets defaults
+	projectDir := "."
+	if len(positional) > 0 {
+		projectDir = positional[0]
+	}
+	projectDir, err := resolveProjectDir(projectDir)
+	if err != nil {
+		return err
+	}
+	if err := applyProjectConfig(fs, projectDir, "build", []string{"o"}, map[string]string{"output": "o"}); err != nil {
 		return err
 	}
 
+	if *targetFlag != "" && !validTarget(*targetFlag) {
+		return fmt.Errorf("unknown --target %q (want systemd or tar)", *targetFlag)
+	}
+
 	outputDir := *outputFlag
+	if outputDir == "" {
+		outputDir = filepath.Join(projectDir, "build")
+	}
+
+	serverDir := filepath.Join(projectDir, "server")
+	clientDir := filepath.Join(projectDir, "client")
 
 	// Validate project structure
-	if _, err := os.Stat("server/main.go"); os.IsNotExist(err) {
-		goli.Print(BuildStep(BuildStepProps{Label: "Validate project", Success: false, Err: "server/main.go not found"}))
-		return fmt.Errorf("not a gapp project (server/main.go not found)")
+	if _, err := os.Stat(filepath.Join(serverDir, "main.go")); os.IsNotExist(err) {
+		goli.Print(BuildStep(BuildStepProps{Label: "Validate project", Success: false, Err: "server/main.go not found in " + projectDir}))
+		return fmt.Errorf("not a gapp project (server/main.go not found in %s)", projectDir)
 	}
-	if _, err := os.Stat("client/package.json"); os.IsNotExist(err) {
-		goli.Print(BuildStep(BuildStepProps{Label: "Validate project", Success: false, Err: "client/package.json not found"}))
-		return fmt.Errorf("not a gapp project (client/package.json not found)")
+	if _, err := os.Stat(filepath.Join(clientDir, "package.json")); os.IsNotExist(err) {
+		goli.Print(BuildStep(BuildStepProps{Label: "Validate project", Success: false, Err: "client/package.json not found in " + projectDir}))
+		return fmt.Errorf("not a gapp project (client/package.json not found in %s)", projectDir)
 	}
 	goli.Print(BuildStep(BuildStepProps{Label: "Validate project", Success: true, Err: ""}))
 
-	// Create temp dir
-	tmpDir := fmt.Sprintf(".gapp-build-tmp-%d", rand.Int())
+	// Builds of the same commit are byte-identical, given the same toolchains
+	if err := checkToolchains(projectDir); err != nil {
+		goli.Print(BuildStep(BuildStepProps{Label: "Check toolchain versions", Success: false, Err: err.Error()}))
+		return err
+	}
+	built := buildTimestamp(projectDir)
+
+	// Create temp dir next to the output, so the final rename doesn't cross
+	// filesystems
+	tmpDir := filepath.Join(filepath.Dir(mustAbs(outputDir)), fmt.Sprintf(".gapp-build-tmp-%d", rand.Int()))
 	if err := os.MkdirAll(tmpDir, 0755); err != nil {
 		goli.Print(BuildStep(BuildStepProps{Label: "Create temp directory", Success: false, Err: err.Error()}))
 		return err
 	}
 	cleanup := func() { os.RemoveAll(tmpDir) }
 
-	// Step 1: npm run build in client/
-	npmCmd := exec.Command("npm", "run", "build")
-	npmCmd.Dir = "client"
-	npmCmd.Stderr = os.Stderr
-	if out, err := npmCmd.Output(); err != nil {
+	// Step 0: codegen, so stale generated code doesn't ship. The proto hash
+	// cache makes it cheap when nothing changed.
+	protoFile, _, err := projectCodegenPaths(projectDir)
+	if err != nil {
 		cleanup()
-		errMsg := string(out)
-		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
-			errMsg = string(exitErr.Stderr)
+		return err
+	}
+	if _, err := codegen.FindProtos(protoFile, nil); err == nil && !*skipCodegenFlag {
+		if err := RunCodegen(projectCodegenArgs(projectDir)); err != nil {
+			cleanup()
+			goli.Print(BuildStep(BuildStepProps{Label: "Codegen", Success: false, Err: err.Error()}))
+			return fmt.Errorf("codegen failed: %w", err)
+		}
+	}
+
+	// Steps 1 and 2 build the client and server concurrently, and the first
+	// failure cancels the other. Embedded builds compile public/ into the
+	// binary, so their server build waits for the client.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var printMu sync.Mutex
+	printStep := func(step gox.VNode) {
+		printMu.Lock()
+		defer printMu.Unlock()
+		goli.Print(step)
+	}
+
+	// Step 1: the client's build script, run by its package manager
+	pm := scaffold.DetectPackageManager(clientDir)
+	clientLabel := "Build client (" + string(pm) + " run build)"
+	buildClient := func() error {
+		npmCmd := exec.CommandContext(ctx, string(pm), "run", "build")
+		npmCmd.Dir = clientDir
+		npmCmd.Stderr = os.Stderr
+		npmCmd.WaitDelay = time.Second
+		if out, err := npmCmd.Output(); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			errMsg := string(out)
+			if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+				errMsg = string(exitErr.Stderr)
+			}
+			printStep(BuildStep(BuildStepProps{Label: clientLabel, Success: false, Err: errMsg}))
+			return fmt.Errorf("client build failed: %w", err)
+		}
+		printStep(BuildStep(BuildStepProps{Label: clientLabel, Success: true, Err: ""}))
+
+		if *pwaFlag {
+			appName := filepath.Base(mustAbs(projectDir))
+			if err := pwa.Write(filepath.Join(serverDir, "public"), appName); err != nil {
+				printStep(BuildStep(BuildStepProps{Label: "Generate service worker and web manifest", Success: false, Err: err.Error()}))
+				return fmt.Errorf("generating PWA files: %w", err)
+			}
+			printStep(BuildStep(BuildStepProps{Label: "Generate service worker and web manifest", Success: true, Err: ""}))
 		}
-		goli.Print(BuildStep(BuildStepProps{Label: "Build client (npm run build)", Success: false, Err: errMsg}))
-		return fmt.Errorf("client build failed: %w", err)
+		return nil
 	}
-	goli.Print(BuildStep(BuildStepProps{Label: "Build client (npm run build)", Success: true, Err: ""}))
 
-	// Step 2: go build in server/
-	serverBin := filepath.Join(tmpDir, "server")
-	goCmd := exec.Command("go", "build", "-o", mustAbs(serverBin), ".")
-	goCmd.Dir = "server"
-	goCmd.Stderr = os.Stderr
-	if out, err := goCmd.Output(); err != nil {
+	// Step 2: go build in server/, embedding public/ if requested
+	// gapp.BuildInfo is stamped with the project's git state
+	goArgs := []string{"build", "-trimpath", "-o", mustAbs(filepath.Join(tmpDir, "server")), "-ldflags", buildLdflags(projectDir, built)}
+	if *embedFlag {
+		if err := ensureEmbedFile(serverDir); err != nil {
+			cleanup()
+			goli.Print(BuildStep(BuildStepProps{Label: "Generate asset embed file", Success: false, Err: err.Error()}))
+			return fmt.Errorf("generating embed file: %w", err)
+		}
+		goArgs = append(goArgs, "-tags", embedBuildTag)
+	}
+	buildServer := func() error {
+		goCmd := exec.CommandContext(ctx, "go", append(goArgs, ".")...)
+		goCmd.Dir = serverDir
+		if dockerFlag.set {
+			// A static Linux binary for the distroless image
+			goCmd.Env = append(os.Environ(), "CGO_ENABLED=0", "GOOS=linux")
+		}
+		goCmd.Stderr = os.Stderr
+		goCmd.WaitDelay = time.Second
+		if out, err := goCmd.Output(); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			errMsg := string(out)
+			if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+				errMsg = string(exitErr.Stderr)
+			}
+			printStep(BuildStep(BuildStepProps{Label: "Build server (go build)", Success: false, Err: errMsg}))
+			return fmt.Errorf("server build failed: %w", err)
+		}
+		printStep(BuildStep(BuildStepProps{Label: "Build server (go build)", Success: true, Err: ""}))
+		return nil
+	}
+
+	var buildErr error
+	if *embedFlag {
+		if buildErr = buildClient(); buildErr == nil {
+			buildErr = buildServer()
+		}
+	} else {
+		buildErr = runFailFast(cancel, buildClient, buildServer)
+	}
+	if buildErr != nil {
 		cleanup()
-		errMsg := string(out)
-		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
-			errMsg = string(exitErr.Stderr)
+		return buildErr
+	}
+
+	// Step 3: Copy server/public/ → tmpDir/public/ (embedded builds don't need it)
+	if !*embedFlag {
+		srcPublic := filepath.Join(serverDir, "public")
+		dstPublic := filepath.Join(tmpDir, "public")
+		if err := copyDir(srcPublic, dstPublic); err != nil {
+			cleanup()
+			goli.Print(BuildStep(BuildStepProps{Label: "Copy public assets", Success: false, Err: err.Error()}))
+			return fmt.Errorf("copying public dir: %w", err)
 		}
-		goli.Print(BuildStep(BuildStepProps{Label: "Build server (go build)", Success: false, Err: errMsg}))
-		return fmt.Errorf("server build failed: %w", err)
+		goli.Print(BuildStep(BuildStepProps{Label: "Copy public assets", Success: true, Err: ""}))
 	}
-	goli.Print(BuildStep(BuildStepProps{Label: "Build server (go build)", Success: true, Err: ""}))
 
-	// Step 3: Copy server/public/ → tmpDir/public/
-	srcPublic := filepath.Join("server", "public")
-	dstPublic := filepath.Join(tmpDir, "public")
-	if err := copyDir(srcPublic, dstPublic); err != nil {
+	if err := normalizeMtimes(tmpDir, built); err != nil {
 		cleanup()
-		goli.Print(BuildStep(BuildStepProps{Label: "Copy public assets", Success: false, Err: err.Error()}))
-		return fmt.Errorf("copying public dir: %w", err)
+		goli.Print(BuildStep(BuildStepProps{Label: "Finalize output", Success: false, Err: err.Error()}))
+		return fmt.Errorf("setting timestamps: %w", err)
+	}
+
+	// Step 4: Smoke test the build before it replaces the previous one
+	if *verifyFlag {
+		var err error
+		if dockerFlag.set && runtime.GOOS != "linux" {
+			err = fmt.Errorf("the --docker server is built for linux and can't run on %s", runtime.GOOS)
+		} else {
+			err = verifyBuild(tmpDir)
+		}
+		if err != nil {
+			cleanup()
+			goli.Print(BuildStep(BuildStepProps{Label: "Verify build", Success: false, Err: err.Error()}))
+			return fmt.Errorf("verify failed: %w", err)
+		}
+		goli.Print(BuildStep(BuildStepProps{Label: "Verify build (/health, /)", Success: true, Err: ""}))
 	}
-	goli.Print(BuildStep(BuildStepProps{Label: "Copy public assets", Success: true, Err: ""}))
 
-	// Step 4: Atomic swap
+	// Step 5: Atomic swap
 	os.RemoveAll(outputDir)
 	if err := os.Rename(tmpDir, outputDir); err != nil {
 		cleanup()
@@ -115,6 +262,48 @@ func RunBuild(args []string) error {
 	}
 
 	runCmd := "    cd " + outputDir + " && ./server"
+	if *embedFlag {
+		runCmd = "    " + filepath.Join(outputDir, "server")
+	}
+
+	// Step 6: Package the output for a classic server deployment
+	if *targetFlag != "" {
+		name := projectSlug(projectDir)
+		tarball, err := writeTarget(outputDir, *targetFlag, name, built)
+		if err != nil {
+			goli.Print(BuildStep(BuildStepProps{Label: "Package for " + *targetFlag, Success: false, Err: err.Error()}))
+			return fmt.Errorf("packaging for %s: %w", *targetFlag, err)
+		}
+		goli.Print(BuildStep(BuildStepProps{Label: "Package " + filepath.Base(tarball), Success: true, Err: ""}))
+		if *targetFlag == targetSystemd {
+			runCmd = "    sudo tar -xzf " + tarball + " -C /opt && sudo systemctl enable --now /opt/" + name + "/" + name + ".service"
+		} else {
+			runCmd = "    tar -xzf " + tarball + " && ./" + name + "/start.sh"
+		}
+	}
+
+	// Step 7: Build a container image from the output
+	if dockerFlag.set {
+		tag := dockerFlag.value
+		if tag == "" {
+			tag = defaultImageTag(projectDir)
+		}
+		if err := writeDockerfile(outputDir, *embedFlag); err != nil {
+			goli.Print(BuildStep(BuildStepProps{Label: "Write Dockerfile", Success: false, Err: err.Error()}))
+			return fmt.Errorf("writing Dockerfile: %w", err)
+		}
+		imageCmd, err := imageBuildCommand(outputDir, tag)
+		if err != nil {
+			goli.Print(BuildStep(BuildStepProps{Label: "Build image", Success: false, Err: err.Error()}))
+			return err
+		}
+		if out, err := imageCmd.CombinedOutput(); err != nil {
+			goli.Print(BuildStep(BuildStepProps{Label: "Build image (" + filepath.Base(imageCmd.Path) + ")", Success: false, Err: string(out)}))
+			return fmt.Errorf("image build failed: %w", err)
+		}
+		goli.Print(BuildStep(BuildStepProps{Label: "Build image " + tag, Success: true, Err: ""}))
+		runCmd = "    docker run -p 8080:8080 " + tag
+	}
 	goli.Print(gox.Element("box", gox.Props{"direction": "column"},
 		gox.Element("box", gox.Props{"direction": "row"},
 			gox.Element("text", gox.Props{"color": "green"},
@@ -131,6 +320,47 @@ func RunBuild(args []string) error {
 	return nil
 }
 
+const embedFileName = "gapp_embed.go"
+
+// embedBuildTag includes embedFileName in a build. Builds without it serve
+// public/ from disk, and compile even when public/ hasn't been built.
+const embedBuildTag = "gapp_embed"
+
+// embedFileContent matches the scaffolded server/gapp_embed.go.
+const embedFileContent = `//go:build gapp_embed
+
+// Embeds public/ into the server binary for ` + "`gapp build --embed`" + `, which
+// builds with -tags gapp_embed. Other builds serve public/ from disk.
+
+package main
+
+import (
+	"embed"
+
+	"github.com/germtb/gapp"
+)
+
+//go:embed all:public
+var gappPublic embed.FS
+
+func init() {
+	gapp.UseEmbeddedAssets(gapp.EmbeddedAssets(gappPublic))
+}
+`
+
+// ensureEmbedFile checks server/public/ exists for embedding, and writes
+// server/gapp_embed.go for projects scaffolded before it was.
+func ensureEmbedFile(serverDir string) error {
+	if _, err := os.Stat(filepath.Join(serverDir, "public")); err != nil {
+		return fmt.Errorf("server/public not found: %w", err)
+	}
+	path := filepath.Join(serverDir, embedFileName)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	return os.WriteFile(path, []byte(embedFileContent), 0644)
+}
+
 func mustAbs(path string) string {
 	abs, err := filepath.Abs(path)
 	if err != nil {
@@ -139,6 +369,24 @@ func mustAbs(path string) string {
 	return abs
 }
 
+// runFailFast runs fns concurrently and returns the first error, calling
+// cancel when it occurs so the others can stop early. It returns once all
+// of them have.
+func runFailFast(cancel func(), fns ...func() error) error {
+	errs := make(chan error, len(fns))
+	for _, fn := range fns {
+		go func() { errs <- fn() }()
+	}
+	var first error
+	for range fns {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+			cancel()
+		}
+	}
+	return first
+}
+
 func copyDir(src, dst string) error {
 	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// buildInfoPackage holds the variables gapp.BuildInfo is read from.
+const buildInfoPackage = "github.com/germtb/gapp"
+
+// buildLdflags returns the -ldflags stamping gapp.BuildInfo with the git
+// tag and commit of dir and the build time.
+func buildLdflags(dir string, built time.Time) string {
+	values := [][2]string{
+		{"buildVersion", gitOutput(dir, "describe", "--tags", "--dirty")},
+		{"buildCommit", gitCommit(dir)},
+		{"buildTime", built.UTC().Format(time.RFC3339)},
+	}
+	var flags []string
+	for _, v := range values {
+		if v[1] != "" {
+			flags = append(flags, "-X "+buildInfoPackage+"."+v[0]+"="+v[1])
+		}
+	}
+	return strings.Join(flags, " ")
+}
+
+// gitCommit returns the commit checked out in dir, marked -dirty when there
+// are uncommitted changes, or "" outside a git repository.
+func gitCommit(dir string) string {
+	commit := gitOutput(dir, "rev-parse", "HEAD")
+	if commit != "" && gitOutput(dir, "status", "--porcelain") != "" {
+		commit += "-dirty"
+	}
+	return commit
+}
+
+// gitOutput runs git in dir and returns its trimmed output, or "" if it fails.
+func gitOutput(dir string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// devCertHosts are the names local development certificates are valid for.
+var devCertHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// DevCert is a local development certificate.
+type DevCert struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string // root that signed it, for NODE_EXTRA_CA_CERTS
+	Trusted  bool   // signed by mkcert's root, which browsers trust
+}
+
+// EnsureDevCert returns the development certificate in dir, creating it if
+// it's missing or about to expire. It's signed by mkcert's local root when
+// mkcert is installed, or self-signed otherwise.
+func EnsureDevCert(dir string) (*DevCert, error) {
+	cert := &DevCert{
+		CertFile: filepath.Join(dir, "localhost.pem"),
+		KeyFile:  filepath.Join(dir, "localhost-key.pem"),
+		CAFile:   filepath.Join(dir, "localhost.pem"),
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	if caRoot := mkcertCARoot(); caRoot != "" {
+		cert.CAFile = filepath.Join(caRoot, "rootCA.pem")
+		cert.Trusted = true
+		if certValid(cert.CertFile, cert.KeyFile) && signedBy(cert.CertFile, cert.CAFile) {
+			return cert, nil
+		}
+		args := append([]string{"-cert-file", cert.CertFile, "-key-file", cert.KeyFile}, devCertHosts...)
+		if err := exec.Command("mkcert", args...).Run(); err == nil {
+			return cert, nil
+		}
+		// Fall back to a self-signed certificate
+		cert.CAFile = cert.CertFile
+		cert.Trusted = false
+	}
+
+	if certValid(cert.CertFile, cert.KeyFile) && signedBy(cert.CertFile, cert.CertFile) {
+		return cert, nil
+	}
+	return cert, writeSelfSignedCert(cert.CertFile, cert.KeyFile)
+}
+
+// mkcertCARoot returns mkcert's root directory, or "" if mkcert isn't
+// installed or hasn't created its root yet (`mkcert -install`).
+func mkcertCARoot() string {
+	if _, err := exec.LookPath("mkcert"); err != nil {
+		return ""
+	}
+	out, err := exec.Command("mkcert", "-CAROOT").Output()
+	if err != nil {
+		return ""
+	}
+	root := strings.TrimSpace(string(out))
+	if _, err := os.Stat(filepath.Join(root, "rootCA.pem")); err != nil {
+		return ""
+	}
+	return root
+}
+
+// certValid reports whether the key pair loads and stays valid for a week.
+func certValid(certFile, keyFile string) bool {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return false
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return false
+	}
+	return time.Now().Add(7 * 24 * time.Hour).Before(leaf.NotAfter)
+}
+
+func signedBy(certFile, caFile string) bool {
+	leaf, err := readCert(certFile)
+	if err != nil {
+		return false
+	}
+	ca, err := readCert(caFile)
+	if err != nil {
+		return false
+	}
+	return leaf.CheckSignatureFrom(ca) == nil
+}
+
+func readCert(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, os.ErrInvalid
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// writeSelfSignedCert writes a certificate for devCertHosts that signs
+// itself, so it can also be given to Node as a CA.
+func writeSelfSignedCert(certFile, keyFile string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"gapp development"}, CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, host := range devCertHosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+}
@@ -1,16 +1,25 @@
 package cmd
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/germtb/goli"
 	"github.com/germtb/gox"
+	"google.golang.org/protobuf/types/pluginpb"
 
 	"github.com/germtb/gapp/cmd/gapp/internal/codegen"
+	"github.com/germtb/gapp/cmd/gapp/scaffold"
 )
 
 type CodegenStepProps struct {
@@ -39,131 +48,621 @@ func CodegenStep(props CodegenStepProps) gox.VNode {
 
 func RunCodegen(args []string) error {
 	fs := flag.NewFlagSet("codegen", flag.ExitOnError)
-	protoFlag := fs.String("proto", "proto/service.proto", "Proto file path")
+	projectFlag := fs.String("project", ".", "Project directory, whose gapp.toml [codegen] table sets defaults and paths are relative to")
+	protoFlag := fs.String("proto", "proto/service.proto", "Proto file, directory of proto files or glob such as proto/*.proto")
+	protoPathFlag := fs.String("proto-path", "", "Extra comma-separated directories proto imports resolve against")
 	goOutFlag := fs.String("go-out", "server/generated", "Go output directory")
 	tsOutFlag := fs.String("ts-out", "client/src/generated", "TypeScript output directory")
 	routesDirFlag := fs.String("routes-dir", "client/src/routes", "Routes directory for preload config")
+	inferRoutePathsFlag := fs.Bool("infer-route-paths", false, "Derive the paths of route files declaring none from where they are in the routes directory")
 	preloadOutFlag := fs.String("preload-out", "server/generated/preload_routes.go", "Preload config output path")
 	forceFlag := fs.Bool("force", false, "Force codegen even if proto hasn't changed")
+	preloadOnlyFlag := fs.Bool("preload-only", false, "Only generate the preload config and route manifest from the routes, skip proto compilation")
+	skipTSFlag := fs.Bool("skip-ts", false, "Only generate Go code, e.g. without Node installed")
+	mocksFlag := fs.Bool("mocks", false, "Generate mock service implementations (gapp_mocks.go)")
+	hooksFlag := fs.String("hooks", "", "Generate React hooks for the RPCs (gapp_hooks.ts) with react-query or swr")
+	zodFlag := fs.Bool("zod", false, "Generate zod schemas of the messages with their protovalidate rules (gapp_zod.ts)")
+	goOptFlag := fs.String("go-opt", "", "Extra comma-separated protoc-gen-go parameters")
+	goPluginFlag := fs.String("go-plugin", "", "protoc-gen-go binary to run instead of the one built into gapp")
+	goPackageFlag := fs.String("go-package", "", "Go import path of the Go output, overriding the protos' go_package")
+	goImportMapFlag := fs.String("go-import-map", "", "Comma-separated file.proto=import/path Go import paths of imported protos (protoc's M flags)")
+	tsOptFlag := fs.String("ts-opt", "", "Extra comma-separated ts-proto parameters, e.g. env=browser")
+	watchFlag := fs.Bool("watch", false, "Keep running, regenerating when the protos or routes change")
+	dryRunFlag := fs.Bool("dry-run", false, "Print a diff of what codegen would change instead of writing it")
+	allowBreakingFlag := fs.Bool("allow-breaking", false, "Generate even if the protos changed in ways that break clients built against the previous ones")
+	swiftOutFlag := fs.String("swift-out", "", "Swift client output directory, generated with protoc-gen-swift")
+	kotlinOutFlag := fs.String("kotlin-out", "", "Kotlin client output directory, generated with pbandk's protoc plugin")
+	docsFlag := fs.Bool("docs", false, "Generate an API reference of the services for HTTP clients")
+	docsFormatFlag := fs.String("docs-format", "openapi,html", "Comma-separated API reference formats: openapi (openapi.json), html (index.html)")
+	docsOutFlag := fs.String("docs-out", "docs/api", "API reference output directory")
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
+	// A monorepo's root isn't a project, each of its apps is
+	if apps, ok := workspaceApps(*projectFlag); ok {
+		if *watchFlag {
+			return watchApps(args, apps)
+		}
+		for _, app := range apps {
+			goli.Print(gox.Element("text", gox.Props{"bold": true},
+				gox.V(app)))
+			if err := RunCodegen(append(slices.Clone(args), "--project", app)); err != nil {
+				return fmt.Errorf("%s: %w", app, err)
+			}
+		}
+		return nil
+	}
+	codegenPaths := []string{"proto", "proto-path", "go-out", "ts-out", "routes-dir", "preload-out", "swift-out", "kotlin-out", "docs-out"}
+	if err := applyProjectConfig(fs, *projectFlag, "codegen", codegenPaths, nil); err != nil {
+		return err
+	}
 
-	protoFile := *protoFlag
-	goOut := *goOutFlag
-	tsOut := *tsOutFlag
 	routesDir := *routesDirFlag
 	preloadOut := *preloadOutFlag
 
-	// Verify proto file exists
-	if _, err := os.Stat(protoFile); os.IsNotExist(err) {
-		goli.Print(CodegenStep(CodegenStepProps{Label: "Proto file: " + protoFile, Success: false, Err: "file not found"}))
-		return fmt.Errorf("proto file not found: %s", protoFile)
+	if *watchFlag && *dryRunFlag {
+		return fmt.Errorf("--dry-run can't be combined with --watch")
 	}
+	out := &codegenOutput{dryRun: *dryRunFlag}
 
-	protoDir := filepath.Dir(protoFile)
-
-	// Derive project root (parent of proto/)
-	projectDir := filepath.Dir(protoDir)
-	if filepath.Base(protoDir) != "proto" {
-		projectDir = "."
+	if *watchFlag {
+		protoDir := filepath.Dir(*protoFlag)
+		if protos, err := codegen.FindProtos(*protoFlag, nil); err == nil {
+			protoDir = protos.Root
+		}
+		label := ""
+		if *projectFlag != "." {
+			label = *projectFlag
+		}
+		return watchCodegen(withoutFlag(args, "watch"), label, protoDir, routesDir)
 	}
 
-	// Hash-based caching
-	if !*forceFlag {
-		currentHash, err := codegen.HashFile(protoFile)
-		if err == nil {
-			storedHash := codegen.ReadStoredHash(projectDir)
-			if currentHash == storedHash {
-				goli.Print(gox.Element("box", gox.Props{"direction": "row"},
-					gox.Element("text", gox.Props{"color": "green"},
-						gox.V("✓")),
-					gox.Element("text", nil,
-						gox.V(" Proto unchanged, codegen up to date (use --force to re-run)"))))
-				return nil
+	var req *pluginpb.CodeGeneratorRequest
+	if !*preloadOnlyFlag {
+		goOut := *goOutFlag
+		tsOut := *tsOutFlag
+
+		protos, err := codegen.FindProtos(*protoFlag, splitList(*protoPathFlag))
+		if err != nil {
+			goli.Print(CodegenStep(CodegenStepProps{Label: "Proto files: " + *protoFlag, Success: false, Err: err.Error()}))
+			return fmt.Errorf("proto files not found: %w", err)
+		}
+		protoDir := protos.Root
+		plugins, err := codegenPlugins(*projectFlag)
+		if err != nil {
+			return err
+		}
+		docs, err := docsOutputs(*docsFlag, *docsFormatFlag, *docsOutFlag)
+		if err != nil {
+			return err
+		}
+		goMappings, err := codegen.GoMappings(protos.Files, *goPackageFlag, splitList(*goImportMapFlag))
+		if err != nil {
+			return err
+		}
+		if *hooksFlag != "" && !slices.Contains(codegen.HookLibraries, *hooksFlag) {
+			return fmt.Errorf("unknown --hooks library %q, expected react-query or swr", *hooksFlag)
+		}
+
+		// Derive project root (parent of proto/), unless the proto is a
+		// monorepo's, shared by several projects with their own hashes
+		projectDir := filepath.Dir(protoDir)
+		if filepath.Base(protoDir) != "proto" || isOutside(*projectFlag, projectDir) {
+			projectDir = *projectFlag
+		}
+
+		// Step 1: Compile the protos with protocompile (no protoc binary
+		// needed), all in one request so they can import each other
+		req, err = protos.Compile()
+		if err != nil {
+			goli.Print(CodegenStep(CodegenStepProps{Label: "Proto compilation", Success: false, Err: err.Error()}))
+			return fmt.Errorf("proto compilation failed: %w", err)
+		}
+		var tsPlugin string
+		var tsPluginErr error
+		if !*skipTSFlag {
+			tsPlugin, tsPluginErr = findTsProtoPlugin(filepath.Dir(tsOut))
+		}
+		natives := nativeTargets(*swiftOutFlag, *kotlinOutFlag)
+
+		// Hash-based caching of everything below: regenerate when any input
+		// changed, not only the protos
+		key, err := codegenCacheKey(fs, req, tsPlugin, plugins, natives)
+		if err != nil {
+			return err
+		}
+		// Dry runs render everything, to compare it all with the disk
+		protoChanged := *forceFlag || *dryRunFlag || key != codegen.ReadStoredHash(projectDir)
+		mocksOut := filepath.Join(goOut, "gapp_mocks.go")
+		if _, err := os.Stat(mocksOut); *mocksFlag && err != nil {
+			protoChanged = true
+		}
+		hooksOut := filepath.Join(tsOut, "gapp_hooks.ts")
+		if _, err := os.Stat(hooksOut); *hooksFlag != "" && !*skipTSFlag && err != nil {
+			protoChanged = true
+		}
+		zodOut := filepath.Join(tsOut, "gapp_zod.ts")
+		if _, err := os.Stat(zodOut); *zodFlag && !*skipTSFlag && err != nil {
+			protoChanged = true
+		}
+		for _, target := range natives {
+			if _, err := os.Stat(target.Out); err != nil {
+				protoChanged = true
+			}
+		}
+		for _, out := range docs {
+			if _, err := os.Stat(out); err != nil {
+				protoChanged = true
 			}
 		}
-	}
-	protoName := filepath.Base(protoFile)
 
-	// Ensure output directories exist
-	os.MkdirAll(goOut, 0755)
-	os.MkdirAll(tsOut, 0755)
+		stored, err := codegen.ReadStoredSchema(projectDir)
+		if err != nil {
+			return err
+		}
 
-	// Step 1: Compile proto with protocompile (no protoc binary needed)
-	req, err := codegen.CompileProto(protoDir, protoName)
-	if err != nil {
-		goli.Print(CodegenStep(CodegenStepProps{Label: "Proto compilation", Success: false, Err: err.Error()}))
-		return fmt.Errorf("proto compilation failed: %w", err)
-	}
-	goli.Print(CodegenStep(CodegenStepProps{Label: "Proto compilation", Success: true, Err: ""}))
+		if protoChanged {
+			// Ensure output directories exist
+			if !*dryRunFlag {
+				os.MkdirAll(goOut, 0755)
+				if !*skipTSFlag {
+					os.MkdirAll(tsOut, 0755)
+				}
+			}
 
-	// Step 2: Generate Go code via protoc-gen-go
-	goResp, err := codegen.RunGoPlugin(req, "paths=source_relative")
-	if err != nil {
-		goli.Print(CodegenStep(CodegenStepProps{Label: "Go codegen", Success: false, Err: err.Error()}))
-		return fmt.Errorf("Go codegen failed: %w", err)
-	}
-	if _, err := codegen.WriteResponse(goResp, goOut); err != nil {
-		goli.Print(CodegenStep(CodegenStepProps{Label: "Go codegen", Success: false, Err: err.Error()}))
-		return fmt.Errorf("writing Go output: %w", err)
-	}
-	goli.Print(CodegenStep(CodegenStepProps{Label: "Go codegen → " + goOut, Success: true, Err: ""}))
+			goli.Print(CodegenStep(CodegenStepProps{Label: "Proto compilation", Success: true, Err: ""}))
 
-	// Step 3: Generate TypeScript code via protoc-gen-ts_proto
-	tsPlugin, err := findTsProtoPlugin(filepath.Dir(tsOut))
-	if err != nil {
-		goli.Print(CodegenStep(CodegenStepProps{Label: "TypeScript codegen", Success: false, Err: err.Error()}))
-		return err
-	}
-	tsResp, err := codegen.RunPlugin(req, tsPlugin, "outputServices=default,esModuleInterop=true,useOptionals=messages")
-	if err != nil {
-		goli.Print(CodegenStep(CodegenStepProps{Label: "TypeScript codegen", Success: false, Err: err.Error()}))
-		return fmt.Errorf("TypeScript codegen failed: %w", err)
-	}
-	if _, err := codegen.WriteResponse(tsResp, tsOut); err != nil {
-		goli.Print(CodegenStep(CodegenStepProps{Label: "TypeScript codegen", Success: false, Err: err.Error()}))
-		return fmt.Errorf("writing TypeScript output: %w", err)
+			// Deployed clients still send and hydrate messages of the protos
+			// last generated, so changes breaking those need to be allowed.
+			// Dry runs report them along with the diff.
+			if changes := codegen.BreakingChanges(stored, req); len(changes) > 0 && !*allowBreakingFlag {
+				goli.Print(CodegenStep(CodegenStepProps{Label: "Breaking changes", Success: false, Err: strings.Join(changes, "\n    ")}))
+				if !*dryRunFlag {
+					return fmt.Errorf("%d breaking change(s) to the protos, pass --allow-breaking to generate anyway", len(changes))
+				}
+			}
+
+			// Step 2: Generate Go code via protoc-gen-go
+			goParams := pluginParams("paths=source_relative", strings.Join(append(goMappings, splitList(*goOptFlag)...), ","))
+			var goResp *pluginpb.CodeGeneratorResponse
+			if *goPluginFlag != "" {
+				goResp, err = codegen.RunPlugin(req, *goPluginFlag, goParams)
+			} else {
+				goResp, err = codegen.RunGoPlugin(req, goParams)
+			}
+			if err != nil {
+				goli.Print(CodegenStep(CodegenStepProps{Label: "Go codegen", Success: false, Err: err.Error()}))
+				return fmt.Errorf("Go codegen failed: %w", err)
+			}
+			if err := out.writeResponse(goResp, goOut); err != nil {
+				goli.Print(CodegenStep(CodegenStepProps{Label: "Go codegen", Success: false, Err: err.Error()}))
+				return fmt.Errorf("writing Go output: %w", err)
+			}
+			goli.Print(CodegenStep(CodegenStepProps{Label: "Go codegen → " + goOut, Success: true, Err: ""}))
+
+			// Step 3: Generate TypeScript code via protoc-gen-ts_proto
+			if !*skipTSFlag {
+				if tsPluginErr != nil {
+					goli.Print(CodegenStep(CodegenStepProps{Label: "TypeScript codegen", Success: false, Err: tsPluginErr.Error()}))
+					return tsPluginErr
+				}
+				tsResp, err := codegen.RunPlugin(req, tsPlugin, pluginParams(tsProtoParams, *tsOptFlag))
+				if err != nil {
+					goli.Print(CodegenStep(CodegenStepProps{Label: "TypeScript codegen", Success: false, Err: err.Error()}))
+					return fmt.Errorf("TypeScript codegen failed: %w", err)
+				}
+				if err := out.writeResponse(tsResp, tsOut); err != nil {
+					goli.Print(CodegenStep(CodegenStepProps{Label: "TypeScript codegen", Success: false, Err: err.Error()}))
+					return fmt.Errorf("writing TypeScript output: %w", err)
+				}
+				goli.Print(CodegenStep(CodegenStepProps{Label: "TypeScript codegen → " + tsOut, Success: true, Err: ""}))
+			}
+
+			// Step 4: Emit the schema hash for hydration version checks
+			if hash, err := protos.Hash(); err == nil {
+				goSchema := codegen.GenerateSchemaGo(hash, filepath.Base(goOut))
+				if err := out.writeFile(filepath.Join(goOut, "gapp_schema.go"), []byte(goSchema)); err != nil {
+					goli.Print(CodegenStep(CodegenStepProps{Label: "Schema hash", Success: false, Err: err.Error()}))
+					return fmt.Errorf("writing Go schema hash: %w", err)
+				}
+				if !*skipTSFlag {
+					if err := out.writeFile(filepath.Join(tsOut, "gapp_schema.ts"), []byte(codegen.GenerateSchemaTS(hash))); err != nil {
+						goli.Print(CodegenStep(CodegenStepProps{Label: "Schema hash", Success: false, Err: err.Error()}))
+						return fmt.Errorf("writing TypeScript schema hash: %w", err)
+					}
+				}
+			}
+
+			// Step 5: Emit hub subscription helpers for messages declared as topics
+			if topics := codegen.ScanTopics(req); len(topics) > 0 && !*skipTSFlag {
+				topicsOut := filepath.Join(tsOut, "gapp_topics.ts")
+				if err := out.writeFile(topicsOut, []byte(codegen.GenerateTopicsTS(topics))); err != nil {
+					goli.Print(CodegenStep(CodegenStepProps{Label: "Hub topics", Success: false, Err: err.Error()}))
+					return fmt.Errorf("writing hub topics: %w", err)
+				}
+				goli.Print(CodegenStep(CodegenStepProps{Label: "Hub topics → " + topicsOut, Success: true, Err: ""}))
+			}
+
+			// Step 6: Emit React hooks wrapping the rpc client, which
+			// client/src/rpc.ts exports next to the generated code
+			if *hooksFlag != "" && !*skipTSFlag {
+				hooks, err := codegen.GenerateHooksTS(req, *hooksFlag, "../rpc")
+				if err != nil {
+					goli.Print(CodegenStep(CodegenStepProps{Label: "React hooks", Success: false, Err: err.Error()}))
+					return err
+				}
+				if hooks != "" {
+					if err := out.writeFile(hooksOut, []byte(hooks)); err != nil {
+						goli.Print(CodegenStep(CodegenStepProps{Label: "React hooks", Success: false, Err: err.Error()}))
+						return fmt.Errorf("writing React hooks: %w", err)
+					}
+					goli.Print(CodegenStep(CodegenStepProps{Label: "React hooks → " + hooksOut, Success: true, Err: ""}))
+				}
+			}
+
+			// Step 7: Emit zod schemas for validating messages before sending
+			if *zodFlag && !*skipTSFlag {
+				schemas, err := codegen.GenerateZodTS(req)
+				if err != nil {
+					goli.Print(CodegenStep(CodegenStepProps{Label: "Zod schemas", Success: false, Err: err.Error()}))
+					return err
+				}
+				if schemas != "" {
+					if err := out.writeFile(zodOut, []byte(schemas)); err != nil {
+						goli.Print(CodegenStep(CodegenStepProps{Label: "Zod schemas", Success: false, Err: err.Error()}))
+						return fmt.Errorf("writing zod schemas: %w", err)
+					}
+					goli.Print(CodegenStep(CodegenStepProps{Label: "Zod schemas → " + zodOut, Success: true, Err: ""}))
+				}
+			}
+
+			// Step 8: Emit mock services answering with example messages
+			if *mocksFlag {
+				mocks, err := codegen.GenerateMocksGo(req, filepath.Base(goOut))
+				if err != nil {
+					goli.Print(CodegenStep(CodegenStepProps{Label: "Mocks", Success: false, Err: err.Error()}))
+					return err
+				}
+				if mocks != "" {
+					if err := out.writeFile(mocksOut, []byte(mocks)); err != nil {
+						goli.Print(CodegenStep(CodegenStepProps{Label: "Mocks", Success: false, Err: err.Error()}))
+						return fmt.Errorf("writing mocks: %w", err)
+					}
+					goli.Print(CodegenStep(CodegenStepProps{Label: "Mocks → " + mocksOut, Success: true, Err: ""}))
+				}
+			}
+
+			// Step 9: Run the extra plugins declared in gapp.toml
+			for _, plugin := range plugins {
+				label := plugin.Label() + " → " + plugin.Out
+				resp, err := plugin.Run(req)
+				if err == nil {
+					err = out.writeResponse(resp, plugin.Out)
+				}
+				if err != nil {
+					goli.Print(CodegenStep(CodegenStepProps{Label: label, Success: false, Err: err.Error()}))
+					return fmt.Errorf("%s failed: %w", plugin.Label(), err)
+				}
+				goli.Print(CodegenStep(CodegenStepProps{Label: label, Success: true, Err: ""}))
+			}
+
+			// Step 10: Generate the native mobile clients
+			for _, target := range natives {
+				label := target.Name + " client → " + target.Out
+				if err := target.Generate(req, out); err != nil {
+					goli.Print(CodegenStep(CodegenStepProps{Label: label, Success: false, Err: err.Error()}))
+					return fmt.Errorf("%s codegen failed: %w", target.Name, err)
+				}
+				goli.Print(CodegenStep(CodegenStepProps{Label: label, Success: true, Err: ""}))
+			}
+
+			// Step 11: Emit the API reference for clients calling over HTTP
+			if len(docs) > 0 {
+				abs, _ := filepath.Abs(*projectFlag)
+				hash, _ := protos.Hash()
+				config := codegen.DocsConfig{Title: filepath.Base(abs), Version: hash, RpcPath: "/rpc"}
+				for _, docOut := range docs {
+					generate := codegen.GenerateOpenAPI
+					if filepath.Ext(docOut) == ".html" {
+						generate = codegen.GenerateDocsHTML
+					}
+					data, err := generate(req, config)
+					if err == nil {
+						err = out.writeFile(docOut, data)
+					}
+					if err != nil {
+						goli.Print(CodegenStep(CodegenStepProps{Label: "API reference", Success: false, Err: err.Error()}))
+						return fmt.Errorf("writing API reference: %w", err)
+					}
+					goli.Print(CodegenStep(CodegenStepProps{Label: "API reference → " + docOut, Success: true, Err: ""}))
+				}
+			}
+		} else {
+			goli.Print(gox.Element("box", gox.Props{"direction": "row"},
+				gox.Element("text", gox.Props{"color": "green"},
+					gox.V("✓")),
+				gox.Element("text", nil,
+					gox.V(" Protos, flags and plugins unchanged, skipping codegen (use --force to re-run)"))))
+		}
+
+		// Write the key after successful codegen. Go-only runs leave it, so
+		// the next full run still generates TypeScript.
+		if protoChanged && !*skipTSFlag && !*dryRunFlag {
+			codegen.WriteHash(projectDir, key)
+		}
+		// The protos are checked against those of the last run that
+		// generated, or the first one since they were stored
+		if (protoChanged || stored == nil) && !*dryRunFlag {
+			if err := codegen.WriteSchema(projectDir, req); err != nil {
+				return fmt.Errorf("storing the protos: %w", err)
+			}
+		}
 	}
-	goli.Print(CodegenStep(CodegenStepProps{Label: "TypeScript codegen → " + tsOut, Success: true, Err: ""}))
 
-	// Step 4: Generate preload routes config
+	// Generate preload routes config
 	if routesDir != "" && preloadOut != "" {
 		if _, err := os.Stat(routesDir); err == nil {
-			routes, err := codegen.ScanRoutes(routesDir)
+			routes, err := codegen.ScanRoutes(routesDir, *inferRoutePathsFlag)
 			if err != nil {
 				goli.Print(CodegenStep(CodegenStepProps{Label: "Preload config", Success: false, Err: err.Error()}))
 				return fmt.Errorf("preload config generation failed: %w", err)
 			}
 
-			if len(routes) == 0 {
+			// Check the routes' RPCs against the protos, compiled just for
+			// that by preload-only runs, unless they don't compile
+			if req == nil {
+				if protos, err := codegen.FindProtos(*protoFlag, splitList(*protoPathFlag)); err == nil {
+					req, _ = protos.Compile()
+				}
+			}
+			if req != nil {
+				if err := codegen.ValidateRoutes(routes, req); err != nil {
+					goli.Print(CodegenStep(CodegenStepProps{Label: "Route RPCs", Success: false, Err: err.Error()}))
+					return fmt.Errorf("invalid route RPCs: %w", err)
+				}
+			}
+
+			// The client's route manifest, which lists routes preloading
+			// nothing too
+			if !*skipTSFlag {
+				routesModule, err := filepath.Rel(*tsOutFlag, routesDir)
+				if err != nil {
+					return fmt.Errorf("route manifest: %w", err)
+				}
+				manifest := codegen.GenerateRoutesTS(routes, filepath.ToSlash(routesModule))
+				manifestOut := filepath.Join(*tsOutFlag, "gapp_routes.ts")
+				if existing, err := os.ReadFile(manifestOut); err != nil || string(existing) != manifest {
+					if err := out.writeFile(manifestOut, []byte(manifest)); err != nil {
+						goli.Print(CodegenStep(CodegenStepProps{Label: "Route manifest", Success: false, Err: err.Error()}))
+						return fmt.Errorf("writing route manifest: %w", err)
+					}
+				}
+				goli.Print(CodegenStep(CodegenStepProps{Label: "Route manifest → " + manifestOut, Success: true, Err: ""}))
+			}
+
+			hasRpcs := func(route codegen.RoutePreload) bool { return len(route.Rpcs) > 0 }
+			if !slices.ContainsFunc(routes, hasRpcs) {
 				goli.Print(CodegenStep(CodegenStepProps{Label: "Preload config — no routes with RPCs found", Success: true, Err: ""}))
 			} else {
 				pkgName := filepath.Base(filepath.Dir(preloadOut))
-				goCode := codegen.GeneratePreloadGo(routes, pkgName)
-
-				os.MkdirAll(filepath.Dir(preloadOut), 0755)
-				if err := os.WriteFile(preloadOut, []byte(goCode), 0644); err != nil {
+				goCode, err := codegen.GeneratePreloadGo(routes, pkgName)
+				if err != nil {
 					goli.Print(CodegenStep(CodegenStepProps{Label: "Preload config", Success: false, Err: err.Error()}))
-					return fmt.Errorf("writing preload config: %w", err)
+					return fmt.Errorf("preload config generation failed: %w", err)
+				}
+
+				// Unchanged output isn't rewritten, so gapp run doesn't restart
+				// the server for route edits that don't affect preloads
+				if existing, err := os.ReadFile(preloadOut); err != nil || string(existing) != goCode {
+					if err := out.writeFile(preloadOut, []byte(goCode)); err != nil {
+						goli.Print(CodegenStep(CodegenStepProps{Label: "Preload config", Success: false, Err: err.Error()}))
+						return fmt.Errorf("writing preload config: %w", err)
+					}
 				}
 				goli.Print(CodegenStep(CodegenStepProps{Label: "Preload config → " + preloadOut, Success: true, Err: ""}))
 			}
 		}
 	}
 
-	// Write hash after successful codegen
-	if hash, err := codegen.HashFile(protoFile); err == nil {
-		codegen.WriteHash(projectDir, hash)
+	if *dryRunFlag {
+		diff, changed, err := out.diff()
+		if err != nil {
+			return err
+		}
+		summary := "Generated files are up to date"
+		if changed > 0 {
+			fmt.Print("\n" + diff + "\n")
+			files := "files"
+			if changed == 1 {
+				files = "file"
+			}
+			summary = fmt.Sprintf("%d generated %s would change (dry run, nothing written)", changed, files)
+		}
+		goli.Print(gox.Element("box", gox.Props{"direction": "row"},
+			gox.Element("text", gox.Props{"color": "green"},
+				gox.V("✓")),
+			gox.Element("text", nil,
+				gox.V(" "+summary))))
+	}
+
+	return nil
+}
+
+// watchMu keeps the runs of gapp codegen --watch, one per monorepo app,
+// from interleaving their output.
+var watchMu sync.Mutex
+
+// watchCodegen runs codegen with args, then again whenever the protos under
+// protoDir or the routes in routesDir change, until interrupted. Route
+// changes only regenerate the preload config. Each run is headed by label,
+// the project, unless it's empty.
+func watchCodegen(args []string, label, protoDir, routesDir string) error {
+	// run runs codegen with args, after a note on why if there's one
+	run := func(args []string, note string) {
+		watchMu.Lock()
+		defer watchMu.Unlock()
+		if note != "" {
+			goli.Print(gox.Element("text", nil,
+				gox.V("")))
+			goli.Print(gox.Element("text", gox.Props{"dim": true},
+				gox.V(time.Now().Format("15:04:05")+" "+note)))
+		}
+		if label != "" {
+			goli.Print(gox.Element("text", gox.Props{"bold": true},
+				gox.V(label)))
+		}
+		if err := RunCodegen(args); err != nil {
+			goli.Print(gox.Element("text", gox.Props{"color": "red"},
+				gox.V("  "+err.Error()+", waiting for changes")))
+		}
+	}
+	run(args, "")
+
+	watcher, err := WatchCodegenFiles(protoDir, routesDir, 300*time.Millisecond, func(changed []string) {
+		names := make([]string, len(changed))
+		protoChanged := false
+		for i, path := range changed {
+			names[i] = filepath.Base(path)
+			protoChanged = protoChanged || strings.HasSuffix(path, ".proto")
+		}
+		note := strings.Join(names, ", ") + " changed"
+		if protoChanged {
+			run(args, note)
+		} else {
+			run(append(slices.Clone(args), "--preload-only"), note)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("watching %s: %w", protoDir, err)
 	}
+	defer watcher.Close()
+	goli.Print(gox.Element("text", gox.Props{"dim": true},
+		gox.V("Watching "+protoDir+" and "+routesDir+" for changes (Ctrl+C to stop)")))
 
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	<-sigCh
 	return nil
 }
 
+// watchApps watches each app of a monorepo, as gapp codegen --watch does a
+// project, until interrupted.
+func watchApps(args []string, apps []string) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(apps))
+	for i, app := range apps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = RunCodegen(append(slices.Clone(args), "--project", app))
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// withoutFlag returns args without the boolean flag name, however it's
+// spelled.
+func withoutFlag(args []string, name string) []string {
+	var rest []string
+	for _, arg := range args {
+		flag, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if strings.HasPrefix(arg, "-") && flag == name {
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return rest
+}
+
+// codegenCacheKey returns the key codegen's output is cached under, hashing
+// its inputs: the compiled protos, imports and comments included, the flags,
+// and the versions of gapp itself, whose generators and protoc-gen-go run in
+// process, and of the plugins it runs, --go-plugin's included.
+func codegenCacheKey(fs *flag.FlagSet, req *pluginpb.CodeGeneratorRequest, tsPlugin string, plugins []codegen.Plugin, natives []nativeTarget) (string, error) {
+	key := codegen.NewCacheKey()
+	if err := key.AddRequest(req); err != nil {
+		return "", err
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		switch f.Name {
+		// Flags that don't change what's generated. The routes only feed the
+		// preload config, which is regenerated on every run.
+		case "project", "force", "watch", "dry-run", "allow-breaking", "preload-only", "skip-ts", "routes-dir", "preload-out", "infer-route-paths":
+		default:
+			key.Add("--"+f.Name, f.Value.String())
+		}
+	})
+	if gapp, err := os.Executable(); err == nil {
+		key.AddBinary("gapp", gapp)
+	}
+	if tsPlugin != "" {
+		key.AddBinary("ts-proto", tsPlugin)
+	}
+	if goPlugin := fs.Lookup("go-plugin").Value.String(); goPlugin != "" {
+		if path, err := exec.LookPath(goPlugin); err == nil {
+			key.AddBinary("protoc-gen-go", path)
+		}
+	}
+	for _, plugin := range plugins {
+		key.Add("plugin", fmt.Sprintf("%+v", plugin))
+		if plugin.Path != "" {
+			if path, err := exec.LookPath(plugin.Path); err == nil {
+				key.AddBinary(plugin.Label(), path)
+			}
+		}
+	}
+	for _, target := range natives {
+		if path, err := target.Plugin(); err == nil {
+			key.AddBinary(target.Name, path)
+		}
+	}
+	return key.String(), nil
+}
+
+// docsOutputs returns the API reference files gapp codegen --docs writes
+// into dir, one per comma-separated format, or none without --docs.
+func docsOutputs(docs bool, formats, dir string) ([]string, error) {
+	if !docs {
+		return nil, nil
+	}
+	var outs []string
+	for _, format := range splitList(formats) {
+		switch format {
+		case "openapi":
+			outs = append(outs, filepath.Join(dir, "openapi.json"))
+		case "html":
+			outs = append(outs, filepath.Join(dir, "index.html"))
+		default:
+			return nil, fmt.Errorf("unknown --docs-format %q, expected openapi or html", format)
+		}
+	}
+	return outs, nil
+}
+
+// tsProtoParams are the ts-proto parameters gapp's client code and
+// generators rely on. The well-known types are spelled out: imported ones,
+// such as google/protobuf/timestamp.proto, get their own modules next to the
+// app's, and Timestamp fields are Dates, as the zod schemas expect. Duration
+// stays a message, Struct and Value become plain objects and values, and the
+// wrappers optional primitives.
+const tsProtoParams = "outputServices=default,esModuleInterop=true,useOptionals=messages,emitImportedFiles=true,useDate=true"
+
+// pluginParams appends the user's parameters to gapp's, so they can add or
+// override options.
+func pluginParams(defaults, extra string) string {
+	if extra == "" {
+		return defaults
+	}
+	return defaults + "," + extra
+}
+
 func findTsProtoPlugin(tsOutDir string) (string, error) {
-	// Walk up from ts output dir to find client/node_modules
-	dir := tsOutDir
+	// Walk up from ts output dir to find client/node_modules, or the
+	// node_modules of a monorepo's npm workspaces
+	dir, _ := filepath.Abs(tsOutDir)
 	for dir != "/" && dir != "." {
 		candidate := filepath.Join(dir, "node_modules", ".bin", "protoc-gen-ts_proto")
 		if _, err := os.Stat(candidate); err == nil {
@@ -182,5 +681,5 @@ func findTsProtoPlugin(tsOutDir string) (string, error) {
 	if err == nil {
 		return path, nil
 	}
-	return "", fmt.Errorf("protoc-gen-ts_proto not found. Run: cd client && npm install")
+	return "", fmt.Errorf("protoc-gen-ts_proto not found. Run: cd client && %s install", scaffold.DetectPackageManager(tsOutDir))
 }
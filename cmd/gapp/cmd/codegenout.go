@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/germtb/gapp/cmd/gapp/internal/codegen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// codegenOutput is where gapp codegen writes: the disk, or in a dry run
+// memory, so the files can be diffed against the disk instead.
+type codegenOutput struct {
+	dryRun bool
+	files  map[string]string // the files a dry run would write, by path
+}
+
+// writeFile writes data to path, creating its directory.
+func (o *codegenOutput) writeFile(path string, data []byte) error {
+	if o.dryRun {
+		if o.files == nil {
+			o.files = make(map[string]string)
+		}
+		o.files[filepath.Clean(path)] = string(data)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// writeResponse writes the files of a plugin's response into outDir.
+func (o *codegenOutput) writeResponse(resp *pluginpb.CodeGeneratorResponse, outDir string) error {
+	if !o.dryRun {
+		_, err := codegen.WriteResponse(resp, outDir)
+		return err
+	}
+	for _, file := range resp.File {
+		if err := o.writeFile(filepath.Join(outDir, file.GetName()), []byte(file.GetContent())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diff returns the unified diffs of a dry run's files against the disk, and
+// how many files differ.
+func (o *codegenOutput) diff() (string, int, error) {
+	var b strings.Builder
+	changed := 0
+	for _, path := range slices.Sorted(maps.Keys(o.files)) {
+		have, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return "", 0, err
+		}
+		if diff := unifiedDiff(filepath.ToSlash(path), string(have), o.files[path]); diff != "" {
+			if changed > 0 {
+				b.WriteByte('\n')
+			}
+			b.WriteString(diff)
+			changed++
+		}
+	}
+	return b.String(), changed, nil
+}
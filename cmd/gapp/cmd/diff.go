@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is how many unchanged lines surround each change.
+const diffContext = 3
+
+// maxDiffCells bounds the pairs of lines compared with each other, past
+// which the changed lines are matched greedily instead, in a longer diff.
+const maxDiffCells = 4 << 20
+
+// unifiedDiff returns a unified diff turning before into after, or "" if
+// they're equal. Lines the files start and end with in common are set aside,
+// and the rest is compared every line with every other, which suits the
+// localized changes of the files gapp owns and generates.
+func unifiedDiff(name, before, after string) string {
+	if before == after {
+		return ""
+	}
+	a := splitLines(before)
+	b := splitLines(after)
+
+	type line struct {
+		op   byte // ' ', '-' or '+'
+		text string
+		a, b int // line numbers before and after, 1-based
+	}
+	var lines []line
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		lines = append(lines, line{' ', a[prefix], prefix + 1, prefix + 1})
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	// lcs[i][j] is the longest common subsequence of midA[i:] and midB[j:],
+	// left nil when they're too long to compare
+	var lcs [][]int
+	if len(midA)*len(midB) <= maxDiffCells {
+		lcs = make([][]int, len(midA)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(midB)+1)
+		}
+		for i := len(midA) - 1; i >= 0; i-- {
+			for j := len(midB) - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+	}
+	common := func(i, j int) int {
+		if lcs == nil {
+			return 0
+		}
+		return lcs[i][j]
+	}
+
+	i, j := 0, 0
+	for i < len(midA) || j < len(midB) {
+		switch {
+		case i < len(midA) && j < len(midB) && midA[i] == midB[j]:
+			lines = append(lines, line{' ', midA[i], prefix + i + 1, prefix + j + 1})
+			i++
+			j++
+		case i < len(midA) && (j == len(midB) || common(i+1, j) >= common(i, j+1)):
+			lines = append(lines, line{'-', midA[i], prefix + i + 1, prefix + j + 1})
+			i++
+		default:
+			lines = append(lines, line{'+', midB[j], prefix + i + 1, prefix + j + 1})
+			j++
+		}
+	}
+	for k := 0; k < suffix; k++ {
+		lines = append(lines, line{' ', a[len(a)-suffix+k], len(a) - suffix + k + 1, len(b) - suffix + k + 1})
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- a/%s\n+++ b/%s\n", name, name)
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+		// Grow the hunk while changes are within twice the context
+		from := max(start-diffContext, 0)
+		end := start
+		for k := start; k < len(lines) && k <= end+2*diffContext; k++ {
+			if lines[k].op != ' ' {
+				end = k
+			}
+		}
+		to := min(end+diffContext+1, len(lines))
+
+		hunk := lines[from:to]
+		aCount, bCount := 0, 0
+		for _, l := range hunk {
+			if l.op != '+' {
+				aCount++
+			}
+			if l.op != '-' {
+				bCount++
+			}
+		}
+		aStart, bStart := hunk[0].a, hunk[0].b
+		// An empty range starts at the line before it
+		if aCount == 0 {
+			aStart--
+		}
+		if bCount == 0 {
+			bStart--
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+		for _, l := range hunk {
+			out.WriteByte(l.op)
+			out.WriteString(l.text)
+			out.WriteByte('\n')
+		}
+		start = to
+	}
+	return out.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
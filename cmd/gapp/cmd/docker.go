@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// optionalValue is a flag that can be given bare (--docker) or with a value
+// (--docker=myapp:1.2).
+type optionalValue struct {
+	set   bool
+	value string
+}
+
+func (v *optionalValue) String() string { return v.value }
+
+func (v *optionalValue) Set(s string) error {
+	v.set, v.value = s != "false", ""
+	if v.set && s != "true" {
+		v.value = s
+	}
+	return nil
+}
+
+// IsBoolFlag lets the flag be given without a value.
+func (v *optionalValue) IsBoolFlag() bool { return true }
+
+// dockerBaseImage runs the static server binary without a shell or package
+// manager.
+const dockerBaseImage = "gcr.io/distroless/static-debian12:nonroot"
+
+// dockerfile returns the Dockerfile for a build output directory.
+func dockerfile(embedded bool) string {
+	var b strings.Builder
+	b.WriteString("# Generated by gapp build --docker\n")
+	fmt.Fprintf(&b, "FROM %s\n", dockerBaseImage)
+	b.WriteString("WORKDIR /app\n")
+	b.WriteString("COPY server ./server\n")
+	if !embedded {
+		b.WriteString("COPY public ./public\n")
+	}
+	b.WriteString("EXPOSE 8080\n")
+	b.WriteString("USER nonroot\n")
+	b.WriteString("ENTRYPOINT [\"/app/server\"]\n")
+	return b.String()
+}
+
+var invalidImageChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// projectSlug names build artifacts after the project directory, in the
+// lowercase form image names (and unit files) accept.
+func projectSlug(projectDir string) string {
+	name := invalidImageChars.ReplaceAllString(strings.ToLower(filepath.Base(mustAbs(projectDir))), "-")
+	name = strings.Trim(name, "-._")
+	if name == "" {
+		name = "app"
+	}
+	return name
+}
+
+// defaultImageTag names the image after the project directory.
+func defaultImageTag(projectDir string) string {
+	return projectSlug(projectDir) + ":latest"
+}
+
+// imageBuildCommand returns the command building dir into an image tagged
+// tag, using docker, or the daemonless podman or buildah.
+func imageBuildCommand(dir, tag string) (*exec.Cmd, error) {
+	for _, builder := range []string{"docker", "podman", "buildah"} {
+		path, err := exec.LookPath(builder)
+		if err != nil {
+			continue
+		}
+		build := "build"
+		if builder == "buildah" {
+			build = "bud"
+		}
+		return exec.Command(path, build, "-t", tag, dir), nil
+	}
+	return nil, fmt.Errorf("no image builder found (install docker, podman or buildah)")
+}
+
+// writeDockerfile writes the Dockerfile into the build output.
+func writeDockerfile(outputDir string, embedded bool) error {
+	return os.WriteFile(filepath.Join(outputDir, "Dockerfile"), []byte(dockerfile(embedded)), 0644)
+}
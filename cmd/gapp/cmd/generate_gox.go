@@ -0,0 +1,308 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/germtb/goli"
+	"github.com/germtb/gox"
+
+	"github.com/germtb/gapp/cmd/gapp/scaffold"
+)
+
+type GenerateStepProps struct {
+	Label   string
+	Success bool
+	Err     string
+}
+
+func GenerateStep(props GenerateStepProps) gox.VNode {
+	if props.Success {
+		return gox.Element("box", gox.Props{"direction": "row"},
+			gox.Element("text", gox.Props{"color": "green"},
+				gox.V("✓")),
+			gox.Element("text", nil,
+				gox.V(" "+props.Label)))
+	}
+	return gox.Element("box", gox.Props{"direction": "column"},
+		gox.Element("box", gox.Props{"direction": "row"},
+			gox.Element("text", gox.Props{"color": "red"},
+				gox.V("✗")),
+			gox.Element("text", nil,
+				gox.V(" "+props.Label))),
+		gox.Element("text", gox.Props{"dim": true},
+			gox.V("    "+props.Err)))
+}
+
+func RunGenerate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: gapp generate route|rpc|store ...")
+	}
+	switch args[0] {
+	case "route":
+		return generateRoute(args[1:])
+	case "rpc":
+		return generateRPC(args[1:])
+	case "store":
+		return generateStore(args[1:])
+	default:
+		return fmt.Errorf("unknown generator %q (want route, rpc or store)", args[0])
+	}
+}
+
+// generateRoute creates a route file, registers it with the client router
+// and refreshes the preload config.
+func generateRoute(args []string) error {
+	fs := flag.NewFlagSet("generate route", flag.ExitOnError)
+	projectFlag := fs.String("project", ".", "Project directory")
+	nameFlag := fs.String("name", "", "Component name (default: derived from the path)")
+	rpcFlag := fs.String("rpc", "", "Comma-separated RPCs to preload, passed the route params")
+	positional, flagArgs := splitArgs(fs, args)
+	if err := fs.Parse(flagArgs); err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: gapp generate route <path> [--name Name] [--rpc Method,...]")
+	}
+
+	projectDir := *projectFlag
+	config, err := scaffold.DetectConfig(projectDir)
+	if err != nil {
+		return fmt.Errorf("not a gapp project: %w", err)
+	}
+
+	var rpcs []string
+	for _, method := range strings.Split(*rpcFlag, ",") {
+		if method = strings.TrimSpace(method); method != "" {
+			rpcs = append(rpcs, method)
+		}
+	}
+	data, err := newRouteTemplateData(positional[0], *nameFlag, rpcs)
+	if err != nil {
+		return err
+	}
+	data.Config = config
+
+	// Step 1: the route file, and page component if the framework has one
+	srcDir := filepath.Join(projectDir, "client", "src")
+	_, routesDir, err := projectCodegenPaths(projectDir)
+	if err != nil {
+		return err
+	}
+	files, err := routeFiles(&data, config.Framework, routesDir, srcDir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if _, err := os.Stat(file.path); err == nil {
+			return fmt.Errorf("%s already exists", file.path)
+		}
+	}
+	for _, file := range files {
+		content, err := scaffold.RenderGenerator(file.template, data)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(file.path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(file.path, []byte(content), 0644); err != nil {
+			goli.Print(GenerateStep(GenerateStepProps{Label: "Create " + file.path, Success: false, Err: err.Error()}))
+			return err
+		}
+		goli.Print(GenerateStep(GenerateStepProps{Label: "Create " + file.path, Success: true, Err: ""}))
+	}
+
+	// Step 2: register it with the router in main.ts(x)
+	mainFile := filepath.Join(projectDir, scaffold.MainFile(config.Framework))
+	main, err := os.ReadFile(mainFile)
+	if err != nil {
+		return err
+	}
+	routesImport, err := filepath.Rel(srcDir, routesDir)
+	if err != nil {
+		return err
+	}
+	registered, ok := registerRoute(string(main), data, config.Framework, "./"+filepath.ToSlash(routesImport))
+	if !ok {
+		goli.Print(GenerateStep(GenerateStepProps{Label: "Register in " + mainFile, Success: false, Err: "no routes array found, add this entry yourself:\n" + routeEntry(data, config.Framework)}))
+	} else if err := os.WriteFile(mainFile, []byte(registered), 0644); err != nil {
+		goli.Print(GenerateStep(GenerateStepProps{Label: "Register in " + mainFile, Success: false, Err: err.Error()}))
+		return err
+	} else {
+		goli.Print(GenerateStep(GenerateStepProps{Label: "Register in " + mainFile, Success: true, Err: ""}))
+	}
+
+	// Step 3: preload the route's RPCs
+	if len(rpcs) > 0 {
+		return RunCodegen(append(projectCodegenArgs(projectDir), "--preload-only"))
+	}
+	return nil
+}
+
+// generateRPC adds a method and its messages to the service proto, runs
+// codegen and creates a handler stub for it in server/.
+func generateRPC(args []string) error {
+	fs := flag.NewFlagSet("generate rpc", flag.ExitOnError)
+	projectFlag := fs.String("project", ".", "Project directory")
+	requestFlag := fs.String("request", "", "Request fields, e.g. userId:string,tags:[]string")
+	responseFlag := fs.String("response", "", "Response fields, e.g. user:User")
+	positional, flagArgs := splitArgs(fs, args)
+	if err := fs.Parse(flagArgs); err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: gapp generate rpc <Method> [--request name:type,...] [--response name:type,...]")
+	}
+	method := positional[0]
+	if !isIdentifier(method) || method[0] < 'A' || method[0] > 'Z' {
+		return fmt.Errorf("invalid RPC method %q (want a PascalCase identifier)", method)
+	}
+
+	projectDir := *projectFlag
+	config, err := scaffold.DetectConfig(projectDir)
+	if err != nil {
+		return fmt.Errorf("not a gapp project: %w", err)
+	}
+	request, err := parseRPCFields(*requestFlag)
+	if err != nil {
+		return fmt.Errorf("--request: %w", err)
+	}
+	response, err := parseRPCFields(*responseFlag)
+	if err != nil {
+		return fmt.Errorf("--response: %w", err)
+	}
+
+	handlerFile := filepath.Join(projectDir, "server", rpcFileName(method))
+	if _, err := os.Stat(handlerFile); err == nil {
+		return fmt.Errorf("%s already exists", handlerFile)
+	}
+
+	// Step 1: the proto, left untouched if the result doesn't compile
+	protos, err := projectProtos(projectDir)
+	if err != nil {
+		return err
+	}
+	protoFile, err := serviceProtoFile(protos)
+	if err != nil {
+		return err
+	}
+	original, err := os.ReadFile(protoFile)
+	if err != nil {
+		return err
+	}
+	updated, err := addRPC(string(original), method, request, response)
+	if err != nil {
+		goli.Print(GenerateStep(GenerateStepProps{Label: "Update " + protoFile, Success: false, Err: err.Error()}))
+		return err
+	}
+	if err := os.WriteFile(protoFile, []byte(updated), 0644); err != nil {
+		return err
+	}
+	if _, err := protos.Compile(); err != nil {
+		os.WriteFile(protoFile, original, 0644)
+		goli.Print(GenerateStep(GenerateStepProps{Label: "Update " + protoFile, Success: false, Err: err.Error()}))
+		return fmt.Errorf("proto compilation failed: %w", err)
+	}
+	goli.Print(GenerateStep(GenerateStepProps{Label: "Add rpc " + method + " to " + protoFile, Success: true, Err: ""}))
+
+	// Step 2: the handler stub
+	data := rpcTemplateData{Module: config.Module, Method: method, Handler: "handle" + method}
+	content, err := scaffold.RenderGenerator("server/handler.go.tmpl", data)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(handlerFile, []byte(content), 0644); err != nil {
+		goli.Print(GenerateStep(GenerateStepProps{Label: "Create " + handlerFile, Success: false, Err: err.Error()}))
+		return err
+	}
+	goli.Print(GenerateStep(GenerateStepProps{Label: "Create " + handlerFile, Success: true, Err: ""}))
+
+	// Step 3: regenerate the Go and TypeScript code
+	if err := RunCodegen(projectCodegenArgs(projectDir)); err != nil {
+		return fmt.Errorf("codegen failed: %w", err)
+	}
+
+	fmt.Println()
+	fmt.Println("Register the handler with the dispatcher in server/main.go:")
+	fmt.Println()
+	fmt.Printf("\tdispatcher.Unary[%q] = %s\n", method, data.Handler)
+	return nil
+}
+
+// generateStore creates a client store keeping the results of some RPCs, and
+// imports it in main.ts(x) so it's registered before preloaded data arrives.
+func generateStore(args []string) error {
+	fs := flag.NewFlagSet("generate store", flag.ExitOnError)
+	projectFlag := fs.String("project", ".", "Project directory")
+	rpcFlag := fs.String("rpc", "", "Comma-separated RPCs whose results the store keeps (default: those mentioning the name)")
+	positional, flagArgs := splitArgs(fs, args)
+	if err := fs.Parse(flagArgs); err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: gapp generate store <Name> [--rpc Method,...]")
+	}
+
+	projectDir := *projectFlag
+	config, err := scaffold.DetectConfig(projectDir)
+	if err != nil {
+		return fmt.Errorf("not a gapp project: %w", err)
+	}
+	protos, err := projectProtos(projectDir)
+	if err != nil {
+		return err
+	}
+	req, err := protos.Compile()
+	if err != nil {
+		return fmt.Errorf("proto compilation failed: %w", err)
+	}
+
+	var rpcs []string
+	for _, method := range strings.Split(*rpcFlag, ",") {
+		if method = strings.TrimSpace(method); method != "" {
+			rpcs = append(rpcs, method)
+		}
+	}
+	data, err := newStoreTemplateData(positional[0], rpcs, req)
+	if err != nil {
+		return err
+	}
+	data.React = config.Framework == scaffold.FrameworkReact
+
+	// Step 1: the store file
+	srcDir := filepath.Join(projectDir, "client", "src")
+	storeFile := filepath.Join(srcDir, "stores", data.Store+".ts")
+	if _, err := os.Stat(storeFile); err == nil {
+		return fmt.Errorf("%s already exists", storeFile)
+	}
+	content, err := scaffold.RenderGenerator("store.ts.tmpl", data)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(storeFile), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(storeFile, []byte(content), 0644); err != nil {
+		goli.Print(GenerateStep(GenerateStepProps{Label: "Create " + storeFile, Success: false, Err: err.Error()}))
+		return err
+	}
+	goli.Print(GenerateStep(GenerateStepProps{Label: "Create " + storeFile, Success: true, Err: ""}))
+
+	// Step 2: import it in main.ts(x)
+	mainFile := filepath.Join(projectDir, scaffold.MainFile(config.Framework))
+	main, err := os.ReadFile(mainFile)
+	if err != nil {
+		return err
+	}
+	importLine := "import \"./stores/" + data.Store + "\";\n"
+	if err := os.WriteFile(mainFile, []byte(insertImport(string(main), importLine, "\"./stores/")), 0644); err != nil {
+		goli.Print(GenerateStep(GenerateStepProps{Label: "Register in " + mainFile, Success: false, Err: err.Error()}))
+		return err
+	}
+	goli.Print(GenerateStep(GenerateStepProps{Label: "Register in " + mainFile, Success: true, Err: ""}))
+	return nil
+}
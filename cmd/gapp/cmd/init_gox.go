@@ -5,11 +5,13 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/germtb/goli"
 	"github.com/germtb/gox"
 
+	"github.com/germtb/gapp/cmd/gapp/internal/codegen"
 	"github.com/germtb/gapp/cmd/gapp/scaffold"
 )
 
@@ -17,6 +19,9 @@ type InitResultProps struct {
 	Name      string
 	Framework scaffold.Framework
 	Files     []string
+	Cd        string   // the directory to work from, if not the current one
+	Setup     []string // commands left to run, with --no-install
+	Run       string
 }
 
 func InitResult(props InitResultProps) gox.VNode {
@@ -35,14 +40,58 @@ func InitResult(props InitResultProps) gox.VNode {
 			gox.V("")),
 		gox.Element("text", gox.Props{"bold": true},
 			gox.V("  Next steps:")),
-		gox.Element("box", gox.Props{"direction": "row"},
+		gox.V(gox.When(props.Cd != "", gox.Element("box", gox.Props{"direction": "row"},
 			gox.Element("text", gox.Props{"dim": true},
 				gox.V("    cd ")),
 			gox.Element("text", gox.Props{"color": "cyan"},
-				gox.V(props.Name))),
+				gox.V(props.Cd))))),
+		gox.V(gox.Map(props.Setup, func(command string) gox.VNode {
+			return gox.Element("box", gox.Props{"direction": "row"},
+				gox.Element("text", gox.Props{"dim": true},
+					gox.V("    "+command)))
+		})),
 		gox.Element("box", gox.Props{"direction": "row"},
 			gox.Element("text", gox.Props{"dim": true},
-				gox.V("    gapp run"))))
+				gox.V("    "+props.Run))))
+}
+
+type InitConflictsProps struct {
+	Dir       string
+	Conflicts []string // the files gapp init would overwrite
+	Force     bool
+}
+
+// InitConflicts reports the files of a non-empty directory that init
+// overwrites with --force, or would overwrite without it.
+func InitConflicts(props InitConflictsProps) gox.VNode {
+	if props.Force {
+		return gox.Element("box", gox.Props{"direction": "column"},
+			gox.Element("box", gox.Props{"direction": "row"},
+				gox.Element("text", gox.Props{"color": "yellow"},
+					gox.V("!")),
+				gox.Element("text", nil,
+					gox.V(" Overwrote "+fmt.Sprint(len(props.Conflicts))+" existing files in "+props.Dir+"/"))),
+			gox.V(gox.Map(props.Conflicts, func(f string) gox.VNode {
+				return gox.Element("text", gox.Props{"dim": true},
+					gox.V("    "+f))
+			})))
+	}
+	return gox.Element("box", gox.Props{"direction": "column"},
+		gox.Element("box", gox.Props{"direction": "row"},
+			gox.Element("text", gox.Props{"color": "red"},
+				gox.V("✗")),
+			gox.Element("text", nil,
+				gox.V(" Directory "+props.Dir+" isn't empty"))),
+		gox.V(gox.When(len(props.Conflicts) > 0, gox.Element("text", nil,
+			gox.V("  These files would be overwritten:")))),
+		gox.V(gox.Map(props.Conflicts, func(f string) gox.VNode {
+			return gox.Element("text", gox.Props{"dim": true},
+				gox.V("    "+f))
+		})),
+		gox.Element("text", nil,
+			gox.V("")),
+		gox.Element("text", gox.Props{"dim": true},
+			gox.V("  Run gapp init again with --force to scaffold into it anyway")))
 }
 
 type InitHintProps struct {
@@ -66,6 +115,14 @@ func InitHint(props InitHintProps) gox.VNode {
 			gox.V("  gapp init "+name+" --framework react    # React + TypeScript")),
 		gox.Element("text", gox.Props{"dim": true},
 			gox.V("  gapp init "+name+" --framework vanilla  # Plain TypeScript")),
+		gox.Element("text", gox.Props{"dim": true},
+			gox.V("  gapp init "+name+" --framework svelte   # Svelte 5")),
+		gox.Element("text", gox.Props{"dim": true},
+			gox.V("  gapp init "+name+" --framework vue      # Vue 3")),
+		gox.Element("text", gox.Props{"dim": true},
+			gox.V("  gapp init "+name+" --framework solid    # SolidJS")),
+		gox.Element("text", gox.Props{"dim": true},
+			gox.V("  gapp init "+name+" --template <repo>    # Your own template")),
 		gox.Element("text", gox.Props{"dim": true},
 			gox.V("  gapp init "+name+" -y                   # Default (react)")))
 }
@@ -83,8 +140,20 @@ func InitError(props InitErrorProps) gox.VNode {
 }
 
 func RunInit(args []string) error {
-	var name, module, framework string
-	var skipConfirm bool
+	var name, module, framework, template, layout, cacheDir, css, pm string
+	var skipConfirm, noInstall, offline, withAuth, force bool
+
+	// Without flags, a terminal gets the wizard rather than the hint
+	if len(args) <= 1 && (len(args) == 0 || !strings.HasPrefix(args[0], "-")) && goli.IsTerminal(goli.Stdin()) && goli.IsTerminal(goli.Stdout()) {
+		if len(args) == 1 {
+			name = args[0]
+		}
+		wizardArgs, err := runInitWizard(name)
+		if err != nil {
+			return err
+		}
+		return RunInit(wizardArgs)
+	}
 
 	// Parse args manually so flags can appear before or after the name
 	for i := 0; i < len(args); i++ {
@@ -99,6 +168,39 @@ func RunInit(args []string) error {
 			if i < len(args) {
 				framework = args[i]
 			}
+		case "--template":
+			i++
+			if i < len(args) {
+				template = args[i]
+			}
+		case "--layout":
+			i++
+			if i < len(args) {
+				layout = args[i]
+			}
+		case "--css":
+			i++
+			if i < len(args) {
+				css = args[i]
+			}
+		case "--cache":
+			i++
+			if i < len(args) {
+				cacheDir = args[i]
+			}
+		case "--pm":
+			i++
+			if i < len(args) {
+				pm = args[i]
+			}
+		case "--no-install":
+			noInstall = true
+		case "--offline":
+			offline = true
+		case "--with-auth":
+			withAuth = true
+		case "--force":
+			force = true
 		case "-y":
 			skipConfirm = true
 		default:
@@ -113,36 +215,87 @@ func RunInit(args []string) error {
 	}
 
 	if name == "" {
-		goli.Print(InitError(InitErrorProps{Err: fmt.Errorf("usage: gapp init <name> --framework react|vanilla")}))
+		goli.Print(InitError(InitErrorProps{Err: fmt.Errorf("usage: gapp init <name> --framework react|vanilla|svelte|vue|solid")}))
 		return fmt.Errorf("missing project name")
 	}
 
-	if module == "" {
-		module = name
+	switch {
+	case layout != "" && layout != "standard" && layout != "monorepo":
+		goli.Print(InitError(InitErrorProps{Err: fmt.Errorf("unknown layout %q (use standard or monorepo)", layout)}))
+		return fmt.Errorf("unknown layout %q", layout)
+	case layout == "monorepo" && template != "":
+		goli.Print(InitError(InitErrorProps{Err: fmt.Errorf("--template can't be combined with --layout monorepo")}))
+		return fmt.Errorf("--template can't be combined with --layout monorepo")
+	case css != "" && !slices.Contains(scaffold.CSSOptions, scaffold.CSS(css)):
+		goli.Print(InitError(InitErrorProps{Err: fmt.Errorf("unknown css %q (use tailwind, vanilla-extract or plain)", css)}))
+		return fmt.Errorf("unknown css %q", css)
+	case css != "" && template != "":
+		goli.Print(InitError(InitErrorProps{Err: fmt.Errorf("--css can't be combined with --template")}))
+		return fmt.Errorf("--css can't be combined with --template")
+	case withAuth && template != "":
+		goli.Print(InitError(InitErrorProps{Err: fmt.Errorf("--with-auth can't be combined with --template")}))
+		return fmt.Errorf("--with-auth can't be combined with --template")
+	case pm != "" && !slices.Contains(scaffold.PackageManagers, scaffold.PackageManager(pm)):
+		goli.Print(InitError(InitErrorProps{Err: fmt.Errorf("unknown package manager %q (use npm, pnpm, yarn or bun)", pm)}))
+		return fmt.Errorf("unknown package manager %q", pm)
+	case pm == "bun" && offline:
+		goli.Print(InitError(InitErrorProps{Err: fmt.Errorf("bun can't install offline, drop --offline or pick another --pm")}))
+		return fmt.Errorf("bun can't install offline")
+	case noInstall && (offline || cacheDir != ""):
+		goli.Print(InitError(InitErrorProps{Err: fmt.Errorf("--no-install can't be combined with --offline or --cache")}))
+		return fmt.Errorf("--no-install can't be combined with --offline or --cache")
 	}
 
+	// From a monorepo's root, --layout monorepo adds an app to it
+	apps, inWorkspace := workspaceApps(".")
+	addApp := layout == "monorepo" && inWorkspace
 	dir := filepath.Join(".", name)
-	if _, err := os.Stat(dir); err == nil {
-		goli.Print(InitError(InitErrorProps{Err: fmt.Errorf("directory %s already exists", name)}))
-		return fmt.Errorf("directory %s already exists", name)
+	switch {
+	case addApp && dir == ".":
+		goli.Print(InitError(InitErrorProps{Err: fmt.Errorf("an app added to a workspace needs a name")}))
+		return fmt.Errorf("an app added to a workspace needs a name")
+	case addApp:
+		dir = filepath.Join("apps", name)
+	case dir == ".":
+		// gapp init . scaffolds into the current directory, named after it
+		name = projectName(dir)
+	}
+	if module == "" && addApp {
+		module = workspaceModule(apps[0])
+	} else if module == "" {
+		module = name
+	}
+	// An app added to a monorepo installs with the workspace's package manager
+	packageManager := scaffold.PackageManager(pm)
+	if packageManager == "" && addApp {
+		packageManager = scaffold.DetectPackageManager(".")
+	} else if packageManager == "" {
+		packageManager = scaffold.PackageManagerNpm
+	}
+
+	// An existing directory, such as a repository's checkout, is scaffolded
+	// into, taking --force if anything besides .git is in it
+	inPlace, occupied := false, false
+	if info, err := os.Stat(dir); err == nil {
+		if addApp || !info.IsDir() {
+			goli.Print(InitError(InitErrorProps{Err: fmt.Errorf("%s already exists", dir)}))
+			return fmt.Errorf("%s already exists", dir)
+		}
+		inPlace, occupied = true, len(existingEntries(dir)) > 0
 	}
 
 	// Determine framework
-	var fw scaffold.Framework
-	switch framework {
-	case "react":
-		fw = scaffold.FrameworkReact
-	case "vanilla":
-		fw = scaffold.FrameworkVanilla
-	case "":
-		if skipConfirm {
+	fw := scaffold.Framework(framework)
+	switch {
+	case framework == "":
+		if skipConfirm || template != "" {
 			fw = scaffold.FrameworkReact
 		} else {
 			goli.Print(InitHint(InitHintProps{Name: name}))
 			return fmt.Errorf("missing --framework flag")
 		}
-	default:
-		goli.Print(InitError(InitErrorProps{Err: fmt.Errorf("unknown framework %q (use react or vanilla)", framework)}))
+	case !slices.Contains(scaffold.Frameworks, fw):
+		goli.Print(InitError(InitErrorProps{Err: fmt.Errorf("unknown framework %q (use react, vanilla, svelte, vue or solid)", framework)}))
 		return fmt.Errorf("unknown framework %q", framework)
 	}
 
@@ -150,46 +303,139 @@ func RunInit(args []string) error {
 	gappClientPath, gappReactPath, gappServerPath := resolveGappPackages()
 
 	config := scaffold.ProjectConfig{
-		Name:          name,
-		Module:        module,
-		Framework:     fw,
+		Name:           name,
+		Module:         module,
+		Framework:      fw,
 		GappClientPath: gappClientPath,
 		GappReactPath:  gappReactPath,
 		GappServerPath: gappServerPath,
+		Auth:           withAuth,
+		CSS:            scaffold.CSS(css),
+		PackageManager: packageManager,
 	}
 
-	files, err := scaffold.Generate(config, dir)
+	// appDir is the gapp project, npmDir where client dependencies install:
+	// a monorepo's root, for its workspaces
+	appDir, npmDir := dir, filepath.Join(dir, "client")
+	result := InitResultProps{Name: name, Cd: name, Run: "gapp run"}
+	if dir == "." {
+		result.Cd = ""
+	}
+	// Into an existing directory, the project is generated next to it first,
+	// to find the files it would overwrite
+	genDir := dir
+	if inPlace {
+		staging, err := os.MkdirTemp(dir, ".gapp-init-")
+		if err != nil {
+			goli.Print(InitError(InitErrorProps{Err: err}))
+			return err
+		}
+		defer os.RemoveAll(staging)
+		genDir = staging
+	}
+	var files []string
+	var err error
+	switch {
+	case template != "":
+		files, err = generateFromTemplate(config, template, genDir)
+		if err == nil {
+			// The template decides the client, whatever --framework said
+			if detected, detectErr := scaffold.DetectConfig(genDir); detectErr == nil {
+				fw = detected.Framework
+			}
+		}
+	case addApp:
+		files, err = scaffold.AddWorkspaceApp(config, ".", name)
+		appDir, npmDir = dir, "."
+		result = InitResultProps{Name: dir, Run: "gapp run " + dir}
+	case layout == "monorepo":
+		files, err = scaffold.GenerateWorkspace(config, genDir, scaffold.WorkspaceApp)
+		appDir, npmDir = filepath.Join(dir, "apps", scaffold.WorkspaceApp), dir
+	default:
+		files, err = scaffold.Generate(config, genDir)
+	}
 	if err != nil {
 		goli.Print(InitError(InitErrorProps{Err: err}))
 		return err
 	}
+	if inPlace {
+		var conflicts []string
+		for _, f := range files {
+			if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(f))); err == nil {
+				conflicts = append(conflicts, f)
+			}
+		}
+		if occupied && !force {
+			goli.Print(InitConflicts(InitConflictsProps{Dir: dir, Conflicts: conflicts, Force: false}))
+			return fmt.Errorf("directory %s isn't empty", dir)
+		}
+		if err := moveInto(genDir, dir); err != nil {
+			goli.Print(InitError(InitErrorProps{Err: err}))
+			return err
+		}
+		if len(conflicts) > 0 {
+			goli.Print(InitConflicts(InitConflictsProps{Dir: dir, Conflicts: conflicts, Force: true}))
+		}
+	}
 
-	// Run npm install in client/
-	goli.Print(gox.Element("box", gox.Props{"direction": "row"},
-		gox.Element("text", gox.Props{"dim": true},
-			gox.V("  Installing client dependencies..."))))
-	npmCmd := exec.Command("npm", "install")
-	npmCmd.Dir = filepath.Join(dir, "client")
-	npmCmd.Stdout = nil
-	npmCmd.Stderr = os.Stderr
-	if err := npmCmd.Run(); err != nil {
+	if addApp {
+		workCmd := exec.Command("go", "work", "use", "./"+filepath.ToSlash(filepath.Join(dir, "server")))
+		workCmd.Stderr = os.Stderr
+		if err := workCmd.Run(); err != nil {
+			goli.Print(gox.Element("box", gox.Props{"direction": "row"},
+				gox.Element("text", gox.Props{"color": "yellow"},
+					gox.V("!")),
+				gox.Element("text", nil,
+					gox.V(" go work use failed: "+err.Error()))))
+		}
+	}
+
+	if noInstall {
+		result.Setup = setupCommands(packageManager, result.Cd, npmDir, appDir)
+		goli.Print(InitResult(InitResultProps{Name: result.Name, Framework: fw, Files: files, Cd: result.Cd, Setup: result.Setup, Run: result.Run}))
+		return nil
+	}
+	installArgs, err := installEnv(packageManager, offline, cacheDir)
+	if err != nil {
+		goli.Print(InitError(InitErrorProps{Err: err}))
+		return err
+	}
+	// Offline, as in CI, a step that fails fails init, rather than leaving a
+	// half-set-up project behind a warning
+	warn := func(step string, err error) error {
 		goli.Print(gox.Element("box", gox.Props{"direction": "row"},
 			gox.Element("text", gox.Props{"color": "yellow"},
 				gox.V("!")),
 			gox.Element("text", nil,
-				gox.V(" npm install failed: "+err.Error()))))
+				gox.V(" "+step+" failed: "+err.Error()))))
+		if offline {
+			return fmt.Errorf("%s failed: %w", step, err)
+		}
+		return nil
+	}
+
+	// Install the client's dependencies
+	goli.Print(gox.Element("box", gox.Props{"direction": "row"},
+		gox.Element("text", gox.Props{"dim": true},
+			gox.V("  Installing client dependencies..."))))
+	installCmd := exec.Command(string(packageManager), append([]string{"install"}, installArgs...)...)
+	installCmd.Dir = npmDir
+	installCmd.Stdout = nil
+	installCmd.Stderr = os.Stderr
+	if err := installCmd.Run(); err != nil {
+		if err := warn(string(packageManager)+" install", err); err != nil {
+			return err
+		}
 	}
 
 	// Run codegen
 	goli.Print(gox.Element("box", gox.Props{"direction": "row"},
 		gox.Element("text", gox.Props{"dim": true},
 			gox.V("  Running codegen..."))))
-	if err := RunCodegen([]string{"--proto", filepath.Join(dir, "proto", "service.proto"), "--go-out", filepath.Join(dir, "server", "generated"), "--ts-out", filepath.Join(dir, "client", "src", "generated"), "--routes-dir", filepath.Join(dir, "client", "src", "routes"), "--preload-out", filepath.Join(dir, "server", "generated", "preload_routes.go")}); err != nil {
-		goli.Print(gox.Element("box", gox.Props{"direction": "row"},
-			gox.Element("text", gox.Props{"color": "yellow"},
-				gox.V("!")),
-			gox.Element("text", nil,
-				gox.V(" codegen failed: "+err.Error()))))
+	if err := RunCodegen(projectCodegenArgs(appDir)); err != nil {
+		if err := warn("codegen", err); err != nil {
+			return err
+		}
 	}
 
 	// Run go mod tidy for server (after codegen so generated packages exist)
@@ -197,21 +443,205 @@ func RunInit(args []string) error {
 		gox.Element("text", gox.Props{"dim": true},
 			gox.V("  Resolving server dependencies..."))))
 	tidyCmd := exec.Command("go", "mod", "tidy")
-	tidyCmd.Dir = filepath.Join(dir, "server")
+	tidyCmd.Dir = filepath.Join(appDir, "server")
 	tidyCmd.Stdout = nil
 	tidyCmd.Stderr = os.Stderr
 	if err := tidyCmd.Run(); err != nil {
-		goli.Print(gox.Element("box", gox.Props{"direction": "row"},
-			gox.Element("text", gox.Props{"color": "yellow"},
-				gox.V("!")),
-			gox.Element("text", nil,
-				gox.V(" go mod tidy failed: "+err.Error()))))
+		if err := warn("go mod tidy", err); err != nil {
+			return err
+		}
 	}
 
-	goli.Print(InitResult(InitResultProps{Name: name, Framework: fw, Files: files}))
+	goli.Print(InitResult(InitResultProps{Name: result.Name, Framework: fw, Files: files, Cd: result.Cd, Run: result.Run}))
 	return nil
 }
 
+// installEnv prepares the dependency installs of gapp init, returning extra
+// args for pm install. Offline, pm and go only use what's cached. cacheDir
+// replaces their caches, pm's with <cacheDir>/<pm> and Go's module cache
+// with <cacheDir>/go, so an online init can fill a cache that CI then inits
+// from offline. The settings carry over to codegen and go mod tidy through
+// the environment. Bun can't install offline.
+func installEnv(pm scaffold.PackageManager, offline bool, cacheDir string) ([]string, error) {
+	var pmArgs []string
+	if cacheDir != "" {
+		cacheDir = mustAbs(cacheDir)
+		pmCache := filepath.Join(cacheDir, string(pm))
+		switch pm {
+		case scaffold.PackageManagerPnpm:
+			pmArgs = append(pmArgs, "--store-dir", pmCache)
+		case scaffold.PackageManagerYarn:
+			os.Setenv("YARN_CACHE_FOLDER", pmCache)
+		case scaffold.PackageManagerBun:
+			pmArgs = append(pmArgs, "--cache-dir", pmCache)
+		default:
+			pmArgs = append(pmArgs, "--cache", pmCache)
+		}
+		os.Setenv("GOMODCACHE", filepath.Join(cacheDir, "go"))
+	}
+	if !offline {
+		return pmArgs, nil
+	}
+
+	if pm == scaffold.PackageManagerYarn && !isYarnClassic() {
+		// Yarn 2+ dropped --offline for a setting
+		os.Setenv("YARN_ENABLE_NETWORK", "0")
+	} else {
+		pmArgs = append(pmArgs, "--offline")
+	}
+	out, err := exec.Command("go", "env", "GOMODCACHE").Output()
+	if err != nil {
+		return nil, fmt.Errorf("locating the Go module cache: %w", err)
+	}
+	// The module cache's download directory serves as a proxy, so versions
+	// such as a plugin's @latest resolve to the newest one cached
+	modCache := strings.TrimSpace(string(out))
+	os.Setenv("GOPROXY", "file://"+filepath.ToSlash(filepath.Join(modCache, "cache", "download")))
+	os.Setenv("GOSUMDB", "off")
+	os.Setenv("GOTOOLCHAIN", "local")
+	return pmArgs, nil
+}
+
+// isYarnClassic reports whether the yarn on PATH is Yarn 1.
+func isYarnClassic() bool {
+	version, err := exec.Command("yarn", "--version").Output()
+	return err == nil && strings.HasPrefix(string(version), "1.")
+}
+
+// setupCommands returns the commands gapp init --no-install leaves to run
+// from cd: installing the client's dependencies in npmDir with pm, codegen
+// and resolving the server's dependencies in appDir.
+func setupCommands(pm scaffold.PackageManager, cd, npmDir, appDir string) []string {
+	base := "."
+	if cd != "" {
+		base = cd
+	}
+	var commands []string
+	if rel, _ := filepath.Rel(base, npmDir); rel == "." {
+		commands = append(commands, string(pm)+" install")
+	} else {
+		commands = append(commands, "(cd "+filepath.ToSlash(rel)+" && "+string(pm)+" install)")
+	}
+	rel, _ := filepath.Rel(base, appDir)
+	if rel == "." {
+		commands = append(commands, "gapp codegen")
+	} else {
+		commands = append(commands, "gapp codegen --project "+filepath.ToSlash(rel))
+	}
+	return append(commands, "(cd "+filepath.ToSlash(filepath.Join(rel, "server"))+" && go mod tidy)")
+}
+
+// projectName returns the name of the project created in dir: dir itself,
+// or the current directory's name for ".".
+func projectName(dir string) string {
+	if filepath.Clean(dir) == "." {
+		return filepath.Base(mustAbs("."))
+	}
+	return dir
+}
+
+// existingEntries returns the names in dir besides .git, which a freshly
+// created repository already has. It's empty if dir doesn't exist.
+func existingEntries(dir string) []string {
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, entry := range entries {
+		if entry.Name() != ".git" {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+// moveInto moves the files generated in staging to dir, replacing any
+// already there, and creates its directories, empty ones included.
+func moveInto(staging, dir string) error {
+	return filepath.WalkDir(staging, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(staging, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if err := os.Rename(p, target); err != nil {
+			return fmt.Errorf("writing %s: %w", filepath.ToSlash(rel), err)
+		}
+		return nil
+	})
+}
+
+// generateFromTemplate creates the project in dir from a custom template,
+// either a local directory or a git repository such as
+// github.com/org/gapp-template. The project is removed again if the template
+// doesn't provide the proto file codegen reads.
+func generateFromTemplate(config scaffold.ProjectConfig, template, dir string) ([]string, error) {
+	src, cleanup, err := fetchTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	files, err := scaffold.GenerateFromTemplate(config, os.DirFS(src), dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("template %s: %w", template, err)
+	}
+	protoFile, _, err := projectCodegenPaths(dir)
+	if err == nil {
+		_, err = codegen.FindProtos(protoFile, nil)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("template %s: no proto file: %w", template, err)
+	}
+	return files, nil
+}
+
+// fetchTemplate returns the directory of a template, shallow-cloning it into
+// a temporary directory unless it's local. Calling cleanup removes the clone.
+func fetchTemplate(template string) (dir string, cleanup func(), err error) {
+	if info, err := os.Stat(template); err == nil && info.IsDir() {
+		return template, func() {}, nil
+	}
+	if strings.HasPrefix(template, ".") || filepath.IsAbs(template) {
+		return "", nil, fmt.Errorf("template directory %s not found", template)
+	}
+	url := template
+	if !strings.Contains(url, "://") && !strings.HasPrefix(url, "git@") {
+		url = "https://" + url
+	}
+
+	tmp, err := os.MkdirTemp("", "gapp-template-")
+	if err != nil {
+		return "", nil, err
+	}
+	goli.Print(gox.Element("box", gox.Props{"direction": "row"},
+		gox.Element("text", gox.Props{"dim": true},
+			gox.V("  Fetching template "+url+"..."))))
+	cloneCmd := exec.Command("git", "clone", "--depth", "1", "--quiet", url, tmp)
+	cloneCmd.Stderr = os.Stderr
+	if err := cloneCmd.Run(); err != nil {
+		os.RemoveAll(tmp)
+		return "", nil, fmt.Errorf("cloning template %s: %w", url, err)
+	}
+	return tmp, func() { os.RemoveAll(tmp) }, nil
+}
+
+// workspaceModule returns the Go module path of the monorepo app is in, from
+// the app's module <module>/apps/<app>/server.
+func workspaceModule(app string) string {
+	config, err := scaffold.DetectConfig(app)
+	if err != nil {
+		return filepath.Base(mustAbs("."))
+	}
+	return strings.TrimSuffix(config.Module, "/apps/"+filepath.Base(app))
+}
+
 // resolveGappPackages finds the @gapp/client and @gapp/react packages
 // relative to the gapp binary location (gapp/cli/ -> gapp/client/, gapp/react/)
 func resolveGappPackages() (clientPath, reactPath, serverPath string) {
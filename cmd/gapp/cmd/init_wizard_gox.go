@@ -0,0 +1,284 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/germtb/goli"
+	"github.com/germtb/gox"
+
+	"github.com/germtb/gapp/cmd/gapp/scaffold"
+)
+
+// wizardChoice is an answer a step of the init wizard offers.
+type wizardChoice struct {
+	Value string
+	Label string
+}
+
+// wizardStep is a question of the init wizard. Steps without Choices take
+// text, which Default fills in when left empty.
+type wizardStep struct {
+	Title    string
+	Choices  []wizardChoice
+	Default  func(answers []string) string
+	Validate func(value string) error
+}
+
+// The steps of the init wizard, indexing its answers
+const (
+	wizardName = iota
+	wizardModule
+	wizardFramework
+	wizardCSS
+	wizardAuth
+	wizardLayout
+	wizardPackageManager
+)
+
+var frameworkDescriptions = map[scaffold.Framework]string{
+	scaffold.FrameworkReact:   "React + TypeScript",
+	scaffold.FrameworkVanilla: "Plain TypeScript",
+	scaffold.FrameworkSvelte:  "Svelte 5",
+	scaffold.FrameworkVue:     "Vue 3",
+	scaffold.FrameworkSolid:   "SolidJS",
+}
+
+func initWizardSteps() []wizardStep {
+	var frameworks []wizardChoice
+	for _, fw := range scaffold.Frameworks {
+		frameworks = append(frameworks, wizardChoice{string(fw), frameworkDescriptions[fw]})
+	}
+	return []wizardStep{
+		{
+			Title: "Project name",
+			Validate: func(name string) error {
+				if name == "" {
+					return fmt.Errorf("the project needs a name")
+				}
+				// Non-empty directories take gapp init --force
+				if len(existingEntries(name)) > 0 {
+					return fmt.Errorf("directory %s isn't empty", name)
+				}
+				return nil
+			},
+		},
+		{
+			Title:   "Go module",
+			Default: func(answers []string) string { return projectName(answers[wizardName]) },
+		},
+		{Title: "Framework", Choices: frameworks},
+		{Title: "CSS", Choices: []wizardChoice{
+			{"plain", "A plain stylesheet"},
+			{"tailwind", "Tailwind CSS"},
+			{"vanilla-extract", "Typed styles in TypeScript"},
+		}},
+		{Title: "Authentication", Choices: []wizardChoice{
+			{"none", "None"},
+			{"siauth", "siauth login, with a protected RPC"},
+		}},
+		{Title: "Layout", Choices: []wizardChoice{
+			{"standard", "A single app"},
+			{"monorepo", "go.work and workspaces, starting with apps/web"},
+		}},
+		{Title: "Package manager", Choices: []wizardChoice{
+			{"npm", "Comes with Node.js"},
+			{"pnpm", "Installs into a shared store"},
+			{"yarn", "Yarn, with a node_modules folder"},
+			{"bun", "Bun's installer"},
+		}},
+	}
+}
+
+// initWizardArgs returns the gapp init arguments the wizard's answers stand
+// for.
+func initWizardArgs(answers []string) []string {
+	args := []string{answers[wizardName]}
+	if answers[wizardModule] != projectName(answers[wizardName]) {
+		args = append(args, "--module", answers[wizardModule])
+	}
+	args = append(args, "--framework", answers[wizardFramework])
+	if answers[wizardCSS] != string(scaffold.CSSPlain) {
+		args = append(args, "--css", answers[wizardCSS])
+	}
+	if answers[wizardAuth] == "siauth" {
+		args = append(args, "--with-auth")
+	}
+	if answers[wizardLayout] == "monorepo" {
+		args = append(args, "--layout", "monorepo")
+	}
+	if answers[wizardPackageManager] != string(scaffold.PackageManagerNpm) {
+		args = append(args, "--pm", answers[wizardPackageManager])
+	}
+	return args
+}
+
+type InitWizardProps struct {
+	Steps   []wizardStep
+	Answers goli.Accessor[[]string]
+	Step    goli.Accessor[int]
+	Choice  goli.Accessor[int]
+	Err     goli.Accessor[string]
+	Input   *goli.Input
+}
+
+func InitWizard(props InitWizardProps) gox.VNode {
+	answers := props.Answers()
+	current := props.Steps[props.Step()]
+	title := current.Title
+	if current.Default != nil {
+		title += " (" + current.Default(answers) + ")"
+	}
+	hint := "Enter to confirm, Esc to cancel"
+	if current.Choices != nil {
+		hint = "↑/↓ to choose, " + hint
+	}
+
+	return gox.Element("box", gox.Props{"direction": "column", "padding": 1},
+		gox.Element("text", gox.Props{"bold": true},
+			gox.V("Create a gapp project")),
+		gox.Element("text", nil,
+			gox.V("")),
+		gox.V(gox.MapIndex(answers, func(i int, answer string) gox.VNode {
+			return gox.Element("box", gox.Props{"direction": "row"},
+				gox.Element("text", gox.Props{"color": "green"},
+					gox.V("✓ ")),
+				gox.Element("text", gox.Props{"dim": true},
+					gox.V(props.Steps[i].Title+": ")),
+				gox.Element("text", nil,
+					gox.V(answer)))
+		})),
+		gox.Element("box", gox.Props{"direction": "row"},
+			gox.Element("text", gox.Props{"color": "cyan"},
+				gox.V("? ")),
+			gox.Element("text", gox.Props{"bold": true},
+				gox.V(title))),
+		gox.V(gox.WhenElse(current.Choices == nil,
+			gox.Element("box", gox.Props{"direction": "row"},
+				gox.Element("text", gox.Props{"color": "cyan"},
+					gox.V("  ❯ ")),
+				gox.Element("input", gox.Props{"input": props.Input, "width": 40, "cursorStyle": map[string]any{"background": "cyan", "color": "black"}})),
+			gox.Element("box", gox.Props{"direction": "column"},
+				gox.V(gox.MapIndex(current.Choices, func(i int, choice wizardChoice) gox.VNode {
+					if i == props.Choice() {
+						return gox.Element("box", gox.Props{"direction": "row"},
+							gox.Element("text", gox.Props{"color": "cyan"},
+								gox.V("  ❯ "+choice.Value)),
+							gox.Element("text", gox.Props{"dim": true},
+								gox.V("  "+choice.Label)))
+					}
+					return gox.Element("box", gox.Props{"direction": "row"},
+						gox.Element("text", nil,
+							gox.V("    "+choice.Value)),
+						gox.Element("text", gox.Props{"dim": true},
+							gox.V("  "+choice.Label)))
+				}))),
+		)),
+		gox.V(gox.When(props.Err() != "", gox.Element("box", gox.Props{"direction": "row"},
+			gox.Element("text", gox.Props{"color": "red"},
+				gox.V("  ✗ "+props.Err()))))),
+		gox.Element("text", nil,
+			gox.V("")),
+		gox.Element("text", gox.Props{"dim": true},
+			gox.V(hint)))
+}
+
+// runInitWizard asks for the options of a new project, starting from name
+// if one was given, and returns the equivalent gapp init arguments.
+func runInitWizard(name string) ([]string, error) {
+	steps := initWizardSteps()
+	answers, setAnswers := goli.CreateSignal([]string{})
+	step, setStep := goli.CreateSignal(0)
+	choice, setChoice := goli.CreateSignal(0)
+	errMsg, setErr := goli.CreateSignal("")
+	var app *goli.App
+	done := false
+
+	// answer records the answer to the current step, reporting false if it
+	// isn't valid
+	answer := func(value string) bool {
+		current := steps[step()]
+		if value == "" && current.Default != nil {
+			value = current.Default(answers())
+		}
+		if current.Validate != nil {
+			if err := current.Validate(value); err != nil {
+				setErr(err.Error())
+				return false
+			}
+		}
+		setErr("")
+		setAnswers(append(slices.Clone(answers()), value))
+		setChoice(0)
+		if step()+1 == len(steps) {
+			done = true
+			app.Quit()
+		} else {
+			setStep(step() + 1)
+		}
+		return true
+	}
+
+	input := goli.NewInput(goli.InputOptions{
+		InitialValue: name,
+		OnKeypress: func(key string, state goli.InputState) *goli.InputState {
+			// Choice steps are answered with the global key handler
+			if steps[step()].Choices != nil {
+				return nil
+			}
+			if key == goli.Enter {
+				if !answer(strings.TrimSpace(state.Value)) {
+					return &state
+				}
+				return &goli.InputState{}
+			}
+			return goli.DefaultInputHandler(key, state)
+		},
+	})
+	defer input.Dispose()
+	input.Focus()
+
+	goli.Run(func() gox.VNode {
+		return InitWizard(InitWizardProps{Steps: steps, Answers: answers, Step: step, Choice: choice, Err: errMsg, Input: input})
+	}, goli.RunOptions{
+		OnMount: func(a *goli.App) {
+			app = a
+			goli.Manager().SetGlobalKeyHandler(func(key string) bool {
+				if key == goli.Escape {
+					app.Quit()
+					return true
+				}
+				choices := steps[step()].Choices
+				if choices == nil {
+					return false
+				}
+				switch key {
+				case goli.Up, "k":
+					setChoice((choice() + len(choices) - 1) % len(choices))
+				case goli.Down, "j":
+					setChoice((choice() + 1) % len(choices))
+				case goli.Enter:
+					answer(choices[choice()].Value)
+				default:
+					return false
+				}
+				return true
+			})
+		},
+	})
+	goli.Manager().SetGlobalKeyHandler(nil)
+
+	if !done {
+		return nil, fmt.Errorf("init cancelled")
+	}
+	args := initWizardArgs(answers())
+	goli.Print(gox.Element("box", gox.Props{"direction": "column"},
+		gox.Element("text", gox.Props{"dim": true},
+			gox.V("  Equivalent command:")),
+		gox.Element("text", gox.Props{"color": "cyan", "wrap": true},
+			gox.V("    gapp init "+strings.Join(args, " ")))))
+	goli.Print(gox.Element("text", nil,
+		gox.V("")))
+	return args, nil
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/germtb/goli"
+)
+
+// logScrollback is how many lines each gapp run pane keeps.
+const logScrollback = 5000
+
+// appendLog appends line to a pane's lines, dropping the oldest past
+// logScrollback.
+func appendLog(lines []string, line string) []string {
+	next := append(lines, line)
+	if len(next) > logScrollback {
+		next = next[len(next)-logScrollback:]
+	}
+	return next
+}
+
+// logPageHeight is how many log lines fit under the tab bar.
+func logPageHeight() int {
+	_, termHeight, _ := goli.GetSize(int(os.Stdout.Fd()))
+	if termHeight < 2 {
+		return 1
+	}
+	return termHeight - 1
+}
+
+// logView is how the active gapp run pane is shown. A paused view shows a
+// snapshot of the pane, so lines arriving meanwhile don't scroll it away.
+type logView struct {
+	Offset  int      // lines scrolled up from the bottom
+	Frozen  []string // snapshot shown while paused, nil when live
+	Filter  string   // only lines containing Filter are shown
+	Editing bool     // keys are typed into Filter
+}
+
+func (v logView) Paused() bool {
+	return v.Frozen != nil
+}
+
+// lines returns the pane's lines that pass the filter.
+func (v logView) lines(live []string) []string {
+	lines := live
+	if v.Paused() {
+		lines = v.Frozen
+	}
+	if v.Filter == "" {
+		return lines
+	}
+	var matched []string
+	for _, line := range lines {
+		if strings.Contains(ansiPattern.ReplaceAllString(line, ""), v.Filter) {
+			matched = append(matched, line)
+		}
+	}
+	return matched
+}
+
+// Visible returns the lines that fit in height at the view's scroll offset.
+func (v logView) Visible(live []string, height int) []string {
+	lines := v.lines(live)
+	end := len(lines) - v.Offset
+	if end < 0 {
+		end = 0
+	}
+	start := end - height
+	if start < 0 {
+		start = 0
+	}
+	if end-start < height && len(lines) >= height {
+		// Scrolled past the top
+		start, end = 0, height
+	}
+	return lines[start:end]
+}
+
+// Status describes the view for the tab bar, e.g. "PAUSED ↑40 /panic".
+func (v logView) Status() string {
+	var parts []string
+	if v.Paused() {
+		parts = append(parts, "PAUSED")
+	}
+	if v.Offset > 0 {
+		parts = append(parts, "↑"+strconv.Itoa(v.Offset))
+	}
+	if v.Editing {
+		parts = append(parts, "/"+v.Filter+"█")
+	} else if v.Filter != "" {
+		parts = append(parts, "/"+v.Filter)
+	}
+	return strings.Join(parts, " ")
+}
+
+// HandleKey applies a scroll, pause or filter key to the view, given the
+// active pane's lines and the page height. It reports whether it used key.
+func (v logView) HandleKey(key string, live []string, page int) (logView, bool) {
+	if v.Editing {
+		switch key {
+		case goli.Enter, goli.EnterLF:
+			v.Editing = false
+		case goli.Escape:
+			v.Editing = false
+			v.Filter = ""
+		case goli.Backspace, goli.BackspaceCtrl:
+			if v.Filter != "" {
+				v.Filter = v.Filter[:len(v.Filter)-1]
+			}
+		default:
+			if len(key) != 1 || key[0] < ' ' {
+				return v, false
+			}
+			v.Filter += key
+		}
+		v.Offset = 0
+		return v, true
+	}
+
+	switch key {
+	case "/":
+		v.Editing = true
+	case goli.Escape:
+		v.Filter = ""
+		v.Offset = 0
+	case goli.Space:
+		if v.Paused() {
+			v.Frozen = nil
+			v.Offset = 0
+		} else {
+			v.Frozen = append([]string{}, live...)
+		}
+	case goli.Up, goli.PageUp, goli.Home, goli.HomeAlt:
+		// Scrolling back pauses, so the lines being read stay put
+		if !v.Paused() {
+			v.Frozen = append([]string{}, live...)
+		}
+		max := len(v.lines(live)) - page
+		switch key {
+		case goli.Up:
+			v.Offset++
+		case goli.PageUp:
+			v.Offset += page
+		default:
+			v.Offset = max
+		}
+		if v.Offset > max {
+			v.Offset = max
+		}
+		if v.Offset < 0 {
+			v.Offset = 0
+		}
+	case goli.Down, goli.PageDown:
+		if key == goli.Down {
+			v.Offset--
+		} else {
+			v.Offset -= page
+		}
+		if v.Offset < 0 {
+			v.Offset = 0
+		}
+	case goli.End, goli.EndAlt:
+		v.Frozen = nil
+		v.Offset = 0
+	default:
+		return v, false
+	}
+	return v, true
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+
+	"google.golang.org/protobuf/types/pluginpb"
+
+	"github.com/germtb/gapp/cmd/gapp/internal/codegen"
+)
+
+// nativeTarget is a mobile platform gapp codegen generates a client for: the
+// messages with the platform's protobuf plugin, and a client per service,
+// with the transport speaking gapp's RPC protocol, with gapp's own generator.
+type nativeTarget struct {
+	Name    string
+	Out     string
+	plugins []string // binary names the plugin goes by, looked up on PATH
+	param   string
+	install string // where to get the plugin
+	clients func(*pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error)
+}
+
+// nativeTargets returns the targets whose output directory is set.
+func nativeTargets(swiftOut, kotlinOut string) []nativeTarget {
+	var targets []nativeTarget
+	if swiftOut != "" {
+		targets = append(targets, nativeTarget{
+			Name:    "Swift",
+			Out:     swiftOut,
+			plugins: []string{"protoc-gen-swift"},
+			param:   "Visibility=Public",
+			install: "install swift-protobuf, e.g. brew install swift-protobuf",
+			clients: codegen.GenerateSwiftClients,
+		})
+	}
+	if kotlinOut != "" {
+		targets = append(targets, nativeTarget{
+			Name:    "Kotlin",
+			Out:     kotlinOut,
+			plugins: []string{"protoc-gen-pbandk", "protoc-gen-kotlin"},
+			install: "install pbandk's protoc plugin from https://github.com/streem/pbandk",
+			clients: codegen.GenerateKotlinClients,
+		})
+	}
+	return targets
+}
+
+// Plugin returns the path of the target's protoc plugin.
+func (t nativeTarget) Plugin() (string, error) {
+	for _, name := range t.plugins {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%s not found on PATH: %s", t.plugins[0], t.install)
+}
+
+// Generate writes the target's messages and clients for req into its
+// output directory.
+func (t nativeTarget) Generate(req *pluginpb.CodeGeneratorRequest, out *codegenOutput) error {
+	plugin, err := t.Plugin()
+	if err != nil {
+		return err
+	}
+	messages, err := codegen.RunPlugin(req, plugin, t.param)
+	if err != nil {
+		return err
+	}
+	if err := out.writeResponse(messages, t.Out); err != nil {
+		return err
+	}
+	clients, err := t.clients(req)
+	if err != nil {
+		return err
+	}
+	return out.writeResponse(clients, t.Out)
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/germtb/gapp/cmd/gapp/internal/codegen"
+)
+
+// projectConfigFile is the optional file at a project's root holding the
+// defaults of the gapp commands. The server's own gapp.toml, read by
+// gapp.LoadConfig, lives in server/.
+const projectConfigFile = "gapp.toml"
+
+// projectConfig is a parsed project gapp.toml: a table per command, such as
+// [codegen] or [build], whose keys are the command's flags in snake_case.
+type projectConfig map[string]map[string]any
+
+// loadProjectConfig reads projectDir/gapp.toml. A missing file is an empty
+// config.
+func loadProjectConfig(projectDir string) (projectConfig, error) {
+	var raw map[string]any
+	path := filepath.Join(projectDir, projectConfigFile)
+	if _, err := toml.DecodeFile(path, &raw); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return projectConfig{}, nil
+		}
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	config := projectConfig{}
+	for command, table := range raw {
+		values, ok := table.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: %q must be set in a command's table, e.g. [run]", path, command)
+		}
+		config[command] = values
+	}
+	return config, nil
+}
+
+// applyProjectConfig sets the flags of fs that weren't given on the command
+// line from the command's table of projectDir/gapp.toml. Relative values of
+// pathFlags, and their defaults, are resolved against projectDir, so the
+// config works wherever gapp runs from; in comma-separated lists, each
+// path is. Arrays of tables, such as [[codegen.plugin]], aren't flags and
+// are left to the command. Keys may be renamed with aliases,
+// e.g. output for -o.
+func applyProjectConfig(fs *flag.FlagSet, projectDir, command string, pathFlags []string, aliases map[string]string) error {
+	config, err := loadProjectConfig(projectDir)
+	if err != nil {
+		return err
+	}
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	for key, value := range config[command] {
+		if _, ok := value.([]map[string]any); ok {
+			continue
+		}
+		name := strings.ReplaceAll(key, "_", "-")
+		if alias, ok := aliases[key]; ok {
+			name = alias
+		}
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown key %q in [%s]", projectConfigFile, key, command)
+		}
+		if given[name] {
+			continue
+		}
+		s, err := configValue(value)
+		if err == nil {
+			err = fs.Set(name, s)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: [%s] %s: %w", projectConfigFile, command, key, err)
+		}
+	}
+
+	for _, name := range pathFlags {
+		f := fs.Lookup(name)
+		if given[name] || f.Value.String() == "" {
+			continue
+		}
+		paths := strings.Split(f.Value.String(), ",")
+		for i, path := range paths {
+			if !filepath.IsAbs(path) {
+				paths[i] = filepath.Join(projectDir, path)
+			}
+		}
+		fs.Set(name, strings.Join(paths, ","))
+	}
+	return nil
+}
+
+// configValue formats a TOML value as a flag value. Arrays become
+// comma-separated lists.
+func configValue(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case []any:
+		var items []string
+		for _, item := range v {
+			s, err := configValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", value)
+	}
+}
+
+// codegenPlugins returns the extra protoc plugins declared in the
+// [[codegen.plugin]] tables of projectDir/gapp.toml, with their paths
+// resolved against projectDir:
+//
+//	[[codegen.plugin]]
+//	path = "protoc-gen-validate"
+//	opt = ["lang=go", "paths=source_relative"]
+//	out = "server/generated"
+func codegenPlugins(projectDir string) ([]codegen.Plugin, error) {
+	config, err := loadProjectConfig(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := config["codegen"]["plugin"]
+	if !ok {
+		return nil, nil
+	}
+	tables, ok := value.([]map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: plugin must be declared as [[codegen.plugin]] tables", projectConfigFile)
+	}
+
+	var plugins []codegen.Plugin
+	for i, table := range tables {
+		var plugin codegen.Plugin
+		for key, value := range table {
+			s, err := configValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: [[codegen.plugin]] %d: %s: %w", projectConfigFile, i+1, key, err)
+			}
+			switch key {
+			case "name":
+				plugin.Name = s
+			case "path":
+				plugin.Path = s
+			case "go":
+				plugin.Go = s
+			case "opt":
+				plugin.Opt = s
+			case "out":
+				plugin.Out = s
+			default:
+				return nil, fmt.Errorf("%s: unknown key %q in [[codegen.plugin]] %d", projectConfigFile, key, i+1)
+			}
+		}
+		switch {
+		case (plugin.Path == "") == (plugin.Go == ""):
+			return nil, fmt.Errorf("%s: [[codegen.plugin]] %d needs either path or go", projectConfigFile, i+1)
+		case plugin.Out == "":
+			return nil, fmt.Errorf("%s: [[codegen.plugin]] %d needs an out directory", projectConfigFile, i+1)
+		}
+		if !filepath.IsAbs(plugin.Out) {
+			plugin.Out = filepath.Join(projectDir, plugin.Out)
+		}
+		// A bare name is looked up on PATH, a path is the project's
+		if strings.ContainsAny(plugin.Path, `/\`) && !filepath.IsAbs(plugin.Path) {
+			plugin.Path = filepath.Join(projectDir, plugin.Path)
+		}
+		plugins = append(plugins, plugin)
+	}
+	return plugins, nil
+}
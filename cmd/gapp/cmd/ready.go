@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"time"
+)
+
+var (
+	ansiPattern     = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	viteURLPattern  = regexp.MustCompile(`Local:\s+(https?://\S+)`)
+	readyPollPeriod = 250 * time.Millisecond
+)
+
+// viteURL returns the local URL vite prints once its dev server listens, or ""
+// if line isn't that announcement.
+func viteURL(line string) string {
+	match := viteURLPattern.FindStringSubmatch(ansiPattern.ReplaceAllString(line, ""))
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// WaitForURL polls url until it answers 200 OK or ctx is done. Certificates
+// aren't verified, since it only polls local dev servers that may use
+// self-signed ones.
+func WaitForURL(ctx context.Context, url string) error {
+	client := &http.Client{
+		Timeout:   2 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	ticker := time.NewTicker(readyPollPeriod)
+	defer ticker.Stop()
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		if resp, err := client.Do(req); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// OpenBrowser opens url in the default browser.
+func OpenBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// buildTimestamp is the time recorded in a build: $SOURCE_DATE_EPOCH, else
+// the commit time of a clean checkout, so rebuilding a commit gives the same
+// output. Builds of uncommitted changes use the current time.
+func buildTimestamp(projectDir string) time.Time {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		if seconds, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+			return time.Unix(seconds, 0).UTC()
+		}
+	}
+	if commit := gitCommit(projectDir); commit != "" && !strings.HasSuffix(commit, "-dirty") {
+		if seconds, err := strconv.ParseInt(gitOutput(projectDir, "log", "-1", "--format=%ct"), 10, 64); err == nil {
+			return time.Unix(seconds, 0).UTC()
+		}
+	}
+	return time.Now().UTC()
+}
+
+// normalizeMtimes sets the modification time of everything under root to t,
+// so copies of the same build are identical, down to Last-Modified headers.
+func normalizeMtimes(root string, t time.Time) error {
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(path, t, t)
+	})
+}
+
+// checkToolchains verifies the Go and Node versions the project pins: the
+// toolchain directive in server/go.mod, and .nvmrc or .node-version in
+// client/ or the project directory. Unpinned tools aren't checked.
+func checkToolchains(projectDir string) error {
+	serverDir := filepath.Join(projectDir, "server")
+	if want := goModToolchain(filepath.Join(serverDir, "go.mod")); want != "" {
+		cmd := exec.Command("go", "env", "GOVERSION")
+		cmd.Dir = serverDir
+		out, err := cmd.Output()
+		if err != nil {
+			return fmt.Errorf("checking go version: %w", err)
+		}
+		if got := strings.TrimSpace(string(out)); got != want {
+			return fmt.Errorf("server/go.mod pins %s but go is %s (build with GOTOOLCHAIN=%s)", want, got, want)
+		}
+	}
+
+	for _, pin := range []string{
+		filepath.Join(projectDir, "client", ".nvmrc"),
+		filepath.Join(projectDir, "client", ".node-version"),
+		filepath.Join(projectDir, ".nvmrc"),
+		filepath.Join(projectDir, ".node-version"),
+	} {
+		data, err := os.ReadFile(pin)
+		if err != nil {
+			continue
+		}
+		want := strings.TrimPrefix(strings.TrimSpace(string(data)), "v")
+		if want == "" || want[0] < '0' || want[0] > '9' {
+			// An alias like lts/iron, which only nvm resolves
+			return nil
+		}
+		out, err := exec.Command("node", "--version").Output()
+		if err != nil {
+			return fmt.Errorf("checking node version: %w", err)
+		}
+		got := strings.TrimPrefix(strings.TrimSpace(string(out)), "v")
+		if !nodeVersionMatches(got, want) {
+			rel, _ := filepath.Rel(projectDir, pin)
+			return fmt.Errorf("%s pins node %s but node is v%s", rel, want, got)
+		}
+		return nil
+	}
+	return nil
+}
+
+// goModToolchain returns the toolchain directive of a go.mod, e.g.
+// "go1.24.3", or "" if it has none.
+func goModToolchain(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "toolchain" {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// nodeVersionMatches reports whether version is the pinned want, which may
+// leave out the minor and patch numbers ("20" matches "20.11.1").
+func nodeVersionMatches(version, want string) bool {
+	return version == want || strings.HasPrefix(version, want+".")
+}
@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/germtb/gapp/cmd/gapp/scaffold"
+)
+
+// routeParam is a :param segment of a route path.
+type routeParam struct {
+	Name     string
+	Optional bool
+}
+
+// routeRpc is a preloaded RPC of a generated route. Params holds the
+// rendered param mappings, e.g. `"id": ":id"`.
+type routeRpc struct {
+	Method string
+	Params string
+}
+
+// routeTemplateData is the data of the gapp generate route templates.
+type routeTemplateData struct {
+	Path         string
+	Component    string // e.g. UsersIdRoute
+	Var          string // e.g. usersIdRoute
+	Page         string // Svelte and Vue page component, e.g. UsersIdPage
+	PageImport   string // the page's path relative to the route file
+	StylesImport string // styles.css relative to the component, for vanilla-extract
+	Params       []routeParam
+	Rpcs         []routeRpc
+	Config       scaffold.ProjectConfig
+}
+
+// routeFile is a file gapp generate route writes.
+type routeFile struct {
+	template string // relative to templates/generate/
+	path     string
+}
+
+var (
+	routeSegmentRe = regexp.MustCompile(`^(:[A-Za-z_][A-Za-z0-9_]*\??|[A-Za-z0-9._~-]+)$`)
+	nonAlnumRe     = regexp.MustCompile(`[^A-Za-z0-9]+`)
+)
+
+// newRouteTemplateData validates path and derives the route's names from it,
+// unless name is given. Each RPC in rpcs is passed every route param.
+func newRouteTemplateData(path, name string, rpcs []string) (routeTemplateData, error) {
+	data := routeTemplateData{Path: path}
+	if !strings.HasPrefix(path, "/") {
+		return data, fmt.Errorf("route path %q must start with /", path)
+	}
+
+	var words []string
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+		if !routeSegmentRe.MatchString(segment) {
+			return data, fmt.Errorf("invalid route segment %q in %s", segment, path)
+		}
+		if strings.HasPrefix(segment, ":") {
+			param := routeParam{Name: strings.TrimSuffix(segment[1:], "?"), Optional: strings.HasSuffix(segment, "?")}
+			data.Params = append(data.Params, param)
+			segment = param.Name
+		}
+		words = append(words, nonAlnumRe.Split(segment, -1)...)
+	}
+
+	if name == "" {
+		for _, word := range words {
+			if word != "" {
+				name += strings.ToUpper(word[:1]) + word[1:]
+			}
+		}
+		if name == "" {
+			name = "Index"
+		}
+	}
+	name = strings.TrimSuffix(name, "Route")
+	if name == "" || !isIdentifier(name) || name[0] < 'A' || name[0] > 'Z' {
+		return data, fmt.Errorf("invalid route name %q (want a PascalCase identifier)", name)
+	}
+	data.Component = name + "Route"
+	data.Var = strings.ToLower(name[:1]) + name[1:] + "Route"
+	data.Page = name + "Page"
+
+	var mappings []string
+	for _, param := range data.Params {
+		mappings = append(mappings, fmt.Sprintf("%q: %q", param.Name, ":"+param.Name))
+	}
+	for _, method := range rpcs {
+		if !isIdentifier(method) {
+			return data, fmt.Errorf("invalid RPC method %q", method)
+		}
+		data.Rpcs = append(data.Rpcs, routeRpc{Method: method, Params: strings.Join(mappings, ", ")})
+	}
+	return data, nil
+}
+
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		if r != '_' && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// routeFiles returns the files of a generated route: its declaration in
+// routesDir, which codegen scans for preloads, and for Svelte and Vue its
+// page component in srcDir/pages. It sets data.PageImport and
+// data.StylesImport.
+func routeFiles(data *routeTemplateData, framework scaffold.Framework, routesDir, srcDir string) ([]routeFile, error) {
+	stylesImport := func(componentDir string) (err error) {
+		data.StylesImport, err = relativeImport(componentDir, filepath.Join(srcDir, "styles.css"))
+		return err
+	}
+	switch framework {
+	case scaffold.FrameworkSvelte, scaffold.FrameworkVue:
+		ext := "." + string(framework)
+		pagesDir := filepath.Join(srcDir, "pages")
+		pageImport, err := relativeImport(routesDir, filepath.Join(pagesDir, data.Page+ext))
+		if err != nil {
+			return nil, err
+		}
+		data.PageImport = pageImport
+		if err := stylesImport(pagesDir); err != nil {
+			return nil, err
+		}
+		return []routeFile{
+			{string(framework) + "/route.ts.tmpl", filepath.Join(routesDir, data.Component+".ts")},
+			{string(framework) + "/page" + ext + ".tmpl", filepath.Join(pagesDir, data.Page+ext)},
+		}, nil
+	}
+	if err := stylesImport(routesDir); err != nil {
+		return nil, err
+	}
+	switch framework {
+	case scaffold.FrameworkVanilla:
+		return []routeFile{{"vanilla/route.ts.tmpl", filepath.Join(routesDir, data.Component+".ts")}}, nil
+	case scaffold.FrameworkSolid:
+		return []routeFile{{"solid/route.tsx.tmpl", filepath.Join(routesDir, data.Component+".tsx")}}, nil
+	default:
+		return []routeFile{{"react/route.tsx.tmpl", filepath.Join(routesDir, data.Component+".tsx")}}, nil
+	}
+}
+
+// relativeImport returns the import specifier of path from a module in dir,
+// e.g. ../pages/HomePage.svelte.
+func relativeImport(dir, path string) (string, error) {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return "", err
+	}
+	rel = filepath.ToSlash(rel)
+	if !strings.HasPrefix(rel, ".") {
+		rel = "./" + rel
+	}
+	return rel, nil
+}
+
+// routeEntry returns the client router entry for a generated route. Svelte,
+// Vue and Solid routes are declared complete in their route file.
+func routeEntry(data routeTemplateData, framework scaffold.Framework) string {
+	if declaresRoute(framework) {
+		return "  " + data.Var + ",\n"
+	}
+	factory := "() => ({ component: " + data.Component + " })"
+	switch {
+	case framework == scaffold.FrameworkVanilla && data.Params != nil:
+		factory = "(params) => ({ render: (root: HTMLElement) => " + data.Component + "(root, params as " + routeParamsType(data, framework) + ") })"
+	case framework == scaffold.FrameworkVanilla:
+		factory = "() => ({ render: " + data.Component + " })"
+	case data.Params != nil:
+		factory = "(params) => ({ component: () => <" + data.Component + " {...(params as " + routeParamsType(data, framework) + ")} /> })"
+	}
+	return "  {\n    path: \"" + data.Path + "\",\n    factory: " + factory + ",\n  },\n"
+}
+
+// declaresRoute reports whether framework's route files export the router
+// entry itself, rather than main building it.
+func declaresRoute(framework scaffold.Framework) bool {
+	return framework == scaffold.FrameworkSvelte || framework == scaffold.FrameworkVue || framework == scaffold.FrameworkSolid
+}
+
+// routeParamsType is the type of a generated route's params. The router
+// types the params of a Route<string, ...> as {}, so entries cast them.
+func routeParamsType(data routeTemplateData, framework scaffold.Framework) string {
+	if framework == scaffold.FrameworkVanilla {
+		return data.Component + "Params"
+	}
+	return data.Component + "Props"
+}
+
+// registerRoute adds the import and router entry of a generated route to
+// the client's main.ts(x), importing it from routesImport such as
+// "./routes". It reports false if main doesn't declare its routes the way
+// the scaffold does.
+func registerRoute(main string, data routeTemplateData, framework scaffold.Framework, routesImport string) (string, bool) {
+	routesStart := strings.Index(main, "const routes")
+	if routesStart == -1 {
+		return main, false
+	}
+	routesEnd := strings.Index(main[routesStart:], "\n];")
+	if routesEnd == -1 {
+		return main, false
+	}
+	routesEnd += routesStart + 1
+	main = main[:routesEnd] + routeEntry(data, framework) + main[routesEnd:]
+
+	names := data.Component
+	if declaresRoute(framework) {
+		names = data.Var
+	} else if data.Params != nil {
+		names += ", type " + routeParamsType(data, framework)
+	}
+	importLine := "import { " + names + " } from \"" + routesImport + "/" + data.Component + "\";\n"
+	return insertImport(main, importLine, "\""+routesImport+"/"), true
+}
+
+// insertImport adds importLine after the last import of src containing near,
+// or else after its last import.
+func insertImport(src, importLine, near string) string {
+	lastImport, lastNear := 0, -1
+	offset := 0
+	for _, line := range strings.SplitAfter(src, "\n") {
+		offset += len(line)
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "import ") || !strings.HasSuffix(line, ";") {
+			continue
+		}
+		lastImport = offset
+		if strings.Contains(line, near) {
+			lastNear = offset
+		}
+	}
+	at := lastImport
+	if lastNear != -1 {
+		at = lastNear
+	}
+	return src[:at] + importLine + src[at:]
+}
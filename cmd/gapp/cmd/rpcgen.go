@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/germtb/gapp/cmd/gapp/internal/codegen"
+)
+
+// rpcField is a field of a generated request or response message.
+type rpcField struct {
+	Name     string // snake_case, as declared in the proto
+	Type     string
+	Repeated bool
+}
+
+// rpcTemplateData is the data of the gapp generate rpc handler template.
+type rpcTemplateData struct {
+	Module  string
+	Method  string
+	Handler string // e.g. handleGetUser
+}
+
+// protoScalars are the proto3 scalar types fields can have.
+var protoScalars = []string{
+	"double", "float", "int32", "int64", "uint32", "uint64", "sint32", "sint64",
+	"fixed32", "fixed64", "sfixed32", "sfixed64", "bool", "string", "bytes",
+}
+
+var (
+	protoTypeRe    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+	protoServiceRe = regexp.MustCompile(`(?m)^\s*service\s+\w+\s*\{`)
+	protoDeclRe    = regexp.MustCompile(`(?m)^\s*(?:message|enum)\s+(\w+)`)
+)
+
+// parseRPCFields parses a comma-separated field list such as
+// "userId:string,tags:[]string,user:User". Names are converted to snake_case.
+func parseRPCFields(spec string) ([]rpcField, error) {
+	var fields []rpcField
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, typ, ok := strings.Cut(part, ":")
+		if !ok || !isIdentifier(name) {
+			return nil, fmt.Errorf("invalid field %q (want name:type)", part)
+		}
+		field := rpcField{Name: snakeCase(name), Type: typ}
+		if rest, ok := strings.CutPrefix(typ, "[]"); ok {
+			field.Type, field.Repeated = rest, true
+		}
+		if !protoTypeRe.MatchString(field.Type) {
+			return nil, fmt.Errorf("invalid type %q of field %s", typ, name)
+		}
+		if slices.ContainsFunc(fields, func(f rpcField) bool { return f.Name == field.Name }) {
+			return nil, fmt.Errorf("duplicate field %s", name)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// snakeCase converts a camelCase or PascalCase name to snake_case, keeping
+// acronyms together: userID becomes user_id.
+func snakeCase(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' {
+			prevLower := i > 0 && (s[i-1] >= 'a' && s[i-1] <= 'z' || s[i-1] >= '0' && s[i-1] <= '9')
+			nextLower := i > 0 && i+1 < len(s) && s[i+1] >= 'a' && s[i+1] <= 'z'
+			if prevLower || nextLower {
+				b.WriteByte('_')
+			}
+			c += 'a' - 'A'
+		}
+		b.WriteByte(c)
+	}
+	return strings.ReplaceAll(b.String(), "__", "_")
+}
+
+// protoMessage renders a message declaration with fields numbered in order.
+func protoMessage(name string, fields []rpcField) string {
+	if len(fields) == 0 {
+		return "message " + name + " {}\n"
+	}
+	var b strings.Builder
+	b.WriteString("message " + name + " {\n")
+	for i, field := range fields {
+		label := ""
+		if field.Repeated {
+			label = "repeated "
+		}
+		fmt.Fprintf(&b, "  %s%s %s = %d;\n", label, field.Type, field.Name, i+1)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// serviceProtoFile returns the first file of the proto set declaring a
+// service, which gapp generate rpc adds methods to.
+func serviceProtoFile(protos codegen.ProtoSet) (string, error) {
+	for _, path := range protos.Paths() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		if protoServiceRe.Match(data) {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no service declared in %s", protos.Root)
+}
+
+// addRPC adds method to the first service of a proto file, and its request
+// and response messages to the end of the file. Message types the fields
+// refer to must be declared in the file, unless they're fully qualified.
+func addRPC(proto, method string, request, response []rpcField) (string, error) {
+	if regexp.MustCompile(`\brpc\s+` + method + `\s*\(`).MatchString(proto) {
+		return "", fmt.Errorf("rpc %s already exists", method)
+	}
+	requestName, responseName := method+"Request", method+"Response"
+	var declared []string
+	for _, match := range protoDeclRe.FindAllStringSubmatch(proto, -1) {
+		declared = append(declared, match[1])
+	}
+	for _, name := range []string{requestName, responseName} {
+		if slices.Contains(declared, name) {
+			return "", fmt.Errorf("message %s already exists", name)
+		}
+	}
+	for _, field := range slices.Concat(request, response) {
+		if slices.Contains(protoScalars, field.Type) || strings.Contains(field.Type, ".") {
+			continue
+		}
+		if !slices.Contains(declared, field.Type) && field.Type != requestName && field.Type != responseName {
+			return "", fmt.Errorf("unknown type %s of field %s (declare the message first)", field.Type, field.Name)
+		}
+	}
+
+	loc := protoServiceRe.FindStringIndex(proto)
+	if loc == nil {
+		return "", fmt.Errorf("no service declared")
+	}
+	// Find the service's closing brace, skipping the options blocks of its rpcs
+	end, depth := -1, 0
+	for i := loc[1] - 1; i < len(proto) && end == -1; i++ {
+		switch proto[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				end = i
+			}
+		}
+	}
+	if end == -1 {
+		return "", fmt.Errorf("unterminated service declaration")
+	}
+	// Put the rpc on its own line, after the last one
+	at := strings.LastIndex(proto[:end], "\n") + 1
+	if strings.TrimSpace(proto[at:end]) != "" {
+		at = end
+	}
+	rpc := fmt.Sprintf("  rpc %s(%s) returns (%s);\n", method, requestName, responseName)
+	proto = proto[:at] + rpc + proto[at:]
+
+	proto = strings.TrimRight(proto, "\n") + "\n\n" + protoMessage(requestName, request) + "\n" + protoMessage(responseName, response)
+	return proto, nil
+}
+
+// rpcFileName returns the server file of a generated handler, e.g.
+// get_user.go for GetUser.
+func rpcFileName(method string) string {
+	return snakeCase(method) + ".go"
+}
@@ -2,48 +2,84 @@ package cmd
 
 import (
 	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/germtb/goli"
 	"github.com/germtb/gox"
 )
 
-type LogPaneProps struct {
-	Title string
-	Lines goli.Accessor[[]string]
+type RunAppProps struct {
+	ServerLines goli.Accessor[[]string]
+	ClientLines goli.Accessor[[]string]
+	GappLines   goli.Accessor[[]string]
+	ActiveTab   goli.Accessor[int]
+	AppURL      goli.Accessor[string]
+	View        goli.Accessor[logView]
 }
 
-func LogPane(props LogPaneProps) gox.VNode {
-	lines := props.Lines()
+func RunApp(props RunAppProps) gox.VNode {
+	active := props.ActiveTab()
+	var lines []string
+	switch active {
+	case 1:
+		lines = props.ServerLines()
+	case 2:
+		lines = props.ClientLines()
+	case 3:
+		lines = props.GappLines()
+	}
+
+	view := props.View()
+	lines = view.Visible(lines, logPageHeight())
+
+	status := " r/c restart server/client · g codegen · ↑↓ scroll · space pause · / filter · Ctrl+C to stop"
+	if url := props.AppURL(); url != "" {
+		status = " " + url + " ·" + status
+	}
+	if viewStatus := view.Status(); viewStatus != "" {
+		status = " " + viewStatus + " ·" + status
+	}
 
-	return gox.Element("box", gox.Props{"direction": "column", "grow": 1, "border": "rounded", "overflow": "hidden"},
+	serverLabel := " 1 Server "
+	clientLabel := " 2 Client "
+	gappLabel := " 3 Gapp "
+	if active == 1 {
+		serverLabel = " ● Server "
+	} else if active == 2 {
+		clientLabel = " ● Client "
+	} else {
+		gappLabel = " ● Gapp "
+	}
+
+	return gox.Element("box", gox.Props{"direction": "column", "grow": 1},
 		gox.Element("box", gox.Props{"direction": "row"},
-			gox.Element("text", gox.Props{"bold": true},
-				gox.V(" "+props.Title+" "))),
+			gox.Element("text", gox.Props{"bold": active == 1, "inverse": active == 1},
+				gox.V(serverLabel)),
+			gox.Element("text", gox.Props{"bold": active == 2, "inverse": active == 2},
+				gox.V(clientLabel)),
+			gox.Element("text", gox.Props{"bold": active == 3, "inverse": active == 3},
+				gox.V(gappLabel)),
+			gox.Element("text", gox.Props{"dim": true},
+				gox.V(status))),
 		gox.V(gox.Map(lines, func(line string) gox.VNode {
 			return gox.Element("ansi", nil,
 				gox.V(line))
 		})))
 }
 
-type RunAppProps struct {
-	ServerLines goli.Accessor[[]string]
-	ClientLines goli.Accessor[[]string]
-}
-
-func RunApp(props RunAppProps) gox.VNode {
-	return gox.Element("box", gox.Props{"direction": "column", "grow": 1},
-		LogPane(LogPaneProps{Title: "server", Lines: props.ServerLines}),
-		LogPane(LogPaneProps{Title: "client", Lines: props.ClientLines}),
-		gox.Element("text", gox.Props{"dim": true},
-			gox.V(" Ctrl+C to stop")))
-}
-
 func killProcessGroup(cmd *exec.Cmd) {
 	if cmd == nil || cmd.Process == nil {
 		return
@@ -55,29 +91,168 @@ func killProcessGroup(cmd *exec.Cmd) {
 }
 
 func RunRun(args []string) error {
-	if _, err := os.Stat("server/main.go"); os.IsNotExist(err) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	openFlag := fs.Bool("open", false, "Open the app in a browser once the server and vite are ready")
+	noOpenFlag := fs.Bool("no-open", false, "Don't open a browser, overriding --open")
+	logDirFlag := fs.String("log-dir", "", "Also write full server and client output to rotating files in this directory")
+	serverOnlyFlag := fs.Bool("server-only", false, "Only run the Go server, without vite or Node")
+	clientOnlyFlag := fs.Bool("client-only", false, "Only run vite, against --backend")
+	httpsFlag := fs.Bool("https", false, "Serve the server and vite over HTTPS with a local certificate (trusted if mkcert is installed)")
+	backendFlag := fs.String("backend", "", "Server URL vite proxies RPCs to with --client-only (default http://localhost:8080)")
+	portFlag := fs.Int("port", 0, "Server port (default $PORT or 8080)")
+	clientPortFlag := fs.Int("client-port", 0, "Vite dev server port (default vite's)")
+	positional, flagArgs := splitArgs(fs, args)
+	if err := fs.Parse(flagArgs); err != nil {
+		return err
+	}
+
+	// Optional project directory, whose gapp.toml [run] table sets defaults
+	projectDir := "."
+	if len(positional) > 0 {
+		projectDir = positional[0]
+	}
+	projectDir, err := resolveProjectDir(projectDir)
+	if err != nil {
+		return err
+	}
+	if err := applyProjectConfig(fs, projectDir, "run", []string{"log-dir"}, nil); err != nil {
+		return err
+	}
+	openBrowser := *openFlag && !*noOpenFlag
+	runServer := !*clientOnlyFlag
+	runClient := !*serverOnlyFlag
+	if !runServer && !runClient {
+		return fmt.Errorf("--server-only and --client-only can't be combined")
+	}
+	if *backendFlag != "" {
+		if runServer {
+			return fmt.Errorf("--backend requires --client-only")
+		}
+		// Read by the scaffolded vite.config.ts
+		os.Setenv("GAPP_SERVER_URL", strings.TrimSuffix(*backendFlag, "/"))
+	}
+
+	serverDir := filepath.Join(projectDir, "server")
+	clientDir := filepath.Join(projectDir, "client")
+
+	if _, err := os.Stat(filepath.Join(serverDir, "main.go")); os.IsNotExist(err) && runServer {
+		goli.Print(gox.Element("box", gox.Props{"direction": "row"},
+			gox.Element("text", gox.Props{"color": "red"},
+				gox.V("✗")),
+			gox.Element("text", nil,
+				gox.V(" Not a gapp project (server/main.go not found in "+projectDir+")"))))
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(clientDir, "package.json")); os.IsNotExist(err) && runClient {
 		goli.Print(gox.Element("box", gox.Props{"direction": "row"},
 			gox.Element("text", gox.Props{"color": "red"},
 				gox.V("✗")),
 			gox.Element("text", nil,
-				gox.V(" Not a gapp project (server/main.go not found)"))))
+				gox.V(" Not a gapp project (client/package.json not found in "+projectDir+", use --server-only to run without it)"))))
 		return err
 	}
 
+	// The server listens on $PORT, like gapp.LoadConfig
+	if *portFlag != 0 {
+		os.Setenv("PORT", strconv.Itoa(*portFlag))
+	}
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	if runServer && port != "8080" {
+		// Read by the scaffolded vite.config.ts
+		os.Setenv("GAPP_SERVER_URL", "http://localhost:"+port)
+	}
+
+	// Local HTTPS: the server reads GAPP_TLS_CERT/KEY through gapp.LoadConfig,
+	// the scaffolded vite.config.ts serves with them and proxies to https
+	scheme := "http"
+	if *httpsFlag {
+		cert, err := EnsureDevCert(filepath.Join(mustAbs(projectDir), ".gapp", "certs"))
+		if err != nil {
+			goli.Print(gox.Element("box", gox.Props{"direction": "row"},
+				gox.Element("text", gox.Props{"color": "red"},
+					gox.V("✗")),
+				gox.Element("text", nil,
+					gox.V(" Failed to create a development certificate: "+err.Error()))))
+			return err
+		}
+		os.Setenv("GAPP_TLS_CERT", cert.CertFile)
+		os.Setenv("GAPP_TLS_KEY", cert.KeyFile)
+		// Lets the vite plugin fetch preloads from the server
+		os.Setenv("NODE_EXTRA_CA_CERTS", cert.CAFile)
+		if !cert.Trusted {
+			goli.Print(gox.Element("box", gox.Props{"direction": "row"},
+				gox.Element("text", gox.Props{"color": "yellow"},
+					gox.V("!")),
+				gox.Element("text", nil,
+					gox.V(" Using a self-signed certificate; install mkcert and run `mkcert -install` for one browsers trust"))))
+		}
+		scheme = "https"
+		if runServer {
+			os.Setenv("GAPP_SERVER_URL", "https://localhost:"+port)
+		}
+	}
+
 	serverLines, setServerLines := goli.CreateSignal([]string{})
 	clientLines, setClientLines := goli.CreateSignal([]string{})
+	gappLines, setGappLines := goli.CreateSignal([]string{})
+	activeTab, setActiveTab := goli.CreateSignal(1)
+	appURL, setAppURL := goli.CreateSignal("")
+	view, setView := goli.CreateSignal(logView{})
+
+	logGapp := func(msg string) {
+		goli.SetWith(setGappLines, func(prev []string) []string {
+			return appendLog(prev, msg)
+		}, gappLines)
+	}
+
+	// Panes keep the tail of each process's output; log files keep all of it
+	var serverLog, clientLog io.Writer
+	var logFiles []*RotatingFile
+	if *logDirFlag != "" {
+		for _, name := range []string{"server.log", "client.log"} {
+			f, err := OpenRotatingFile(filepath.Join(*logDirFlag, name), 10<<20, 3)
+			if err != nil {
+				goli.Print(gox.Element("box", gox.Props{"direction": "row"},
+					gox.Element("text", gox.Props{"color": "red"},
+						gox.V("✗")),
+					gox.Element("text", nil,
+						gox.V(" Failed to open log file: "+err.Error()))))
+				return err
+			}
+			logFiles = append(logFiles, f)
+		}
+		serverLog, clientLog = logFiles[0], logFiles[1]
+	}
 
 	var serverCmd *exec.Cmd
 	var clientCmd *exec.Cmd
 	var mu sync.Mutex
+	var watcher *fsnotify.Watcher
+	var codegenWatcher *fsnotify.Watcher
+
+	// Canceled on exit, to stop waiting for readiness
+	ctx, cancel := context.WithCancel(context.Background())
 
 	var cleanupOnce sync.Once
 	cleanup := func() {
 		cleanupOnce.Do(func() {
+			cancel()
 			mu.Lock()
 			defer mu.Unlock()
 			killProcessGroup(serverCmd)
 			killProcessGroup(clientCmd)
+			if watcher != nil {
+				watcher.Close()
+			}
+			if codegenWatcher != nil {
+				codegenWatcher.Close()
+			}
+			for _, f := range logFiles {
+				f.Close()
+			}
 		})
 	}
 
@@ -89,7 +264,7 @@ func RunRun(args []string) error {
 		os.Exit(0)
 	}()
 
-	startSubprocess := func(name string, cmdArgs []string, dir string, setter goli.Setter[[]string], getter goli.Accessor[[]string]) *exec.Cmd {
+	startSubprocess := func(name string, cmdArgs []string, dir string, setter goli.Setter[[]string], getter goli.Accessor[[]string], logFile io.Writer, onLine func(string)) *exec.Cmd {
 		cmd := exec.Command(name, cmdArgs...)
 		cmd.Dir = dir
 		cmd.Env = append(os.Environ(), "FORCE_COLOR=1")
@@ -113,19 +288,27 @@ func RunRun(args []string) error {
 
 		setter([]string{"Starting " + name + " ..."})
 
+		var output io.Reader = r
+		if logFile != nil {
+			fmt.Fprintf(logFile, "=== %s: %s %s ===\n", time.Now().Format(time.RFC3339), name, strings.Join(cmdArgs, " "))
+			output = io.TeeReader(r, logFile)
+		}
+
 		go func() {
-			scanner := bufio.NewScanner(r)
+			scanner := bufio.NewScanner(output)
 			scanner.Buffer(make([]byte, 64*1024), 64*1024)
 			for scanner.Scan() {
 				line := scanner.Text()
+				if onLine != nil {
+					onLine(line)
+				}
 				goli.SetWith(setter, func(prev []string) []string {
-					next := append(prev, line)
-					if len(next) > 500 {
-						next = next[len(next)-500:]
-					}
-					return next
+					return appendLog(prev, line)
 				}, getter)
 			}
+			// Drain lines too long for the pane, so the process doesn't block
+			// and the log file stays complete
+			io.Copy(io.Discard, output)
 			r.Close()
 		}()
 
@@ -133,17 +316,173 @@ func RunRun(args []string) error {
 			cmd.Wait()
 			time.Sleep(50 * time.Millisecond)
 			goli.SetWith(setter, func(prev []string) []string {
-				return append(prev, "Process exited")
+				return appendLog(prev, "Process exited")
 			}, getter)
 		}()
 
 		return cmd
 	}
 
+	startServer := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		serverCmd = startSubprocess("go", []string{"run", "."}, serverDir, setServerLines, serverLines, serverLog, nil)
+	}
+
+	// Restarts requested while codegen writes server/generated are held back
+	// until it finishes, so the server restarts once on complete output
+	var codegenMu sync.Mutex
+	var codegenRunning, restartPending bool
+
+	doRestart := func(reason string) {
+		mu.Lock()
+		killProcessGroup(serverCmd)
+		mu.Unlock()
+
+		// Give the old process a moment to exit
+		time.Sleep(100 * time.Millisecond)
+
+		logGapp(reason)
+
+		startServer()
+	}
+
+	requestRestart := func(reason string) {
+		if !runServer {
+			return
+		}
+		codegenMu.Lock()
+		if codegenRunning {
+			restartPending = true
+			codegenMu.Unlock()
+			return
+		}
+		codegenMu.Unlock()
+		doRestart(reason)
+	}
+
+	restartServer := func() {
+		requestRestart("Server file change detected, restarting...")
+	}
+
+	// viteReady receives vite's URL each time it starts
+	viteReady := make(chan string, 1)
+	startClient := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		var viteArgs []string
+		if *clientPortFlag != 0 {
+			viteArgs = []string{"--port", strconv.Itoa(*clientPortFlag)}
+		}
+		clientCmd = startSubprocess(nodeBin(clientDir, "vite"), viteArgs, clientDir, setClientLines, clientLines, clientLog, func(line string) {
+			if url := viteURL(line); url != "" {
+				select {
+				case viteReady <- url:
+				default:
+				}
+			}
+		})
+	}
+
+	restartClient := func() {
+		if !runClient {
+			return
+		}
+		mu.Lock()
+		killProcessGroup(clientCmd)
+		mu.Unlock()
+
+		time.Sleep(100 * time.Millisecond)
+
+		logGapp("Restarting client...")
+		startClient()
+	}
+
+	var codegenRun sync.Mutex
+	runCodegen := func(force bool) {
+		codegenRun.Lock()
+		defer codegenRun.Unlock()
+
+		codegenMu.Lock()
+		codegenRunning = true
+		codegenMu.Unlock()
+
+		codegenArgs := projectCodegenArgs(projectDir)
+		if force {
+			codegenArgs = append(codegenArgs, "--force")
+		}
+		if !runClient {
+			// Without vite, Node may not be installed for the TypeScript plugin
+			codegenArgs = append(codegenArgs, "--skip-ts")
+		}
+		err := RunCodegen(codegenArgs)
+		if err != nil {
+			logGapp("Codegen error: " + err.Error())
+		} else {
+			logGapp("Codegen complete")
+		}
+
+		// Let the Go watcher's debounce see the last generated file
+		time.Sleep(400 * time.Millisecond)
+
+		codegenMu.Lock()
+		codegenRunning = false
+		pending := restartPending
+		restartPending = false
+		codegenMu.Unlock()
+		if pending {
+			doRestart("Generated code changed, restarting...")
+		}
+	}
+
 	goli.Run(func() gox.VNode {
-		return RunApp(RunAppProps{ServerLines: serverLines, ClientLines: clientLines})
+		return RunApp(RunAppProps{ServerLines: serverLines, ClientLines: clientLines, GappLines: gappLines, ActiveTab: activeTab, AppURL: appURL, View: view})
 	}, goli.RunOptions{
 		OnMount: func(app *goli.App) {
+			// Switching panes keeps the filter but returns to the live tail
+			switchTab := func(tab int) {
+				setActiveTab(tab)
+				setView(logView{Filter: view().Filter})
+			}
+			goli.Manager().SetGlobalKeyHandler(func(key string) bool {
+				var live []string
+				switch activeTab() {
+				case 1:
+					live = serverLines()
+				case 2:
+					live = clientLines()
+				case 3:
+					live = gappLines()
+				}
+				if next, ok := view().HandleKey(key, live, logPageHeight()); ok {
+					setView(next)
+					return true
+				}
+
+				switch key {
+				case "r":
+					go requestRestart("Restarting server...")
+					return true
+				case "c":
+					go restartClient()
+					return true
+				case "g":
+					logGapp("Running codegen...")
+					go runCodegen(true)
+					return true
+				case "1", "2", "3":
+					switchTab(int(key[0] - '0'))
+					return true
+				case goli.Tab:
+					switchTab(activeTab()%3 + 1)
+					return true
+				case goli.ShiftTab:
+					switchTab((activeTab()+1)%3 + 1)
+					return true
+				}
+				return false
+			})
+
 			go func() {
 				ticker := time.NewTicker(50 * time.Millisecond)
 				defer ticker.Stop()
@@ -152,8 +491,86 @@ func RunRun(args []string) error {
 				}
 			}()
 
-			serverCmd = startSubprocess("go", []string{"run", "."}, "server", setServerLines, serverLines)
-			clientCmd = startSubprocess("./node_modules/.bin/vite", nil, "client", setClientLines, clientLines)
+			if runServer {
+				logGapp("Starting server: go run . (" + serverDir + ")")
+				startServer()
+			} else {
+				setServerLines([]string{"Server not started (--client-only)"})
+			}
+			if runClient {
+				logGapp("Starting client: vite (" + clientDir + ")")
+				if url := os.Getenv("GAPP_SERVER_URL"); url != "" && !runServer {
+					logGapp("Proxying RPCs to " + url)
+				}
+				startClient()
+			} else {
+				setClientLines([]string{"Client not started (--server-only)"})
+			}
+
+			// Announce the app once the server is healthy and vite serves it
+			go func() {
+				ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+				defer cancel()
+
+				url := scheme + "://localhost:" + port
+				if runServer {
+					health := url + "/health"
+					if err := WaitForURL(ctx, health); err != nil {
+						logGapp("Server not healthy at " + health + ": " + err.Error())
+						return
+					}
+				}
+				if runClient {
+					select {
+					case url = <-viteReady:
+					case <-ctx.Done():
+						logGapp("Vite did not report its URL")
+						return
+					}
+					if err := WaitForURL(ctx, url); err != nil {
+						logGapp("Vite not ready at " + url + ": " + err.Error())
+						return
+					}
+				}
+
+				setAppURL(url)
+				logGapp("Ready at " + url)
+				if openBrowser {
+					if err := OpenBrowser(url); err != nil {
+						logGapp("Failed to open browser: " + err.Error())
+					}
+				}
+			}()
+
+			// Watch for .go file changes and restart server
+			if runServer {
+				logGapp("Watching " + serverDir + " for .go changes")
+				var watchErr error
+				watcher, watchErr = WatchGoFiles(serverDir, 300*time.Millisecond, restartServer)
+				if watchErr != nil {
+					logGapp("Warning: file watcher failed: " + watchErr.Error())
+				}
+			}
+
+			// Run codegen at startup and watch for proto/route changes
+			_, routesDir, _ := projectCodegenPaths(projectDir)
+			protoDir := filepath.Join(projectDir, "proto")
+			if protos, err := projectProtos(projectDir); err == nil {
+				protoDir = protos.Root
+			}
+			if _, err := os.Stat(protoDir); err == nil {
+				logGapp("Running initial codegen...")
+				go runCodegen(false)
+
+				var cwErr error
+				codegenWatcher, cwErr = WatchCodegenFiles(protoDir, routesDir, 500*time.Millisecond, func([]string) {
+					logGapp("Proto/route change detected, running codegen...")
+					runCodegen(false)
+				})
+				if cwErr != nil {
+					logGapp("Warning: codegen watcher failed: " + cwErr.Error())
+				}
+			}
 		},
 		OnUnmount: func() {
 			signal.Stop(sigCh)
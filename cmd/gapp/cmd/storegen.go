@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// storeRpc is an RPC whose results a generated store keeps.
+type storeRpc struct {
+	Method string
+	Key    string // state field, e.g. getUser
+	Type   string // TypeScript response type
+}
+
+// storeTemplateData is the data of the gapp generate store template.
+type storeTemplateData struct {
+	Store string // e.g. UserStore
+	Var   string // e.g. userStore
+	State string // e.g. UserState
+	Types string // the response types to import
+	Rpcs  []storeRpc
+	React bool
+}
+
+// serviceMethods returns the methods of the services declared in the file
+// being generated, and the file's proto package.
+func serviceMethods(req *pluginpb.CodeGeneratorRequest) ([]*descriptorpb.MethodDescriptorProto, string) {
+	var methods []*descriptorpb.MethodDescriptorProto
+	for _, file := range req.GetProtoFile() {
+		if !slices.Contains(req.GetFileToGenerate(), file.GetName()) {
+			continue
+		}
+		for _, service := range file.GetService() {
+			methods = append(methods, service.GetMethod()...)
+		}
+		return methods, file.GetPackage()
+	}
+	return nil, ""
+}
+
+// newStoreTemplateData derives a store's names from name and types its
+// state with the responses of rpcs. Without rpcs, the methods whose name
+// contains name are used, as GetItems and CreateItem are for Item.
+func newStoreTemplateData(name string, rpcs []string, req *pluginpb.CodeGeneratorRequest) (storeTemplateData, error) {
+	name = strings.TrimSuffix(name, "Store")
+	if name == "" || !isIdentifier(name) || name[0] < 'A' || name[0] > 'Z' {
+		return storeTemplateData{}, fmt.Errorf("invalid store name %q (want a PascalCase identifier)", name)
+	}
+	data := storeTemplateData{
+		Store: name + "Store",
+		Var:   strings.ToLower(name[:1]) + name[1:] + "Store",
+		State: name + "State",
+	}
+
+	methods, pkg := serviceMethods(req)
+	var names []string
+	for _, method := range methods {
+		names = append(names, method.GetName())
+	}
+	if len(rpcs) == 0 {
+		for _, method := range names {
+			if strings.Contains(method, name) {
+				rpcs = append(rpcs, method)
+			}
+		}
+		if len(rpcs) == 0 {
+			return data, fmt.Errorf("no RPC mentions %s, pick some with --rpc (one of %s)", name, strings.Join(names, ", "))
+		}
+	}
+
+	var types []string
+	for _, rpc := range rpcs {
+		i := slices.Index(names, rpc)
+		if i == -1 {
+			return data, fmt.Errorf("unknown RPC %s (want one of %s)", rpc, strings.Join(names, ", "))
+		}
+		// ts-proto names nested messages Outer_Inner
+		output, ok := strings.CutPrefix(methods[i].GetOutputType(), "."+pkg+".")
+		if pkg == "" {
+			output, ok = strings.CutPrefix(methods[i].GetOutputType(), ".")
+		}
+		if !ok {
+			return data, fmt.Errorf("%s returns %s, which isn't declared in the service's proto file", rpc, methods[i].GetOutputType())
+		}
+		output = strings.ReplaceAll(output, ".", "_")
+		data.Rpcs = append(data.Rpcs, storeRpc{
+			Method: rpc,
+			Key:    strings.ToLower(rpc[:1]) + rpc[1:],
+			Type:   output,
+		})
+		if !slices.Contains(types, output) {
+			types = append(types, output)
+		}
+	}
+	data.Types = strings.Join(types, ", ")
+	return data, nil
+}
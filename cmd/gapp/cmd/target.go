@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Deployment targets for gapp build --target.
+const (
+	targetSystemd = "systemd"
+	targetTar     = "tar"
+)
+
+// validTarget reports whether target is a known --target value.
+func validTarget(target string) bool {
+	return target == targetSystemd || target == targetTar
+}
+
+// startScript runs the server from its own directory, so it finds public/
+// and gapp.toml, with the variables in <name>.env.
+func startScript(name string) string {
+	return `#!/bin/sh
+# Generated by gapp build. Starts the server from this directory with the
+# variables in ` + name + `.env, if present.
+set -e
+cd "$(dirname "$0")"
+if [ -f ./` + name + `.env ]; then
+	set -a
+	. ./` + name + `.env
+	set +a
+fi
+exec ./server "$@"
+`
+}
+
+// systemdUnit runs the server extracted to /opt/<name> as a sandboxed
+// dynamic user, with its data under /var/lib/<name>.
+func systemdUnit(name string) string {
+	dir := "/opt/" + name
+	return `# Generated by gapp build --target systemd. Install with:
+#   sudo tar -xzf ` + name + `.tar.gz -C /opt
+#   sudo systemctl enable --now ` + dir + `/` + name + `.service
+
+[Unit]
+Description=` + name + `
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+WorkingDirectory=` + dir + `
+EnvironmentFile=-` + dir + `/` + name + `.env
+Environment=DATA_ROOT=%S/` + name + `
+ExecStart=` + dir + `/server
+Restart=on-failure
+RestartSec=2
+TimeoutStopSec=30
+
+DynamicUser=yes
+StateDirectory=` + name + `
+NoNewPrivileges=yes
+ProtectSystem=strict
+ProtectHome=yes
+PrivateTmp=yes
+
+[Install]
+WantedBy=multi-user.target
+`
+}
+
+// envFileTemplate lists the server's environment variables, commented out.
+// It's read by both the unit and the start script.
+func envFileTemplate(name string) string {
+	return `# Environment for ` + name + `. Uncomment what the deployment needs;
+# these override gapp.toml.
+# PORT=8080
+# GAPP_HOST=127.0.0.1
+# GAPP_ADDR=unix:/run/` + name + `/` + name + `.sock
+# APP_NAME=` + name + `
+# GAPP_TLS_CERT=/etc/` + name + `/cert.pem
+# GAPP_TLS_KEY=/etc/` + name + `/key.pem
+# GAPP_CORS_ORIGINS=https://example.com
+# GAPP_SHUTDOWN_TIMEOUT=20s
+`
+}
+
+// writeTarget adds the files for target to outputDir and packages it as
+// <name>.tar.gz inside it, returning the tarball's path. Everything is
+// timestamped built, so the tarball is reproducible.
+func writeTarget(outputDir, target, name string, built time.Time) (string, error) {
+	files := map[string]string{"start.sh": startScript(name)}
+	if target == targetSystemd {
+		files[name+".service"] = systemdUnit(name)
+		files[name+".env"] = envFileTemplate(name)
+	}
+	for file, content := range files {
+		mode := os.FileMode(0644)
+		if strings.HasSuffix(file, ".sh") {
+			mode = 0755
+		}
+		path := filepath.Join(outputDir, file)
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			return "", err
+		}
+		if err := os.Chtimes(path, built, built); err != nil {
+			return "", err
+		}
+	}
+	tarball := filepath.Join(outputDir, name+".tar.gz")
+	if err := writeTarball(outputDir, tarball, name, built); err != nil {
+		os.Remove(tarball)
+		return "", err
+	}
+	return tarball, nil
+}
+
+// writeTarball packages the files in dir under prefix/ as a gzipped tar at
+// path, which is skipped if it's inside dir. Every entry is timestamped
+// modTime.
+func writeTarball(dir, path, prefix string, modTime time.Time) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	err = filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if file == path {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(filepath.Join(prefix, rel))
+		// Owned by whoever extracts it, like other release tarballs
+		header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+		header.ModTime, header.AccessTime, header.ChangeTime = modTime, time.Time{}, time.Time{}
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("packaging %s: %w", dir, err)
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
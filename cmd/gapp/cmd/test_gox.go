@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/germtb/goli"
+	"github.com/germtb/gox"
+
+	"github.com/germtb/gapp/cmd/gapp/scaffold"
+)
+
+type TestStepProps struct {
+	Label   string
+	Success bool
+	Note    string
+}
+
+func TestStep(props TestStepProps) gox.VNode {
+	if props.Success {
+		return gox.Element("box", gox.Props{"direction": "row"},
+			gox.Element("text", gox.Props{"color": "green"},
+				gox.V("✓")),
+			gox.Element("text", nil,
+				gox.V(" "+props.Label)),
+			gox.Element("text", gox.Props{"dim": true},
+				gox.V(" "+props.Note)))
+	}
+	return gox.Element("box", gox.Props{"direction": "row"},
+		gox.Element("text", gox.Props{"color": "red"},
+			gox.V("✗")),
+		gox.Element("text", nil,
+			gox.V(" "+props.Label)),
+		gox.Element("text", gox.Props{"dim": true},
+			gox.V(" "+props.Note)))
+}
+
+// testSuite is the outcome of one stack's tests.
+type testSuite struct {
+	label   string
+	output  string
+	err     error
+	skipped string // why the suite didn't run, if it didn't
+}
+
+func RunTest(args []string) error {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	serverOnlyFlag := fs.Bool("server-only", false, "Only run the Go tests")
+	clientOnlyFlag := fs.Bool("client-only", false, "Only run the vitest tests")
+	integrationFlag := fs.Bool("integration", false, "Start the server for the client tests, at $GAPP_SERVER_URL")
+	verboseFlag := fs.Bool("v", false, "Show the output of passing suites too")
+	positional, flagArgs := splitArgs(fs, args)
+	if err := fs.Parse(flagArgs); err != nil {
+		return err
+	}
+
+	// Optional project directory, whose gapp.toml [test] table sets defaults
+	projectDir := "."
+	if len(positional) > 0 {
+		projectDir = positional[0]
+	}
+	projectDir, err := resolveProjectDir(projectDir)
+	if err != nil {
+		return err
+	}
+	if err := applyProjectConfig(fs, projectDir, "test", nil, nil); err != nil {
+		return err
+	}
+	if *serverOnlyFlag && *clientOnlyFlag {
+		return fmt.Errorf("--server-only and --client-only can't be combined")
+	}
+	serverDir := filepath.Join(projectDir, "server")
+	clientDir := filepath.Join(projectDir, "client")
+	if _, err := os.Stat(filepath.Join(serverDir, "go.mod")); os.IsNotExist(err) && !*clientOnlyFlag {
+		return fmt.Errorf("not a gapp project (server/go.mod not found in %s)", projectDir)
+	}
+	if _, err := os.Stat(filepath.Join(clientDir, "package.json")); os.IsNotExist(err) && !*serverOnlyFlag {
+		return fmt.Errorf("not a gapp project (client/package.json not found in %s)", projectDir)
+	}
+
+	// The suites run concurrently, and each is reported as it finishes
+	var printMu sync.Mutex
+	var suites []testSuite
+	report := func(suite testSuite) {
+		printMu.Lock()
+		defer printMu.Unlock()
+		suites = append(suites, suite)
+		if suite.skipped != "" {
+			goli.Print(TestStep(TestStepProps{Label: suite.label, Success: true, Note: "(skipped: " + suite.skipped + ")"}))
+			return
+		}
+		if suite.err != nil || *verboseFlag {
+			fmt.Print(suite.output)
+		}
+		if suite.err != nil {
+			goli.Print(TestStep(TestStepProps{Label: suite.label, Success: false, Note: "(" + suite.err.Error() + ")"}))
+		} else {
+			goli.Print(TestStep(TestStepProps{Label: suite.label, Success: true, Note: ""}))
+		}
+	}
+
+	var wg sync.WaitGroup
+	if !*clientOnlyFlag {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report(runServerTests(serverDir))
+		}()
+	}
+	if !*serverOnlyFlag {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report(runClientTests(serverDir, clientDir, *integrationFlag))
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, suite := range suites {
+		if suite.err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d test suites failed", failed, len(suites))
+	}
+	return nil
+}
+
+// runServerTests runs go test ./... in serverDir.
+func runServerTests(serverDir string) testSuite {
+	suite := testSuite{label: "Server tests (go test ./...)"}
+	cmd := exec.Command("go", "test", "./...")
+	cmd.Dir = serverDir
+	out, err := cmd.CombinedOutput()
+	suite.output, suite.err = string(out), err
+	return suite
+}
+
+// runClientTests runs vitest in clientDir. With integration, the server is
+// built and started first, and its URL passed to the tests as
+// $GAPP_SERVER_URL.
+func runClientTests(serverDir, clientDir string, integration bool) testSuite {
+	suite := testSuite{label: "Client tests (vitest run)"}
+	vitest := nodeBin(clientDir, "vitest")
+	if _, err := os.Stat(vitest); err != nil {
+		pkg, _ := os.ReadFile(filepath.Join(clientDir, "package.json"))
+		if strings.Contains(string(pkg), "\"vitest\"") {
+			suite.err = fmt.Errorf("vitest isn't installed, run %s install in client/", scaffold.DetectPackageManager(clientDir))
+		} else {
+			suite.skipped = "vitest isn't a client dependency"
+		}
+		return suite
+	}
+
+	env := os.Environ()
+	if integration {
+		suite.label = "Client integration tests (vitest run)"
+		tmpDir, err := os.MkdirTemp("", "gapp-test-")
+		if err != nil {
+			suite.err = err
+			return suite
+		}
+		defer os.RemoveAll(tmpDir)
+		binary := filepath.Join(tmpDir, "server")
+		build := exec.Command("go", "build", "-o", binary, ".")
+		build.Dir = serverDir
+		if out, err := build.CombinedOutput(); err != nil {
+			suite.output, suite.err = string(out), fmt.Errorf("building server: %w", err)
+			return suite
+		}
+		server, err := startLocalServer(binary, serverDir)
+		if err != nil {
+			suite.output, suite.err = err.Error()+"\n", fmt.Errorf("server didn't start")
+			return suite
+		}
+		defer server.Stop()
+		env = append(env, "GAPP_SERVER_URL="+server.URL)
+	}
+
+	cmd := exec.Command(mustAbs(vitest), "run")
+	cmd.Dir = clientDir
+	cmd.Env = env
+	out, err := cmd.CombinedOutput()
+	suite.output, suite.err = string(out), err
+	return suite
+}
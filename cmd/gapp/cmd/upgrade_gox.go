@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/germtb/goli"
+	"github.com/germtb/gox"
+
+	"github.com/germtb/gapp/cmd/gapp/internal/codegen"
+	"github.com/germtb/gapp/cmd/gapp/scaffold"
+)
+
+type UpgradeStepProps struct {
+	Label   string
+	Success bool
+	Err     string
+}
+
+func UpgradeStep(props UpgradeStepProps) gox.VNode {
+	if props.Success {
+		return gox.Element("box", gox.Props{"direction": "row"},
+			gox.Element("text", gox.Props{"color": "green"},
+				gox.V("✓")),
+			gox.Element("text", nil,
+				gox.V(" "+props.Label)))
+	}
+	return gox.Element("box", gox.Props{"direction": "column"},
+		gox.Element("box", gox.Props{"direction": "row"},
+			gox.Element("text", gox.Props{"color": "red"},
+				gox.V("✗")),
+			gox.Element("text", nil,
+				gox.V(" "+props.Label))),
+		gox.Element("text", gox.Props{"dim": true},
+			gox.V("    "+props.Err)))
+}
+
+// gappModule is the Go module gapp upgrade bumps in server/go.mod.
+const gappModule = "github.com/germtb/gapp"
+
+// gappPackages are the npm packages gapp upgrade bumps in client/.
+var gappPackages = []string{"@gapp/client", "@gapp/react"}
+
+// managedUpdate is a gapp-owned file whose template changed.
+type managedUpdate struct {
+	path    string // project-relative
+	content string
+	diff    string
+}
+
+func RunUpgrade(args []string) error {
+	fs := flag.NewFlagSet("upgrade", flag.ExitOnError)
+	versionFlag := fs.String("version", "latest", "gapp version to upgrade to")
+	yesFlag := fs.Bool("y", false, "Apply the changes without asking")
+	positional, flagArgs := splitArgs(fs, args)
+	if err := fs.Parse(flagArgs); err != nil {
+		return err
+	}
+
+	projectDir := "."
+	if len(positional) > 0 {
+		projectDir = positional[0]
+	}
+	projectDir, err := resolveProjectDir(projectDir)
+	if err != nil {
+		return err
+	}
+	serverDir := filepath.Join(projectDir, "server")
+	clientDir := filepath.Join(projectDir, "client")
+
+	config, err := scaffold.DetectConfig(projectDir)
+	if err != nil {
+		goli.Print(UpgradeStep(UpgradeStepProps{Label: "Read project", Success: false, Err: err.Error()}))
+		return fmt.Errorf("not a gapp project: %w", err)
+	}
+
+	// Files gapp owns are compared with the current templates first, so the
+	// changes can be reviewed before anything is written
+	var updates []managedUpdate
+	for _, path := range scaffold.ManagedFiles() {
+		want, err := scaffold.Render(config, path)
+		if err != nil {
+			return err
+		}
+		have, err := os.ReadFile(filepath.Join(projectDir, path))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if diff := unifiedDiff(path, string(have), want); diff != "" {
+			updates = append(updates, managedUpdate{path: path, content: want, diff: diff})
+		}
+	}
+
+	// Go versions are v-prefixed, npm versions aren't
+	goVersion := *versionFlag
+	if goVersion != "latest" && !strings.HasPrefix(goVersion, "v") {
+		goVersion = "v" + goVersion
+	}
+	npmVersion := strings.TrimPrefix(*versionFlag, "v")
+
+	var npmArgs []string
+	if config.GappClientPath == "" {
+		npmArgs = append(npmArgs, gappPackages[0]+"@"+npmVersion)
+	}
+	if config.Framework == scaffold.FrameworkReact && config.GappReactPath == "" {
+		npmArgs = append(npmArgs, gappPackages[1]+"@"+npmVersion)
+	}
+
+	fmt.Println("gapp upgrade will:")
+	if config.GappServerPath == "" {
+		fmt.Printf("  bump %s to %s in server/go.mod\n", gappModule, goVersion)
+	} else {
+		fmt.Printf("  keep %s (replaced by %s)\n", gappModule, config.GappServerPath)
+	}
+	if len(npmArgs) > 0 {
+		fmt.Printf("  install %s in client/\n", strings.Join(npmArgs, " "))
+	} else {
+		fmt.Println("  keep the @gapp packages (installed from local paths)")
+	}
+	for _, update := range updates {
+		fmt.Printf("  update %s\n", update.path)
+	}
+	fmt.Println("  regenerate the generated code")
+	for _, update := range updates {
+		fmt.Println()
+		fmt.Print(update.diff)
+	}
+	fmt.Println()
+
+	if !*yesFlag {
+		if !confirm("Apply these changes?") {
+			return fmt.Errorf("upgrade cancelled")
+		}
+	}
+
+	// Step 1: bump the Go module
+	if config.GappServerPath == "" {
+		for _, goArgs := range [][]string{{"get", gappModule + "@" + goVersion}, {"mod", "tidy"}} {
+			goCmd := exec.Command("go", goArgs...)
+			goCmd.Dir = serverDir
+			if out, err := goCmd.CombinedOutput(); err != nil {
+				goli.Print(UpgradeStep(UpgradeStepProps{Label: "go " + strings.Join(goArgs, " "), Success: false, Err: string(out)}))
+				return fmt.Errorf("upgrading %s: %w", gappModule, err)
+			}
+		}
+		goli.Print(UpgradeStep(UpgradeStepProps{Label: "Upgrade " + gappModule + " (" + goVersion + ")", Success: true, Err: ""}))
+	}
+
+	// Step 2: bump the npm packages
+	if len(npmArgs) > 0 {
+		addArgs := config.PackageManager.AddArgs(npmArgs...)
+		npmCmd := exec.Command(string(config.PackageManager), addArgs...)
+		npmCmd.Dir = clientDir
+		if out, err := npmCmd.CombinedOutput(); err != nil {
+			goli.Print(UpgradeStep(UpgradeStepProps{Label: string(config.PackageManager) + " " + addArgs[0], Success: false, Err: string(out)}))
+			return fmt.Errorf("upgrading npm packages: %w", err)
+		}
+		goli.Print(UpgradeStep(UpgradeStepProps{Label: "Upgrade " + strings.Join(npmArgs, " "), Success: true, Err: ""}))
+	}
+
+	// Step 3: rewrite the files gapp owns
+	for _, update := range updates {
+		path := filepath.Join(projectDir, update.path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(update.content), 0644); err != nil {
+			goli.Print(UpgradeStep(UpgradeStepProps{Label: "Update " + update.path, Success: false, Err: err.Error()}))
+			return err
+		}
+		goli.Print(UpgradeStep(UpgradeStepProps{Label: "Update " + update.path, Success: true, Err: ""}))
+	}
+
+	// Step 4: regenerate with the new codegen
+	if protoFile, _, err := projectCodegenPaths(projectDir); err != nil {
+		return err
+	} else if _, err := codegen.FindProtos(protoFile, nil); err == nil {
+		if err := RunCodegen(append(projectCodegenArgs(projectDir), "--force")); err != nil {
+			return fmt.Errorf("codegen failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// confirm asks a yes/no question on the terminal, defaulting to no.
+func confirm(question string) bool {
+	fmt.Print(question + " [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
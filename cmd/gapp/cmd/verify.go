@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// localServerTimeout bounds how long a local server has to become healthy.
+const localServerTimeout = 30 * time.Second
+
+// localServerEnvOverrides are unset for servers started by gapp build
+// --verify and gapp test, so they listen where they're told and use their
+// own public/.
+var localServerEnvOverrides = []string{
+	"PORT", "GAPP_HOST", "GAPP_ADDR", "GAPP_CONFIG", "GAPP_PUBLIC_DIR",
+	"GAPP_MANIFEST_PATH", "GAPP_TLS_CERT", "GAPP_TLS_KEY", "DATA_ROOT",
+}
+
+// localServer is a server binary running on a free local port with a
+// throwaway DATA_ROOT.
+type localServer struct {
+	URL string
+
+	cmd      *exec.Cmd
+	output   *lockedBuffer
+	exited   chan struct{}
+	dataRoot string
+}
+
+// startLocalServer runs binary in dir and waits until its /health reports ok.
+func startLocalServer(binary, dir string) (*localServer, error) {
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	dataRoot, err := os.MkdirTemp("", "gapp-server-")
+	if err != nil {
+		return nil, err
+	}
+
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if !slices.Contains(localServerEnvOverrides, name) {
+			env = append(env, kv)
+		}
+	}
+	env = append(env, "PORT="+strconv.Itoa(port), "GAPP_HOST=127.0.0.1", "DATA_ROOT="+dataRoot)
+
+	s := &localServer{
+		URL:      "http://127.0.0.1:" + strconv.Itoa(port),
+		cmd:      exec.Command(mustAbs(binary)),
+		output:   &lockedBuffer{},
+		exited:   make(chan struct{}),
+		dataRoot: dataRoot,
+	}
+	s.cmd.Dir = dir
+	s.cmd.Env = env
+	s.cmd.Stdout = s.output
+	s.cmd.Stderr = s.output
+	if err := s.cmd.Start(); err != nil {
+		os.RemoveAll(dataRoot)
+		return nil, err
+	}
+	go func() {
+		s.cmd.Wait()
+		close(s.exited)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), localServerTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-s.exited:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := WaitForURL(ctx, s.URL+"/health"); err != nil {
+		select {
+		case <-s.exited:
+			os.RemoveAll(dataRoot)
+			return nil, fmt.Errorf("server exited before becoming healthy%s", s.Output())
+		default:
+		}
+		status, body := fetchStatus(s.URL + "/health")
+		s.Stop()
+		return nil, fmt.Errorf("/health didn't report ok within %s (last response: %s %s)%s", localServerTimeout, status, body, s.Output())
+	}
+	return s, nil
+}
+
+// Output returns the last lines the server logged, after a newline.
+func (s *localServer) Output() string {
+	return s.output.Tail(20)
+}
+
+// Stop interrupts the server, killing it if it doesn't exit within 5s.
+func (s *localServer) Stop() {
+	s.cmd.Process.Signal(os.Interrupt)
+	select {
+	case <-s.exited:
+	case <-time.After(5 * time.Second):
+		s.cmd.Process.Kill()
+		<-s.exited
+	}
+	os.RemoveAll(s.dataRoot)
+}
+
+// verifyBuild boots the server built into dir and checks that /health
+// reports ok and that / renders, exercising the preload engine and the asset
+// manifest.
+func verifyBuild(dir string) error {
+	server, err := startLocalServer(filepath.Join(dir, "server"), dir)
+	if err != nil {
+		return err
+	}
+	defer server.Stop()
+
+	resp, err := http.Get(server.URL + "/")
+	if err != nil {
+		return fmt.Errorf("requesting /: %w", err)
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("/ responded %s: %s%s", resp.Status, strings.TrimSpace(string(body)), server.Output())
+	}
+	return nil
+}
+
+// freePort returns a local TCP port that's free right now.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// fetchStatus returns the status and start of the body at url, for errors.
+func fetchStatus(url string) (string, string) {
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "no response", err.Error()
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return resp.Status, strings.TrimSpace(string(body))
+}
+
+// lockedBuffer collects a subprocess's output from several goroutines.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// Tail returns the last n lines written, after a newline, or "" if nothing
+// was written.
+func (b *lockedBuffer) Tail(n int) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.buf.Len() == 0 {
+		return ""
+	}
+	lines := strings.Split(strings.TrimRight(b.buf.String(), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return "\n" + strings.Join(lines, "\n")
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -72,25 +73,28 @@ func WatchGoFiles(dir string, debounce time.Duration, onChange func()) (*fsnotif
 }
 
 // WatchCodegenFiles watches for proto and route file changes and calls onChange
-// after debouncing. It watches *.proto files in protoDir and *.ts/*.tsx files
-// in routesDir. Returns the watcher so the caller can close it.
-func WatchCodegenFiles(protoDir, routesDir string, debounce time.Duration, onChange func()) (*fsnotify.Watcher, error) {
+// after debouncing, with the files that changed. It watches *.proto files in
+// protoDir and its subdirectories, and *.ts/*.tsx files in routesDir and its
+// subdirectories. Returns the watcher so the caller can close it.
+func WatchCodegenFiles(protoDir, routesDir string, debounce time.Duration, onChange func(changed []string)) (*fsnotify.Watcher, error) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
 	}
 
-	// Watch proto directory if it exists
-	if _, err := os.Stat(protoDir); err == nil {
-		if err := watcher.Add(protoDir); err != nil {
-			watcher.Close()
-			return nil, err
+	// Watch the proto and routes directories if they exist, with the
+	// packages and nested routes below them
+	for _, dir := range []string{protoDir, routesDir} {
+		if _, err := os.Stat(dir); err != nil {
+			continue
 		}
-	}
-
-	// Watch routes directory if it exists
-	if _, err := os.Stat(routesDir); err == nil {
-		if err := watcher.Add(routesDir); err != nil {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || !info.IsDir() {
+				return nil
+			}
+			return watcher.Add(path)
+		})
+		if err != nil {
 			watcher.Close()
 			return nil, err
 		}
@@ -104,6 +108,7 @@ func WatchCodegenFiles(protoDir, routesDir string, debounce time.Duration, onCha
 
 	var mu sync.Mutex
 	var timer *time.Timer
+	var changed []string
 
 	go func() {
 		for {
@@ -122,7 +127,16 @@ func WatchCodegenFiles(protoDir, routesDir string, debounce time.Duration, onCha
 				if timer != nil {
 					timer.Stop()
 				}
-				timer = time.AfterFunc(debounce, onChange)
+				if !slices.Contains(changed, event.Name) {
+					changed = append(changed, event.Name)
+				}
+				timer = time.AfterFunc(debounce, func() {
+					mu.Lock()
+					files := changed
+					changed = nil
+					mu.Unlock()
+					onChange(files)
+				})
 				mu.Unlock()
 
 			case _, ok := <-watcher.Errors:
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// workspaceApps returns the apps of the monorepo rooted at dir, as gapp init
+// --layout monorepo lays it out: a go.work next to apps/<name>/ projects. ok
+// is false if dir isn't a monorepo root.
+func workspaceApps(dir string) (apps []string, ok bool) {
+	if _, err := os.Stat(filepath.Join(dir, "go.work")); err != nil {
+		return nil, false
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "apps"))
+	if err != nil {
+		return nil, false
+	}
+	for _, entry := range entries {
+		app := filepath.Join(dir, "apps", entry.Name())
+		if _, err := os.Stat(filepath.Join(app, "server", "go.mod")); entry.IsDir() && err == nil {
+			apps = append(apps, app)
+		}
+	}
+	return apps, len(apps) > 0
+}
+
+// resolveProjectDir returns the project a command works on for the path it
+// was given: the path itself, or the app of the monorepo rooted there if it
+// has a single one.
+func resolveProjectDir(path string) (string, error) {
+	apps, ok := workspaceApps(path)
+	switch {
+	case !ok:
+		return path, nil
+	case len(apps) == 1:
+		return apps[0], nil
+	default:
+		return "", fmt.Errorf("%s is a monorepo with several apps, pick one of %s", path, strings.Join(apps, ", "))
+	}
+}
+
+// nodeBin returns the path of the binary of an npm package the client in
+// clientDir depends on. npm workspaces install binaries at the workspace
+// root, so the directories above clientDir are searched too.
+func nodeBin(clientDir, name string) string {
+	dir, err := filepath.Abs(clientDir)
+	if err != nil {
+		dir = clientDir
+	}
+	for d := dir; ; d = filepath.Dir(d) {
+		candidate := filepath.Join(d, "node_modules", ".bin", name)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+		if filepath.Dir(d) == d {
+			return filepath.Join(dir, "node_modules", ".bin", name)
+		}
+	}
+}
+
+// isOutside reports whether path is outside of dir.
+func isOutside(dir, path string) bool {
+	rel, err := filepath.Rel(mustAbs(dir), mustAbs(path))
+	return err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -3,6 +3,7 @@ module github.com/germtb/gapp/cmd/gapp
 go 1.24.0
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/bufbuild/protocompile v0.14.1
 	github.com/fsnotify/fsnotify v1.9.0
 	github.com/germtb/goli v0.1.12
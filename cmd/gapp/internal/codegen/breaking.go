@@ -0,0 +1,238 @@
+package codegen
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// schemaFile is where the protos of the last codegen run are kept, as a
+// FileDescriptorSet, for BreakingChanges to compare the next ones with.
+const schemaFile = "schema.binpb"
+
+// ReadStoredSchema reads the protos stored by WriteSchema in
+// .gapp/schema.binpb, or returns nil if there are none.
+func ReadStoredSchema(projectDir string) (*descriptorpb.FileDescriptorSet, error) {
+	data, err := os.ReadFile(filepath.Join(projectDir, ".gapp", schemaFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("reading stored protos %s: %w", schemaFile, err)
+	}
+	return &set, nil
+}
+
+// WriteSchema stores the files req generates in .gapp/schema.binpb, without
+// their comments, which can't break anything.
+func WriteSchema(projectDir string, req *pluginpb.CodeGeneratorRequest) error {
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, file := range req.GetProtoFile() {
+		if slices.Contains(req.GetFileToGenerate(), file.GetName()) {
+			file = proto.Clone(file).(*descriptorpb.FileDescriptorProto)
+			file.SourceCodeInfo = nil
+			set.File = append(set.File, file)
+		}
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(set)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(projectDir, ".gapp")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, schemaFile), data, 0644)
+}
+
+// BreakingChanges compares the files req generates with the stored ones and
+// describes the changes that break the wire format for clients built
+// against old, such as deployed clients hydrating preloaded responses:
+// removed messages, enums, services and RPCs, fields and enum values removed
+// without reserving their numbers, fields renumbered or changing type, and
+// RPCs changing their request, response or streaming. Types are matched by
+// full name, so moving them between files breaks nothing.
+func BreakingChanges(old *descriptorpb.FileDescriptorSet, req *pluginpb.CodeGeneratorRequest) []string {
+	before := indexSchema(old.GetFile())
+	var generated []*descriptorpb.FileDescriptorProto
+	for _, file := range req.GetProtoFile() {
+		if slices.Contains(req.GetFileToGenerate(), file.GetName()) {
+			generated = append(generated, file)
+		}
+	}
+	after := indexSchema(generated)
+
+	var changes []string
+	for _, name := range slices.Sorted(maps.Keys(before.messages)) {
+		msg, ok := after.messages[name]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("message %s was removed", name))
+			continue
+		}
+		changes = append(changes, fieldChanges(name, before.messages[name], msg)...)
+	}
+	for _, name := range slices.Sorted(maps.Keys(before.enums)) {
+		enum, ok := after.enums[name]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("enum %s was removed", name))
+			continue
+		}
+		for _, value := range before.enums[name].GetValue() {
+			if !slices.ContainsFunc(enum.GetValue(), func(v *descriptorpb.EnumValueDescriptorProto) bool { return v.GetNumber() == value.GetNumber() }) &&
+				!enumReserved(enum, value.GetNumber()) {
+				changes = append(changes, fmt.Sprintf("enum value %s.%s (%d) was removed without reserving its number", name, value.GetName(), value.GetNumber()))
+			}
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(before.services)) {
+		service, ok := after.services[name]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("service %s was removed", name))
+			continue
+		}
+		for _, method := range before.services[name].GetMethod() {
+			i := slices.IndexFunc(service.GetMethod(), func(m *descriptorpb.MethodDescriptorProto) bool { return m.GetName() == method.GetName() })
+			if i < 0 {
+				changes = append(changes, fmt.Sprintf("rpc %s.%s was removed", name, method.GetName()))
+				continue
+			}
+			now := service.GetMethod()[i]
+			if now.GetInputType() != method.GetInputType() {
+				changes = append(changes, fmt.Sprintf("rpc %s.%s changed its request from %s to %s", name, method.GetName(), strings.TrimPrefix(method.GetInputType(), "."), strings.TrimPrefix(now.GetInputType(), ".")))
+			}
+			if now.GetOutputType() != method.GetOutputType() {
+				changes = append(changes, fmt.Sprintf("rpc %s.%s changed its response from %s to %s", name, method.GetName(), strings.TrimPrefix(method.GetOutputType(), "."), strings.TrimPrefix(now.GetOutputType(), ".")))
+			}
+			if now.GetClientStreaming() != method.GetClientStreaming() || now.GetServerStreaming() != method.GetServerStreaming() {
+				changes = append(changes, fmt.Sprintf("rpc %s.%s changed from %s to %s", name, method.GetName(), streaming(method), streaming(now)))
+			}
+		}
+	}
+	return changes
+}
+
+// fieldChanges describes the breaking changes between the fields of two
+// versions of the message name.
+func fieldChanges(name string, before, after *descriptorpb.DescriptorProto) []string {
+	var changes []string
+	for _, field := range before.GetField() {
+		byNumber := slices.IndexFunc(after.GetField(), func(f *descriptorpb.FieldDescriptorProto) bool { return f.GetNumber() == field.GetNumber() })
+		byName := slices.IndexFunc(after.GetField(), func(f *descriptorpb.FieldDescriptorProto) bool { return f.GetName() == field.GetName() })
+		switch {
+		case byNumber >= 0:
+			now := after.GetField()[byNumber]
+			if was, is := fieldType(before, field), fieldType(after, now); was != is {
+				changes = append(changes, fmt.Sprintf("field %s.%s (%d) changed type from %s to %s", name, now.GetName(), field.GetNumber(), was, is))
+			}
+		case byName >= 0:
+			changes = append(changes, fmt.Sprintf("field %s.%s was renumbered from %d to %d", name, field.GetName(), field.GetNumber(), after.GetField()[byName].GetNumber()))
+		case !messageReserved(after, field.GetNumber()):
+			changes = append(changes, fmt.Sprintf("field %s.%s (%d) was removed without reserving its number", name, field.GetName(), field.GetNumber()))
+		}
+	}
+	return changes
+}
+
+// fieldType describes the type of a field of msg as it's encoded, e.g.
+// repeated int64, app.Item or map<string, int32>.
+func fieldType(msg *descriptorpb.DescriptorProto, field *descriptorpb.FieldDescriptorProto) string {
+	var t string
+	switch field.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, descriptorpb.FieldDescriptorProto_TYPE_ENUM, descriptorpb.FieldDescriptorProto_TYPE_GROUP:
+		t = strings.TrimPrefix(field.GetTypeName(), ".")
+		// Map fields are repeated entries nested in msg
+		entry := t[strings.LastIndex(t, ".")+1:]
+		for _, nested := range msg.GetNestedType() {
+			if nested.GetName() == entry && nested.GetOptions().GetMapEntry() && len(nested.GetField()) == 2 {
+				return fmt.Sprintf("map<%s, %s>", fieldType(nested, nested.GetField()[0]), fieldType(nested, nested.GetField()[1]))
+			}
+		}
+	default:
+		t = strings.ToLower(strings.TrimPrefix(field.GetType().String(), "TYPE_"))
+	}
+	if field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+		return "repeated " + t
+	}
+	return t
+}
+
+func messageReserved(msg *descriptorpb.DescriptorProto, number int32) bool {
+	return slices.ContainsFunc(msg.GetReservedRange(), func(r *descriptorpb.DescriptorProto_ReservedRange) bool {
+		return number >= r.GetStart() && number < r.GetEnd() // end is exclusive
+	})
+}
+
+func enumReserved(enum *descriptorpb.EnumDescriptorProto, number int32) bool {
+	return slices.ContainsFunc(enum.GetReservedRange(), func(r *descriptorpb.EnumDescriptorProto_EnumReservedRange) bool {
+		return number >= r.GetStart() && number <= r.GetEnd() // end is inclusive
+	})
+}
+
+func streaming(method *descriptorpb.MethodDescriptorProto) string {
+	switch {
+	case method.GetClientStreaming() && method.GetServerStreaming():
+		return "bidirectional streaming"
+	case method.GetClientStreaming():
+		return "client streaming"
+	case method.GetServerStreaming():
+		return "server streaming"
+	}
+	return "unary"
+}
+
+// schemaIndex is the types of a set of files, by full name without the
+// leading dot, e.g. app.Item.Size.
+type schemaIndex struct {
+	messages map[string]*descriptorpb.DescriptorProto
+	enums    map[string]*descriptorpb.EnumDescriptorProto
+	services map[string]*descriptorpb.ServiceDescriptorProto
+}
+
+func indexSchema(files []*descriptorpb.FileDescriptorProto) schemaIndex {
+	index := schemaIndex{
+		messages: make(map[string]*descriptorpb.DescriptorProto),
+		enums:    make(map[string]*descriptorpb.EnumDescriptorProto),
+		services: make(map[string]*descriptorpb.ServiceDescriptorProto),
+	}
+	var addMessage func(prefix string, msg *descriptorpb.DescriptorProto)
+	addMessage = func(prefix string, msg *descriptorpb.DescriptorProto) {
+		name := prefix + msg.GetName()
+		// Map entries are covered by their field's type
+		if !msg.GetOptions().GetMapEntry() {
+			index.messages[name] = msg
+		}
+		for _, enum := range msg.GetEnumType() {
+			index.enums[name+"."+enum.GetName()] = enum
+		}
+		for _, nested := range msg.GetNestedType() {
+			addMessage(name+".", nested)
+		}
+	}
+	for _, file := range files {
+		prefix := ""
+		if file.GetPackage() != "" {
+			prefix = file.GetPackage() + "."
+		}
+		for _, msg := range file.GetMessageType() {
+			addMessage(prefix, msg)
+		}
+		for _, enum := range file.GetEnumType() {
+			index.enums[prefix+enum.GetName()] = enum
+		}
+		for _, service := range file.GetService() {
+			index.services[prefix+service.GetName()] = service
+		}
+	}
+	return index
+}
@@ -0,0 +1,90 @@
+package codegen
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBreakingChanges(t *testing.T) {
+	req := compileProto(t, map[string]string{"service.proto": `syntax = "proto3";
+package app;
+
+enum Status {
+  STATUS_UNSPECIFIED = 0;
+  STATUS_ACTIVE = 1;
+  STATUS_ARCHIVED = 2;
+}
+
+message Item {
+  string id = 1;
+  int64 price = 2;
+  string title = 3;
+  string note = 4;
+  string legacy = 5;
+  map<string, int32> stock = 6;
+  Status status = 7;
+}
+
+message Empty {}
+
+service Shop {
+  rpc GetItem(Empty) returns (Item);
+  rpc ListItems(Empty) returns (stream Item);
+  rpc DeleteItem(Item) returns (Empty);
+}
+`})
+	dir := t.TempDir()
+	if err := WriteSchema(dir, req); err != nil {
+		t.Fatal(err)
+	}
+	old, err := ReadStoredSchema(dir)
+	if err != nil || old == nil {
+		t.Fatalf("ReadStoredSchema = %v, %v", old, err)
+	}
+	if changes := BreakingChanges(old, req); len(changes) != 0 {
+		t.Errorf("BreakingChanges of the same protos = %q, want none", changes)
+	}
+
+	req = compileProto(t, map[string]string{"service.proto": `syntax = "proto3";
+package app;
+
+enum Status {
+  STATUS_UNSPECIFIED = 0;
+  STATUS_ACTIVE = 1;
+}
+
+message Item {
+  reserved 5;
+
+  string id = 1;
+  string price = 2;
+  string headline = 3;
+  string note = 8;
+  map<string, int64> stock = 6;
+  Status status = 7;
+  string added = 9;
+}
+
+message Empty {}
+
+service Shop {
+  rpc GetItem(Empty) returns (Item);
+  rpc ListItems(Empty) returns (Item);
+}
+`})
+	want := []string{
+		"field app.Item.price (2) changed type from int64 to string",
+		"field app.Item.note was renumbered from 4 to 8",
+		"field app.Item.stock (6) changed type from map<string, int32> to map<string, int64>",
+		"enum value app.Status.STATUS_ARCHIVED (2) was removed without reserving its number",
+		"rpc app.Shop.ListItems changed from server streaming to unary",
+		"rpc app.Shop.DeleteItem was removed",
+	}
+	if changes := BreakingChanges(old, req); !slices.Equal(changes, want) {
+		t.Errorf("BreakingChanges =\n%q\nwant\n%q", changes, want)
+	}
+
+	if schema, err := ReadStoredSchema(t.TempDir()); schema != nil || err != nil {
+		t.Errorf("ReadStoredSchema without a stored schema = %v, %v", schema, err)
+	}
+}
@@ -0,0 +1,234 @@
+package codegen
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// BufWorkspace is the buf module layout a proto set belongs to, read from
+// buf.yaml (a v1 module or v2 workspace) or buf.work.yaml. Like buf, codegen
+// resolves imports against every module root, and against the dependencies
+// buf.lock pins.
+type BufWorkspace struct {
+	Dir     string   // directory of the buf.yaml or buf.work.yaml
+	Modules []string // module roots
+	Deps    []BufDep // pinned by the modules' buf.lock files
+}
+
+// BufDep is a dependency pinned by buf.lock.
+type BufDep struct {
+	Name   string // e.g. buf.build/googleapis/googleapis
+	Commit string
+}
+
+// FindBufWorkspace returns the buf workspace of dir, found in dir or a
+// directory above it, or nil if there's none.
+func FindBufWorkspace(dir string) (*BufWorkspace, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	var module string // the closest v1 buf.yaml's directory
+	for d := abs; ; d = filepath.Dir(d) {
+		if data, err := os.ReadFile(filepath.Join(d, "buf.work.yaml")); err == nil {
+			ws := &BufWorkspace{Dir: d}
+			for _, item := range parseBufFile(data).lists["directories"] {
+				ws.Modules = append(ws.Modules, filepath.Join(d, item[""]))
+			}
+			return ws, ws.readLocks(ws.Modules)
+		}
+		if data, err := os.ReadFile(filepath.Join(d, "buf.yaml")); err == nil {
+			config := parseBufFile(data)
+			if config.scalars["version"] == "v2" {
+				ws := &BufWorkspace{Dir: d}
+				for _, item := range config.lists["modules"] {
+					ws.Modules = append(ws.Modules, filepath.Join(d, item["path"]))
+				}
+				if len(ws.Modules) == 0 {
+					ws.Modules = []string{d}
+				}
+				return ws, ws.readLocks([]string{d})
+			}
+			if module == "" {
+				module = d
+			}
+		}
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	if module == "" {
+		return nil, nil
+	}
+	ws := &BufWorkspace{Dir: module, Modules: []string{module}}
+	return ws, ws.readLocks(ws.Modules)
+}
+
+// readLocks adds the dependencies pinned by the buf.lock files in dirs.
+func (ws *BufWorkspace) readLocks(dirs []string) error {
+	for _, dir := range dirs {
+		data, err := os.ReadFile(filepath.Join(dir, "buf.lock"))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		for _, item := range parseBufFile(data).lists["deps"] {
+			dep := BufDep{Name: item["name"], Commit: item["commit"]}
+			if dep.Name == "" {
+				// v1 locks split the name up
+				dep.Name = item["remote"] + "/" + item["owner"] + "/" + item["repository"]
+			}
+			if dep.Commit == "" {
+				return fmt.Errorf("%s: %s isn't pinned to a commit", filepath.Join(dir, "buf.lock"), dep.Name)
+			}
+			ws.Deps = append(ws.Deps, dep)
+		}
+	}
+	return nil
+}
+
+// ImportPaths returns the directories imports in the workspace resolve
+// against: its module roots, then its dependencies, which are exported with
+// the buf CLI once and cached.
+func (ws *BufWorkspace) ImportPaths() ([]string, error) {
+	paths := append([]string(nil), ws.Modules...)
+	for _, dep := range ws.Deps {
+		dir, err := exportBufDep(dep)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, dir)
+	}
+	return paths, nil
+}
+
+// Module returns the module root dir is in, or "" if it's in none, or ws is
+// nil.
+func (ws *BufWorkspace) Module(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if ws == nil || err != nil {
+		return ""
+	}
+	for _, module := range ws.Modules {
+		if rel, err := filepath.Rel(module, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return module
+		}
+	}
+	return ""
+}
+
+// exportBufDep returns the directory holding the files of dep, exporting
+// them with `buf export` into the user's cache the first time.
+func exportBufDep(dep BufDep) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(cacheDir, "gapp", "buf", filepath.FromSlash(dep.Name), dep.Commit)
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+	if _, err := exec.LookPath("buf"); err != nil {
+		return "", fmt.Errorf("buf.lock depends on %s, which needs the buf CLI to download once", dep.Name)
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return "", err
+	}
+	// Exported next to its final place, so an interrupted export isn't
+	// mistaken for a cached one
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), dep.Commit+"-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	cmd := exec.Command("buf", "export", dep.Name+":"+dep.Commit, "--output", tmp)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("exporting %s: %w\n%s", dep.Name, err, out)
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// bufFile is what gapp reads of a buf YAML file: its top-level scalars, and
+// its top-level lists, whose items are mappings or, under "", scalars.
+type bufFile struct {
+	scalars map[string]string
+	lists   map[string][]map[string]string
+}
+
+// parseBufFile parses the subset of YAML buf.yaml, buf.work.yaml and
+// buf.lock use for the settings gapp needs: top-level keys with scalar
+// values, and lists of scalars or flat mappings, in block or flow style.
+// Anything nested deeper, such as lint settings, is skipped.
+func parseBufFile(data []byte) bufFile {
+	file := bufFile{scalars: map[string]string{}, lists: map[string][]map[string]string{}}
+	var key string             // the top-level key whose block is being read
+	var item map[string]string // the list item being read
+	dashIndent := -1           // the indentation of the list's dashes
+	itemIndent := -1           // the indentation of the item's keys
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 && (i == 0 || line[i-1] == ' ') {
+			line = line[:i]
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		dash := trimmed == "-" || strings.HasPrefix(trimmed, "- ")
+
+		if indent == 0 && !dash {
+			k, v, _ := strings.Cut(trimmed, ":")
+			key, item, dashIndent, itemIndent = strings.TrimSpace(k), nil, -1, -1
+			v = strings.TrimSpace(v)
+			switch {
+			case strings.HasPrefix(v, "["):
+				for _, s := range strings.Split(strings.Trim(v, "[]"), ",") {
+					if s = unquote(s); s != "" {
+						file.lists[key] = append(file.lists[key], map[string]string{"": s})
+					}
+				}
+			case v != "":
+				file.scalars[key] = unquote(v)
+			}
+			continue
+		}
+
+		if dash && dashIndent == -1 {
+			dashIndent = indent
+		}
+		if dash && indent == dashIndent {
+			rest := strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))
+			item = map[string]string{}
+			file.lists[key] = append(file.lists[key], item)
+			itemIndent = indent + 2
+			if k, v, found := strings.Cut(rest, ":"); found && !strings.Contains(k, " ") {
+				item[strings.TrimSpace(k)] = unquote(v)
+			} else if rest != "" {
+				item[""] = unquote(rest)
+			}
+			continue
+		}
+		if item != nil && indent == itemIndent {
+			if k, v, found := strings.Cut(trimmed, ":"); found {
+				item[strings.TrimSpace(k)] = unquote(v)
+			}
+		}
+	}
+	return file
+}
+
+// unquote trims the spaces and quotes around a YAML scalar.
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,181 @@
+package codegen
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseBufFile(t *testing.T) {
+	file := parseBufFile([]byte(`# Generated by buf. DO NOT EDIT.
+version: v1
+deps:
+  - remote: buf.build
+    owner: googleapis
+    repository: googleapis
+    commit: "75b4300737fb4efca0831636be94e517"
+    digest: shake256:abc
+lint:
+  use:
+    - DEFAULT
+  except: [PACKAGE_VERSION_SUFFIX]
+breaking: { use: [FILE] }
+directories: [proto, 'vendor/proto']
+`))
+	if got := file.scalars["version"]; got != "v1" {
+		t.Errorf("version = %q", got)
+	}
+	deps := file.lists["deps"]
+	if len(deps) != 1 || deps[0]["owner"] != "googleapis" || deps[0]["commit"] != "75b4300737fb4efca0831636be94e517" {
+		t.Errorf("deps = %v", deps)
+	}
+	if use := file.lists["use"]; use != nil {
+		t.Errorf("Nested lists should be skipped, got %v", use)
+	}
+	var dirs []string
+	for _, item := range file.lists["directories"] {
+		dirs = append(dirs, item[""])
+	}
+	if !slices.Equal(dirs, []string{"proto", "vendor/proto"}) {
+		t.Errorf("directories = %v", dirs)
+	}
+
+	modules := parseBufFile([]byte(`version: v2
+modules:
+  - path: proto
+    excludes:
+      - proto/legacy
+  -
+    path: vendor/proto
+`)).lists["modules"]
+	if len(modules) != 2 || modules[0]["path"] != "proto" || modules[1]["path"] != "vendor/proto" {
+		t.Errorf("modules = %v", modules)
+	}
+}
+
+func TestFindBufWorkspace(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		dir     string
+		modules []string
+		deps    []BufDep
+	}{
+		{
+			name: "v1 module",
+			files: map[string]string{
+				"proto/buf.yaml": "version: v1\n",
+				"proto/buf.lock": "version: v1\ndeps:\n  - remote: buf.build\n    owner: acme\n    repository: common\n    commit: c1\n",
+			},
+			dir:     "proto/acme/api/v1",
+			modules: []string{"proto"},
+			deps:    []BufDep{{"buf.build/acme/common", "c1"}},
+		},
+		{
+			name: "v1 workspace",
+			files: map[string]string{
+				"buf.work.yaml":        "version: v1\ndirectories:\n  - proto\n  - third_party\n",
+				"proto/buf.yaml":       "version: v1\n",
+				"third_party/buf.yaml": "version: v1\n",
+			},
+			dir:     "proto",
+			modules: []string{"proto", "third_party"},
+		},
+		{
+			name: "v2 workspace",
+			files: map[string]string{
+				"buf.yaml": "version: v2\nmodules:\n  - path: proto\n  - path: third_party\n",
+				"buf.lock": "version: v2\ndeps:\n  - name: buf.build/acme/common\n    commit: c2\n    digest: b5:abc\n",
+			},
+			dir:     "proto/acme",
+			modules: []string{"proto", "third_party"},
+			deps:    []BufDep{{"buf.build/acme/common", "c2"}},
+		},
+		{
+			name:    "v2 module",
+			files:   map[string]string{"buf.yaml": "version: v2\n"},
+			dir:     "acme",
+			modules: []string{"."},
+		},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		writeProtos(t, dir, tt.files)
+		ws, err := FindBufWorkspace(filepath.Join(dir, tt.dir))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if ws == nil {
+			t.Fatalf("%s: no workspace found", tt.name)
+		}
+		var modules []string
+		for _, m := range ws.Modules {
+			rel, _ := filepath.Rel(dir, m)
+			modules = append(modules, filepath.ToSlash(rel))
+		}
+		if !slices.Equal(modules, tt.modules) || !slices.Equal(ws.Deps, tt.deps) {
+			t.Errorf("%s: modules %v deps %v, want %v %v", tt.name, modules, ws.Deps, tt.modules, tt.deps)
+		}
+	}
+
+	ws, err := FindBufWorkspace(t.TempDir())
+	if err != nil || ws != nil {
+		t.Errorf("FindBufWorkspace without buf files = %v, %v", ws, err)
+	}
+
+	dir := t.TempDir()
+	writeProtos(t, dir, map[string]string{
+		"buf.yaml": "version: v2\n",
+		"buf.lock": "version: v2\ndeps:\n  - name: buf.build/acme/common\n",
+	})
+	if _, err := FindBufWorkspace(dir); err == nil {
+		t.Error("Expected an error for a dependency without a commit")
+	}
+}
+
+func TestFindProtosBufModules(t *testing.T) {
+	dir := t.TempDir()
+	writeProtos(t, dir, map[string]string{
+		"buf.work.yaml":  "version: v1\ndirectories:\n  - proto\n  - third_party/proto\n",
+		"proto/buf.yaml": "version: v1\n",
+		"proto/acme/api/v1/items.proto": `syntax = "proto3";
+package acme.api.v1;
+option go_package = "./generated";
+
+import "acme/common/v1/money.proto";
+
+message Item {
+  acme.common.v1.Money price = 1;
+}
+`,
+		"third_party/proto/buf.yaml": "version: v1\n",
+		"third_party/proto/acme/common/v1/money.proto": `syntax = "proto3";
+package acme.common.v1;
+option go_package = "./generated";
+
+message Money {
+  int64 cents = 1;
+}
+`,
+	})
+
+	set, err := FindProtos(filepath.Join(dir, "proto", "acme", "api"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	protoDir := filepath.Join(dir, "proto")
+	if set.Root != protoDir || !slices.Equal(set.Files, []string{"acme/api/v1/items.proto"}) {
+		t.Errorf("FindProtos = %s %v, want files relative to the module root", set.Root, set.Files)
+	}
+	if !slices.Equal(set.ImportPaths, []string{protoDir, filepath.Join(dir, "third_party", "proto")}) {
+		t.Errorf("ImportPaths = %v", set.ImportPaths)
+	}
+
+	req, err := set.Compile()
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	if !slices.Equal(req.GetFileToGenerate(), []string{"acme/api/v1/items.proto"}) {
+		t.Errorf("FileToGenerate = %v", req.GetFileToGenerate())
+	}
+}
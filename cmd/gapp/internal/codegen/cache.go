@@ -0,0 +1,80 @@
+package codegen
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// CacheKey hashes the inputs of a codegen run, so the run can be skipped
+// while the key matches the stored one (see ReadStoredHash).
+type CacheKey struct {
+	h hash.Hash
+}
+
+// NewCacheKey returns an empty cache key.
+func NewCacheKey() *CacheKey {
+	return &CacheKey{h: sha256.New()}
+}
+
+// Add adds a named input, such as a flag's value.
+func (k *CacheKey) Add(name, value string) {
+	fmt.Fprintf(k.h, "%s\x00%d\x00%s\x00", name, len(value), value)
+}
+
+// AddRequest adds the compiled protos of req, which covers their imports,
+// comments and options as well as the files being generated.
+func (k *CacheKey) AddRequest(req *pluginpb.CodeGeneratorRequest) error {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return err
+	}
+	k.Add("protos", string(data))
+	return nil
+}
+
+// AddBinary adds the version of the executable at path: the package version
+// for a Node package's binary under node_modules, otherwise its size and
+// modification time, which change whenever it's rebuilt or reinstalled.
+func (k *CacheKey) AddBinary(name, path string) {
+	k.Add(name, binaryVersion(path))
+}
+
+// String returns the hex-encoded key.
+func (k *CacheKey) String() string {
+	return hex.EncodeToString(k.h.Sum(nil))
+}
+
+func binaryVersion(path string) string {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "missing"
+	}
+	if strings.Contains(filepath.ToSlash(real), "/node_modules/") {
+		// The binary belongs to the closest package.json above it
+		for dir := filepath.Dir(real); filepath.Base(dir) != "node_modules" && dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+			data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+			if err != nil {
+				continue
+			}
+			var pkg struct{ Name, Version string }
+			if json.Unmarshal(data, &pkg) == nil && pkg.Version != "" {
+				return pkg.Name + "@" + pkg.Version
+			}
+			break
+		}
+	}
+	info, err := os.Stat(real)
+	if err != nil {
+		return "missing"
+	}
+	return fmt.Sprintf("%d %d", info.Size(), info.ModTime().UnixNano())
+}
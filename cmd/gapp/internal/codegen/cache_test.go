@@ -0,0 +1,66 @@
+package codegen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheKey(t *testing.T) {
+	key := func(proto, opt string) string {
+		t.Helper()
+		k := NewCacheKey()
+		if err := k.AddRequest(compileProto(t, map[string]string{"service.proto": proto})); err != nil {
+			t.Fatal(err)
+		}
+		k.Add("--ts-opt", opt)
+		return k.String()
+	}
+
+	proto := "syntax = \"proto3\";\n\nmessage Item {}\n"
+	base := key(proto, "")
+	if key(proto, "") != base {
+		t.Error("key should be stable for the same inputs")
+	}
+	if key(proto, "useDate=true") == base {
+		t.Error("key should change with a flag")
+	}
+	if key("syntax = \"proto3\";\n\n// An item.\nmessage Item {}\n", "") == base {
+		t.Error("key should change with a proto's comments")
+	}
+}
+
+func TestBinaryVersion(t *testing.T) {
+	dir := t.TempDir()
+	pkg := filepath.Join(dir, "node_modules", "ts-proto")
+	if err := os.MkdirAll(filepath.Join(pkg, "build"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	bin := filepath.Join(pkg, "build", "plugin.js")
+	if err := os.WriteFile(bin, []byte("#!/usr/bin/env node\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pkg, "package.json"), []byte(`{"name": "ts-proto", "version": "2.6.1"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := binaryVersion(bin); got != "ts-proto@2.6.1" {
+		t.Errorf("binaryVersion of a Node package's binary = %q, want ts-proto@2.6.1", got)
+	}
+
+	other := filepath.Join(dir, "protoc-gen-foo")
+	if err := os.WriteFile(other, []byte("v1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	before := binaryVersion(other)
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(other, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if binaryVersion(other) == before {
+		t.Error("binaryVersion should change when the binary is replaced")
+	}
+	if got := binaryVersion(filepath.Join(dir, "missing")); got != "missing" {
+		t.Errorf("binaryVersion of a missing binary = %q, want missing", got)
+	}
+}
@@ -0,0 +1,301 @@
+package codegen
+
+import (
+	_ "embed"
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// The transports the native clients call through, speaking gapp's RPC
+// protocol like client/src/rpcTransport.ts does.
+var (
+	//go:embed clients/GappTransport.swift
+	swiftTransport string
+	//go:embed clients/GappTransport.kt
+	kotlinTransport string
+)
+
+// generatedFiles returns the descriptors of the files req generates.
+func generatedFiles(req *pluginpb.CodeGeneratorRequest) ([]protoreflect.FileDescriptor, error) {
+	files, err := compiledFiles(req)
+	if err != nil {
+		return nil, err
+	}
+	var generate []protoreflect.FileDescriptor
+	for _, name := range req.GetFileToGenerate() {
+		file, err := files.FindFileByPath(name)
+		if err != nil {
+			return nil, err
+		}
+		generate = append(generate, file)
+	}
+	return generate, nil
+}
+
+// compiledFiles returns the descriptors of every file in req, imports
+// included.
+func compiledFiles(req *pluginpb.CodeGeneratorRequest) (*protoregistry.Files, error) {
+	files, err := protodesc.NewFiles(&descriptorpb.FileDescriptorSet{File: req.GetProtoFile()})
+	if err != nil {
+		return nil, fmt.Errorf("reading the compiled protos: %w", err)
+	}
+	return files, nil
+}
+
+// GenerateSwiftClients generates a Swift client per service of the files
+// being generated, calling its RPCs with the message types protoc-gen-swift
+// generates, and the GappTransport they share:
+//
+//	let items = AppServiceClient(transport: GappTransport(url: URL(string: "https://example.com/rpc")!))
+//	let response = try await items.getItems(App_GetItemsRequest())
+func GenerateSwiftClients(req *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+	files, err := generatedFiles(req)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pluginpb.CodeGeneratorResponse{}
+	for _, file := range files {
+		for i := 0; i < file.Services().Len(); i++ {
+			service := file.Services().Get(i)
+			name := string(service.Name()) + "Client"
+
+			var b strings.Builder
+			b.WriteString("// Code generated by gapp codegen. DO NOT EDIT.\n\nimport Foundation\n\n")
+			writeDocComment(&b, "", swiftDoc, service, fmt.Sprintf("%s's RPCs, called through a GappTransport.", service.Name()))
+			fmt.Fprintf(&b, "public struct %s: Sendable {\n", name)
+			b.WriteString("    public let transport: GappTransport\n\n")
+			b.WriteString("    public init(transport: GappTransport) {\n        self.transport = transport\n    }\n")
+			for j := 0; j < service.Methods().Len(); j++ {
+				method := service.Methods().Get(j)
+				input, output := swiftTypeName(method.Input()), swiftTypeName(method.Output())
+				fn := swiftIdentifier(lowerCamelCase(string(method.Name())))
+				if method.IsStreamingClient() && method.IsStreamingServer() {
+					// Neither gapp's dispatcher nor its transports stream both ways
+					continue
+				}
+				b.WriteString("\n")
+				writeDocComment(&b, "    ", swiftDoc, method, "")
+				switch {
+				case method.IsStreamingServer():
+					fmt.Fprintf(&b, "    public func %s(_ request: %s) -> AsyncThrowingStream<%s, Error> {\n", fn, input, output)
+					fmt.Fprintf(&b, "        transport.serverStream(%q, request)\n    }\n", method.Name())
+				case method.IsStreamingClient():
+					fmt.Fprintf(&b, "    public func %s(_ requests: [%s]) async throws -> %s {\n", fn, input, output)
+					fmt.Fprintf(&b, "        try await transport.clientStream(%q, requests)\n    }\n", method.Name())
+				default:
+					fmt.Fprintf(&b, "    public func %s(_ request: %s) async throws -> %s {\n", fn, input, output)
+					fmt.Fprintf(&b, "        try await transport.unary(%q, request)\n    }\n", method.Name())
+				}
+			}
+			b.WriteString("}\n")
+			resp.File = append(resp.File, &pluginpb.CodeGeneratorResponse_File{Name: proto.String(name + ".swift"), Content: proto.String(b.String())})
+		}
+	}
+	if len(resp.File) > 0 {
+		resp.File = append(resp.File, &pluginpb.CodeGeneratorResponse_File{Name: proto.String("GappTransport.swift"), Content: proto.String(swiftTransport)})
+	}
+	return resp, nil
+}
+
+// GenerateKotlinClients generates a Kotlin client per service of the files
+// being generated, calling its RPCs with the message classes pbandk's
+// protoc-gen-kotlin generates, and the GappTransport they share:
+//
+//	val items = AppServiceClient(GappTransport("https://example.com/rpc"))
+//	val response = items.getItems(GetItemsRequest())
+func GenerateKotlinClients(req *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+	files, err := generatedFiles(req)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pluginpb.CodeGeneratorResponse{}
+	for _, file := range files {
+		pkg := string(file.Package())
+		for i := 0; i < file.Services().Len(); i++ {
+			service := file.Services().Get(i)
+			name := string(service.Name()) + "Client"
+
+			var b strings.Builder
+			b.WriteString("// Code generated by gapp codegen. DO NOT EDIT.\n\n")
+			if pkg != "" {
+				fmt.Fprintf(&b, "package %s\n\n", kotlinPackage(pkg))
+			}
+			b.WriteString("import gapp.GappTransport\nimport kotlinx.coroutines.flow.Flow\n\n")
+			writeDocComment(&b, "", kotlinDoc, service, fmt.Sprintf("%s's RPCs, called through a GappTransport.", service.Name()))
+			fmt.Fprintf(&b, "class %s(private val transport: GappTransport) {\n", name)
+			first := true
+			for j := 0; j < service.Methods().Len(); j++ {
+				method := service.Methods().Get(j)
+				input, output := kotlinTypeName(pkg, method.Input()), kotlinTypeName(pkg, method.Output())
+				fn := kotlinIdentifier(lowerCamelCase(string(method.Name())))
+				if method.IsStreamingClient() && method.IsStreamingServer() {
+					continue
+				}
+				if !first {
+					b.WriteString("\n")
+				}
+				first = false
+				writeDocComment(&b, "    ", kotlinDoc, method, "")
+				switch {
+				case method.IsStreamingServer():
+					fmt.Fprintf(&b, "    fun %s(request: %s): Flow<%s> =\n", fn, input, output)
+					fmt.Fprintf(&b, "        transport.serverStream(%q, request, %s)\n", method.Name(), output)
+				case method.IsStreamingClient():
+					fmt.Fprintf(&b, "    suspend fun %s(requests: List<%s>): %s =\n", fn, input, output)
+					fmt.Fprintf(&b, "        transport.clientStream(%q, requests, %s)\n", method.Name(), output)
+				default:
+					fmt.Fprintf(&b, "    suspend fun %s(request: %s): %s =\n", fn, input, output)
+					fmt.Fprintf(&b, "        transport.unary(%q, request, %s)\n", method.Name(), output)
+				}
+			}
+			b.WriteString("}\n")
+			out := path.Join(strings.ReplaceAll(pkg, ".", "/"), name+".kt")
+			resp.File = append(resp.File, &pluginpb.CodeGeneratorResponse_File{Name: proto.String(out), Content: proto.String(b.String())})
+		}
+	}
+	if len(resp.File) > 0 {
+		resp.File = append(resp.File, &pluginpb.CodeGeneratorResponse_File{Name: proto.String("gapp/GappTransport.kt"), Content: proto.String(kotlinTransport)})
+	}
+	return resp, nil
+}
+
+// docSyntax is how a language writes doc comments: an opening line, a
+// prefix for each line and a closing line, the first and last optional.
+type docSyntax struct{ open, line, close string }
+
+var (
+	swiftDoc  = docSyntax{line: "///"}
+	kotlinDoc = docSyntax{open: "/**", line: " *", close: " */"}
+)
+
+// writeDocComment writes the proto comment of d, or fallback when it has
+// none, as a doc comment.
+func writeDocComment(b *strings.Builder, indent string, syntax docSyntax, d protoreflect.Descriptor, fallback string) {
+	comment := docComment(d)
+	if comment == "" {
+		comment = fallback
+	}
+	if comment == "" {
+		return
+	}
+	if syntax.open != "" {
+		b.WriteString(indent + syntax.open + "\n")
+	}
+	for _, line := range strings.Split(comment, "\n") {
+		b.WriteString(strings.TrimRight(indent+syntax.line+" "+line, " ") + "\n")
+	}
+	if syntax.close != "" {
+		b.WriteString(indent + syntax.close + "\n")
+	}
+}
+
+// swiftTypeName returns the name protoc-gen-swift gives msg: its nested name
+// prefixed with the file's swift_prefix or, by default, its package in upper
+// camel case, e.g. Acme_Api_V1_Item.Tag for acme.api.v1.Item.Tag.
+func swiftTypeName(msg protoreflect.MessageDescriptor) string {
+	file := msg.ParentFile()
+	nested := strings.TrimPrefix(string(msg.FullName()), string(file.Package())+".")
+	if options, _ := file.Options().(*descriptorpb.FileOptions); options != nil && options.SwiftPrefix != nil {
+		return options.GetSwiftPrefix() + nested
+	}
+	var prefix strings.Builder
+	if file.Package() != "" {
+		for _, part := range strings.Split(string(file.Package()), ".") {
+			prefix.WriteString(upperCamelCase(part) + "_")
+		}
+	}
+	return prefix.String() + nested
+}
+
+// kotlinTypeName returns the class pbandk generates for msg, qualified by its
+// package unless that's pkg.
+func kotlinTypeName(pkg string, msg protoreflect.MessageDescriptor) string {
+	msgPkg := string(msg.ParentFile().Package())
+	nested := strings.TrimPrefix(string(msg.FullName()), msgPkg+".")
+	if msgPkg == pkg || msgPkg == "" {
+		return nested
+	}
+	return kotlinPackage(msgPkg) + "." + nested
+}
+
+// kotlinPackage escapes the parts of a proto package that are Kotlin
+// keywords.
+func kotlinPackage(pkg string) string {
+	parts := strings.Split(pkg, ".")
+	for i, part := range parts {
+		parts[i] = kotlinIdentifier(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// upperCamelCase joins the underscore-separated words of s, capitalized.
+func upperCamelCase(s string) string {
+	var b strings.Builder
+	for _, word := range strings.Split(s, "_") {
+		if word != "" {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
+
+// lowerCamelCase lowercases the leading capitals of an RPC name, keeping the
+// last of a run that starts a word: GetItems is getItems, URLPreview
+// urlPreview.
+func lowerCamelCase(s string) string {
+	runes := []rune(s)
+	n := 0
+	for n < len(runes) && unicode.IsUpper(runes[n]) {
+		n++
+	}
+	if n > 1 && n < len(runes) {
+		n--
+	}
+	for i := 0; i < n; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+var swiftKeywords = map[string]bool{
+	"associatedtype": true, "break": true, "case": true, "catch": true, "class": true, "continue": true,
+	"default": true, "defer": true, "deinit": true, "do": true, "else": true, "enum": true,
+	"extension": true, "fallthrough": true, "false": true, "fileprivate": true, "for": true, "func": true,
+	"guard": true, "if": true, "import": true, "in": true, "init": true, "inout": true,
+	"internal": true, "is": true, "let": true, "nil": true, "open": true, "operator": true,
+	"private": true, "protocol": true, "public": true, "repeat": true, "rethrows": true, "return": true,
+	"self": true, "static": true, "struct": true, "subscript": true, "super": true, "switch": true,
+	"throw": true, "throws": true, "true": true, "try": true, "typealias": true, "var": true,
+	"where": true, "while": true,
+}
+
+var kotlinKeywords = map[string]bool{
+	"as": true, "break": true, "class": true, "continue": true, "do": true, "else": true,
+	"false": true, "for": true, "fun": true, "if": true, "in": true, "interface": true,
+	"is": true, "null": true, "object": true, "package": true, "return": true, "super": true,
+	"this": true, "throw": true, "true": true, "try": true, "typealias": true, "typeof": true,
+	"val": true, "var": true, "when": true, "while": true,
+}
+
+func swiftIdentifier(name string) string {
+	if swiftKeywords[name] {
+		return "`" + name + "`"
+	}
+	return name
+}
+
+func kotlinIdentifier(name string) string {
+	if kotlinKeywords[name] {
+		return "`" + name + "`"
+	}
+	return name
+}
@@ -0,0 +1,111 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+const clientsProto = `syntax = "proto3";
+
+package acme.shop_v1;
+
+message Item {
+  message Tag {
+    string label = 1;
+  }
+  string id = 1;
+}
+
+message GetItemsRequest {}
+
+message GetItemsResponse {
+  repeated Item items = 1;
+}
+
+// AppService serves the catalog.
+service AppService {
+  // GetItems lists every item.
+  rpc GetItems(GetItemsRequest) returns (GetItemsResponse);
+  rpc WatchItems(GetItemsRequest) returns (stream Item);
+  rpc Import(stream Item.Tag) returns (GetItemsResponse);
+  rpc Chat(stream Item) returns (stream Item);
+}
+`
+
+func responseFiles(t *testing.T, resp *pluginpb.CodeGeneratorResponse) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	for _, file := range resp.GetFile() {
+		files[file.GetName()] = file.GetContent()
+	}
+	return files
+}
+
+func TestGenerateSwiftClients(t *testing.T) {
+	resp, err := GenerateSwiftClients(compileProto(t, map[string]string{"service.proto": clientsProto}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := responseFiles(t, resp)
+	if !strings.Contains(files["GappTransport.swift"], "public final class GappTransport") {
+		t.Error("GappTransport.swift missing")
+	}
+	src := files["AppServiceClient.swift"]
+	for _, want := range []string{
+		"/// AppService serves the catalog.\npublic struct AppServiceClient: Sendable {",
+		"    /// GetItems lists every item.\n    public func getItems(_ request: Acme_ShopV1_GetItemsRequest) async throws -> Acme_ShopV1_GetItemsResponse {",
+		`        try await transport.unary("GetItems", request)`,
+		"public func watchItems(_ request: Acme_ShopV1_GetItemsRequest) -> AsyncThrowingStream<Acme_ShopV1_Item, Error> {",
+		"public func `import`(_ requests: [Acme_ShopV1_Item.Tag]) async throws -> Acme_ShopV1_GetItemsResponse {",
+		`        try await transport.clientStream("Import", requests)`,
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("Swift client missing %q:\n%s", want, src)
+		}
+	}
+	if strings.Contains(src, "chat") {
+		t.Errorf("Bidirectional Chat should be skipped:\n%s", src)
+	}
+}
+
+func TestGenerateKotlinClients(t *testing.T) {
+	resp, err := GenerateKotlinClients(compileProto(t, map[string]string{"service.proto": clientsProto}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := responseFiles(t, resp)
+	if !strings.Contains(files["gapp/GappTransport.kt"], "class GappTransport(") {
+		t.Error("gapp/GappTransport.kt missing")
+	}
+	src := files["acme/shop_v1/AppServiceClient.kt"]
+	for _, want := range []string{
+		"package acme.shop_v1\n",
+		"/**\n * AppService serves the catalog.\n */\nclass AppServiceClient(private val transport: GappTransport) {",
+		"    suspend fun getItems(request: GetItemsRequest): GetItemsResponse =\n        transport.unary(\"GetItems\", request, GetItemsResponse)",
+		"    fun watchItems(request: GetItemsRequest): Flow<Item> =\n        transport.serverStream(\"WatchItems\", request, Item)",
+		"    suspend fun import(requests: List<Item.Tag>): GetItemsResponse =",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("Kotlin client missing %q:\n%s", want, src)
+		}
+	}
+	if strings.Contains(src, "chat") {
+		t.Errorf("Bidirectional Chat should be skipped:\n%s", src)
+	}
+}
+
+func TestLowerCamelCase(t *testing.T) {
+	for in, want := range map[string]string{
+		"GetItems":   "getItems",
+		"URLPreview": "urlPreview",
+		"URL":        "url",
+		"getItems":   "getItems",
+		"X":          "x",
+	} {
+		if got := lowerCamelCase(in); got != want {
+			t.Errorf("lowerCamelCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -6,13 +6,21 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"io/fs"
+	"maps"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/bufbuild/protocompile"
+	gengo "google.golang.org/protobuf/cmd/protoc-gen-go/internal_gengo"
+	"google.golang.org/protobuf/compiler/protogen"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/descriptorpb"
 	"google.golang.org/protobuf/types/pluginpb"
 )
@@ -20,27 +28,67 @@ import (
 // CompileProto parses a .proto file using protocompile and returns a
 // CodeGeneratorRequest that can be piped to any protoc plugin.
 func CompileProto(protoDir, protoFile string) (*pluginpb.CodeGeneratorRequest, error) {
+	return CompileProtos([]string{protoDir}, protoFile)
+}
+
+// wellKnownImports resolves the files protoc ships with, such as
+// google/protobuf/timestamp.proto, to the descriptors built into the Go
+// protobuf runtime, and nothing else.
+var wellKnownImports = protocompile.WithStandardImports(protocompile.ResolverFunc(func(name string) (protocompile.SearchResult, error) {
+	return protocompile.SearchResult{}, fs.ErrNotExist
+}))
+
+// isWellKnownImport reports whether name is one of the files protoc ships
+// with, which protos import without having them.
+func isWellKnownImport(name string) bool {
+	_, err := wellKnownImports.FindFileByPath(name)
+	return err == nil
+}
+
+// CompileProtos parses .proto files together, resolving their imports
+// against the standard imports and then importPaths, and returns a
+// CodeGeneratorRequest generating all of them. The request carries the files'
+// dependencies too, ahead of the files importing them, as protoc's does.
+func CompileProtos(importPaths []string, protoFiles ...string) (*pluginpb.CodeGeneratorRequest, error) {
+	source := &protocompile.SourceResolver{ImportPaths: importPaths}
 	compiler := &protocompile.Compiler{
-		Resolver: protocompile.WithStandardImports(
-			&protocompile.SourceResolver{
-				ImportPaths: []string{protoDir},
-			},
-		),
+		// The well-known types are always the Go runtime's, even with copies
+		// vendored into an import path, such as googleapis': an outdated or
+		// edited copy would generate code the runtime registers twice
+		Resolver: protocompile.ResolverFunc(func(name string) (protocompile.SearchResult, error) {
+			if res, err := wellKnownImports.FindFileByPath(name); err == nil {
+				return res, nil
+			}
+			return source.FindFileByPath(name)
+		}),
 		SourceInfoMode: protocompile.SourceInfoStandard,
 	}
 
-	linkedFiles, err := compiler.Compile(context.Background(), protoFile)
+	linkedFiles, err := compiler.Compile(context.Background(), protoFiles...)
 	if err != nil {
 		return nil, fmt.Errorf("compiling proto: %w", err)
 	}
 
 	var fileDescriptors []*descriptorpb.FileDescriptorProto
-	for _, file := range linkedFiles {
+	seen := make(map[string]bool)
+	var add func(file protoreflect.FileDescriptor)
+	add = func(file protoreflect.FileDescriptor) {
+		if seen[file.Path()] {
+			return
+		}
+		seen[file.Path()] = true
+		imports := file.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
 		fileDescriptors = append(fileDescriptors, protodesc.ToFileDescriptorProto(file))
 	}
+	for _, file := range linkedFiles {
+		add(file)
+	}
 
 	return &pluginpb.CodeGeneratorRequest{
-		FileToGenerate: []string{protoFile},
+		FileToGenerate: protoFiles,
 		ProtoFile:      fileDescriptors,
 	}, nil
 }
@@ -48,41 +96,71 @@ func CompileProto(protoDir, protoFile string) (*pluginpb.CodeGeneratorRequest, e
 // RunPlugin invokes a protoc plugin binary with the given CodeGeneratorRequest,
 // passing the serialized request on stdin and reading the response from stdout.
 func RunPlugin(req *pluginpb.CodeGeneratorRequest, pluginPath string, param string) (*pluginpb.CodeGeneratorResponse, error) {
+	return runPlugin(req, exec.Command(pluginPath), "plugin "+pluginPath, param)
+}
+
+// RunGoPlugin generates Go code with the protoc-gen-go built into gapp, the
+// version of google.golang.org/protobuf in its go.mod, so the output doesn't
+// depend on what's installed or on upstream releases. Pass another
+// protoc-gen-go binary to RunPlugin to use it instead.
+func RunGoPlugin(req *pluginpb.CodeGeneratorRequest, param string) (*pluginpb.CodeGeneratorResponse, error) {
 	r := proto.Clone(req).(*pluginpb.CodeGeneratorRequest)
 	if param != "" {
 		r.Parameter = proto.String(param)
 	}
-
-	data, err := proto.Marshal(r)
-	if err != nil {
-		return nil, fmt.Errorf("marshaling request: %w", err)
-	}
-
-	cmd := exec.Command(pluginPath)
-	cmd.Stdin = bytes.NewReader(data)
-
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-
-	output, err := cmd.Output()
+	gen, err := protogen.Options{
+		ParamFunc: func(name, value string) error {
+			return fmt.Errorf("unknown parameter %q", name)
+		},
+	}.New(r)
 	if err != nil {
-		return nil, fmt.Errorf("running plugin %s: %w\n%s", pluginPath, err, stderr.String())
+		return nil, fmt.Errorf("protoc-gen-go: %w", err)
 	}
-
-	var resp pluginpb.CodeGeneratorResponse
-	if err := proto.Unmarshal(output, &resp); err != nil {
-		return nil, fmt.Errorf("unmarshaling response: %w", err)
+	for _, file := range gen.Files {
+		if file.Generate {
+			gengo.GenerateFile(gen, file)
+		}
 	}
+	gen.SupportedFeatures = gengo.SupportedFeatures
+	gen.SupportedEditionsMinimum = gengo.SupportedEditionsMinimum
+	gen.SupportedEditionsMaximum = gengo.SupportedEditionsMaximum
 
+	resp := gen.Response()
 	if resp.Error != nil && *resp.Error != "" {
-		return nil, fmt.Errorf("plugin error: %s", *resp.Error)
+		return nil, fmt.Errorf("protoc-gen-go: %s", *resp.Error)
 	}
+	return resp, nil
+}
 
-	return &resp, nil
+// GoMappings returns protoc-gen-go's M parameters, M<file>=<import path>,
+// which set the Go import paths of proto files over their go_package option.
+// With goPackage, the import path of the Go output directory, each of files
+// gets it, or the package of its subdirectory, since paths=source_relative
+// writes it there. importMap maps more files, e.g. imported protos lacking a
+// go_package, as file=importpath entries, and wins over goPackage.
+func GoMappings(files []string, goPackage string, importMap []string) ([]string, error) {
+	mappings := make(map[string]string)
+	if goPackage != "" {
+		for _, file := range files {
+			mappings[file] = path.Join(goPackage, path.Dir(file))
+		}
+	}
+	for _, entry := range importMap {
+		file, importPath, ok := strings.Cut(entry, "=")
+		if !ok || file == "" || importPath == "" {
+			return nil, fmt.Errorf("invalid Go import mapping %q, expected file.proto=import/path", entry)
+		}
+		mappings[file] = importPath
+	}
+	var params []string
+	for _, file := range slices.Sorted(maps.Keys(mappings)) {
+		params = append(params, "M"+file+"="+mappings[file])
+	}
+	return params, nil
 }
 
-// RunGoPlugin invokes protoc-gen-go via `go run` so no global install is needed.
-func RunGoPlugin(req *pluginpb.CodeGeneratorRequest, param string) (*pluginpb.CodeGeneratorResponse, error) {
+// runPlugin runs the plugin command cmd on req, named name in errors.
+func runPlugin(req *pluginpb.CodeGeneratorRequest, cmd *exec.Cmd, name, param string) (*pluginpb.CodeGeneratorResponse, error) {
 	r := proto.Clone(req).(*pluginpb.CodeGeneratorRequest)
 	if param != "" {
 		r.Parameter = proto.String(param)
@@ -93,15 +171,6 @@ func RunGoPlugin(req *pluginpb.CodeGeneratorRequest, param string) (*pluginpb.Co
 		return nil, fmt.Errorf("marshaling request: %w", err)
 	}
 
-	// Try local binary first, fall back to `go run`
-	pluginPath, err := exec.LookPath("protoc-gen-go")
-	var cmd *exec.Cmd
-	if err == nil {
-		cmd = exec.Command(pluginPath)
-	} else {
-		cmd = exec.Command("go", "run", "google.golang.org/protobuf/cmd/protoc-gen-go@latest")
-	}
-
 	cmd.Stdin = bytes.NewReader(data)
 
 	var stderr bytes.Buffer
@@ -109,7 +178,7 @@ func RunGoPlugin(req *pluginpb.CodeGeneratorRequest, param string) (*pluginpb.Co
 
 	output, err := cmd.Output()
 	if err != nil {
-		return nil, fmt.Errorf("running protoc-gen-go: %w\n%s", err, stderr.String())
+		return nil, fmt.Errorf("running %s: %w\n%s", name, err, stderr.String())
 	}
 
 	var resp pluginpb.CodeGeneratorResponse
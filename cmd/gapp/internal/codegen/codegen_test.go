@@ -0,0 +1,56 @@
+package codegen
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGoMappings(t *testing.T) {
+	params, err := GoMappings(
+		[]string{"service.proto", "shop/v1/items.proto", "common.proto"},
+		"example.com/app/server/generated",
+		[]string{"common.proto=example.com/shared/commonpb", "vendor/money.proto=example.com/money;moneypb"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"Mcommon.proto=example.com/shared/commonpb",
+		"Mservice.proto=example.com/app/server/generated",
+		"Mshop/v1/items.proto=example.com/app/server/generated/shop/v1",
+		"Mvendor/money.proto=example.com/money;moneypb",
+	}
+	if !slices.Equal(params, want) {
+		t.Errorf("GoMappings = %q, want %q", params, want)
+	}
+
+	if params, _ := GoMappings([]string{"service.proto"}, "", nil); len(params) != 0 {
+		t.Errorf("GoMappings without a package or map = %q, want none", params)
+	}
+	if _, err := GoMappings(nil, "", []string{"service.proto"}); err == nil {
+		t.Error("GoMappings should reject a mapping without an import path")
+	}
+}
+
+func TestRunGoPlugin(t *testing.T) {
+	req := compileProto(t, map[string]string{"service.proto": "syntax = \"proto3\";\n\npackage shop;\n\nmessage Item {}\n"})
+
+	resp, err := RunGoPlugin(req, "paths=source_relative,Mservice.proto=example.com/app/shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.File) != 1 || resp.File[0].GetName() != "service.pb.go" {
+		t.Fatalf("RunGoPlugin generated %d files, want service.pb.go", len(resp.File))
+	}
+	if src := resp.File[0].GetContent(); !strings.Contains(src, "package shop\n") || !strings.Contains(src, "type Item struct") {
+		t.Errorf("service.pb.go doesn't declare Item in package shop:\n%s", src)
+	}
+
+	if _, err := RunGoPlugin(req, "paths=source_relative"); err == nil {
+		t.Error("RunGoPlugin should fail on a proto without a Go import path")
+	}
+	if _, err := RunGoPlugin(req, "Mservice.proto=example.com/app/shop,plugins=grpc"); err == nil {
+		t.Error("RunGoPlugin should reject an unknown parameter")
+	}
+}
@@ -0,0 +1,604 @@
+package codegen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// DocsConfig describes the API the reference docs document.
+type DocsConfig struct {
+	Title   string // e.g. the project's name
+	Version string // e.g. the schema hash
+	RpcPath string // where the dispatcher is mounted, e.g. /rpc
+}
+
+// docError is an RPC error code and the HTTP status it's sent with.
+type docError struct {
+	Code        string
+	Status      int
+	Description string
+}
+
+// rpcErrors mirrors the error codes of gapp's errors.go.
+var rpcErrors = []docError{
+	{"VALIDATION_ERROR", 400, "The request is invalid."},
+	{"UNAUTHENTICATED", 401, "The caller isn't signed in."},
+	{"PERMISSION_DENIED", 403, "The caller may not make this call."},
+	{"NOT_FOUND", 404, "The method, or what it looked up, doesn't exist."},
+	{"ALREADY_EXISTS", 409, "What the call would create already exists."},
+	{"PAYLOAD_TOO_LARGE", 413, "The request body is over the server's limit."},
+	{"RATE_LIMITED", 429, "Too many calls; retry later."},
+	{"INTERNAL", 500, "The server failed; the details aren't exposed."},
+	{"DEADLINE_EXCEEDED", 504, "The call took longer than its deadline."},
+}
+
+// docsGen collects what the docs of a CodeGeneratorRequest cover: the
+// services of the files being generated, and every message and enum they
+// define or their methods reach, sorted by full name.
+type docsGen struct {
+	config   DocsConfig
+	services []protoreflect.ServiceDescriptor
+	messages []protoreflect.MessageDescriptor
+	enums    []protoreflect.EnumDescriptor
+	seen     map[protoreflect.FullName]bool
+}
+
+func newDocsGen(req *pluginpb.CodeGeneratorRequest, config DocsConfig) (*docsGen, error) {
+	files, err := generatedFiles(req)
+	if err != nil {
+		return nil, err
+	}
+	if config.RpcPath == "" {
+		config.RpcPath = "/rpc"
+	}
+	g := &docsGen{config: config, seen: make(map[protoreflect.FullName]bool)}
+	for _, file := range files {
+		for i := 0; i < file.Services().Len(); i++ {
+			service := file.Services().Get(i)
+			g.services = append(g.services, service)
+			for j := 0; j < service.Methods().Len(); j++ {
+				g.addMessage(service.Methods().Get(j).Input())
+				g.addMessage(service.Methods().Get(j).Output())
+			}
+		}
+		for i := 0; i < file.Messages().Len(); i++ {
+			g.addMessage(file.Messages().Get(i))
+		}
+		for i := 0; i < file.Enums().Len(); i++ {
+			g.addEnum(file.Enums().Get(i))
+		}
+	}
+	slices.SortFunc(g.messages, func(a, b protoreflect.MessageDescriptor) int {
+		return strings.Compare(string(a.FullName()), string(b.FullName()))
+	})
+	slices.SortFunc(g.enums, func(a, b protoreflect.EnumDescriptor) int {
+		return strings.Compare(string(a.FullName()), string(b.FullName()))
+	})
+	return g, nil
+}
+
+// addMessage adds msg, its nested types and the types of its fields. Map
+// entries and well-known types, which have JSON forms of their own, are
+// inlined instead.
+func (g *docsGen) addMessage(msg protoreflect.MessageDescriptor) {
+	if g.seen[msg.FullName()] {
+		return
+	}
+	g.seen[msg.FullName()] = true
+	if !msg.IsMapEntry() && wellKnownSchema(msg) == nil {
+		g.messages = append(g.messages, msg)
+	}
+	for i := 0; i < msg.Fields().Len(); i++ {
+		field := msg.Fields().Get(i)
+		if field.Message() != nil {
+			g.addMessage(field.Message())
+		}
+		if field.Enum() != nil {
+			g.addEnum(field.Enum())
+		}
+	}
+	for i := 0; i < msg.Messages().Len(); i++ {
+		g.addMessage(msg.Messages().Get(i))
+	}
+	for i := 0; i < msg.Enums().Len(); i++ {
+		g.addEnum(msg.Enums().Get(i))
+	}
+}
+
+func (g *docsGen) addEnum(enum protoreflect.EnumDescriptor) {
+	if g.seen[enum.FullName()] || enum.FullName() == "google.protobuf.NullValue" {
+		return
+	}
+	g.seen[enum.FullName()] = true
+	g.enums = append(g.enums, enum)
+}
+
+// docComment returns the comment above d in its .proto file, without the
+// comment markers.
+func docComment(d protoreflect.Descriptor) string {
+	comment := d.ParentFile().SourceLocations().ByDescriptor(d).LeadingComments
+	lines := strings.Split(strings.TrimSpace(comment), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// streamKind describes how method streams, or returns "" for a unary one.
+func streamKind(method protoreflect.MethodDescriptor) string {
+	switch {
+	case method.IsStreamingClient() && method.IsStreamingServer():
+		return "bidirectional streaming"
+	case method.IsStreamingServer():
+		return "server streaming"
+	case method.IsStreamingClient():
+		return "client streaming"
+	}
+	return ""
+}
+
+// GenerateOpenAPI generates an OpenAPI 3.1 document for the services of the
+// files being generated, with messages described in their proto3 JSON
+// mapping. Every RPC is a POST to the dispatcher's path naming the method in
+// the X-Rpc-Method header, so each gets a path of its own with the method as
+// fragment, e.g. /rpc#GetItems, which clients drop when sending it.
+func GenerateOpenAPI(req *pluginpb.CodeGeneratorRequest, config DocsConfig) ([]byte, error) {
+	g, err := newDocsGen(req, config)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]any)
+	for _, service := range g.services {
+		for i := 0; i < service.Methods().Len(); i++ {
+			method := service.Methods().Get(i)
+			paths[g.config.RpcPath+"#"+string(method.Name())] = map[string]any{"post": g.operation(service, method)}
+		}
+	}
+
+	schemas := map[string]any{"RpcError": map[string]any{
+		"type":     "object",
+		"required": []string{"code", "message"},
+		"properties": map[string]any{
+			"code":    map[string]any{"type": "string", "enum": errorCodes()},
+			"message": map[string]any{"type": "string"},
+			"details": map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}},
+		},
+	}}
+	for _, msg := range g.messages {
+		schemas[string(msg.FullName())] = g.messageSchema(msg)
+	}
+	for _, enum := range g.enums {
+		schemas[string(enum.FullName())] = enumSchema(enum)
+	}
+
+	var errors strings.Builder
+	errors.WriteString("An error. Its code is one of:\n")
+	for _, e := range rpcErrors {
+		fmt.Fprintf(&errors, "\n- `%s` (%d): %s", e.Code, e.Status, e.Description)
+	}
+
+	doc := struct {
+		OpenAPI    string         `json:"openapi"`
+		Info       map[string]any `json:"info"`
+		Paths      map[string]any `json:"paths"`
+		Components map[string]any `json:"components"`
+	}{
+		OpenAPI: "3.1.0",
+		Info: map[string]any{
+			"title":   g.config.Title,
+			"version": g.config.Version,
+			"description": "Every RPC is a POST to " + g.config.RpcPath + " with the method in the X-Rpc-Method header " +
+				"and the request message as binary protobuf. Messages are described in their proto3 JSON mapping. " +
+				"Server-streaming RPCs also take JSON requests, and answer with NDJSON when asked for application/x-ndjson.",
+		},
+		Paths: paths,
+		Components: map[string]any{
+			"schemas": schemas,
+			"responses": map[string]any{"RpcError": map[string]any{
+				"description": errors.String(),
+				"content":     map[string]any{"application/json": map[string]any{"schema": schemaRef("RpcError")}},
+			}},
+		},
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encoding the OpenAPI document: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+func (g *docsGen) operation(service protoreflect.ServiceDescriptor, method protoreflect.MethodDescriptor) map[string]any {
+	name := string(method.Name())
+	input := schemaRef(string(method.Input().FullName()))
+	if s := wellKnownSchema(method.Input()); s != nil {
+		input = s
+	}
+	output := schemaRef(string(method.Output().FullName()))
+	if s := wellKnownSchema(method.Output()); s != nil {
+		output = s
+	}
+
+	requestContent := map[string]any{"application/x-protobuf": map[string]any{"schema": input}}
+	responseContent := map[string]any{"application/x-protobuf": map[string]any{"schema": output}}
+	responseDescription := "The response message."
+	if method.IsStreamingServer() {
+		requestContent["application/json"] = map[string]any{"schema": input}
+		responseContent["application/x-ndjson"] = map[string]any{"schema": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"result":      output,
+				"resumeToken": map[string]any{"type": "string"},
+				"error":       schemaRef("RpcError"),
+			},
+		}}
+		responseDescription = "A stream of response messages, one NDJSON line each, ending in an error line if the RPC fails."
+	}
+
+	op := map[string]any{
+		"operationId": name,
+		"tags":        []string{string(service.Name())},
+		"parameters": []any{map[string]any{
+			"name":     "X-Rpc-Method",
+			"in":       "header",
+			"required": true,
+			"schema":   map[string]any{"type": "string", "const": name},
+		}},
+		"requestBody": map[string]any{"required": true, "content": requestContent},
+		"responses": map[string]any{
+			"200":     map[string]any{"description": responseDescription, "content": responseContent},
+			"default": map[string]any{"$ref": "#/components/responses/RpcError"},
+		},
+	}
+	comment := docComment(method)
+	if kind := streamKind(method); kind != "" {
+		comment = strings.TrimSpace(comment + "\n\nA " + kind + " RPC.")
+	}
+	if comment != "" {
+		summary, _, _ := strings.Cut(comment, "\n")
+		op["summary"] = summary
+		op["description"] = comment
+	}
+	return op
+}
+
+func (g *docsGen) messageSchema(msg protoreflect.MessageDescriptor) map[string]any {
+	properties := make(map[string]any)
+	for i := 0; i < msg.Fields().Len(); i++ {
+		field := msg.Fields().Get(i)
+		schema := fieldSchema(field)
+		if comment := docComment(field); comment != "" {
+			schema["description"] = comment
+		}
+		properties[field.JSONName()] = schema
+	}
+	schema := map[string]any{"type": "object", "properties": properties}
+	description := docComment(msg)
+	for i := 0; i < msg.Oneofs().Len(); i++ {
+		oneof := msg.Oneofs().Get(i)
+		if oneof.IsSynthetic() {
+			continue
+		}
+		var names []string
+		for j := 0; j < oneof.Fields().Len(); j++ {
+			names = append(names, oneof.Fields().Get(j).JSONName())
+		}
+		description = strings.TrimSpace(description + "\n\nAt most one of " + strings.Join(names, ", ") + " is set.")
+	}
+	if description != "" {
+		schema["description"] = description
+	}
+	return schema
+}
+
+func enumSchema(enum protoreflect.EnumDescriptor) map[string]any {
+	var names []string
+	for i := 0; i < enum.Values().Len(); i++ {
+		names = append(names, string(enum.Values().Get(i).Name()))
+	}
+	schema := map[string]any{"type": "string", "enum": names}
+	if comment := docComment(enum); comment != "" {
+		schema["description"] = comment
+	}
+	return schema
+}
+
+// fieldSchema returns the schema of field's JSON value.
+func fieldSchema(field protoreflect.FieldDescriptor) map[string]any {
+	if field.IsMap() {
+		return map[string]any{"type": "object", "additionalProperties": valueSchema(field.MapValue())}
+	}
+	if field.IsList() {
+		return map[string]any{"type": "array", "items": valueSchema(field)}
+	}
+	return valueSchema(field)
+}
+
+// valueSchema returns the schema of a single value of field. 64-bit integers
+// are strings in JSON, and enums their value names.
+func valueSchema(field protoreflect.FieldDescriptor) map[string]any {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]any{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]any{"type": "integer", "format": "uint32", "minimum": 0}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return map[string]any{"type": "string", "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return map[string]any{"type": "string", "format": "uint64"}
+	case protoreflect.FloatKind:
+		return map[string]any{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return map[string]any{"type": "number", "format": "double"}
+	case protoreflect.StringKind:
+		return map[string]any{"type": "string"}
+	case protoreflect.BytesKind:
+		return map[string]any{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		if field.Enum().FullName() == "google.protobuf.NullValue" {
+			return map[string]any{"type": "null"}
+		}
+		return schemaRef(string(field.Enum().FullName()))
+	default:
+		if s := wellKnownSchema(field.Message()); s != nil {
+			return s
+		}
+		return schemaRef(string(field.Message().FullName()))
+	}
+}
+
+func schemaRef(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// wellKnownSchema returns the schema of the special JSON form of a
+// well-known type, or nil if msg isn't one.
+func wellKnownSchema(msg protoreflect.MessageDescriptor) map[string]any {
+	switch msg.FullName() {
+	case "google.protobuf.Timestamp":
+		return map[string]any{"type": "string", "format": "date-time"}
+	case "google.protobuf.Duration":
+		return map[string]any{"type": "string", "description": "Seconds with an s suffix, e.g. 1.5s"}
+	case "google.protobuf.FieldMask":
+		return map[string]any{"type": "string", "description": "Comma-separated field paths"}
+	case "google.protobuf.Struct":
+		return map[string]any{"type": "object"}
+	case "google.protobuf.Value":
+		return map[string]any{}
+	case "google.protobuf.ListValue":
+		return map[string]any{"type": "array"}
+	case "google.protobuf.Empty":
+		return map[string]any{"type": "object"}
+	case "google.protobuf.Any":
+		return map[string]any{"type": "object", "required": []string{"@type"}, "properties": map[string]any{"@type": map[string]any{"type": "string"}}}
+	case "google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value", "google.protobuf.Int64Value",
+		"google.protobuf.UInt64Value", "google.protobuf.FloatValue", "google.protobuf.DoubleValue":
+		schema := valueSchema(msg.Fields().ByName("value"))
+		schema["type"] = []any{schema["type"], "null"}
+		return schema
+	}
+	return nil
+}
+
+func errorCodes() []string {
+	codes := make([]string, len(rpcErrors))
+	for i, e := range rpcErrors {
+		codes[i] = e.Code
+	}
+	return codes
+}
+
+// GenerateDocsHTML generates a static HTML reference of the services of the
+// files being generated: their methods, the messages and enums they use, and
+// the error codes RPCs fail with.
+func GenerateDocsHTML(req *pluginpb.CodeGeneratorRequest, config DocsConfig) ([]byte, error) {
+	g, err := newDocsGen(req, config)
+	if err != nil {
+		return nil, err
+	}
+
+	type typeRef struct {
+		Name   string
+		Anchor string // "" for scalars and well-known types
+	}
+	ref := func(field protoreflect.FieldDescriptor) typeRef {
+		var name protoreflect.FullName
+		switch {
+		case field.Enum() != nil:
+			name = field.Enum().FullName()
+		case field.Message() != nil:
+			name = field.Message().FullName()
+		default:
+			return typeRef{Name: field.Kind().String()}
+		}
+		if g.seen[name] && (field.Message() == nil || wellKnownSchema(field.Message()) == nil) {
+			return typeRef{Name: string(name), Anchor: string(name)}
+		}
+		return typeRef{Name: string(name)}
+	}
+	messageRef := func(msg protoreflect.MessageDescriptor) typeRef {
+		if wellKnownSchema(msg) != nil {
+			return typeRef{Name: string(msg.FullName())}
+		}
+		return typeRef{Name: string(msg.FullName()), Anchor: string(msg.FullName())}
+	}
+
+	type method struct {
+		Name, Comment, Kind string
+		Input, Output       typeRef
+	}
+	type service struct {
+		Name, Comment string
+		Methods       []method
+	}
+	type field struct {
+		Name, Label, Comment string
+		Type                 typeRef
+		Value                *typeRef // of maps
+	}
+	type message struct {
+		Name, Comment string
+		Fields        []field
+	}
+	type enumValue struct {
+		Name    string
+		Number  int32
+		Comment string
+	}
+	type enum struct {
+		Name, Comment string
+		Values        []enumValue
+	}
+	data := struct {
+		DocsConfig
+		Services []service
+		Messages []message
+		Enums    []enum
+		Errors   []docError
+	}{DocsConfig: g.config, Errors: rpcErrors}
+
+	for _, s := range g.services {
+		svc := service{Name: string(s.Name()), Comment: docComment(s)}
+		for i := 0; i < s.Methods().Len(); i++ {
+			m := s.Methods().Get(i)
+			svc.Methods = append(svc.Methods, method{
+				Name:    string(m.Name()),
+				Comment: docComment(m),
+				Kind:    streamKind(m),
+				Input:   messageRef(m.Input()),
+				Output:  messageRef(m.Output()),
+			})
+		}
+		data.Services = append(data.Services, svc)
+	}
+	for _, m := range g.messages {
+		msg := message{Name: string(m.FullName()), Comment: docComment(m)}
+		for i := 0; i < m.Fields().Len(); i++ {
+			f := m.Fields().Get(i)
+			fd := field{Name: f.JSONName(), Comment: docComment(f), Type: ref(f)}
+			switch {
+			case f.IsMap():
+				fd.Label = "map"
+				value := ref(f.MapValue())
+				fd.Type, fd.Value = ref(f.MapKey()), &value
+			case f.IsList():
+				fd.Label = "repeated"
+			case f.HasOptionalKeyword():
+				fd.Label = "optional"
+			}
+			if oneof := f.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() {
+				fd.Label = "oneof " + string(oneof.Name())
+			}
+			msg.Fields = append(msg.Fields, fd)
+		}
+		data.Messages = append(data.Messages, msg)
+	}
+	for _, e := range g.enums {
+		en := enum{Name: string(e.FullName()), Comment: docComment(e)}
+		for i := 0; i < e.Values().Len(); i++ {
+			v := e.Values().Get(i)
+			en.Values = append(en.Values, enumValue{Name: string(v.Name()), Number: int32(v.Number()), Comment: docComment(v)})
+		}
+		data.Enums = append(data.Enums, en)
+	}
+
+	var b bytes.Buffer
+	if err := docsTemplate.Execute(&b, data); err != nil {
+		return nil, fmt.Errorf("rendering the API reference: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+var docsTemplate = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} API reference</title>
+<style>
+  body { font: 15px/1.5 system-ui, sans-serif; max-width: 60rem; margin: 2rem auto; padding: 0 1rem; color: #1f2328; }
+  code, .type { font-family: ui-monospace, monospace; font-size: 0.9em; }
+  h2 { border-bottom: 1px solid #d0d7de; padding-bottom: 0.3rem; margin-top: 2.5rem; }
+  h3 { margin-bottom: 0.3rem; }
+  .comment { white-space: pre-line; color: #59636e; }
+  .label { color: #8250df; }
+  table { border-collapse: collapse; width: 100%; margin: 0.5rem 0 1.5rem; }
+  th, td { text-align: left; vertical-align: top; padding: 0.3rem 0.6rem; border-bottom: 1px solid #eaeef2; }
+  a { color: #0969da; text-decoration: none; }
+</style>
+</head>
+<body>
+{{- define "type"}}{{if .Anchor}}<a href="#{{.Anchor}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}{{end}}
+<h1>{{.Title}} API reference</h1>
+<p>Every RPC is a <code>POST</code> to <code>{{.RpcPath}}</code> with the method in the <code>X-Rpc-Method</code> header and the request message, encoded as binary protobuf, as the body. Errors are JSON objects with a <code>code</code>, a <code>message</code> and optional <code>details</code>.</p>
+{{- if .Version}}
+<p>Schema version <code>{{.Version}}</code></p>
+{{- end}}
+{{- range .Services}}
+<h2>{{.Name}}</h2>
+{{- if .Comment}}
+<p class="comment">{{.Comment}}</p>
+{{- end}}
+{{- range .Methods}}
+<h3 id="{{.Name}}"><code>{{.Name}}</code></h3>
+<p class="type">({{template "type" .Input}}) → {{if .Kind}}<span class="label">stream</span> {{end}}{{template "type" .Output}}</p>
+{{- if .Comment}}
+<p class="comment">{{.Comment}}</p>
+{{- end}}
+{{- if .Kind}}
+<p>A {{.Kind}} RPC.</p>
+{{- end}}
+{{- end}}
+{{- end}}
+{{- if .Messages}}
+<h2>Messages</h2>
+{{- range .Messages}}
+<h3 id="{{.Name}}"><code>{{.Name}}</code></h3>
+{{- if .Comment}}
+<p class="comment">{{.Comment}}</p>
+{{- end}}
+{{- if .Fields}}
+<table>
+<tr><th>Field</th><th>Type</th><th>Description</th></tr>
+{{- range .Fields}}
+<tr><td><code>{{.Name}}</code></td><td class="type">{{if .Label}}<span class="label">{{.Label}}</span> {{end}}{{if .Value}}&lt;{{template "type" .Type}}, {{template "type" .Value}}&gt;{{else}}{{template "type" .Type}}{{end}}</td><td class="comment">{{.Comment}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+{{- end}}
+{{- if .Enums}}
+<h2>Enums</h2>
+{{- range .Enums}}
+<h3 id="{{.Name}}"><code>{{.Name}}</code></h3>
+{{- if .Comment}}
+<p class="comment">{{.Comment}}</p>
+{{- end}}
+<table>
+<tr><th>Value</th><th>Number</th><th>Description</th></tr>
+{{- range .Values}}
+<tr><td><code>{{.Name}}</code></td><td>{{.Number}}</td><td class="comment">{{.Comment}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+<h2>Errors</h2>
+<table>
+<tr><th>Code</th><th>HTTP status</th><th>Meaning</th></tr>
+{{- range .Errors}}
+<tr><td><code>{{.Code}}</code></td><td>{{.Status}}</td><td>{{.Description}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,149 @@
+package codegen
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const docsProto = `syntax = "proto3";
+
+package app;
+
+import "google/protobuf/timestamp.proto";
+
+// Status is where an item is in its life.
+enum Status {
+  STATUS_UNSPECIFIED = 0;
+  // Listed for sale.
+  STATUS_ACTIVE = 1;
+}
+
+// Item is something for <sale>.
+message Item {
+  string id = 1;
+  // Price in cents.
+  int64 price_cents = 2;
+  map<string, int32> scores = 3;
+  repeated string tags = 4;
+  Status status = 5;
+  google.protobuf.Timestamp created_at = 6;
+  oneof value {
+    string text = 7;
+    Item parent = 8;
+  }
+}
+
+message GetItemsRequest {}
+
+message GetItemsResponse {
+  repeated Item items = 1;
+}
+
+// AppService serves the catalog.
+service AppService {
+  // GetItems lists every item.
+  // Newest first.
+  rpc GetItems(GetItemsRequest) returns (GetItemsResponse);
+  rpc WatchItems(GetItemsRequest) returns (stream Item);
+}
+`
+
+func TestGenerateOpenAPI(t *testing.T) {
+	data, err := GenerateOpenAPI(compileProto(t, map[string]string{"service.proto": docsProto}), DocsConfig{Title: "shop", Version: "abc123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]struct {
+			Post struct {
+				OperationID string `json:"operationId"`
+				Summary     string `json:"summary"`
+				Description string `json:"description"`
+				RequestBody struct {
+					Content map[string]any `json:"content"`
+				} `json:"requestBody"`
+				Responses map[string]struct {
+					Content map[string]any `json:"content"`
+				} `json:"responses"`
+			} `json:"post"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Type        string                    `json:"type"`
+				Description string                    `json:"description"`
+				Properties  map[string]map[string]any `json:"properties"`
+				Enum        []string                  `json:"enum"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, data)
+	}
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("openapi = %q", doc.OpenAPI)
+	}
+
+	get := doc.Paths["/rpc#GetItems"].Post
+	if get.OperationID != "GetItems" || get.Summary != "GetItems lists every item." || get.Description != "GetItems lists every item.\nNewest first." {
+		t.Errorf("GetItems operation = %+v", get)
+	}
+	if _, ok := get.RequestBody.Content["application/json"]; ok {
+		t.Error("Unary RPCs shouldn't take JSON requests")
+	}
+	watch := doc.Paths["/rpc#WatchItems"].Post
+	if _, ok := watch.Responses["200"].Content["application/x-ndjson"]; !ok {
+		t.Errorf("WatchItems should stream NDJSON: %+v", watch.Responses)
+	}
+
+	item := doc.Components.Schemas["app.Item"]
+	if !strings.HasPrefix(item.Description, "Item is something for <sale>.") || !strings.Contains(item.Description, "At most one of text, parent is set.") {
+		t.Errorf("Item description = %q", item.Description)
+	}
+	for name, want := range map[string]map[string]any{
+		"priceCents": {"type": "string", "format": "int64", "description": "Price in cents."},
+		"createdAt":  {"type": "string", "format": "date-time"},
+		"status":     {"$ref": "#/components/schemas/app.Status"},
+		"scores":     {"type": "object", "additionalProperties": map[string]any{"type": "integer", "format": "int32"}},
+		"tags":       {"type": "array", "items": map[string]any{"type": "string"}},
+	} {
+		got, _ := json.Marshal(item.Properties[name])
+		wantJSON, _ := json.Marshal(want)
+		if string(got) != string(wantJSON) {
+			t.Errorf("Item.%s = %s, want %s", name, got, wantJSON)
+		}
+	}
+	if status := doc.Components.Schemas["app.Status"]; strings.Join(status.Enum, ",") != "STATUS_UNSPECIFIED,STATUS_ACTIVE" {
+		t.Errorf("Status = %+v", status)
+	}
+	if _, ok := doc.Components.Schemas["google.protobuf.Timestamp"]; ok {
+		t.Error("Well-known types should be inlined")
+	}
+}
+
+func TestGenerateDocsHTML(t *testing.T) {
+	data, err := GenerateDocsHTML(compileProto(t, map[string]string{"service.proto": docsProto}), DocsConfig{Title: "shop", RpcPath: "/api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(data)
+	for _, want := range []string{
+		"<title>shop API reference</title>",
+		"<code>/api</code>",
+		`<h3 id="GetItems"><code>GetItems</code></h3>`,
+		`(<a href="#app.GetItemsRequest">app.GetItemsRequest</a>) → <a href="#app.GetItemsResponse">app.GetItemsResponse</a>`,
+		"A server streaming RPC.",
+		"Item is something for &lt;sale&gt;.",
+		`<span class="label">map</span> &lt;string, int32&gt;`,
+		`<span class="label">repeated</span> <a href="#app.Item">app.Item</a>`,
+		`<span class="label">oneof value</span> string`,
+		"google.protobuf.Timestamp",
+		"<td><code>STATUS_ACTIVE</code></td><td>1</td><td class=\"comment\">Listed for sale.</td>",
+		"<td><code>NOT_FOUND</code></td><td>404</td>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML reference missing %q:\n%s", want, html)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package codegen
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// HookLibraries are the data fetching libraries GenerateHooksTS supports.
+var HookLibraries = []string{"react-query", "swr"}
+
+// readPrefixes start the names of RPCs that only read, whose hooks are
+// queries rather than mutations.
+var readPrefixes = []string{"Get", "List", "Search", "Find", "Fetch", "Query", "Count", "Lookup"}
+
+// isReadMethod reports whether method only reads: it's declared with
+// idempotency_level = NO_SIDE_EFFECTS, or named like a read, e.g. GetItems.
+func isReadMethod(method protoreflect.MethodDescriptor) bool {
+	if options, _ := method.Options().(*descriptorpb.MethodOptions); options.GetIdempotencyLevel() == descriptorpb.MethodOptions_NO_SIDE_EFFECTS {
+		return true
+	}
+	name := string(method.Name())
+	for _, prefix := range readPrefixes {
+		if rest, ok := strings.CutPrefix(name, prefix); ok && (rest == "" || unicode.IsUpper(rune(rest[0]))) {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateHooksTS generates React hooks for the unary RPCs of the files being
+// generated, with library (react-query or swr): a query for each read, such
+// as useGetItems(params), and a mutation for each write. Queries start from
+// the response the server preloaded for the same request. The hooks call
+// the rpc client and read the preloads from the registry that the module at
+// rpcModule exports, as the scaffolded client/src/rpc.ts does. It returns ""
+// when there are no unary RPCs.
+func GenerateHooksTS(req *pluginpb.CodeGeneratorRequest, library, rpcModule string) (string, error) {
+	switch library {
+	case "react-query", "swr":
+	default:
+		return "", fmt.Errorf("unknown hooks library %q, expected %s", library, strings.Join(HookLibraries, " or "))
+	}
+	files, err := generatedFiles(req)
+	if err != nil {
+		return "", err
+	}
+
+	var body strings.Builder
+	var modules []string // the ts-proto modules messages are imported from
+	imports := make(map[string][]string)
+	use := func(msg protoreflect.MessageDescriptor) string {
+		name := tsTypeName(msg)
+		module := tsModule(msg)
+		if imports[module] == nil {
+			modules = append(modules, module)
+		}
+		for _, imported := range imports[module] {
+			if imported == name {
+				return name
+			}
+		}
+		imports[module] = append(imports[module], name)
+		return name
+	}
+	queries, mutations := 0, 0
+	for _, file := range files {
+		for i := 0; i < file.Services().Len(); i++ {
+			service := file.Services().Get(i)
+			for j := 0; j < service.Methods().Len(); j++ {
+				method := service.Methods().Get(j)
+				if method.IsStreamingClient() || method.IsStreamingServer() {
+					continue
+				}
+				name := string(method.Name())
+				input, output := use(method.Input()), use(method.Output())
+				body.WriteString("\n")
+				if comment := docComment(method); comment != "" {
+					for _, line := range strings.Split(comment, "\n") {
+						fmt.Fprintf(&body, "// %s\n", line)
+					}
+				}
+				if isReadMethod(method) {
+					queries++
+					writeQueryHook(&body, library, name, input, output)
+				} else {
+					mutations++
+					writeMutationHook(&body, library, name, input, output)
+				}
+			}
+		}
+	}
+	if queries+mutations == 0 {
+		return "", nil
+	}
+
+	var b strings.Builder
+	b.WriteString("// Code generated by gapp codegen. DO NOT EDIT.\n\n")
+	switch library {
+	case "react-query":
+		var names []string
+		if queries > 0 {
+			names = append(names, "useQuery", "type UseQueryOptions")
+		}
+		if mutations > 0 {
+			names = append(names, "useMutation", "type UseMutationOptions")
+		}
+		fmt.Fprintf(&b, "import { %s } from \"@tanstack/react-query\";\n", strings.Join(names, ", "))
+	case "swr":
+		if queries > 0 {
+			b.WriteString("import useSWR, { type SWRConfiguration } from \"swr\";\n")
+		}
+		if mutations > 0 {
+			b.WriteString("import useSWRMutation, { type SWRMutationConfiguration } from \"swr/mutation\";\n")
+		}
+	}
+	b.WriteString("import type { RpcError } from \"@gapp/client\";\n")
+	if queries > 0 {
+		fmt.Fprintf(&b, "import { rpc, registry } from %q;\n", rpcModule)
+	} else {
+		fmt.Fprintf(&b, "import { rpc } from %q;\n", rpcModule)
+	}
+	for i, module := range modules {
+		names := imports[module]
+		if i == 0 {
+			names = append(names, "type DeepPartial")
+		}
+		fmt.Fprintf(&b, "import { %s } from %q;\n", strings.Join(names, ", "), module)
+	}
+	if queries > 0 {
+		b.WriteString(`
+// The response the server preloaded for method, if it was for request
+function preloaded<Req, Resp>(
+  method: string,
+  request: Req,
+  toJSON: (message: Req) => unknown
+): Resp | undefined {
+  const preload = registry.preloaded(method);
+  if (!preload || JSON.stringify(toJSON(preload.request as Req)) !== JSON.stringify(toJSON(request))) {
+    return undefined;
+  }
+  return preload.response as Resp;
+}
+`)
+	}
+	b.WriteString(body.String())
+	return b.String(), nil
+}
+
+func writeQueryHook(b *strings.Builder, library, name, input, output string) {
+	key := lowerCamelCase(name) + "Key"
+	fmt.Fprintf(b, "export function %s(params: DeepPartial<%s> = {}) {\n", key, input)
+	fmt.Fprintf(b, "  return [%q, %s.toJSON(%s.fromPartial(params))] as const;\n", name, input, input)
+	b.WriteString("}\n\n")
+	switch library {
+	case "react-query":
+		fmt.Fprintf(b, "export function use%s(\n", name)
+		fmt.Fprintf(b, "  params: DeepPartial<%s> = {},\n", input)
+		fmt.Fprintf(b, "  options?: Omit<UseQueryOptions<%s, RpcError>, \"queryKey\" | \"queryFn\" | \"initialData\">\n", output)
+		b.WriteString(") {\n")
+		fmt.Fprintf(b, "  const request = %s.fromPartial(params);\n", input)
+		b.WriteString("  return useQuery({\n")
+		fmt.Fprintf(b, "    queryKey: %s(request),\n", key)
+		fmt.Fprintf(b, "    queryFn: () => rpc.%s(request),\n", name)
+		fmt.Fprintf(b, "    initialData: () => preloaded<%s, %s>(%q, request, %s.toJSON),\n", input, output, name, input)
+		b.WriteString("    initialDataUpdatedAt: window.__PRELOAD_TIMESTAMP__,\n")
+		b.WriteString("    ...options,\n")
+		b.WriteString("  });\n")
+	case "swr":
+		fmt.Fprintf(b, "export function use%s(\n", name)
+		fmt.Fprintf(b, "  params: DeepPartial<%s> = {},\n", input)
+		fmt.Fprintf(b, "  config?: SWRConfiguration<%s, RpcError>\n", output)
+		b.WriteString(") {\n")
+		fmt.Fprintf(b, "  const request = %s.fromPartial(params);\n", input)
+		fmt.Fprintf(b, "  return useSWR(%s(request), () => rpc.%s(request), {\n", key, name)
+		fmt.Fprintf(b, "    fallbackData: preloaded<%s, %s>(%q, request, %s.toJSON),\n", input, output, name, input)
+		b.WriteString("    ...config,\n")
+		b.WriteString("  });\n")
+	}
+	b.WriteString("}\n")
+}
+
+func writeMutationHook(b *strings.Builder, library, name, input, output string) {
+	switch library {
+	case "react-query":
+		fmt.Fprintf(b, "export function use%s(\n", name)
+		fmt.Fprintf(b, "  options?: Omit<UseMutationOptions<%s, RpcError, DeepPartial<%s>>, \"mutationFn\">\n", output, input)
+		b.WriteString(") {\n")
+		b.WriteString("  return useMutation({\n")
+		fmt.Fprintf(b, "    mutationFn: (params: DeepPartial<%s>) => rpc.%s(%s.fromPartial(params)),\n", input, name, input)
+		b.WriteString("    ...options,\n")
+		b.WriteString("  });\n")
+	case "swr":
+		fmt.Fprintf(b, "export function use%s(\n", name)
+		fmt.Fprintf(b, "  config?: SWRMutationConfiguration<%s, RpcError, %q, DeepPartial<%s>>\n", output, name, input)
+		b.WriteString(") {\n")
+		fmt.Fprintf(b, "  return useSWRMutation(\n    %q,\n", name)
+		fmt.Fprintf(b, "    (_key: string, { arg }: { arg: DeepPartial<%s> }) => rpc.%s(%s.fromPartial(arg)),\n", input, name, input)
+		b.WriteString("    config\n")
+		b.WriteString("  );\n")
+	}
+	b.WriteString("}\n")
+}
+
+// tsTypeName returns the name ts-proto gives a message or enum: its nested
+// name joined with underscores, e.g. Item_Tag.
+func tsTypeName(d protoreflect.Descriptor) string {
+	nested := strings.TrimPrefix(string(d.FullName()), string(d.ParentFile().Package())+".")
+	return strings.ReplaceAll(nested, ".", "_")
+}
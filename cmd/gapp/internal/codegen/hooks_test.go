@@ -0,0 +1,113 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestIsReadMethod(t *testing.T) {
+	proto := `syntax = "proto3";
+
+package hooks;
+
+message Empty {}
+
+service Svc {
+  rpc GetItems(Empty) returns (Empty);
+  rpc List(Empty) returns (Empty);
+  rpc Getaway(Empty) returns (Empty);
+  rpc CreateItem(Empty) returns (Empty);
+  rpc Total(Empty) returns (Empty) {
+    option idempotency_level = NO_SIDE_EFFECTS;
+  }
+}
+`
+	req := compileProto(t, map[string]string{"service.proto": proto})
+	files, err := generatedFiles(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	methods := files[0].Services().Get(0).Methods()
+	for name, want := range map[string]bool{
+		"GetItems":   true,
+		"List":       true,
+		"Getaway":    false,
+		"CreateItem": false,
+		"Total":      true,
+	} {
+		if got := isReadMethod(methods.ByName(protoreflect.Name(name))); got != want {
+			t.Errorf("isReadMethod(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestGenerateHooksTSReactQuery(t *testing.T) {
+	src, err := GenerateHooksTS(compileProto(t, map[string]string{"service.proto": clientsProto}), "react-query", "../rpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`import { useQuery, type UseQueryOptions } from "@tanstack/react-query";`,
+		`import { rpc, registry } from "../rpc";`,
+		`import { GetItemsRequest, GetItemsResponse, type DeepPartial } from "./service";`,
+		"function preloaded<Req, Resp>(",
+		"// GetItems lists every item.\nexport function getItemsKey(params: DeepPartial<GetItemsRequest> = {}) {",
+		"export function useGetItems(\n  params: DeepPartial<GetItemsRequest> = {},",
+		`    initialData: () => preloaded<GetItemsRequest, GetItemsResponse>("GetItems", request, GetItemsRequest.toJSON),`,
+		"    initialDataUpdatedAt: window.__PRELOAD_TIMESTAMP__,",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("hooks missing %q:\n%s", want, src)
+		}
+	}
+	// Streaming RPCs have no hooks
+	for _, unwanted := range []string{"useWatchItems", "useImport", "useChat"} {
+		if strings.Contains(src, unwanted) {
+			t.Errorf("hooks should not contain %s:\n%s", unwanted, src)
+		}
+	}
+}
+
+func TestGenerateHooksTSSWR(t *testing.T) {
+	proto := `syntax = "proto3";
+
+package hooks;
+
+message Item {
+  string id = 1;
+}
+
+service Svc {
+  rpc SaveItem(Item) returns (Item);
+}
+`
+	req := compileProto(t, map[string]string{"service.proto": proto})
+	src, err := GenerateHooksTS(req, "swr", "../rpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`import useSWRMutation, { type SWRMutationConfiguration } from "swr/mutation";`,
+		`import { rpc } from "../rpc";`,
+		"export function useSaveItem(\n  config?: SWRMutationConfiguration<Item, RpcError, \"SaveItem\", DeepPartial<Item>>",
+		"(_key: string, { arg }: { arg: DeepPartial<Item> }) => rpc.SaveItem(Item.fromPartial(arg)),",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("hooks missing %q:\n%s", want, src)
+		}
+	}
+	// Without queries there's no need for useSWR or the preloads
+	for _, unwanted := range []string{`from "swr";`, "preloaded", "registry"} {
+		if strings.Contains(src, unwanted) {
+			t.Errorf("hooks should not contain %s:\n%s", unwanted, src)
+		}
+	}
+}
+
+func TestGenerateHooksTSUnknownLibrary(t *testing.T) {
+	if _, err := GenerateHooksTS(compileProto(t, map[string]string{"service.proto": clientsProto}), "apollo", "../rpc"); err == nil {
+		t.Error("expected an error for an unknown library")
+	}
+}
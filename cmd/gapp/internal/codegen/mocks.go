@@ -0,0 +1,473 @@
+package codegen
+
+import (
+	"fmt"
+	"go/format"
+	"strings"
+
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// mockDepth bounds how deep example messages nest, so recursive messages
+// terminate.
+const mockDepth = 2
+
+// GenerateMocksGo generates a mock implementation of each service in the
+// files being generated. A mock answers every RPC with a populated example
+// message, and its exported function fields can be replaced per test:
+//
+//	mock := generated.NewAppServiceMock()
+//	mock.GetItems = func(ctx context.Context, req *generated.GetItemsRequest) (*generated.GetItemsResponse, error) {
+//		return &generated.GetItemsResponse{}, nil
+//	}
+//	mock.Register(dispatcher)
+//
+// It returns "" when there are no services.
+func GenerateMocksGo(req *pluginpb.CodeGeneratorRequest, packageName string) (string, error) {
+	generate := make(map[string]bool)
+	for _, name := range req.GetFileToGenerate() {
+		generate[name] = true
+	}
+
+	g := &mockGen{}
+	var services []*descriptorpb.ServiceDescriptorProto
+	for _, file := range req.GetProtoFile() {
+		if !generate[file.GetName()] {
+			continue
+		}
+		g.files = append(g.files, file)
+		services = append(services, file.GetService()...)
+	}
+	if len(services) == 0 {
+		return "", nil
+	}
+	g.index()
+
+	var body strings.Builder
+	for _, service := range services {
+		g.writeService(&body, service)
+	}
+	for _, msg := range g.order {
+		g.writeExample(&body, msg)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Code generated by gapp codegen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", packageName)
+	b.WriteString("\t\"context\"\n")
+	if strings.Contains(body.String(), "io.EOF") {
+		b.WriteString("\t\"errors\"\n\t\"io\"\n")
+	}
+	if strings.Contains(body.String(), "http.") {
+		b.WriteString("\t\"net/http\"\n")
+	}
+	b.WriteString("\n\t\"github.com/germtb/gapp\"\n")
+	if strings.Contains(body.String(), "proto.") {
+		b.WriteString("\t\"google.golang.org/protobuf/proto\"\n")
+	}
+	b.WriteString(")\n")
+	b.WriteString(body.String())
+
+	src, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return "", fmt.Errorf("formatting mocks: %w", err)
+	}
+	return string(src), nil
+}
+
+type mockMessage struct {
+	goName string
+	desc   *descriptorpb.DescriptorProto
+	proto2 bool
+}
+
+type mockEnum struct {
+	goName string // Go type
+	value  string // Go name of the example value
+}
+
+type mockGen struct {
+	files    []*descriptorpb.FileDescriptorProto
+	messages map[string]*mockMessage // by fully qualified name, e.g. ".app.Item"
+	enums    map[string]mockEnum     // by fully qualified name
+	order    []*mockMessage
+}
+
+// index collects the messages and enums defined in the generated files,
+// which are the only ones the mocks can construct.
+func (g *mockGen) index() {
+	g.messages = make(map[string]*mockMessage)
+	g.enums = make(map[string]mockEnum)
+	for _, file := range g.files {
+		prefix := "."
+		if file.GetPackage() != "" {
+			prefix += file.GetPackage() + "."
+		}
+		proto2 := file.GetSyntax() != "proto3" && file.GetSyntax() != "editions"
+		for _, enum := range file.GetEnumType() {
+			g.indexEnum(enum, prefix, "")
+		}
+		for _, msg := range file.GetMessageType() {
+			g.indexMessage(msg, prefix, "", proto2)
+		}
+	}
+}
+
+func (g *mockGen) indexMessage(msg *descriptorpb.DescriptorProto, prefix, parent string, proto2 bool) {
+	if msg.GetOptions().GetMapEntry() {
+		g.messages[prefix+msg.GetName()] = &mockMessage{desc: msg, proto2: proto2}
+		return
+	}
+	goName := parent + goCamelCase(msg.GetName())
+	m := &mockMessage{goName: goName, desc: msg, proto2: proto2}
+	g.messages[prefix+msg.GetName()] = m
+	g.order = append(g.order, m)
+	for _, enum := range msg.GetEnumType() {
+		g.indexEnum(enum, prefix+msg.GetName()+".", goName+"_")
+	}
+	for _, nested := range msg.GetNestedType() {
+		g.indexMessage(nested, prefix+msg.GetName()+".", goName+"_", proto2)
+	}
+}
+
+// indexEnum records the enum's first value. Values of nested enums are
+// prefixed with the parent message, like protoc-gen-go does.
+func (g *mockGen) indexEnum(enum *descriptorpb.EnumDescriptorProto, prefix, parent string) {
+	if len(enum.GetValue()) == 0 {
+		return
+	}
+	goName := parent + goCamelCase(enum.GetName())
+	valuePrefix := parent
+	if valuePrefix == "" {
+		valuePrefix = goName + "_"
+	}
+	value := enum.GetValue()[0]
+	if len(enum.GetValue()) > 1 && value.GetNumber() == 0 {
+		// Prefer a meaningful value over the UNSPECIFIED default
+		value = enum.GetValue()[1]
+	}
+	g.enums[prefix+enum.GetName()] = mockEnum{goName: goName, value: valuePrefix + value.GetName()}
+}
+
+func (g *mockGen) goType(typeName string) (string, bool) {
+	m, ok := g.messages[typeName]
+	if !ok || m.goName == "" {
+		return "", false
+	}
+	return m.goName, true
+}
+
+func (g *mockGen) writeService(b *strings.Builder, service *descriptorpb.ServiceDescriptorProto) {
+	name := goCamelCase(service.GetName()) + "Mock"
+	type method struct {
+		name, rpc string
+		req, resp string
+		signature string
+		example   string
+		kind      string
+	}
+	var methods []method
+	for _, m := range service.GetMethod() {
+		req, okReq := g.goType(m.GetInputType())
+		resp, okResp := g.goType(m.GetOutputType())
+		if !okReq || !okResp || (m.GetClientStreaming() && m.GetServerStreaming()) {
+			// Imported types and bidirectional streams aren't mocked
+			continue
+		}
+		mm := method{name: goCamelCase(m.GetName()), rpc: m.GetName(), req: req, resp: resp}
+		switch {
+		case m.GetServerStreaming():
+			mm.kind = "server"
+			mm.signature = fmt.Sprintf("func(ctx context.Context, req *%s) ([]*%s, error)", req, resp)
+			mm.example = fmt.Sprintf("return []*%s{Example%s()}, nil", resp, resp)
+		case m.GetClientStreaming():
+			mm.kind = "client"
+			mm.signature = fmt.Sprintf("func(ctx context.Context, reqs []*%s) (*%s, error)", req, resp)
+			mm.example = fmt.Sprintf("return Example%s(), nil", resp)
+		default:
+			mm.kind = "unary"
+			mm.signature = fmt.Sprintf("func(ctx context.Context, req *%s) (*%s, error)", req, resp)
+			mm.example = fmt.Sprintf("return Example%s(), nil", resp)
+		}
+		methods = append(methods, mm)
+	}
+	if len(methods) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "\n// %s is a fake %s that answers every RPC with an example\n", name, service.GetName())
+	b.WriteString("// response. Replace its fields to control responses in tests.\n")
+	fmt.Fprintf(b, "type %s struct {\n", name)
+	for _, m := range methods {
+		fmt.Fprintf(b, "\t%s %s\n", m.name, m.signature)
+	}
+	b.WriteString("}\n")
+
+	fmt.Fprintf(b, "\n// New%s returns a mock answering every RPC with an example response.\n", name)
+	fmt.Fprintf(b, "func New%s() *%s {\n\treturn &%s{\n", name, name, name)
+	for _, m := range methods {
+		fmt.Fprintf(b, "\t\t%s: %s {\n\t\t\t%s\n\t\t},\n", m.name, m.signature, m.example)
+	}
+	b.WriteString("\t}\n}\n")
+
+	b.WriteString("\n// Register adds the mock's handlers to d for the methods that don't have a\n")
+	b.WriteString("// handler yet, so a client can run against a partly implemented server.\n")
+	fmt.Fprintf(b, "func (m *%s) Register(d *gapp.Dispatcher) {\n", name)
+	for _, m := range methods {
+		switch m.kind {
+		case "unary":
+			fmt.Fprintf(b, `	if _, ok := d.Unary[%[4]q]; !ok {
+		d.Unary[%[4]q] = func(w http.ResponseWriter, r *http.Request, method string, body []byte) ([]byte, error) {
+			req := &%[2]s{}
+			if err := proto.Unmarshal(body, req); err != nil {
+				return nil, gapp.ErrValidation("invalid request for " + method)
+			}
+			resp, err := m.%[1]s(r.Context(), req)
+			if err != nil {
+				return nil, err
+			}
+			return proto.Marshal(resp)
+		}
+	}
+`, m.name, m.req, m.resp, m.rpc)
+		case "client":
+			fmt.Fprintf(b, `	if _, ok := d.Unary[%[4]q]; !ok {
+		d.Unary[%[4]q] = func(w http.ResponseWriter, r *http.Request, method string, body []byte) ([]byte, error) {
+			var reqs []*%[2]s
+			reader := gapp.NewMessageReader(body)
+			for {
+				data, err := reader.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					return nil, gapp.ErrValidation("invalid request for " + method)
+				}
+				req := &%[2]s{}
+				if err := proto.Unmarshal(data, req); err != nil {
+					return nil, gapp.ErrValidation("invalid request for " + method)
+				}
+				reqs = append(reqs, req)
+			}
+			resp, err := m.%[1]s(r.Context(), reqs)
+			if err != nil {
+				return nil, err
+			}
+			return proto.Marshal(resp)
+		}
+	}
+`, m.name, m.req, m.resp, m.rpc)
+		case "server":
+			fmt.Fprintf(b, `	if _, ok := d.Streaming[%[4]q]; !ok {
+		d.Streaming[%[4]q] = gapp.StreamHandlerFor(func(ctx context.Context, req *%[2]s, stream *gapp.TypedStream[*%[3]s]) error {
+			resps, err := m.%[1]s(ctx, req)
+			if err != nil {
+				return err
+			}
+			for _, resp := range resps {
+				if err := stream.Send(resp); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+`, m.name, m.req, m.resp, m.rpc)
+		}
+	}
+	b.WriteString("}\n")
+}
+
+// writeExample writes an exported Example constructor for msg and the
+// depth-bounded helper it calls.
+func (g *mockGen) writeExample(b *strings.Builder, msg *mockMessage) {
+	helper := "example" + msg.goName
+	fmt.Fprintf(b, "\n// Example%s returns a %s with every field populated.\n", msg.goName, msg.goName)
+	fmt.Fprintf(b, "func Example%s() *%s {\n\treturn %s(0)\n}\n", msg.goName, msg.goName, helper)
+
+	fmt.Fprintf(b, "\nfunc %s(depth int) *%s {\n", helper, msg.goName)
+	fmt.Fprintf(b, "\tm := &%s{}\n", msg.goName)
+	var nested []string
+	oneofSet := make(map[int32]bool)
+	for _, field := range msg.desc.GetField() {
+		inOneof := field.OneofIndex != nil && !field.GetProto3Optional()
+		if inOneof {
+			if oneofSet[field.GetOneofIndex()] {
+				continue
+			}
+		}
+		value, recursive, ok := g.fieldValue(msg, field)
+		if !ok {
+			continue
+		}
+		if inOneof {
+			oneofSet[field.GetOneofIndex()] = true
+			oneof := goCamelCase(msg.desc.GetOneofDecl()[field.GetOneofIndex()].GetName())
+			value = fmt.Sprintf("&%s_%s{%s: %s}", msg.goName, goCamelCase(field.GetName()), goCamelCase(field.GetName()), value)
+			if recursive {
+				nested = append(nested, fmt.Sprintf("m.%s = %s", oneof, value))
+			} else {
+				fmt.Fprintf(b, "\tm.%s = %s\n", oneof, value)
+			}
+			continue
+		}
+		line := fmt.Sprintf("m.%s = %s", goCamelCase(field.GetName()), value)
+		if recursive {
+			nested = append(nested, line)
+		} else {
+			fmt.Fprintf(b, "\t%s\n", line)
+		}
+	}
+	if len(nested) > 0 {
+		fmt.Fprintf(b, "\tif depth < %d {\n", mockDepth)
+		for _, line := range nested {
+			fmt.Fprintf(b, "\t\t%s\n", line)
+		}
+		b.WriteString("\t}\n")
+	}
+	b.WriteString("\treturn m\n}\n")
+}
+
+// fieldValue returns a Go expression for an example value of field, and
+// whether it builds a nested message and so must be depth-bounded.
+func (g *mockGen) fieldValue(msg *mockMessage, field *descriptorpb.FieldDescriptorProto) (string, bool, bool) {
+	repeated := field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+
+	if field.GetType() == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE {
+		if entry, ok := g.messages[field.GetTypeName()]; ok && entry.desc.GetOptions().GetMapEntry() {
+			return g.mapValue(entry)
+		}
+		goType, ok := g.goType(field.GetTypeName())
+		if !ok {
+			return "", false, false
+		}
+		value := fmt.Sprintf("example%s(depth + 1)", goType)
+		if repeated {
+			value = fmt.Sprintf("[]*%s{%s}", goType, value)
+		}
+		return value, true, true
+	}
+
+	value, goType, ok := g.scalarValue(field)
+	if !ok {
+		return "", false, false
+	}
+	switch {
+	case repeated:
+		return fmt.Sprintf("[]%s{%s}", goType, value), false, true
+	case field.GetProto3Optional() || (msg.proto2 && field.OneofIndex == nil):
+		if field.GetType() == descriptorpb.FieldDescriptorProto_TYPE_BYTES {
+			return value, false, true
+		}
+		if field.GetType() == descriptorpb.FieldDescriptorProto_TYPE_ENUM {
+			return value + ".Enum()", false, true
+		}
+		return fmt.Sprintf("proto.%s(%s)", protoHelper(goType), value), false, true
+	}
+	return value, false, true
+}
+
+func (g *mockGen) mapValue(entry *mockMessage) (string, bool, bool) {
+	var key, value *descriptorpb.FieldDescriptorProto
+	for _, f := range entry.desc.GetField() {
+		if f.GetNumber() == 1 {
+			key = f
+		} else {
+			value = f
+		}
+	}
+	keyValue, keyType, ok := g.scalarValue(key)
+	if !ok {
+		return "", false, false
+	}
+	if value.GetType() == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE {
+		goType, ok := g.goType(value.GetTypeName())
+		if !ok {
+			return "", false, false
+		}
+		return fmt.Sprintf("map[%s]*%s{%s: example%s(depth + 1)}", keyType, goType, keyValue, goType), true, true
+	}
+	valueValue, valueType, ok := g.scalarValue(value)
+	if !ok {
+		return "", false, false
+	}
+	return fmt.Sprintf("map[%s]%s{%s: %s}", keyType, valueType, keyValue, valueValue), false, true
+}
+
+// scalarValue returns an example value for a non-message field and its Go
+// type.
+func (g *mockGen) scalarValue(field *descriptorpb.FieldDescriptorProto) (value, goType string, ok bool) {
+	switch field.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_STRING:
+		return fmt.Sprintf("%q", "example "+strings.ReplaceAll(field.GetName(), "_", " ")), "string", true
+	case descriptorpb.FieldDescriptorProto_TYPE_BYTES:
+		return fmt.Sprintf("[]byte(%q)", field.GetName()), "[]byte", true
+	case descriptorpb.FieldDescriptorProto_TYPE_BOOL:
+		return "true", "bool", true
+	case descriptorpb.FieldDescriptorProto_TYPE_INT32, descriptorpb.FieldDescriptorProto_TYPE_SINT32, descriptorpb.FieldDescriptorProto_TYPE_SFIXED32:
+		return "1", "int32", true
+	case descriptorpb.FieldDescriptorProto_TYPE_INT64, descriptorpb.FieldDescriptorProto_TYPE_SINT64, descriptorpb.FieldDescriptorProto_TYPE_SFIXED64:
+		return "1", "int64", true
+	case descriptorpb.FieldDescriptorProto_TYPE_UINT32, descriptorpb.FieldDescriptorProto_TYPE_FIXED32:
+		return "1", "uint32", true
+	case descriptorpb.FieldDescriptorProto_TYPE_UINT64, descriptorpb.FieldDescriptorProto_TYPE_FIXED64:
+		return "1", "uint64", true
+	case descriptorpb.FieldDescriptorProto_TYPE_FLOAT:
+		return "1.5", "float32", true
+	case descriptorpb.FieldDescriptorProto_TYPE_DOUBLE:
+		return "1.5", "float64", true
+	case descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+		enum, ok := g.enums[field.GetTypeName()]
+		if !ok {
+			return "", "", false
+		}
+		return enum.value, enum.goName, true
+	}
+	return "", "", false
+}
+
+func protoHelper(goType string) string {
+	switch goType {
+	case "float32":
+		return "Float32"
+	case "float64":
+		return "Float64"
+	default:
+		return goCamelCase(goType)
+	}
+}
+
+// goCamelCase converts a proto name to the Go identifier protoc-gen-go uses,
+// e.g. "bytes_received" to "BytesReceived".
+func goCamelCase(s string) string {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '.' && i+1 < len(s) && isASCIILower(s[i+1]):
+			// Skip over '.' in ".{{lowercase}}".
+		case c == '.':
+			b = append(b, '_')
+		case c == '_' && (i == 0 || s[i-1] == '.'):
+			// Convert initial '_' to ensure we start with a capital letter.
+			b = append(b, 'X')
+		case c == '_' && i+1 < len(s) && isASCIILower(s[i+1]):
+			// Skip over '_' in "_{{lowercase}}".
+		case isASCIIDigit(c):
+			b = append(b, c)
+		default:
+			// Assume we have a letter now - if not, it's a bogus identifier.
+			if isASCIILower(c) {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+			for ; i+1 < len(s) && isASCIILower(s[i+1]); i++ {
+				b = append(b, s[i+1])
+			}
+		}
+	}
+	return string(b)
+}
+
+func isASCIILower(c byte) bool { return 'a' <= c && c <= 'z' }
+func isASCIIDigit(c byte) bool { return '0' <= c && c <= '9' }
@@ -0,0 +1,71 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateMocksGo(t *testing.T) {
+	proto := `syntax = "proto3";
+
+package app;
+
+enum Status {
+  STATUS_UNSPECIFIED = 0;
+  STATUS_ACTIVE = 1;
+}
+
+message Item {
+  message Tag {
+    string label = 1;
+  }
+  string id = 1;
+  optional string note = 2;
+  repeated Tag tags = 3;
+  map<string, int32> scores = 4;
+  Status status = 5;
+  oneof value {
+    string text = 6;
+    Item parent = 7;
+  }
+}
+
+message GetItemsRequest {}
+
+message GetItemsResponse {
+  repeated Item items = 1;
+}
+
+service AppService {
+  rpc GetItems(GetItemsRequest) returns (GetItemsResponse);
+  rpc WatchItems(GetItemsRequest) returns (stream Item);
+  rpc Chat(stream Item) returns (stream Item);
+}
+`
+	req := compileProto(t, map[string]string{"service.proto": proto})
+
+	src, err := GenerateMocksGo(req, "generated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"package generated",
+		"GetItems   func(ctx context.Context, req *GetItemsRequest) (*GetItemsResponse, error)",
+		"WatchItems func(ctx context.Context, req *GetItemsRequest) ([]*Item, error)",
+		`if _, ok := d.Unary["GetItems"]; !ok {`,
+		`d.Streaming["WatchItems"] = gapp.StreamHandlerFor(`,
+		`m.Note = proto.String("example note")`,
+		"m.Tags = []*Item_Tag{exampleItem_Tag(depth + 1)}",
+		`m.Scores = map[string]int32{"example key": 1}`,
+		"m.Status = Status_STATUS_ACTIVE",
+		`m.Value = &Item_Text{Text: "example text"}`,
+		"func ExampleGetItemsResponse() *GetItemsResponse {",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("generated mocks missing %q:\n%s", want, src)
+		}
+	}
+	if strings.Contains(src, "Chat") {
+		t.Errorf("bidirectional Chat should not be mocked:\n%s", src)
+	}
+}
@@ -0,0 +1,41 @@
+package codegen
+
+import (
+	"os/exec"
+	"path"
+	"strings"
+
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// Plugin is a protoc plugin codegen runs on the compiled protos after its
+// own, declared in gapp.toml as a [[codegen.plugin]] table.
+type Plugin struct {
+	Name string // shown in codegen's output (default: the binary's name)
+	Path string // binary, looked up on PATH unless it's a path
+	Go   string // go run target instead of Path, e.g. example.com/protoc-gen-foo@v1.2.0
+	Opt  string // comma-separated plugin parameters
+	Out  string // directory the generated files are written to
+}
+
+// Label returns the plugin's name, or the name of its binary.
+func (p Plugin) Label() string {
+	switch {
+	case p.Name != "":
+		return p.Name
+	case p.Go != "":
+		target, _, _ := strings.Cut(p.Go, "@")
+		return path.Base(target)
+	default:
+		return path.Base(strings.ReplaceAll(p.Path, `\`, "/"))
+	}
+}
+
+// Run runs the plugin on req.
+func (p Plugin) Run(req *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+	cmd := exec.Command(p.Path)
+	if p.Go != "" {
+		cmd = exec.Command("go", "run", p.Go)
+	}
+	return runPlugin(req, cmd, p.Label(), p.Opt)
+}
@@ -0,0 +1,19 @@
+package codegen
+
+import "testing"
+
+func TestPluginLabel(t *testing.T) {
+	tests := []struct {
+		plugin Plugin
+		want   string
+	}{
+		{Plugin{Name: "validate", Path: "protoc-gen-validate"}, "validate"},
+		{Plugin{Path: "./bin/protoc-gen-custom"}, "protoc-gen-custom"},
+		{Plugin{Go: "github.com/grpc-ecosystem/grpc-gateway/v2/protoc-gen-grpc-gateway@v2.26.0"}, "protoc-gen-grpc-gateway"},
+	}
+	for _, tt := range tests {
+		if got := tt.plugin.Label(); got != tt.want {
+			t.Errorf("%+v.Label() = %q, want %q", tt.plugin, got, tt.want)
+		}
+	}
+}
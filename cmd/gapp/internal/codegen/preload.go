@@ -1,12 +1,20 @@
 package codegen
 
 import (
+	"errors"
 	"fmt"
+	"go/format"
+	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/pluginpb"
 )
 
 // RpcSpec defines an RPC to preload with optional parameter mappings.
@@ -17,8 +25,10 @@ type RpcSpec struct {
 
 // RoutePreload defines preload configuration for a route pattern.
 type RoutePreload struct {
-	Path string
-	Rpcs []RpcSpec
+	Path   string
+	Rpcs   []RpcSpec
+	File   string // route file declaring it, relative to the routes directory
+	Export string // name of the route object the file exports, if found
 }
 
 var (
@@ -26,6 +36,11 @@ var (
 	methodRe = regexp.MustCompile(`method:\s*"([^"]+)"`)
 	paramsRe = regexp.MustCompile(`params:\s*\{([^}]+)\}`)
 	paramKV  = regexp.MustCompile(`"([^"]+)":\s*"([^"]+)"`)
+	exportRe = regexp.MustCompile(`export\s+const\s+([A-Za-z_$][\w$]*)\s*(?::[^=]*)?=\s*\{`)
+
+	// placeholderRe matches the :param placeholders in RPC param values,
+	// substituted with the route's params
+	placeholderRe = regexp.MustCompile(`:([A-Za-z_][A-Za-z0-9_]*)`)
 )
 
 // ParseRouteFile extracts the route path and RPC declarations from a TypeScript route file.
@@ -45,26 +60,50 @@ func ParseRouteFile(filePath string) (*RoutePreload, error) {
 	if err != nil {
 		return nil, err
 	}
-	content := string(data)
-
-	// Extract path
-	pathMatch := pathRe.FindStringSubmatch(content)
-	if pathMatch == nil {
+	route := parseRoute(string(data))
+	if route == nil || route.Path == "" {
 		return nil, nil // No route definition found
 	}
-	routePath := pathMatch[1]
+	return route, nil
+}
+
+// parseRoute extracts the path and RPC declarations of a route file, either
+// of which may be missing, and the name of the route object it exports. It
+// returns nil when the file declares neither.
+func parseRoute(content string) *RoutePreload {
+	route := &RoutePreload{Rpcs: parseRpcs(content)}
+	anchor := strings.Index(content, "rpcs:")
+	if pathMatch := pathRe.FindStringSubmatchIndex(content); pathMatch != nil {
+		route.Path = content[pathMatch[2]:pathMatch[3]]
+		anchor = pathMatch[0]
+	}
+	if route.Path == "" && len(route.Rpcs) == 0 {
+		return nil
+	}
+	// The route object is the last export ahead of its declarations
+	for _, m := range exportRe.FindAllStringSubmatchIndex(content, -1) {
+		if m[0] > anchor {
+			break
+		}
+		route.Export = content[m[2]:m[3]]
+	}
+	return route
+}
 
+// parseRpcs extracts the { method, params } objects of a route file's rpcs
+// array.
+func parseRpcs(content string) []RpcSpec {
 	// Find the rpcs array region
 	rpcsIdx := strings.Index(content, "rpcs:")
 	if rpcsIdx == -1 {
-		return nil, nil // No rpcs declaration
+		return nil // No rpcs declaration
 	}
 
 	// Extract the rpcs array content (from rpcs: [ ... ])
 	rpcsContent := content[rpcsIdx:]
 	bracketStart := strings.Index(rpcsContent, "[")
 	if bracketStart == -1 {
-		return nil, nil
+		return nil
 	}
 
 	// Find matching bracket
@@ -85,7 +124,7 @@ func ParseRouteFile(filePath string) (*RoutePreload, error) {
 		}
 	}
 	if bracketEnd == -1 {
-		return nil, nil
+		return nil
 	}
 
 	arrayContent := rpcsContent[bracketStart : bracketEnd+1]
@@ -131,46 +170,207 @@ func ParseRouteFile(filePath string) (*RoutePreload, error) {
 		}
 	}
 
-	if len(rpcs) == 0 {
-		return nil, nil
+	return rpcs
+}
+
+// ScanRoutes scans a directory and its subdirectories for route files and
+// extracts preload configs, including routes that preload no RPCs, which
+// GeneratePreloadGo leaves out. Directories starting with . or _ are
+// skipped.
+//
+// With inferPaths, files that don't declare a path get one from where they
+// are in routesDir (see InferRoutePath), so routes/users/[id].tsx is
+// /users/:id. A declared path always wins.
+func ScanRoutes(routesDir string, inferPaths bool) ([]RoutePreload, error) {
+	var routes []RoutePreload
+	err := filepath.WalkDir(routesDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("reading routes directory: %w", err)
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != routesDir && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".ts") && !strings.HasSuffix(name, ".tsx") {
+			return nil
+		}
+		// Tests sit next to the routes they cover
+		if strings.Contains(name, ".test.") {
+			return nil
+		}
+
+		rel, _ := filepath.Rel(routesDir, path)
+		rel = filepath.ToSlash(rel)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", rel, err)
+		}
+		route := parseRoute(string(data))
+		if route == nil {
+			return nil
+		}
+		if route.Path == "" {
+			if !inferPaths {
+				return nil
+			}
+			route.Path = InferRoutePath(rel)
+		}
+		route.File = rel
+		routes = append(routes, *route)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return routes, nil
+}
 
-	return &RoutePreload{Path: routePath, Rpcs: rpcs}, nil
+// InferRoutePath returns the path of the route declared in file, a
+// slash-separated path relative to the routes directory. Its directories and
+// name, without the extension, are the path's segments: [param] is :param,
+// [[param]] the optional :param?, and index the directory itself. So
+// users/[id]/index.tsx is /users/:id and users/[id]/posts.tsx
+// /users/:id/posts.
+func InferRoutePath(file string) string {
+	file = strings.TrimSuffix(strings.TrimSuffix(file, ".tsx"), ".ts")
+	var segments []string
+	for _, segment := range strings.Split(file, "/") {
+		switch {
+		case strings.HasPrefix(segment, "[[") && strings.HasSuffix(segment, "]]"):
+			segment = ":" + segment[2:len(segment)-2] + "?"
+		case strings.HasPrefix(segment, "[") && strings.HasSuffix(segment, "]"):
+			segment = ":" + segment[1:len(segment)-1]
+		}
+		segments = append(segments, segment)
+	}
+	if segments[len(segments)-1] == "index" {
+		segments = segments[:len(segments)-1]
+	}
+	return "/" + strings.Join(segments, "/")
 }
 
-// ScanRoutes scans a directory for route files and extracts preload configs.
-func ScanRoutes(routesDir string) ([]RoutePreload, error) {
-	entries, err := os.ReadDir(routesDir)
+// ValidateRoutes checks the RPCs the routes preload against the compiled
+// protos of req: each method must be one of their unary RPCs, each param a
+// field of its request, by proto or JSON name, and each :param placeholder a
+// param of the route's pattern. Otherwise these mistakes only show at
+// runtime, as preloads that fail or are skipped. It returns every problem
+// found, or nil.
+func ValidateRoutes(routes []RoutePreload, req *pluginpb.CodeGeneratorRequest) error {
+	files, err := generatedFiles(req)
 	if err != nil {
-		return nil, fmt.Errorf("reading routes directory: %w", err)
+		return err
+	}
+	methods := make(map[string]protoreflect.MethodDescriptor)
+	for _, file := range files {
+		for i := 0; i < file.Services().Len(); i++ {
+			service := file.Services().Get(i)
+			for j := 0; j < service.Methods().Len(); j++ {
+				method := service.Methods().Get(j)
+				methods[string(method.Name())] = method
+			}
+		}
 	}
 
-	var routes []RoutePreload
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
+	var errs []error
+	for _, route := range routes {
+		where := route.File
+		if where == "" {
+			where = route.Path
 		}
-		name := entry.Name()
-		if !strings.HasSuffix(name, ".ts") && !strings.HasSuffix(name, ".tsx") {
-			continue
+		routeParams := make(map[string]bool)
+		for _, segment := range splitPattern(route.Path) {
+			if strings.HasPrefix(segment, ":") {
+				routeParams[strings.TrimSuffix(segment[1:], "?")] = true
+			}
 		}
-
-		route, err := ParseRouteFile(filepath.Join(routesDir, name))
-		if err != nil {
-			return nil, fmt.Errorf("parsing %s: %w", name, err)
+		for _, rpc := range route.Rpcs {
+			method, ok := methods[rpc.Method]
+			if !ok {
+				msg := fmt.Sprintf("%s: %s isn't an RPC of the protos", where, rpc.Method)
+				if suggestion := closest(rpc.Method, slices.Collect(maps.Keys(methods))); suggestion != "" {
+					msg += fmt.Sprintf(", did you mean %s?", suggestion)
+				}
+				errs = append(errs, errors.New(msg))
+				continue
+			}
+			if method.IsStreamingClient() || method.IsStreamingServer() {
+				errs = append(errs, fmt.Errorf("%s: %s streams, only unary RPCs can be preloaded", where, rpc.Method))
+				continue
+			}
+			input := method.Input()
+			for _, name := range slices.Sorted(maps.Keys(rpc.Params)) {
+				if input.Fields().ByName(protoreflect.Name(name)) == nil && input.Fields().ByJSONName(name) == nil {
+					var fields []string
+					for i := 0; i < input.Fields().Len(); i++ {
+						fields = append(fields, string(input.Fields().Get(i).Name()))
+					}
+					msg := fmt.Sprintf("%s: %s param %q isn't a field of %s", where, rpc.Method, name, input.Name())
+					if suggestion := closest(name, fields); suggestion != "" {
+						msg += fmt.Sprintf(", did you mean %s?", suggestion)
+					}
+					errs = append(errs, errors.New(msg))
+				}
+				for _, m := range placeholderRe.FindAllStringSubmatch(rpc.Params[name], -1) {
+					if !routeParams[m[1]] {
+						errs = append(errs, fmt.Errorf("%s: %s param %q uses :%s, which isn't a param of %s", where, rpc.Method, name, m[1], route.Path))
+					}
+				}
+			}
 		}
-		if route != nil {
-			routes = append(routes, *route)
+	}
+	return errors.Join(errs...)
+}
+
+// closest returns the candidate closest to name, if it's close enough to be
+// what a typo of name meant.
+func closest(name string, candidates []string) string {
+	slices.Sort(candidates)
+	best, bestDistance := "", 3
+	for _, candidate := range candidates {
+		d := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if d < bestDistance {
+			best, bestDistance = candidate, d
 		}
 	}
+	return best
+}
 
-	return routes, nil
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
 }
 
 // GeneratePreloadGo generates Go source code for the preload route config.
 // It imports gapp's RouteSpec/RpcSpec types directly so the generated var
 // is compatible with gapp.NewPreloadEngine.
-func GeneratePreloadGo(routes []RoutePreload, packageName string) string {
+//
+// The output only depends on the routes, not the order they're found in:
+// routes are sorted most specific first, since the first matching pattern
+// wins, and params by name. It's gofmt-formatted, so regenerating it on
+// another machine doesn't change it.
+func GeneratePreloadGo(routes []RoutePreload, packageName string) (string, error) {
+	routes = slices.Clone(routes)
+	slices.SortStableFunc(routes, compareRoutes)
+
 	methodSet := make(map[string]bool)
 	for _, r := range routes {
 		for _, rpc := range r.Rpcs {
@@ -197,6 +397,9 @@ func GeneratePreloadGo(routes []RoutePreload, packageName string) string {
 		if len(route.Rpcs) == 0 {
 			continue
 		}
+		if route.File != "" {
+			fmt.Fprintf(&b, "\t// %s\n", route.File)
+		}
 		b.WriteString("\t{\n")
 		b.WriteString(fmt.Sprintf("\t\tPattern: %q,\n", route.Path))
 		b.WriteString("\t\tRpcs: []gapp.RpcSpec{\n")
@@ -204,10 +407,9 @@ func GeneratePreloadGo(routes []RoutePreload, packageName string) string {
 			params := "nil"
 			if len(rpc.Params) > 0 {
 				var kvs []string
-				for k, v := range rpc.Params {
-					kvs = append(kvs, fmt.Sprintf("%q: %q", k, v))
+				for _, k := range slices.Sorted(maps.Keys(rpc.Params)) {
+					kvs = append(kvs, fmt.Sprintf("%q: %q", k, rpc.Params[k]))
 				}
-				sort.Strings(kvs)
 				params = "map[string]string{" + strings.Join(kvs, ", ") + "}"
 			}
 			b.WriteString(fmt.Sprintf("\t\t\t{Method: %q, Params: %s},\n", rpc.Method, params))
@@ -225,5 +427,43 @@ func GeneratePreloadGo(routes []RoutePreload, packageName string) string {
 	}
 	b.WriteString("}\n")
 
-	return b.String()
+	src, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return "", fmt.Errorf("formatting preload config: %w", err)
+	}
+	return string(src), nil
+}
+
+// compareRoutes orders routes by their patterns segment by segment, static
+// segments before params and required params before optional ones, so a
+// pattern comes before the more general ones that would also match its
+// paths: /items/new before /items/:id. Ties are broken by pattern and file.
+func compareRoutes(a, b RoutePreload) int {
+	rank := func(segment string) int {
+		switch {
+		case strings.HasPrefix(segment, ":") && strings.HasSuffix(segment, "?"):
+			return 2
+		case strings.HasPrefix(segment, ":"):
+			return 1
+		}
+		return 0
+	}
+	as, bs := splitPattern(a.Path), splitPattern(b.Path)
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if c := rank(as[i]) - rank(bs[i]); c != 0 {
+			return c
+		}
+	}
+	if c := strings.Compare(a.Path, b.Path); c != 0 {
+		return c
+	}
+	return strings.Compare(a.File, b.File)
+}
+
+func splitPattern(pattern string) []string {
+	pattern = strings.Trim(pattern, "/")
+	if pattern == "" {
+		return nil
+	}
+	return strings.Split(pattern, "/")
 }
@@ -1,8 +1,11 @@
 package codegen
 
 import (
+	"go/format"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -136,8 +139,10 @@ func TestScanRoutes(t *testing.T) {
 	os.WriteFile(filepath.Join(dir, "HomeRoute.tsx"), []byte(home), 0644)
 	os.WriteFile(filepath.Join(dir, "AboutRoute.ts"), []byte(about), 0644)
 	os.WriteFile(filepath.Join(dir, "utils.ts"), []byte(utils), 0644)
+	// Tests are skipped, even if they declare routes of their own
+	os.WriteFile(filepath.Join(dir, "HomeRoute.test.tsx"), []byte(home), 0644)
 
-	routes, err := ScanRoutes(dir)
+	routes, err := ScanRoutes(dir, false)
 	if err != nil {
 		t.Fatalf("ScanRoutes failed: %v", err)
 	}
@@ -163,7 +168,10 @@ func TestGeneratePreloadGo(t *testing.T) {
 		},
 	}
 
-	code := GeneratePreloadGo(routes, "generated")
+	code, err := GeneratePreloadGo(routes, "generated")
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if !strings.Contains(code, "package generated") {
 		t.Error("Should contain package declaration")
@@ -199,3 +207,172 @@ func TestGeneratePreloadGo(t *testing.T) {
 		t.Error("PreloadMethods should contain GetUserPosts")
 	}
 }
+
+func TestGeneratePreloadGoDeterministic(t *testing.T) {
+	routes := []RoutePreload{
+		{Path: "/items/:id", File: "ItemRoute.tsx", Rpcs: []RpcSpec{
+			{Method: "GetItem", Params: map[string]string{"id": "itemId", "expand": "fields", "a": "b"}},
+		}},
+		{Path: "/items/:id?/all", File: "AllRoute.tsx", Rpcs: []RpcSpec{{Method: "ListItems"}}},
+		{Path: "/items/new", File: "NewItemRoute.tsx", Rpcs: []RpcSpec{{Method: "GetDraft"}}},
+		{Path: "/", File: "HomeRoute.tsx", Rpcs: []RpcSpec{{Method: "GetItems"}}},
+	}
+	code, err := GeneratePreloadGo(routes, "generated")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The order routes are found in doesn't matter
+	slices.Reverse(routes)
+	if again, _ := GeneratePreloadGo(routes, "generated"); again != code {
+		t.Errorf("output depends on the routes' order:\n%s\n---\n%s", code, again)
+	}
+
+	formatted, err := format.Source([]byte(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(formatted) != code {
+		t.Errorf("output isn't gofmt-formatted:\n%s", code)
+	}
+
+	// Specific patterns come first, since the first match wins
+	var order []string
+	for _, pattern := range []string{`"/"`, `"/items/new"`, `"/items/:id"`, `"/items/:id?/all"`} {
+		order = append(order, pattern)
+		if !strings.Contains(code, "Pattern: "+pattern) {
+			t.Fatalf("missing pattern %s:\n%s", pattern, code)
+		}
+	}
+	for i := 1; i < len(order); i++ {
+		if strings.Index(code, "Pattern: "+order[i-1]) > strings.Index(code, "Pattern: "+order[i]) {
+			t.Errorf("%s should come before %s:\n%s", order[i-1], order[i], code)
+		}
+	}
+	for _, want := range []string{
+		"\t// NewItemRoute.tsx\n\t{\n\t\tPattern: \"/items/new\",",
+		`Params: map[string]string{"a": "b", "expand": "fields", "id": "itemId"}`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("output missing %q:\n%s", want, code)
+		}
+	}
+}
+
+func TestValidateRoutes(t *testing.T) {
+	proto := `syntax = "proto3";
+
+message GetUserRequest {
+  string user_id = 1;
+}
+
+message User {}
+
+service AppService {
+  rpc GetUser(GetUserRequest) returns (User);
+  rpc WatchUser(GetUserRequest) returns (stream User);
+}
+`
+	req := compileProto(t, map[string]string{"service.proto": proto})
+
+	valid := []RoutePreload{{Path: "/users/:id", File: "UserRoute.tsx", Rpcs: []RpcSpec{
+		{Method: "GetUser", Params: map[string]string{"user_id": ":id"}},
+		{Method: "GetUser", Params: map[string]string{"userId": ":id"}},
+	}}}
+	if err := ValidateRoutes(valid, req); err != nil {
+		t.Errorf("ValidateRoutes of valid routes: %v", err)
+	}
+
+	invalid := []RoutePreload{{Path: "/users/:id", File: "UserRoute.tsx", Rpcs: []RpcSpec{
+		{Method: "GetUsr"},
+		{Method: "WatchUser"},
+		{Method: "GetUser", Params: map[string]string{"user": ":id"}},
+		{Method: "GetUser", Params: map[string]string{"user_id": ":userId"}},
+	}}}
+	err := ValidateRoutes(invalid, req)
+	if err == nil {
+		t.Fatal("ValidateRoutes of invalid routes should fail")
+	}
+	for _, want := range []string{
+		"UserRoute.tsx: GetUsr isn't an RPC of the protos, did you mean GetUser?",
+		"UserRoute.tsx: WatchUser streams, only unary RPCs can be preloaded",
+		`UserRoute.tsx: GetUser param "user" isn't a field of GetUserRequest`,
+		`UserRoute.tsx: GetUser param "user_id" uses :userId, which isn't a param of /users/:id`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("ValidateRoutes error missing %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestScanRoutesNested(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"HomeRoute.tsx": `export const homeRoute = {
+  path: "/",
+  factory: () => ({ rpcs: [{ method: "GetItems" }] }),
+};
+`,
+		"users/UserRoute.tsx": `export const userRoute = {
+  path: "/users/:id",
+  factory: () => ({ rpcs: [{ method: "GetUser", params: { "id": ":id" } }] }),
+};
+`,
+		// Declares no path, so it's only found with inferred paths
+		"admin/[section]/index.tsx": `export const adminRoute = {
+  factory: () => ({ rpcs: [{ method: "GetSection", params: { "name": ":section" } }] }),
+};
+`,
+		"_drafts/DraftRoute.tsx": `export const draftRoute = {
+  path: "/drafts",
+  factory: () => ({ rpcs: [{ method: "GetDrafts" }] }),
+};
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found := func(inferPaths bool) map[string]string {
+		t.Helper()
+		routes, err := ScanRoutes(dir, inferPaths)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths := make(map[string]string)
+		for _, route := range routes {
+			paths[route.File] = route.Path
+		}
+		return paths
+	}
+	want := map[string]string{"HomeRoute.tsx": "/", "users/UserRoute.tsx": "/users/:id"}
+	if got := found(false); !maps.Equal(got, want) {
+		t.Errorf("ScanRoutes = %v, want %v", got, want)
+	}
+	want["admin/[section]/index.tsx"] = "/admin/:section"
+	if got := found(true); !maps.Equal(got, want) {
+		t.Errorf("ScanRoutes with inferred paths = %v, want %v", got, want)
+	}
+}
+
+func TestInferRoutePath(t *testing.T) {
+	for file, want := range map[string]string{
+		"index.tsx":               "/",
+		"about.tsx":               "/about",
+		"users/[id].tsx":          "/users/:id",
+		"users/[id]/index.ts":     "/users/:id",
+		"users/[id]/posts.tsx":    "/users/:id/posts",
+		"search/[[query]].tsx":    "/search/:query?",
+		"docs/getting-started.ts": "/docs/getting-started",
+	} {
+		if got := InferRoutePath(file); got != want {
+			t.Errorf("InferRoutePath(%q) = %q, want %q", file, got, want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package codegen
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// ProtoSet is the .proto files codegen compiles together, so they can import
+// each other.
+type ProtoSet struct {
+	Root        string   // the directory Files are relative to
+	Files       []string // slash-separated, sorted
+	ImportPaths []string // where imports resolve, Root first
+}
+
+// FindProtos returns the proto set proto names: a single file, every .proto
+// file under a directory, or the files matching a glob such as
+// proto/*.proto. Files import each other relative to the set's root, the
+// file's directory or the directory above the glob's first wildcard, and
+// then relative to importPaths. The well-known types, such as
+// google/protobuf/timestamp.proto, are built in, and copies of them in the
+// set are left out.
+//
+// Inside a buf module (see FindBufWorkspace), files are relative to the
+// module root instead, and import the workspace's other modules and the
+// dependencies its buf.lock pins, as they would when built with buf.
+func FindProtos(proto string, importPaths []string) (ProtoSet, error) {
+	var set ProtoSet
+	if i := strings.IndexAny(proto, "*?["); i >= 0 {
+		set.Root = filepath.Dir(proto[:i+1])
+		matches, err := filepath.Glob(proto)
+		if err != nil {
+			return ProtoSet{}, fmt.Errorf("proto glob %s: %w", proto, err)
+		}
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && !info.IsDir() {
+				rel, _ := filepath.Rel(set.Root, match)
+				set.Files = append(set.Files, filepath.ToSlash(rel))
+			}
+		}
+	} else {
+		info, err := os.Stat(proto)
+		if err != nil {
+			return ProtoSet{}, err
+		}
+		if info.IsDir() {
+			set.Root = proto
+			err := filepath.WalkDir(proto, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !d.IsDir() && strings.HasSuffix(path, ".proto") {
+					rel, _ := filepath.Rel(proto, path)
+					set.Files = append(set.Files, filepath.ToSlash(rel))
+				}
+				return nil
+			})
+			if err != nil {
+				return ProtoSet{}, err
+			}
+		} else {
+			set.Root, set.Files = filepath.Dir(proto), []string{filepath.Base(proto)}
+		}
+	}
+	slices.Sort(set.Files)
+
+	set.ImportPaths = []string{set.Root}
+	ws, err := FindBufWorkspace(set.Root)
+	if err != nil {
+		return ProtoSet{}, err
+	}
+	if module := ws.Module(set.Root); module != "" {
+		if err := set.rebase(module); err != nil {
+			return ProtoSet{}, err
+		}
+		paths, err := ws.ImportPaths()
+		if err != nil {
+			return ProtoSet{}, err
+		}
+		importPaths = append(paths, importPaths...)
+	}
+	// Vendored copies of the well-known types aren't the app's to generate,
+	// their code comes with the protobuf runtimes
+	set.Files = slices.DeleteFunc(set.Files, isWellKnownImport)
+	if len(set.Files) == 0 {
+		return ProtoSet{}, fmt.Errorf("no .proto files in %s", proto)
+	}
+	for _, path := range importPaths {
+		if path != "" && !slices.Contains(set.ImportPaths, path) {
+			set.ImportPaths = append(set.ImportPaths, path)
+		}
+	}
+	return set, nil
+}
+
+// rebase makes the set's files relative to module, a directory above its
+// root.
+func (s *ProtoSet) rebase(module string) error {
+	abs, err := filepath.Abs(s.Root)
+	if err != nil {
+		return err
+	}
+	prefix, err := filepath.Rel(module, abs)
+	if err != nil {
+		return err
+	}
+	for i, f := range s.Files {
+		s.Files[i] = path.Join(filepath.ToSlash(prefix), f)
+	}
+	slices.Sort(s.Files)
+	// A relative root stays relative to the working directory
+	relative := !filepath.IsAbs(s.Root)
+	s.Root = module
+	if wd, err := os.Getwd(); err == nil && relative {
+		if rel, err := filepath.Rel(wd, module); err == nil {
+			s.Root = rel
+		}
+	}
+	s.ImportPaths[0] = s.Root
+	return nil
+}
+
+// Paths returns the set's files joined to its root.
+func (s ProtoSet) Paths() []string {
+	paths := make([]string, len(s.Files))
+	for i, f := range s.Files {
+		paths[i] = filepath.Join(s.Root, filepath.FromSlash(f))
+	}
+	return paths
+}
+
+// Compile compiles the set into one CodeGeneratorRequest.
+func (s ProtoSet) Compile() (*pluginpb.CodeGeneratorRequest, error) {
+	return CompileProtos(s.ImportPaths, s.Files...)
+}
+
+// Hash returns the hex-encoded SHA256 hash of the set's file names and
+// contents, so adding, renaming or editing any of them changes it.
+func (s ProtoSet) Hash() (string, error) {
+	h := sha256.New()
+	for i, path := range s.Paths() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", s.Files[i], len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
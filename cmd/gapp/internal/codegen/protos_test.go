@@ -0,0 +1,197 @@
+package codegen
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func writeProtos(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// compileProto writes files into a temp dir and compiles service.proto.
+func compileProto(t *testing.T, files map[string]string) *pluginpb.CodeGeneratorRequest {
+	t.Helper()
+	dir := t.TempDir()
+	writeProtos(t, dir, files)
+	req, err := CompileProto(dir, "service.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestFindProtos(t *testing.T) {
+	dir := t.TempDir()
+	writeProtos(t, dir, map[string]string{
+		"proto/items.proto":        `syntax = "proto3";`,
+		"proto/users.proto":        `syntax = "proto3";`,
+		"proto/common/money.proto": `syntax = "proto3";`,
+		"proto/README.md":          "# API",
+	})
+	protoDir := filepath.Join(dir, "proto")
+
+	tests := []struct {
+		proto string
+		root  string
+		files []string
+	}{
+		{filepath.Join(protoDir, "items.proto"), protoDir, []string{"items.proto"}},
+		{protoDir, protoDir, []string{"common/money.proto", "items.proto", "users.proto"}},
+		{filepath.Join(protoDir, "*.proto"), protoDir, []string{"items.proto", "users.proto"}},
+		{filepath.Join(protoDir, "*", "*.proto"), protoDir, []string{"common/money.proto"}},
+	}
+	for _, tt := range tests {
+		set, err := FindProtos(tt.proto, []string{"vendor/proto"})
+		if err != nil {
+			t.Fatalf("FindProtos(%s): %v", tt.proto, err)
+		}
+		if set.Root != tt.root || !slices.Equal(set.Files, tt.files) {
+			t.Errorf("FindProtos(%s) = %s %v, want %s %v", tt.proto, set.Root, set.Files, tt.root, tt.files)
+		}
+		if !slices.Equal(set.ImportPaths, []string{tt.root, "vendor/proto"}) {
+			t.Errorf("FindProtos(%s) import paths = %v", tt.proto, set.ImportPaths)
+		}
+	}
+
+	if _, err := FindProtos(filepath.Join(protoDir, "*.txt"), nil); err == nil {
+		t.Error("Expected an error for a glob matching no files")
+	}
+	if _, err := FindProtos(filepath.Join(protoDir, "missing.proto"), nil); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestCompileProtoSet(t *testing.T) {
+	dir := t.TempDir()
+	writeProtos(t, dir, map[string]string{
+		"common.proto": `syntax = "proto3";
+package app;
+option go_package = "./generated";
+
+message Money {
+  int64 cents = 1;
+}
+`,
+		"items.proto": `syntax = "proto3";
+package app;
+option go_package = "./generated";
+
+import "common.proto";
+import "google/protobuf/timestamp.proto";
+
+message Item {
+  string id = 1;
+  Money price = 2;
+  google.protobuf.Timestamp created_at = 3;
+}
+`,
+		"users.proto": `syntax = "proto3";
+package app;
+option go_package = "./generated";
+
+import "items.proto";
+
+message User {
+  repeated Item items = 1;
+}
+`,
+	})
+
+	set, err := FindProtos(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := set.Compile()
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	if !slices.Equal(req.GetFileToGenerate(), []string{"common.proto", "items.proto", "users.proto"}) {
+		t.Errorf("FileToGenerate = %v", req.GetFileToGenerate())
+	}
+
+	// Every file comes after its imports, and only once
+	position := make(map[string]int)
+	for i, file := range req.GetProtoFile() {
+		if _, ok := position[file.GetName()]; ok {
+			t.Errorf("%s is in the request twice", file.GetName())
+		}
+		position[file.GetName()] = i
+	}
+	for _, file := range req.GetProtoFile() {
+		for _, dep := range file.GetDependency() {
+			if p, ok := position[dep]; !ok || p > position[file.GetName()] {
+				t.Errorf("%s should come before %s", dep, file.GetName())
+			}
+		}
+	}
+
+	hash, err := set.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeProtos(t, dir, map[string]string{"users.proto": "syntax = \"proto3\";\npackage app;\n"})
+	if newHash, _ := set.Hash(); newHash == hash {
+		t.Error("Editing any file of the set should change its hash")
+	}
+}
+
+func TestCompileWellKnownTypes(t *testing.T) {
+	dir := t.TempDir()
+	writeProtos(t, dir, map[string]string{
+		"proto/service.proto": `syntax = "proto3";
+package app;
+
+import "google/protobuf/duration.proto";
+import "google/protobuf/struct.proto";
+import "google/protobuf/timestamp.proto";
+
+message Event {
+  google.protobuf.Timestamp at = 1;
+  google.protobuf.Duration length = 2;
+  google.protobuf.Struct details = 3;
+}
+`,
+		// Copies vendored into the set and an import path, e.g. with googleapis
+		"proto/google/protobuf/timestamp.proto":      "syntax = \"proto3\";\npackage google.protobuf;\nmessage Timestamp { string value = 1; }\n",
+		"third_party/google/protobuf/duration.proto": "syntax = \"proto3\";\npackage google.protobuf;\nmessage Duration { string value = 1; }\n",
+	})
+
+	set, err := FindProtos(filepath.Join(dir, "proto"), []string{filepath.Join(dir, "third_party")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(set.Files, []string{"service.proto"}) {
+		t.Errorf("Files = %v, want the vendored well-known types left out", set.Files)
+	}
+	req, err := set.Compile()
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	packages := make(map[string]string)
+	for _, file := range req.GetProtoFile() {
+		packages[file.GetName()] = file.GetOptions().GetGoPackage()
+	}
+	for name, want := range map[string]string{
+		"google/protobuf/timestamp.proto": "google.golang.org/protobuf/types/known/timestamppb",
+		"google/protobuf/duration.proto":  "google.golang.org/protobuf/types/known/durationpb",
+		"google/protobuf/struct.proto":    "google.golang.org/protobuf/types/known/structpb",
+	} {
+		if packages[name] != want {
+			t.Errorf("%s has go_package %q, want the built-in %q", name, packages[name], want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package codegen
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+)
+
+// GenerateRoutesTS generates the client's route manifest: every route of
+// routes, in the order GeneratePreloadGo lists them, with its path, file,
+// the RPCs it preloads and a load function importing its module on demand.
+// Apps build their router from it rather than a registry of their own, which
+// could drift from the preload config:
+//
+//	import { routes } from "./generated/gapp_routes";
+//	const router = new Router(await Promise.all(routes.map((route) => route.load())));
+//
+// routesModule is the routes directory as imported from the manifest, e.g.
+// ../routes. The manifest also exports RoutePath, the union of the paths.
+func GenerateRoutesTS(routes []RoutePreload, routesModule string) string {
+	routes = slices.Clone(routes)
+	slices.SortStableFunc(routes, compareRoutes)
+
+	var b strings.Builder
+	b.WriteString("// Code generated by gapp codegen. DO NOT EDIT.\n\n")
+	b.WriteString("import type { RpcDeclaration } from \"@gapp/client\";\n\n")
+	b.WriteString("export const routes = [\n")
+	for _, route := range routes {
+		module := path.Join(routesModule, strings.TrimSuffix(strings.TrimSuffix(route.File, ".tsx"), ".ts"))
+		if !strings.HasPrefix(module, ".") {
+			module = "./" + module
+		}
+		load := fmt.Sprintf("import(%q)", module)
+		if route.Export != "" {
+			load += fmt.Sprintf(".then((m) => m.%s)", route.Export)
+		}
+
+		b.WriteString("  {\n")
+		fmt.Fprintf(&b, "    path: %q,\n", route.Path)
+		fmt.Fprintf(&b, "    file: %q,\n", route.File)
+		b.WriteString("    rpcs: [")
+		for i, rpc := range route.Rpcs {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "{ method: %q", rpc.Method)
+			if len(rpc.Params) > 0 {
+				b.WriteString(", params: { ")
+				for j, key := range slices.Sorted(maps.Keys(rpc.Params)) {
+					if j > 0 {
+						b.WriteString(", ")
+					}
+					fmt.Fprintf(&b, "%q: %q", key, rpc.Params[key])
+				}
+				b.WriteString(" }")
+			}
+			b.WriteString(" }")
+		}
+		b.WriteString("] as RpcDeclaration[],\n")
+		fmt.Fprintf(&b, "    load: () => %s,\n", load)
+		b.WriteString("  },\n")
+	}
+	b.WriteString("] as const;\n\n")
+	b.WriteString("export type RoutePath = (typeof routes)[number][\"path\"];\n")
+	return b.String()
+}
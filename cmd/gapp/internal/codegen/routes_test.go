@@ -0,0 +1,92 @@
+package codegen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateRoutesTS(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"HomeRoute.tsx": `export const homeRoute = {
+  path: "/",
+  factory: () => ({
+    rpcs: [{ method: "GetItems" }] as RpcDeclaration[],
+  }),
+};
+`,
+		"users/UserRoute.tsx": `const PAGE_SIZE = 20;
+
+export const userRoute: Route<"/users/:id", Metadata> = {
+  path: "/users/:id",
+  factory: () => ({
+    rpcs: [
+      { method: "GetUser", params: { "user_id": ":id", "page_size": "20" } },
+      { method: "GetUserPosts" },
+    ] as RpcDeclaration[],
+  }),
+};
+`,
+		// A route preloading nothing still belongs in the manifest
+		"AboutRoute.ts": `export const aboutRoute = {
+  path: "/about",
+  factory: () => ({ component: About }),
+};
+`,
+		"utils.ts": `export const formatDate = (d: Date) => d.toString();`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	routes, err := ScanRoutes(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 3 {
+		t.Fatalf("len(routes) = %d, want 3", len(routes))
+	}
+	src := GenerateRoutesTS(routes, "../routes")
+
+	want := `// Code generated by gapp codegen. DO NOT EDIT.
+
+import type { RpcDeclaration } from "@gapp/client";
+
+export const routes = [
+  {
+    path: "/",
+    file: "HomeRoute.tsx",
+    rpcs: [{ method: "GetItems" }] as RpcDeclaration[],
+    load: () => import("../routes/HomeRoute").then((m) => m.homeRoute),
+  },
+  {
+    path: "/about",
+    file: "AboutRoute.ts",
+    rpcs: [] as RpcDeclaration[],
+    load: () => import("../routes/AboutRoute").then((m) => m.aboutRoute),
+  },
+  {
+    path: "/users/:id",
+    file: "users/UserRoute.tsx",
+    rpcs: [{ method: "GetUser", params: { "page_size": "20", "user_id": ":id" } }, { method: "GetUserPosts" }] as RpcDeclaration[],
+    load: () => import("../routes/users/UserRoute").then((m) => m.userRoute),
+  },
+] as const;
+
+export type RoutePath = (typeof routes)[number]["path"];
+`
+	if src != want {
+		t.Errorf("GenerateRoutesTS =\n%s\nwant\n%s", src, want)
+	}
+
+	// Routes in the manifest's own directory are imported relative to it
+	if src := GenerateRoutesTS(routes[:1], "routes"); !strings.Contains(src, `import("./routes/AboutRoute")`) {
+		t.Errorf("GenerateRoutesTS with a nested routes module:\n%s", src)
+	}
+}
@@ -0,0 +1,25 @@
+package codegen
+
+import "fmt"
+
+// GenerateSchemaGo generates Go source exposing the proto schema hash, so the
+// server can embed it in rendered HTML for client-side mismatch detection.
+func GenerateSchemaGo(hash, packageName string) string {
+	return fmt.Sprintf(`// Code generated by gapp codegen. DO NOT EDIT.
+
+package %s
+
+// SchemaHash identifies the proto schema this package was generated from.
+const SchemaHash = %q
+`, packageName, hash)
+}
+
+// GenerateSchemaTS generates TypeScript source exposing the proto schema hash,
+// compared at startup against the hash embedded by the server.
+func GenerateSchemaTS(hash string) string {
+	return fmt.Sprintf(`// Code generated by gapp codegen. DO NOT EDIT.
+
+// SCHEMA_HASH identifies the proto schema this bundle was generated from.
+export const SCHEMA_HASH = %q;
+`, hash)
+}
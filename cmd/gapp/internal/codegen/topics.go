@@ -0,0 +1,110 @@
+package codegen
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// TopicSpec is a hub topic declared on a proto message with a leading
+// comment such as:
+//
+//	// gapp:topic orders/:orderId
+//	message OrderUpdated { ... }
+type TopicSpec struct {
+	Message string   // proto message name
+	Pattern string   // topic pattern with :params
+	Params  []string // param names in order of appearance
+	File    string   // proto file declaring the message, e.g. service.proto
+}
+
+var (
+	topicRe      = regexp.MustCompile(`(?m)^\s*gapp:topic\s+(\S+)\s*$`)
+	topicParamRe = regexp.MustCompile(`:([A-Za-z_][A-Za-z0-9_]*)`)
+)
+
+// messageTypeField is the field number of message_type in FileDescriptorProto,
+// used to find message locations in the source code info.
+const messageTypeField = 4
+
+// ScanTopics collects the topics declared on the top-level messages of the
+// files being generated.
+func ScanTopics(req *pluginpb.CodeGeneratorRequest) []TopicSpec {
+	generate := make(map[string]bool)
+	for _, name := range req.GetFileToGenerate() {
+		generate[name] = true
+	}
+
+	var topics []TopicSpec
+	for _, file := range req.GetProtoFile() {
+		if !generate[file.GetName()] {
+			continue
+		}
+		for _, loc := range file.GetSourceCodeInfo().GetLocation() {
+			path := loc.GetPath()
+			if len(path) != 2 || path[0] != messageTypeField {
+				continue
+			}
+			m := topicRe.FindStringSubmatch(loc.GetLeadingComments())
+			if m == nil {
+				continue
+			}
+			topic := TopicSpec{Message: file.GetMessageType()[path[1]].GetName(), Pattern: m[1], File: file.GetName()}
+			for _, p := range topicParamRe.FindAllStringSubmatch(m[1], -1) {
+				topic.Params = append(topic.Params, p[1])
+			}
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
+
+// GenerateTopicsTS generates TypeScript subscription helpers for the topics,
+// importing their messages from the modules ts-proto generates next to it,
+// e.g. "./service" for service.proto.
+func GenerateTopicsTS(topics []TopicSpec) string {
+	var b strings.Builder
+	b.WriteString("// Code generated by gapp codegen. DO NOT EDIT.\n\n")
+	b.WriteString("import type { HubClient } from \"@gapp/client\";\n")
+
+	var files []string
+	messages := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, t := range topics {
+		if seen[t.File+"."+t.Message] {
+			continue
+		}
+		seen[t.File+"."+t.Message] = true
+		if messages[t.File] == nil {
+			files = append(files, t.File)
+		}
+		messages[t.File] = append(messages[t.File], t.Message)
+	}
+	for _, file := range files {
+		importPath := "./" + strings.TrimSuffix(file, ".proto")
+		fmt.Fprintf(&b, "import { %s } from %q;\n", strings.Join(messages[file], ", "), importPath)
+	}
+
+	for _, t := range topics {
+		topic := topicParamRe.ReplaceAllString(strings.ReplaceAll(t.Pattern, "`", "\\`"), "$${params.$1}")
+
+		b.WriteString("\n")
+		fmt.Fprintf(&b, "// Subscribes to %q.\n", t.Pattern)
+		fmt.Fprintf(&b, "export function subscribe%s(\n", t.Message)
+		b.WriteString("  hub: HubClient,\n")
+		if len(t.Params) > 0 {
+			fields := make([]string, len(t.Params))
+			for i, p := range t.Params {
+				fields[i] = p + ": string"
+			}
+			fmt.Fprintf(&b, "  params: { %s },\n", strings.Join(fields, "; "))
+		}
+		fmt.Fprintf(&b, "  onMessage: (message: %s) => void\n", t.Message)
+		b.WriteString("): () => void {\n")
+		fmt.Fprintf(&b, "  return hub.subscribe(`%s`, (bytes) => %s.decode(bytes), onMessage);\n", topic, t.Message)
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,56 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScanTopics(t *testing.T) {
+	proto := `syntax = "proto3";
+
+package app;
+
+// gapp:topic orders/:orderId
+message OrderUpdated {
+  string order_id = 1;
+}
+
+// Plain message without a topic.
+message Item {
+  string id = 1;
+}
+
+// Broadcast to everyone.
+// gapp:topic announcements
+message Announcement {
+  string text = 1;
+}
+`
+	req := compileProto(t, map[string]string{"service.proto": proto})
+
+	topics := ScanTopics(req)
+	if len(topics) != 2 {
+		t.Fatalf("len(topics) = %d, want 2", len(topics))
+	}
+	if topics[0].Message != "OrderUpdated" || topics[0].Pattern != "orders/:orderId" {
+		t.Errorf("topics[0] = %+v", topics[0])
+	}
+	if len(topics[0].Params) != 1 || topics[0].Params[0] != "orderId" {
+		t.Errorf("topics[0].Params = %v, want [orderId]", topics[0].Params)
+	}
+	if topics[1].Message != "Announcement" || len(topics[1].Params) != 0 {
+		t.Errorf("topics[1] = %+v", topics[1])
+	}
+
+	ts := GenerateTopicsTS(topics)
+	for _, want := range []string{
+		`import { OrderUpdated, Announcement } from "./service";`,
+		"params: { orderId: string },",
+		"hub.subscribe(`orders/${params.orderId}`, (bytes) => OrderUpdated.decode(bytes), onMessage);",
+		"export function subscribeAnnouncement(\n  hub: HubClient,\n  onMessage: (message: Announcement) => void\n)",
+	} {
+		if !strings.Contains(ts, want) {
+			t.Errorf("generated TS missing %q:\n%s", want, ts)
+		}
+	}
+}
@@ -0,0 +1,471 @@
+package codegen
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// validateExtension is the protovalidate option holding a field's rules,
+// e.g. [(buf.validate.field).string.min_len = 1].
+const validateExtension = "buf.validate.field"
+
+// stringFormats are the well-known string formats protovalidate checks that
+// zod has checks for.
+var stringFormats = []struct{ rule, check string }{
+	{"email", ".email()"},
+	{"uri", ".url()"},
+	{"uuid", ".uuid()"},
+	{"ip", ".ip()"},
+	{"ipv4", `.ip({ version: "v4" })`},
+	{"ipv6", `.ip({ version: "v6" })`},
+}
+
+// regexFlagsRe matches the flags RE2 patterns set up front, which
+// JavaScript takes as the flags of the RegExp instead.
+var regexFlagsRe = regexp.MustCompile(`^\(\?([ims]+)\)`)
+
+// GenerateZodTS generates a zod schema per message of the files being
+// generated, e.g. GetItemsRequestSchema, shaped like the interfaces ts-proto
+// generates with its default options. Fields declaring protovalidate rules
+// get the zod checks that match them, so a form can be validated as the
+// server will before it's sent; CEL expressions have no zod counterpart and
+// are left to the server. Schemas import enums from the ts-proto modules
+// next to them. It returns "" when there are no messages.
+func GenerateZodTS(req *pluginpb.CodeGeneratorRequest) (string, error) {
+	registry, err := compiledFiles(req)
+	if err != nil {
+		return "", err
+	}
+	files, err := generatedFiles(req)
+	if err != nil {
+		return "", err
+	}
+	g := &zodGen{
+		types:    dynamicpb.NewTypes(registry),
+		generate: make(map[protoreflect.FullName]bool),
+		imports:  make(map[string][]string),
+	}
+	if ext, err := g.types.FindExtensionByName(validateExtension); err == nil {
+		g.rules = ext
+	}
+	var messages []protoreflect.MessageDescriptor
+	var add func(msgs protoreflect.MessageDescriptors)
+	add = func(msgs protoreflect.MessageDescriptors) {
+		for i := 0; i < msgs.Len(); i++ {
+			msg := msgs.Get(i)
+			if msg.IsMapEntry() {
+				continue
+			}
+			messages = append(messages, msg)
+			g.generate[msg.FullName()] = true
+			add(msg.Messages())
+		}
+	}
+	for _, file := range files {
+		add(file.Messages())
+	}
+	if len(messages) == 0 {
+		return "", nil
+	}
+
+	var body strings.Builder
+	recursive := recursiveMessages(messages)
+	for _, msg := range messages {
+		name := tsTypeName(msg)
+		body.WriteString("\n")
+		if comment := docComment(msg); comment != "" {
+			for _, line := range strings.Split(comment, "\n") {
+				fmt.Fprintf(&body, "// %s\n", line)
+			}
+		}
+		// A schema referring to itself needs its type spelled out
+		if recursive[msg.FullName()] {
+			module := tsModule(msg)
+			g.use(module, "type "+name)
+			g.use(module, "type DeepPartial")
+			fmt.Fprintf(&body, "export const %sSchema: z.ZodType<DeepPartial<%s>> = z.object({\n", name, name)
+		} else {
+			fmt.Fprintf(&body, "export const %sSchema = z.object({\n", name)
+		}
+		for i := 0; i < msg.Fields().Len(); i++ {
+			field := msg.Fields().Get(i)
+			fmt.Fprintf(&body, "  %s: %s,\n", lowerCamelCase(upperCamelCase(string(field.Name()))), g.field(field))
+		}
+		body.WriteString("});\n")
+	}
+
+	var b strings.Builder
+	b.WriteString("// Code generated by gapp codegen. DO NOT EDIT.\n\n")
+	b.WriteString("import { z } from \"zod\";\n")
+	for _, module := range g.modules {
+		fmt.Fprintf(&b, "import { %s } from %q;\n", strings.Join(g.imports[module], ", "), module)
+	}
+	b.WriteString(body.String())
+	return b.String(), nil
+}
+
+type zodGen struct {
+	types    *dynamicpb.Types
+	rules    protoreflect.ExtensionType // nil when the protos don't import protovalidate
+	generate map[protoreflect.FullName]bool
+	modules  []string // the ts-proto modules enums and types are imported from
+	imports  map[string][]string
+}
+
+// use imports name, e.g. "Status" or "type Item", from module.
+func (g *zodGen) use(module, name string) {
+	if g.imports[module] == nil {
+		g.modules = append(g.modules, module)
+	}
+	for _, imported := range g.imports[module] {
+		if imported == name {
+			return
+		}
+	}
+	g.imports[module] = append(g.imports[module], name)
+}
+
+// fieldRules returns the protovalidate rules of field, or nil without any.
+func (g *zodGen) fieldRules(field protoreflect.FieldDescriptor) protoreflect.Message {
+	if g.rules == nil {
+		return nil
+	}
+	options, _ := field.Options().(*descriptorpb.FieldOptions)
+	if options == nil {
+		return nil
+	}
+	// Reparse the options with the protos' own types, so the rules are
+	// readable without protovalidate's generated Go code
+	data, err := proto.Marshal(options)
+	if err != nil {
+		return nil
+	}
+	parsed := &descriptorpb.FieldOptions{}
+	if err := (proto.UnmarshalOptions{Resolver: g.types}).Unmarshal(data, parsed); err != nil {
+		return nil
+	}
+	desc := g.rules.TypeDescriptor()
+	if !parsed.ProtoReflect().Has(desc) {
+		return nil
+	}
+	return parsed.ProtoReflect().Get(desc).Message()
+}
+
+// field returns the schema of field, checked against its rules.
+func (g *zodGen) field(field protoreflect.FieldDescriptor) string {
+	rules := g.fieldRules(field)
+	required := false
+	if v, ok := ruleValue(rules, "required"); ok {
+		required = v.Bool()
+	}
+	var schema string
+	switch {
+	case field.IsMap():
+		schema = fmt.Sprintf("z.record(z.string(), %s)", g.value(field.MapValue(), subRules(subRules(rules, "map"), "values"), false))
+		mapRules := subRules(rules, "map")
+		if v, ok := ruleValue(mapRules, "min_pairs"); ok {
+			schema += fmt.Sprintf(".refine((v) => Object.keys(v).length >= %d, \"must have at least %d entries\")", v.Uint(), v.Uint())
+		} else if required {
+			schema += ".refine((v) => Object.keys(v).length > 0, \"is required\")"
+		}
+		if v, ok := ruleValue(mapRules, "max_pairs"); ok {
+			schema += fmt.Sprintf(".refine((v) => Object.keys(v).length <= %d, \"must have at most %d entries\")", v.Uint(), v.Uint())
+		}
+	case field.IsList():
+		schema = fmt.Sprintf("z.array(%s)", g.value(field, subRules(subRules(rules, "repeated"), "items"), false))
+		listRules := subRules(rules, "repeated")
+		if v, ok := ruleValue(listRules, "min_items"); ok {
+			schema += fmt.Sprintf(".min(%d)", v.Uint())
+		} else if required {
+			schema += ".min(1)"
+		}
+		if v, ok := ruleValue(listRules, "max_items"); ok {
+			schema += fmt.Sprintf(".max(%d)", v.Uint())
+		}
+		if v, ok := ruleValue(listRules, "unique"); ok && v.Bool() {
+			schema += ".refine((v) => new Set(v).size === v.length, \"items must be unique\")"
+		}
+	default:
+		// Fields with presence are set or not, the rest must be non-zero
+		// when required
+		schema = g.value(field, rules, required && !field.HasPresence())
+	}
+	if field.HasPresence() && !required {
+		schema += ".optional()"
+	}
+	return schema
+}
+
+// value returns the schema of a single value of field, checked against the
+// rules for its type. nonZero adds a check that it isn't the zero value.
+func (g *zodGen) value(field protoreflect.FieldDescriptor, rules protoreflect.Message, nonZero bool) string {
+	typed := subRules(rules, field.Kind().String())
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		schema := "z.boolean()"
+		if v, ok := ruleValue(typed, "const"); ok {
+			schema += fmt.Sprintf(".refine((v) => v === %t, \"must be %t\")", v.Bool(), v.Bool())
+		} else if nonZero {
+			schema += ".refine((v) => v, \"is required\")"
+		}
+		return schema
+	case protoreflect.StringKind:
+		return "z.string()" + stringChecks(typed, nonZero)
+	case protoreflect.BytesKind:
+		return "z.instanceof(Uint8Array)" + bytesChecks(typed, nonZero)
+	case protoreflect.EnumKind:
+		if field.Enum().FullName() == "google.protobuf.NullValue" {
+			return "z.null()"
+		}
+		name := tsTypeName(field.Enum())
+		g.use(tsModule(field.Enum()), name)
+		return fmt.Sprintf("z.nativeEnum(%s)", name) + numberChecks(typed, nonZero)
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return g.message(field.Message())
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return "z.number()" + numberChecks(typed, nonZero)
+	}
+	// ts-proto represents 64-bit integers as numbers too by default
+	return "z.number().int()" + numberChecks(typed, nonZero)
+}
+
+// message returns the schema of a message field's value: the message's own
+// schema when it's generated, or the shape ts-proto gives well-known types.
+func (g *zodGen) message(msg protoreflect.MessageDescriptor) string {
+	if g.generate[msg.FullName()] {
+		// Lazily, since the message's schema may be declared further down
+		return fmt.Sprintf("z.lazy(() => %sSchema)", tsTypeName(msg))
+	}
+	switch msg.FullName() {
+	case "google.protobuf.Timestamp":
+		return "z.date()"
+	case "google.protobuf.Duration":
+		return "z.object({ seconds: z.number().int(), nanos: z.number().int() })"
+	case "google.protobuf.Empty":
+		return "z.object({})"
+	case "google.protobuf.Struct":
+		return "z.record(z.string(), z.unknown())"
+	case "google.protobuf.ListValue":
+		return "z.array(z.unknown())"
+	case "google.protobuf.Value":
+		return "z.unknown()"
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue", "google.protobuf.Int64Value",
+		"google.protobuf.UInt64Value", "google.protobuf.Int32Value", "google.protobuf.UInt32Value",
+		"google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		// Wrappers are their value, or undefined
+		return g.value(msg.Fields().ByName("value"), nil, false)
+	}
+	return "z.unknown()"
+}
+
+// stringChecks returns the zod checks of protovalidate's string rules.
+func stringChecks(rules protoreflect.Message, nonZero bool) string {
+	var b strings.Builder
+	if v, ok := ruleValue(rules, "const"); ok {
+		fmt.Fprintf(&b, ".refine((v) => v === %q, %q)", v.String(), fmt.Sprintf("must be %q", v.String()))
+	}
+	if v, ok := ruleValue(rules, "len"); ok {
+		fmt.Fprintf(&b, ".length(%d)", v.Uint())
+	}
+	if v, ok := ruleValue(rules, "min_len"); ok {
+		fmt.Fprintf(&b, ".min(%d)", v.Uint())
+	} else if nonZero {
+		b.WriteString(".min(1)")
+	}
+	if v, ok := ruleValue(rules, "max_len"); ok {
+		fmt.Fprintf(&b, ".max(%d)", v.Uint())
+	}
+	if v, ok := ruleValue(rules, "pattern"); ok {
+		pattern, flags := v.String(), ""
+		if m := regexFlagsRe.FindStringSubmatch(pattern); m != nil {
+			pattern, flags = pattern[len(m[0]):], m[1]
+		}
+		fmt.Fprintf(&b, ".regex(new RegExp(%q, %q))", pattern, flags)
+	}
+	if v, ok := ruleValue(rules, "prefix"); ok {
+		fmt.Fprintf(&b, ".startsWith(%q)", v.String())
+	}
+	if v, ok := ruleValue(rules, "suffix"); ok {
+		fmt.Fprintf(&b, ".endsWith(%q)", v.String())
+	}
+	if v, ok := ruleValue(rules, "contains"); ok {
+		fmt.Fprintf(&b, ".includes(%q)", v.String())
+	}
+	if v, ok := ruleValue(rules, "not_contains"); ok {
+		fmt.Fprintf(&b, ".refine((v) => !v.includes(%q), %q)", v.String(), fmt.Sprintf("must not contain %q", v.String()))
+	}
+	for _, format := range stringFormats {
+		if v, ok := ruleValue(rules, format.rule); ok && v.Bool() {
+			b.WriteString(format.check)
+		}
+	}
+	b.WriteString(inChecks(rules))
+	return b.String()
+}
+
+// bytesChecks returns the zod checks of protovalidate's length rules for
+// bytes.
+func bytesChecks(rules protoreflect.Message, nonZero bool) string {
+	var b strings.Builder
+	if v, ok := ruleValue(rules, "len"); ok {
+		fmt.Fprintf(&b, ".refine((v) => v.length === %d, \"must be %d bytes\")", v.Uint(), v.Uint())
+	}
+	if v, ok := ruleValue(rules, "min_len"); ok {
+		fmt.Fprintf(&b, ".refine((v) => v.length >= %d, \"must be at least %d bytes\")", v.Uint(), v.Uint())
+	} else if nonZero {
+		b.WriteString(".refine((v) => v.length > 0, \"is required\")")
+	}
+	if v, ok := ruleValue(rules, "max_len"); ok {
+		fmt.Fprintf(&b, ".refine((v) => v.length <= %d, \"must be at most %d bytes\")", v.Uint(), v.Uint())
+	}
+	return b.String()
+}
+
+// numberChecks returns the zod checks of protovalidate's rules for numbers
+// and enums.
+func numberChecks(rules protoreflect.Message, nonZero bool) string {
+	var b strings.Builder
+	if v, ok := ruleValue(rules, "const"); ok {
+		fmt.Fprintf(&b, ".refine((v) => v === %v, \"must be %v\")", v.Interface(), v.Interface())
+	} else if nonZero {
+		b.WriteString(".refine((v) => v !== 0, \"is required\")")
+	}
+	lower, lowerOp := ruleValue(rules, "gt")
+	lowerCheck := "gt"
+	if v, ok := ruleValue(rules, "gte"); ok {
+		lower, lowerOp, lowerCheck = v, true, "gte"
+	}
+	upper, upperOp := ruleValue(rules, "lt")
+	upperCheck := "lt"
+	if v, ok := ruleValue(rules, "lte"); ok {
+		upper, upperOp, upperCheck = v, true, "lte"
+	}
+	// A lower bound above the upper one excludes the range between them
+	if lowerOp && upperOp && greater(lower, upper) {
+		ops := map[string]string{"gt": ">", "gte": ">=", "lt": "<", "lte": "<="}
+		fmt.Fprintf(&b, ".refine((v) => v %s %v || v %s %v, \"is out of range\")",
+			ops[lowerCheck], lower.Interface(), ops[upperCheck], upper.Interface())
+	} else {
+		if lowerOp {
+			fmt.Fprintf(&b, ".%s(%v)", lowerCheck, lower.Interface())
+		}
+		if upperOp {
+			fmt.Fprintf(&b, ".%s(%v)", upperCheck, upper.Interface())
+		}
+	}
+	b.WriteString(inChecks(rules))
+	return b.String()
+}
+
+// inChecks returns the zod checks of the in and not_in rules.
+func inChecks(rules protoreflect.Message) string {
+	var b strings.Builder
+	for _, rule := range []string{"in", "not_in"} {
+		v, ok := ruleValue(rules, rule)
+		if !ok {
+			continue
+		}
+		list := v.List()
+		values := make([]string, list.Len())
+		for i := range values {
+			values[i] = fmt.Sprintf("%#v", list.Get(i).Interface())
+		}
+		in := "[" + strings.Join(values, ", ") + "]"
+		if rule == "in" {
+			fmt.Fprintf(&b, ".refine((v) => %s.includes(v), \"must be one of %s\")", in, strings.ReplaceAll(in, `"`, `'`))
+		} else {
+			fmt.Fprintf(&b, ".refine((v) => !%s.includes(v), \"must not be one of %s\")", in, strings.ReplaceAll(in, `"`, `'`))
+		}
+	}
+	return b.String()
+}
+
+// greater reports whether a number rule value a is greater than b.
+func greater(a, b protoreflect.Value) bool {
+	switch x := a.Interface().(type) {
+	case int32:
+		return int64(x) > int64(b.Interface().(int32))
+	case int64:
+		return x > b.Int()
+	case uint32:
+		return uint64(x) > uint64(b.Interface().(uint32))
+	case uint64:
+		return x > b.Uint()
+	case float32:
+		return float64(x) > float64(b.Interface().(float32))
+	case float64:
+		return x > b.Float()
+	case protoreflect.EnumNumber:
+		return x > b.Enum()
+	}
+	return false
+}
+
+// ruleValue returns the named field of a protovalidate rules message, if
+// it's set.
+func ruleValue(rules protoreflect.Message, name string) (protoreflect.Value, bool) {
+	if rules == nil {
+		return protoreflect.Value{}, false
+	}
+	field := rules.Descriptor().Fields().ByName(protoreflect.Name(name))
+	if field == nil || !rules.Has(field) {
+		return protoreflect.Value{}, false
+	}
+	return rules.Get(field), true
+}
+
+// subRules returns the named rules message within rules, such as the string
+// rules of a field's, or nil if it's not set.
+func subRules(rules protoreflect.Message, name string) protoreflect.Message {
+	v, ok := ruleValue(rules, name)
+	if !ok {
+		return nil
+	}
+	if _, isMessage := v.Interface().(protoreflect.Message); !isMessage {
+		return nil
+	}
+	return v.Message()
+}
+
+// recursiveMessages returns the messages whose fields lead back to them.
+func recursiveMessages(messages []protoreflect.MessageDescriptor) map[protoreflect.FullName]bool {
+	recursive := make(map[protoreflect.FullName]bool)
+	for _, msg := range messages {
+		seen := make(map[protoreflect.FullName]bool)
+		var reaches func(from protoreflect.MessageDescriptor) bool
+		reaches = func(from protoreflect.MessageDescriptor) bool {
+			fields := from.Fields()
+			for i := 0; i < fields.Len(); i++ {
+				next := fields.Get(i).Message()
+				if next == nil {
+					continue
+				}
+				if next.FullName() == msg.FullName() {
+					return true
+				}
+				if !seen[next.FullName()] {
+					seen[next.FullName()] = true
+					if reaches(next) {
+						return true
+					}
+				}
+			}
+			return false
+		}
+		recursive[msg.FullName()] = reaches(msg)
+	}
+	return recursive
+}
+
+// tsModule returns the ts-proto module declaring d, e.g. "./service" for
+// the messages of service.proto.
+func tsModule(d protoreflect.Descriptor) string {
+	return "./" + strings.TrimSuffix(d.ParentFile().Path(), ".proto")
+}
@@ -0,0 +1,164 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+)
+
+// validateProto declares the part of protovalidate's buf/validate/validate.proto
+// the tests use, with the same names and field numbers.
+const validateProto = `syntax = "proto2";
+
+package buf.validate;
+
+import "google/protobuf/descriptor.proto";
+
+extend google.protobuf.FieldOptions {
+  optional FieldRules field = 1159;
+}
+
+message FieldRules {
+  optional bool required = 25;
+  oneof type {
+    Int32Rules int32 = 3;
+    StringRules string = 14;
+    EnumRules enum = 16;
+    RepeatedRules repeated = 18;
+  }
+}
+
+message Int32Rules {
+  optional int32 const = 1;
+  oneof less_than {
+    int32 lt = 2;
+    int32 lte = 3;
+  }
+  oneof greater_than {
+    int32 gt = 4;
+    int32 gte = 5;
+  }
+  repeated int32 in = 6;
+}
+
+message StringRules {
+  optional uint64 min_len = 2;
+  optional uint64 max_len = 3;
+  optional string pattern = 6;
+  repeated string in = 10;
+  oneof well_known {
+    bool email = 12;
+  }
+}
+
+message EnumRules {
+  optional bool defined_only = 2;
+  repeated int32 not_in = 4;
+}
+
+message RepeatedRules {
+  optional uint64 min_items = 1;
+  optional uint64 max_items = 2;
+  optional bool unique = 3;
+  optional FieldRules items = 4;
+}
+`
+
+func compileZodProto(t *testing.T, proto string) *zodResult {
+	t.Helper()
+	req := compileProto(t, map[string]string{
+		"buf/validate/validate.proto": validateProto,
+		"service.proto":               proto,
+	})
+	src, err := GenerateZodTS(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &zodResult{t, src}
+}
+
+type zodResult struct {
+	t   *testing.T
+	src string
+}
+
+func (r *zodResult) contains(want ...string) {
+	r.t.Helper()
+	for _, w := range want {
+		if !strings.Contains(r.src, w) {
+			r.t.Errorf("zod schemas missing %q:\n%s", w, r.src)
+		}
+	}
+}
+
+func TestGenerateZodTS(t *testing.T) {
+	r := compileZodProto(t, `syntax = "proto3";
+
+package shop;
+
+import "buf/validate/validate.proto";
+import "google/protobuf/timestamp.proto";
+import "google/protobuf/wrappers.proto";
+
+enum Status {
+  STATUS_UNSPECIFIED = 0;
+  STATUS_ACTIVE = 1;
+}
+
+// An item for sale.
+message Item {
+  string display_name = 1 [(buf.validate.field).string = {min_len: 1, max_len: 80}];
+  string email = 2 [(buf.validate.field).string.email = true, (buf.validate.field).string.pattern = "(?i)^[a-z]+@"];
+  int32 quantity = 3 [(buf.validate.field).int32 = {gte: 1, lte: 100}];
+  repeated string tags = 4 [(buf.validate.field).repeated = {max_items: 5, unique: true, items: {string: {max_len: 20}}}];
+  Status status = 5 [(buf.validate.field).enum.not_in = 0];
+  optional string note = 6;
+  Dimensions dimensions = 7 [(buf.validate.field).required = true];
+  google.protobuf.Timestamp created_at = 8;
+  google.protobuf.StringValue sku = 9;
+  map<string, int32> stock = 10;
+  string size = 11 [(buf.validate.field).string.in = "S", (buf.validate.field).string.in = "M"];
+  string title = 12 [(buf.validate.field).required = true];
+  int32 discount = 13 [(buf.validate.field).int32 = {lt: 0, gt: 10}];
+
+  message Dimensions {
+    int32 width = 1;
+  }
+}
+`)
+	r.contains(
+		`import { z } from "zod";`,
+		`import { Status } from "./service";`,
+		"// An item for sale.\nexport const ItemSchema = z.object({",
+		"  displayName: z.string().min(1).max(80),",
+		`  email: z.string().regex(new RegExp("^[a-z]+@", "i")).email(),`,
+		"  quantity: z.number().int().gte(1).lte(100),",
+		`  tags: z.array(z.string().max(20)).max(5).refine((v) => new Set(v).size === v.length, "items must be unique"),`,
+		`  status: z.nativeEnum(Status).refine((v) => ![0].includes(v), "must not be one of [0]"),`,
+		"  note: z.string().optional(),",
+		"  dimensions: z.lazy(() => Item_DimensionsSchema),",
+		"  createdAt: z.date().optional(),",
+		"  sku: z.string().optional(),",
+		"  stock: z.record(z.string(), z.number().int()),",
+		`  size: z.string().refine((v) => ["S", "M"].includes(v), "must be one of ['S', 'M']"),`,
+		"  title: z.string().min(1),",
+		`  discount: z.number().int().refine((v) => v > 10 || v < 0, "is out of range"),`,
+		"export const Item_DimensionsSchema = z.object({\n  width: z.number().int(),\n});",
+	)
+}
+
+func TestGenerateZodTSRecursive(t *testing.T) {
+	r := compileZodProto(t, `syntax = "proto3";
+
+package tree;
+
+message Node {
+  string name = 1;
+  repeated Node children = 2;
+}
+`)
+	r.contains(
+		`import { type Node, type DeepPartial } from "./service";`,
+		"export const NodeSchema: z.ZodType<DeepPartial<Node>> = z.object({",
+		"  children: z.array(z.lazy(() => NodeSchema)),",
+	)
+}
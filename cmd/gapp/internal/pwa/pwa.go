@@ -0,0 +1,189 @@
+// Package pwa generates the service worker and web app manifest written by
+// `gapp build --pwa`.
+package pwa
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// manifestChunk is the subset of a Vite manifest entry needed for precaching.
+type manifestChunk struct {
+	File   string   `json:"file"`
+	CSS    []string `json:"css"`
+	Assets []string `json:"assets"`
+}
+
+// PrecacheList returns the sorted, absolute URLs of every file referenced by
+// a Vite manifest.
+func PrecacheList(viteManifest []byte) ([]string, error) {
+	var manifest map[string]manifestChunk
+	if err := json.Unmarshal(viteManifest, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing Vite manifest: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var urls []string
+	add := func(file string) {
+		if file == "" || seen[file] {
+			return
+		}
+		seen[file] = true
+		urls = append(urls, "/"+file)
+	}
+	for _, chunk := range manifest {
+		add(chunk.File)
+		for _, css := range chunk.CSS {
+			add(css)
+		}
+		for _, asset := range chunk.Assets {
+			add(asset)
+		}
+	}
+	sort.Strings(urls)
+	return urls, nil
+}
+
+// GenerateServiceWorker returns a service worker that precaches the files
+// referenced by the Vite manifest and the / page. Assets are served
+// cache-first, pages network-first with the last successful copy, or /, as
+// the offline fallback, and RPCs are never cached. The cache name is derived from the manifest so each build
+// replaces the previous cache.
+func GenerateServiceWorker(viteManifest []byte) (string, error) {
+	urls, err := PrecacheList(viteManifest)
+	if err != nil {
+		return "", err
+	}
+	// The offline fallback for pages never visited
+	urls = append([]string{"/"}, urls...)
+	precache, err := json.MarshalIndent(urls, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(viteManifest)
+	cacheName := "gapp-" + hex.EncodeToString(sum[:])[:12]
+
+	return fmt.Sprintf(`// Code generated by gapp build --pwa. DO NOT EDIT.
+
+const CACHE = %q;
+const PRECACHE = %s;
+
+self.addEventListener("install", (event) => {
+  event.waitUntil(
+    caches.open(CACHE)
+      .then((cache) => cache.addAll(PRECACHE))
+      .then(() => self.skipWaiting()),
+  );
+});
+
+self.addEventListener("activate", (event) => {
+  event.waitUntil(
+    caches.keys()
+      .then((keys) => Promise.all(
+        keys.filter((key) => key.startsWith("gapp-") && key !== CACHE).map((key) => caches.delete(key)),
+      ))
+      .then(() => self.clients.claim()),
+  );
+});
+
+self.addEventListener("fetch", (event) => {
+  const request = event.request;
+  const url = new URL(request.url);
+  if (request.method !== "GET" || url.origin !== self.location.origin) return;
+  if (url.pathname.startsWith("/rpc") || url.pathname.startsWith("/__preload")) return;
+
+  if (request.mode === "navigate") {
+    event.respondWith(
+      fetch(request)
+        .then((response) => {
+          // Errors mustn't replace the good offline copy
+          if (response.ok) {
+            const copy = response.clone();
+            caches.open(CACHE).then((cache) => cache.put(request, copy));
+          }
+          return response;
+        })
+        .catch(() => caches.match(request).then((cached) => cached || caches.match("/"))),
+    );
+    return;
+  }
+
+  event.respondWith(caches.match(request).then((cached) => cached || fetch(request)));
+});
+`, cacheName, precache), nil
+}
+
+// WebManifest is a web app manifest (manifest.webmanifest).
+type WebManifest struct {
+	Name            string `json:"name"`
+	ShortName       string `json:"short_name"`
+	StartURL        string `json:"start_url"`
+	Display         string `json:"display"`
+	BackgroundColor string `json:"background_color"`
+	ThemeColor      string `json:"theme_color"`
+	Icons           []Icon `json:"icons"`
+}
+
+// Icon is a web app manifest icon.
+type Icon struct {
+	Src   string `json:"src"`
+	Sizes string `json:"sizes"`
+	Type  string `json:"type"`
+}
+
+// iconSizes are the icon files picked up from public/ when present.
+var iconSizes = []string{"192x192", "512x512"}
+
+// GenerateWebManifest returns a web app manifest for the app, listing any
+// icon-192x192.png / icon-512x512.png files found in publicDir.
+func GenerateWebManifest(name, publicDir string) (string, error) {
+	manifest := WebManifest{
+		Name:            name,
+		ShortName:       name,
+		StartURL:        "/",
+		Display:         "standalone",
+		BackgroundColor: "#ffffff",
+		ThemeColor:      "#ffffff",
+		Icons:           []Icon{},
+	}
+	for _, size := range iconSizes {
+		file := "icon-" + size + ".png"
+		if _, err := os.Stat(filepath.Join(publicDir, file)); err == nil {
+			manifest.Icons = append(manifest.Icons, Icon{Src: "/" + file, Sizes: size, Type: "image/png"})
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// Write generates sw.js and manifest.webmanifest into publicDir from its
+// .vite/manifest.json.
+func Write(publicDir, appName string) error {
+	viteManifest, err := os.ReadFile(filepath.Join(publicDir, ".vite", "manifest.json"))
+	if err != nil {
+		return err
+	}
+
+	sw, err := GenerateServiceWorker(viteManifest)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(publicDir, "sw.js"), []byte(sw), 0644); err != nil {
+		return err
+	}
+
+	webManifest, err := GenerateWebManifest(appName, publicDir)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(publicDir, "manifest.webmanifest"), []byte(webManifest), 0644)
+}
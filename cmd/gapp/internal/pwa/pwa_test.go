@@ -0,0 +1,55 @@
+package pwa
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPrecacheList(t *testing.T) {
+	manifest := []byte(`{
+  "index.html": {"file": "assets/index-abc.js", "isEntry": true, "css": ["assets/index-def.css"], "imports": ["_shared-123.js"]},
+  "_shared-123.js": {"file": "assets/shared-123.js", "css": ["assets/index-def.css"]},
+  "src/logo.svg": {"file": "assets/logo-456.svg", "assets": ["assets/font-789.woff2"]}
+}`)
+
+	urls, err := PrecacheList(manifest)
+	if err != nil {
+		t.Fatalf("PrecacheList failed: %v", err)
+	}
+
+	want := []string{
+		"/assets/font-789.woff2",
+		"/assets/index-abc.js",
+		"/assets/index-def.css",
+		"/assets/logo-456.svg",
+		"/assets/shared-123.js",
+	}
+	if strings.Join(urls, ",") != strings.Join(want, ",") {
+		t.Errorf("urls = %v, want %v", urls, want)
+	}
+}
+
+func TestGenerateServiceWorkerCacheName(t *testing.T) {
+	a, err := GenerateServiceWorker([]byte(`{"index.html": {"file": "assets/index-a.js"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := GenerateServiceWorker([]byte(`{"index.html": {"file": "assets/index-b.js"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(a, `"/assets/index-a.js"`) {
+		t.Error("service worker should precache the entry file")
+	}
+	if !strings.Contains(a, "const PRECACHE = [\n  \"/\",") {
+		t.Error("service worker should precache / as the offline fallback")
+	}
+	if !strings.Contains(a, "if (response.ok) {") {
+		t.Error("service worker should only cache successful pages")
+	}
+	cacheLine := func(sw string) string { return strings.SplitN(sw, "\n", 4)[2] }
+	if cacheLine(a) == cacheLine(b) {
+		t.Errorf("cache name should change between builds, both are %s", cacheLine(a))
+	}
+}
@@ -36,6 +36,21 @@ func main() {
 			fmt.Fprintf(os.Stderr, "gapp: %v\n", err)
 			os.Exit(1)
 		}
+	case "test":
+		if err := cmd.RunTest(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "gapp: %v\n", err)
+			os.Exit(1)
+		}
+	case "generate":
+		if err := cmd.RunGenerate(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "gapp: %v\n", err)
+			os.Exit(1)
+		}
+	case "upgrade":
+		if err := cmd.RunUpgrade(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "gapp: %v\n", err)
+			os.Exit(1)
+		}
 	case "help", "-h", "--help":
 		printUsage()
 	default:
@@ -52,27 +67,108 @@ Usage:
   gapp <command> [arguments]
 
 Commands:
-  init <name>    Create a new gapp project
+  init [name|.]  Create a new gapp project, interactively without flags
   codegen        Run proto codegen (Go + TypeScript)
   run [path]     Start server and client dev server
   build [path]   Build for production
+  test [path]    Run the server and client tests
+  upgrade [path] Upgrade gapp and refresh the files it owns
+  generate route <path>  Add a client route, e.g. /users/:id
+  generate rpc <Method>  Add an RPC to the proto with a Go handler stub
+  generate store <Name>  Add a client store keeping the results of RPCs
   help           Show this help message
 
 Init Options:
   --module <path>          Go module path (default: project name)
-  --framework react|vanilla  Client framework (default: react)
+  --framework <name>       Client framework: react, vanilla, svelte, vue or solid (default: react)
+  --template <dir|repo>    Start from a custom template, e.g. github.com/org/gapp-template
+  --css <name>             Styling: plain, tailwind or vanilla-extract (default: plain)
+  --with-auth              Add siauth login: an auth endpoint, a login page and protected RPCs
+  --layout monorepo        Create a workspace with go.work, npm workspaces, a shared proto
+                           and apps/web, or add apps/<name> when run from one
+  --pm <name>              Package manager: npm, pnpm, yarn or bun (default: npm, or the
+                           workspace's when adding an app)
+  --no-install             Skip the client install, codegen and go mod tidy, and list them instead
+  --offline                Install from the package manager and Go caches only, failing if
+                           anything's missing
+  --cache <dir>            Package manager and Go caches to use, filled by an init without --offline
+  --force                  Scaffold into a directory that isn't empty, overwriting the files it lists
   -y                       Skip confirmation, use defaults
 
 Codegen Options:
-  --proto <file>         Proto file path (default: proto/service.proto)
+  --project <dir>        Project whose gapp.toml to read, paths are relative to (default: .)
+  --proto <path>         Proto file, directory or glob (default: proto/service.proto)
+  --proto-path <dir,...> Extra directories proto imports resolve against
   --go-out <dir>         Go output directory (default: server/generated)
   --ts-out <dir>         TypeScript output directory (default: client/src/generated)
   --routes-dir <dir>     Routes directory (default: client/src/routes)
+  --infer-route-paths    Derive undeclared route paths from file locations, e.g. users/[id].tsx
   --preload-out <path>   Preload config output (default: server/generated/preload_routes.go)
   --force                Force codegen even if proto hasn't changed
+  --mocks                Generate mock services (server/generated/gapp_mocks.go)
+  --hooks <library>      Generate React hooks (gapp_hooks.ts): react-query or swr
+  --zod                  Generate zod schemas with protovalidate rules (gapp_zod.ts)
+  --skip-ts              Only generate Go code
+  --watch                Keep running, regenerating when the protos or routes change
+  --dry-run              Print a diff of what would change, without writing
+  --allow-breaking       Generate despite changes breaking clients of the previous protos
+  --swift-out <dir>      Generate a Swift client, with protoc-gen-swift
+  --kotlin-out <dir>     Generate a Kotlin client, with pbandk's protoc plugin
+  --docs                 Generate an API reference for clients calling over HTTP
+  --docs-format <f,...>  Reference formats: openapi, html (default: openapi,html)
+  --docs-out <dir>       Reference output directory (default: docs/api)
+  --go-opt <a=b,...>     Extra protoc-gen-go parameters
+  --go-plugin <path>     Run this protoc-gen-go instead of the built-in one
+  --go-package <path>    Go import path of the Go output, overriding go_package
+  --go-import-map <f=p>  Go import paths of imported protos, e.g. common.proto=example.com/commonpb
+  --ts-opt <a=b,...>     Extra ts-proto parameters, e.g. env=browser
+
+Run Options:
+  --open                 Open the app in a browser once it's ready
+  --no-open              Don't open a browser, overriding --open
+  --log-dir <dir>        Write full server and client output to rotating log files
+  --server-only          Only run the Go server (no vite or Node needed)
+  --client-only          Only run vite
+  --backend <url>        Server vite proxies to with --client-only
+  --https                Serve over HTTPS with a local certificate (uses mkcert if installed)
+  --port <n>             Server port (default: $PORT or 8080)
+  --client-port <n>      Vite dev server port
 
 Build Options:
   -o <dir>               Output directory (default: <path>/build)
+  --embed                Embed client assets into a single binary (-tags gapp_embed)
+  --pwa                  Generate a service worker and web app manifest
+  --skip-codegen         Don't run codegen before building
+  --verify               Boot the built server and check /health and / before finishing
+  --target <t>           Package for deployment: systemd (unit, env file, tarball) or tar
+  --docker[=tag]         Also build a distroless container image (default tag: <project>:latest)
+
+Test Options:
+  --server-only          Only run go test ./... in server/
+  --client-only          Only run vitest in client/
+  --integration          Start the server for the client tests ($GAPP_SERVER_URL)
+  -v                     Show the output of passing suites too
+
+Generate Options:
+  --project <dir>        Project directory (default: .)
+  --name <Name>          Route component name (default: derived from the path)
+  --rpc <A,B>            RPCs a route preloads, passed its params, or a store keeps
+  --request <fields>     Request fields of an rpc, e.g. userId:string,tags:[]string
+  --response <fields>    Response fields of an rpc, e.g. user:User
+
+Upgrade Options:
+  --version <v>          gapp version to upgrade to (default: latest)
+  -y                     Apply the changes without asking
+
+Project Configuration:
+  A gapp.toml at the project root sets defaults for codegen, run, build and
+  test, one table per command with its flags in snake_case:
+
+    [codegen]
+    go_out = "server/generated"
+    [build]
+    output = "dist"
+    embed = true
 
 Examples:
   gapp init myapp -y && gapp run myapp
@@ -0,0 +1,78 @@
+package scaffold
+
+import "strconv"
+
+// CSS is how a scaffolded client styles its components.
+type CSS string
+
+const (
+	CSSPlain          CSS = "plain"           // classes from client/src/styles.css
+	CSSTailwind       CSS = "tailwind"        // Tailwind utility classes
+	CSSVanillaExtract CSS = "vanilla-extract" // typed styles from client/src/styles.css.ts
+)
+
+// CSSOptions lists the CSS setups gapp init can scaffold.
+var CSSOptions = []CSS{CSSPlain, CSSTailwind, CSSVanillaExtract}
+
+// tailwindClasses are the utilities standing in for the classes of
+// styles.css in Tailwind projects. Preflight resets headings, lists and form
+// controls, so they get a style of their own.
+var tailwindClasses = map[string]string{
+	"page":     "mx-auto max-w-[600px] p-8",
+	"login":    "mx-auto max-w-[400px] p-8",
+	"title":    "mb-4 text-3xl font-bold",
+	"subtitle": "mb-2 text-xl font-semibold",
+	"form":     "mb-4",
+	"input":    "mr-2 rounded border border-gray-300 p-2",
+	"field":    "mb-4 block w-full rounded border border-gray-300 p-2",
+	"button":   "mr-2 rounded border border-gray-300 px-4 py-2",
+	"list":     "list-disc pl-6",
+	"divider":  "my-8",
+	"error":    "text-red-600",
+	"userbar":  "flex justify-end gap-4 px-4 py-2",
+}
+
+// Tailwind reports whether c's client uses Tailwind.
+func (c ProjectConfig) Tailwind() bool {
+	return c.CSS == CSSTailwind
+}
+
+// VanillaExtract reports whether c's client uses vanilla-extract, whose
+// styles components import from styles.css.ts.
+func (c ProjectConfig) VanillaExtract() bool {
+	return c.CSS == CSSVanillaExtract
+}
+
+// ClassName returns the TypeScript expression for one of the scaffold's
+// classes, such as "page", in c's CSS setup: a string, or with
+// vanilla-extract the style exported by styles.css.ts.
+func (c ProjectConfig) ClassName(name string) string {
+	switch c.CSS {
+	case CSSVanillaExtract:
+		return "styles." + name
+	case CSSTailwind:
+		return strconv.Quote(tailwindClasses[name])
+	default:
+		return strconv.Quote(name)
+	}
+}
+
+// Class returns the attribute setting one of the scaffold's classes on an
+// element in c's framework, e.g. className="page" for React or
+// :class="styles.page" for Vue with vanilla-extract.
+func (c ProjectConfig) Class(name string) string {
+	value := c.ClassName(name)
+	literal := value[0] == '"'
+	switch {
+	case c.Framework == FrameworkReact && literal:
+		return "className=" + value
+	case c.Framework == FrameworkReact:
+		return "className={" + value + "}"
+	case c.Framework == FrameworkVue && !literal:
+		return `:class="` + value + `"`
+	case literal:
+		return "class=" + value
+	default:
+		return "class={" + value + "}"
+	}
+}
@@ -0,0 +1,106 @@
+package scaffold
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// requiredFiles are the files a custom template must produce for the gapp
+// commands to work on the project.
+var requiredFiles = []string{
+	"client/package.json",
+	"server/go.mod",
+	"server/main.go",
+}
+
+// GenerateFromTemplate creates a new gapp project in dir from a custom
+// template. Files ending in .tmpl are rendered with config, like the built-in
+// templates, and lose the suffix; other files are copied as is. If the
+// template has a template/ directory, only its contents are used, so the
+// repository can keep a README of its own. Nothing is written unless the
+// template has the files in requiredFiles.
+// Returns the list of created files (relative to dir).
+func GenerateFromTemplate(config ProjectConfig, src fs.FS, dir string) ([]string, error) {
+	if info, err := fs.Stat(src, "template"); err == nil && info.IsDir() {
+		sub, err := fs.Sub(src, "template")
+		if err != nil {
+			return nil, err
+		}
+		src = sub
+	}
+
+	type file struct {
+		dst     string
+		content []byte
+		perm    os.FileMode
+	}
+	var files []file
+	err := fs.WalkDir(src, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		content, err := fs.ReadFile(src, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		f := file{dst: p, content: content, perm: 0644}
+		if info.Mode().Perm()&0111 != 0 {
+			f.perm = 0755
+		}
+		if dst, ok := strings.CutSuffix(p, ".tmpl"); ok {
+			rendered, err := renderTemplate(p, string(content), config)
+			if err != nil {
+				return fmt.Errorf("rendering template %s: %w", p, err)
+			}
+			f.dst, f.content = dst, []byte(rendered)
+		}
+		files = append(files, f)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading template: %w", err)
+	}
+
+	for _, required := range requiredFiles {
+		found := false
+		for _, f := range files {
+			found = found || f.dst == required
+		}
+		if !found {
+			return nil, fmt.Errorf("template has no %s (or %s.tmpl)", required, required)
+		}
+	}
+
+	var created []string
+	for _, f := range files {
+		outPath := filepath.Join(dir, filepath.FromSlash(f.dst))
+		if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+			return nil, fmt.Errorf("creating directory for %s: %w", f.dst, err)
+		}
+		if err := os.WriteFile(outPath, f.content, f.perm); err != nil {
+			return nil, fmt.Errorf("writing %s: %w", f.dst, err)
+		}
+		created = append(created, f.dst)
+	}
+
+	// Codegen writes into these, like in the built-in templates
+	for _, generated := range []string{"server/generated", "client/src/generated"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.FromSlash(generated)), 0755); err != nil {
+			return nil, fmt.Errorf("creating %s: %w", generated, err)
+		}
+	}
+	return created, nil
+}
@@ -0,0 +1,92 @@
+package scaffold
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PackageManager installs a client's dependencies and runs its scripts.
+type PackageManager string
+
+const (
+	PackageManagerNpm  PackageManager = "npm"
+	PackageManagerPnpm PackageManager = "pnpm"
+	PackageManagerYarn PackageManager = "yarn"
+	PackageManagerBun  PackageManager = "bun"
+)
+
+// PackageManagers lists the package managers gapp init can set a project up
+// with.
+var PackageManagers = []PackageManager{PackageManagerNpm, PackageManagerPnpm, PackageManagerYarn, PackageManagerBun}
+
+// lockfiles are the lockfiles of each package manager, the one it writes
+// today first.
+var lockfiles = map[PackageManager][]string{
+	PackageManagerNpm:  {"package-lock.json"},
+	PackageManagerPnpm: {"pnpm-lock.yaml"},
+	PackageManagerYarn: {"yarn.lock"},
+	PackageManagerBun:  {"bun.lock", "bun.lockb"},
+}
+
+// Lockfile returns the lockfile pm writes next to package.json.
+func (pm PackageManager) Lockfile() string {
+	return lockfiles[pm][0]
+}
+
+// AddArgs returns the arguments adding packages to a client's dependencies:
+// npm install, or pnpm, yarn and bun add.
+func (pm PackageManager) AddArgs(packages ...string) []string {
+	verb := "add"
+	if pm == PackageManagerNpm {
+		verb = "install"
+	}
+	return append([]string{verb}, packages...)
+}
+
+// DetectPackageManager returns the package manager of the client in
+// clientDir: the one whose lockfile is there or, for workspaces, in a
+// directory above it, else the one package.json names in packageManager.
+// Defaults to npm.
+func DetectPackageManager(clientDir string) PackageManager {
+	dir, err := filepath.Abs(clientDir)
+	if err != nil {
+		dir = clientDir
+	}
+	for d := dir; ; d = filepath.Dir(d) {
+		for _, pm := range PackageManagers {
+			for _, lockfile := range lockfiles[pm] {
+				if _, err := os.Stat(filepath.Join(d, lockfile)); err == nil {
+					return pm
+				}
+			}
+		}
+		if pm := declaredPackageManager(d); pm != "" {
+			return pm
+		}
+		if filepath.Dir(d) == d {
+			return PackageManagerNpm
+		}
+	}
+}
+
+// declaredPackageManager returns the package manager the package.json in
+// dir declares for Corepack, e.g. "pnpm@9.15.0", if any.
+func declaredPackageManager(dir string) PackageManager {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return ""
+	}
+	var pkg struct {
+		PackageManager string `json:"packageManager"`
+	}
+	if json.Unmarshal(data, &pkg) != nil {
+		return ""
+	}
+	name, _, _ := strings.Cut(pkg.PackageManager, "@")
+	if _, ok := lockfiles[PackageManager(name)]; ok {
+		return PackageManager(name)
+	}
+	return ""
+}
@@ -2,6 +2,7 @@ package scaffold
 
 import (
 	"embed"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -17,15 +18,25 @@ type Framework string
 const (
 	FrameworkReact   Framework = "react"
 	FrameworkVanilla Framework = "vanilla"
+	FrameworkSvelte  Framework = "svelte"
+	FrameworkVue     Framework = "vue"
+	FrameworkSolid   Framework = "solid"
 )
 
+// Frameworks lists the client frameworks gapp init can scaffold.
+var Frameworks = []Framework{FrameworkReact, FrameworkVanilla, FrameworkSvelte, FrameworkVue, FrameworkSolid}
+
 type ProjectConfig struct {
-	Name          string
-	Module        string
-	Framework     Framework
-	GappClientPath string // absolute path to @gapp/client
-	GappReactPath  string // absolute path to @gapp/react (react only)
-	GappServerPath string // absolute path to gapp server Go module
+	Name           string
+	Module         string
+	Framework      Framework
+	GappClientPath string         // absolute path to @gapp/client
+	GappReactPath  string         // absolute path to @gapp/react (react only)
+	GappServerPath string         // absolute path to gapp server Go module
+	SharedProto    string         // monorepo apps: the workspace's proto, relative to the app
+	Auth           bool           // siauth login, with the app's RPCs behind it
+	CSS            CSS            // how components are styled (default: plain)
+	PackageManager PackageManager // installs client dependencies (default: npm)
 }
 
 // templateFile maps a template path to an output path.
@@ -38,11 +49,16 @@ var sharedFiles = []templateFile{
 	{"proto/service.proto", "proto/service.proto"},
 	{"server/go.mod.tmpl", "server/go.mod"},
 	{"server/main.go.tmpl", "server/main.go"},
+	{"server/main_test.go.tmpl", "server/main_test.go"},
+	{"server/gapp_embed.go.tmpl", "server/gapp_embed.go"},
 	{"client/src/rpc.ts.tmpl", "client/src/rpc.ts"},
 	{"client/src/rpcTypes.ts.tmpl", "client/src/rpcTypes.ts"},
 	{"client/src/preload.ts.tmpl", "client/src/preload.ts"},
 	{"client/src/stores/ItemStore.ts.tmpl", "client/src/stores/ItemStore.ts"},
+	{"client/src/stores/ItemStore.test.ts.tmpl", "client/src/stores/ItemStore.test.ts"},
+	{"client/vitest.config.ts.tmpl", "client/vitest.config.ts"},
 	{"Dockerfile.tmpl", "Dockerfile"},
+	{"gapp.toml", "gapp.toml"},
 }
 
 var reactFiles = []templateFile{
@@ -52,6 +68,8 @@ var reactFiles = []templateFile{
 	{"client/index.html.tmpl", "client/index.html"},
 	{"client/src/main.tsx.tmpl", "client/src/main.tsx"},
 	{"client/src/routes/HomeRoute.tsx.tmpl", "client/src/routes/HomeRoute.tsx"},
+	{"client/src/routes/HomeRoute.test.tsx.tmpl", "client/src/routes/HomeRoute.test.tsx"},
+	{"client/src/components/ItemFeed.tsx.tmpl", "client/src/components/ItemFeed.tsx"},
 }
 
 var vanillaFiles = []templateFile{
@@ -61,33 +79,140 @@ var vanillaFiles = []templateFile{
 	{"client/index.html.tmpl", "client/index.html"},
 	{"client/src/main.ts.tmpl", "client/src/main.ts"},
 	{"client/src/routes/HomeRoute.ts.tmpl", "client/src/routes/HomeRoute.ts"},
+	{"client/src/routes/HomeRoute.test.ts.tmpl", "client/src/routes/HomeRoute.test.ts"},
+	{"client/src/components/ItemFeed.ts.tmpl", "client/src/components/ItemFeed.ts"},
+}
+
+// svelteFiles, vueFiles and solidFiles keep the route declarations codegen
+// scans in routes/, and for Svelte and Vue the page components in pages/.
+// Store and router bindings live in src/lib/gapp.ts.
+var svelteFiles = []templateFile{
+	{"client/package.json.tmpl", "client/package.json"},
+	{"client/tsconfig.json.tmpl", "client/tsconfig.json"},
+	{"client/vite.config.ts.tmpl", "client/vite.config.ts"},
+	{"client/svelte.config.js.tmpl", "client/svelte.config.js"},
+	{"client/index.html.tmpl", "client/index.html"},
+	{"client/src/vite-env.d.ts.tmpl", "client/src/vite-env.d.ts"},
+	{"client/src/lib/gapp.ts.tmpl", "client/src/lib/gapp.ts"},
+	{"client/src/main.ts.tmpl", "client/src/main.ts"},
+	{"client/src/App.svelte.tmpl", "client/src/App.svelte"},
+	{"client/src/routes/HomeRoute.ts.tmpl", "client/src/routes/HomeRoute.ts"},
+	{"client/src/pages/HomePage.svelte.tmpl", "client/src/pages/HomePage.svelte"},
+	{"client/src/components/ItemFeed.svelte.tmpl", "client/src/components/ItemFeed.svelte"},
+	{"client/src/pages/HomePage.test.ts.tmpl", "client/src/pages/HomePage.test.ts"},
+}
+
+var vueFiles = []templateFile{
+	{"client/package.json.tmpl", "client/package.json"},
+	{"client/tsconfig.json.tmpl", "client/tsconfig.json"},
+	{"client/vite.config.ts.tmpl", "client/vite.config.ts"},
+	{"client/index.html.tmpl", "client/index.html"},
+	{"client/src/vite-env.d.ts.tmpl", "client/src/vite-env.d.ts"},
+	{"client/src/lib/gapp.ts.tmpl", "client/src/lib/gapp.ts"},
+	{"client/src/main.ts.tmpl", "client/src/main.ts"},
+	{"client/src/App.vue.tmpl", "client/src/App.vue"},
+	{"client/src/routes/HomeRoute.ts.tmpl", "client/src/routes/HomeRoute.ts"},
+	{"client/src/pages/HomePage.vue.tmpl", "client/src/pages/HomePage.vue"},
+	{"client/src/components/ItemFeed.vue.tmpl", "client/src/components/ItemFeed.vue"},
+	{"client/src/pages/HomePage.test.ts.tmpl", "client/src/pages/HomePage.test.ts"},
+}
+
+var solidFiles = []templateFile{
+	{"client/package.json.tmpl", "client/package.json"},
+	{"client/tsconfig.json.tmpl", "client/tsconfig.json"},
+	{"client/vite.config.ts.tmpl", "client/vite.config.ts"},
+	{"client/index.html.tmpl", "client/index.html"},
+	{"client/src/lib/gapp.ts.tmpl", "client/src/lib/gapp.ts"},
+	{"client/src/main.tsx.tmpl", "client/src/main.tsx"},
+	{"client/src/routes/HomeRoute.tsx.tmpl", "client/src/routes/HomeRoute.tsx"},
+	{"client/src/routes/HomeRoute.test.tsx.tmpl", "client/src/routes/HomeRoute.test.tsx"},
+	{"client/src/components/ItemFeed.tsx.tmpl", "client/src/components/ItemFeed.tsx"},
+}
+
+// authFiles are added to the shared and framework files of projects
+// scaffolded with Auth: the session store and the login page the client
+// shows while signed out.
+var authFiles = []templateFile{
+	{"client/src/stores/AuthStore.ts.tmpl", "client/src/stores/AuthStore.ts"},
 }
 
-func filesForFramework(fw Framework) []struct {
+// cssFiles hold the styles of each CSS setup, which main or the components
+// import.
+var cssFiles = map[CSS]templateFile{
+	CSSPlain:          {"client/src/styles.css.tmpl", "client/src/styles.css"},
+	CSSTailwind:       {"client/src/styles.css.tmpl", "client/src/styles.css"},
+	CSSVanillaExtract: {"client/src/styles.css.ts.tmpl", "client/src/styles.css.ts"},
+}
+
+// yarnFiles keep Yarn 2+ installing to node_modules, whose binaries gapp
+// runs, rather than Plug'n'Play. Workspace apps share the workspace's.
+var yarnFiles = []templateFile{
+	{"client/.yarnrc.yml", "client/.yarnrc.yml"},
+}
+
+var authPages = map[Framework]templateFile{
+	FrameworkReact:   {"client/src/pages/LoginPage.tsx.tmpl", "client/src/pages/LoginPage.tsx"},
+	FrameworkVanilla: {"client/src/pages/LoginPage.ts.tmpl", "client/src/pages/LoginPage.ts"},
+	FrameworkSvelte:  {"client/src/pages/LoginPage.svelte.tmpl", "client/src/pages/LoginPage.svelte"},
+	FrameworkVue:     {"client/src/pages/LoginPage.vue.tmpl", "client/src/pages/LoginPage.vue"},
+	FrameworkSolid:   {"client/src/pages/LoginPage.tsx.tmpl", "client/src/pages/LoginPage.tsx"},
+}
+
+var frameworkFiles = map[Framework][]templateFile{
+	FrameworkReact:   reactFiles,
+	FrameworkVanilla: vanillaFiles,
+	FrameworkSvelte:  svelteFiles,
+	FrameworkVue:     vueFiles,
+	FrameworkSolid:   solidFiles,
+}
+
+func filesForFramework(config ProjectConfig) []struct {
 	prefix string
 	files  []templateFile
 } {
-	fwFiles := reactFiles
-	fwPrefix := "react"
-	if fw == FrameworkVanilla {
-		fwFiles = vanillaFiles
-		fwPrefix = "vanilla"
+	fw := config.Framework
+	fwFiles, ok := frameworkFiles[fw]
+	if !ok {
+		fw, fwFiles = FrameworkReact, reactFiles
+	}
+	shared := append(append([]templateFile(nil), sharedFiles...), cssFiles[config.CSS])
+	if config.PackageManager == PackageManagerYarn && config.SharedProto == "" {
+		shared = append(shared, yarnFiles...)
+	}
+	if config.Auth {
+		shared = append(shared, authFiles...)
+		fwFiles = append(append([]templateFile(nil), fwFiles...), authPages[fw])
 	}
 	return []struct {
 		prefix string
 		files  []templateFile
 	}{
-		{"shared", sharedFiles},
-		{fwPrefix, fwFiles},
+		{"shared", shared},
+		{string(fw), fwFiles},
 	}
 }
 
+// MainFile returns the project-relative client entry point of fw, which
+// declares the router's routes.
+func MainFile(fw Framework) string {
+	if fw == FrameworkReact || fw == FrameworkSolid {
+		return "client/src/main.tsx"
+	}
+	return "client/src/main.ts"
+}
+
 // Generate creates a new gapp project in the given directory.
 // Returns the list of created files (relative to dir).
 func Generate(config ProjectConfig, dir string) ([]string, error) {
 	if config.Framework == "" {
 		config.Framework = FrameworkReact
 	}
+	if config.CSS == "" {
+		config.CSS = CSSPlain
+	}
+	if config.PackageManager == "" {
+		config.PackageManager = PackageManagerNpm
+	}
 
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("creating project directory: %w", err)
@@ -105,8 +230,11 @@ func Generate(config ProjectConfig, dir string) ([]string, error) {
 
 	var created []string
 
-	for _, group := range filesForFramework(config.Framework) {
+	for _, group := range filesForFramework(config) {
 		for _, f := range group.files {
+			if f.dst == "proto/service.proto" && config.SharedProto != "" {
+				continue
+			}
 			outPath := filepath.Join(dir, f.dst)
 
 			// Ensure parent directory exists
@@ -146,7 +274,7 @@ func (c ProjectConfig) ProtoPackage() string {
 	return strings.ReplaceAll(c.Name, "-", "_")
 }
 
-func renderTemplate(name, content string, data ProjectConfig) (string, error) {
+func renderTemplate(name, content string, data any) (string, error) {
 	tmpl, err := template.New(name).Delims("<<", ">>").Parse(content)
 	if err != nil {
 		return "", err
@@ -159,3 +287,112 @@ func renderTemplate(name, content string, data ProjectConfig) (string, error) {
 
 	return buf.String(), nil
 }
+
+// managedFiles are the scaffolded files gapp upgrade keeps in step with the
+// templates. They hold framework glue rather than app code.
+var managedFiles = []string{
+	"client/src/rpc.ts",
+	"client/src/preload.ts",
+	"client/vite.config.ts",
+}
+
+// ManagedFiles returns the project-relative paths gapp upgrade refreshes.
+func ManagedFiles() []string {
+	return append([]string(nil), managedFiles...)
+}
+
+// Render returns the current template for the scaffolded file at dst, a
+// project-relative path such as "client/src/rpc.ts".
+func Render(config ProjectConfig, dst string) (string, error) {
+	if config.Framework == "" {
+		config.Framework = FrameworkReact
+	}
+	if config.CSS == "" {
+		config.CSS = CSSPlain
+	}
+	if config.PackageManager == "" {
+		config.PackageManager = PackageManagerNpm
+	}
+	for _, group := range filesForFramework(config) {
+		for _, f := range group.files {
+			if f.dst != dst {
+				continue
+			}
+			content, err := templateFS.ReadFile("templates/" + group.prefix + "/" + f.src)
+			if err != nil {
+				return "", fmt.Errorf("reading template %s/%s: %w", group.prefix, f.src, err)
+			}
+			return renderTemplate(f.src, string(content), config)
+		}
+	}
+	return "", fmt.Errorf("%s isn't a scaffolded file", dst)
+}
+
+// DetectConfig reads the ProjectConfig of the project scaffolded in dir back
+// from its client/package.json and server/go.mod.
+func DetectConfig(dir string) (ProjectConfig, error) {
+	var config ProjectConfig
+
+	pkgData, err := os.ReadFile(filepath.Join(dir, "client", "package.json"))
+	if err != nil {
+		return config, err
+	}
+	var pkg struct {
+		Name            string            `json:"name"`
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.Unmarshal(pkgData, &pkg); err != nil {
+		return config, fmt.Errorf("client/package.json: %w", err)
+	}
+	config.Name = strings.TrimSuffix(pkg.Name, "-client")
+	config.Framework = FrameworkVanilla
+	for dep, fw := range map[string]Framework{"@gapp/react": FrameworkReact, "svelte": FrameworkSvelte, "vue": FrameworkVue, "solid-js": FrameworkSolid} {
+		if _, ok := pkg.Dependencies[dep]; ok {
+			config.Framework = fw
+		}
+	}
+	config.GappClientPath = strings.TrimPrefix(pkg.Dependencies["@gapp/client"], "file:")
+	if config.GappClientPath == pkg.Dependencies["@gapp/client"] {
+		config.GappClientPath = ""
+	}
+	_, config.Auth = pkg.Dependencies["siauth-ts"]
+	config.CSS = CSSPlain
+	if _, ok := pkg.DevDependencies["tailwindcss"]; ok {
+		config.CSS = CSSTailwind
+	} else if _, ok := pkg.Dependencies["@vanilla-extract/css"]; ok {
+		config.CSS = CSSVanillaExtract
+	}
+	config.PackageManager = DetectPackageManager(filepath.Join(dir, "client"))
+	config.GappReactPath = strings.TrimPrefix(pkg.Dependencies["@gapp/react"], "file:")
+	if config.GappReactPath == pkg.Dependencies["@gapp/react"] {
+		config.GappReactPath = ""
+	}
+
+	modData, err := os.ReadFile(filepath.Join(dir, "server", "go.mod"))
+	if err != nil {
+		return config, err
+	}
+	for _, line := range strings.Split(string(modData), "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 2 && fields[0] == "module":
+			config.Module = strings.TrimSuffix(fields[1], "/server")
+		case len(fields) == 4 && fields[0] == "replace" && fields[1] == "github.com/germtb/gapp" && fields[2] == "=>":
+			config.GappServerPath = fields[3]
+		}
+	}
+	if config.Name == "" {
+		config.Name = filepath.Base(config.Module)
+	}
+	return config, nil
+}
+
+// RenderGenerator renders a gapp generate template, e.g. "react/route.tsx.tmpl".
+func RenderGenerator(path string, data any) (string, error) {
+	content, err := templateFS.ReadFile("templates/generate/" + path)
+	if err != nil {
+		return "", fmt.Errorf("reading template generate/%s: %w", path, err)
+	}
+	return renderTemplate(path, string(content), data)
+}
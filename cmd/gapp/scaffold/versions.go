@@ -0,0 +1,56 @@
+package scaffold
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+)
+
+// versionsJSON pins the dependencies gapp init writes into go.mod and
+// package.json, so that the projects a gapp release scaffolds install the
+// same versions every time rather than the latest ones. Bump it with each
+// release.
+//
+//go:embed versions.json
+var versionsJSON []byte
+
+// VersionManifest maps the dependencies of a scaffolded project to the exact
+// versions they're pinned to.
+type VersionManifest struct {
+	Go  map[string]string `json:"go"`  // Go modules, v-prefixed
+	Npm map[string]string `json:"npm"` // npm packages
+}
+
+var versions = func() VersionManifest {
+	var manifest VersionManifest
+	if err := json.Unmarshal(versionsJSON, &manifest); err != nil {
+		panic("scaffold: parsing versions.json: " + err.Error())
+	}
+	return manifest
+}()
+
+// Versions returns the dependency versions this gapp release scaffolds
+// projects with.
+func Versions() VersionManifest {
+	return versions
+}
+
+// GoVersion returns the version module is pinned to in go.mod. Templates
+// fail to render for modules missing from the manifest.
+func (c ProjectConfig) GoVersion(module string) (string, error) {
+	version, ok := versions.Go[module]
+	if !ok {
+		return "", fmt.Errorf("no version pinned for Go module %s", module)
+	}
+	return version, nil
+}
+
+// NpmVersion returns the version pkg is pinned to in package.json.
+// Templates fail to render for packages missing from the manifest.
+func (c ProjectConfig) NpmVersion(pkg string) (string, error) {
+	version, ok := versions.Npm[pkg]
+	if !ok {
+		return "", fmt.Errorf("no version pinned for npm package %s", pkg)
+	}
+	return version, nil
+}
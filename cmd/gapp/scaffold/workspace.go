@@ -0,0 +1,111 @@
+package scaffold
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// WorkspaceApp is the app a new monorepo starts with.
+const WorkspaceApp = "web"
+
+// workspaceFiles are the files at the root of a monorepo, relative to
+// templates/workspace/.
+var workspaceFiles = []templateFile{
+	{"go.work.tmpl", "go.work"},
+	{"package.json.tmpl", "package.json"},
+	{"README.md.tmpl", "README.md"},
+	{"services/.gitkeep", "services/.gitkeep"},
+}
+
+// workspacePackageManagerFiles configure the workspace for package managers
+// that need more than package.json: pnpm lists workspaces in
+// pnpm-workspace.yaml, and Yarn 2+ is kept on node_modules.
+var workspacePackageManagerFiles = map[PackageManager][]templateFile{
+	PackageManagerPnpm: {{"pnpm-workspace.yaml.tmpl", "pnpm-workspace.yaml"}},
+	PackageManagerYarn: {{".yarnrc.yml", ".yarnrc.yml"}},
+}
+
+// workspaceData is the data of the workspace templates.
+type workspaceData struct {
+	ProjectConfig
+	App string
+}
+
+// GenerateWorkspace creates a monorepo in dir: a go.work and npm workspaces
+// root with the proto its apps share in proto/, a services/ directory for
+// other Go modules, and a first app in apps/<app>.
+// Returns the list of created files (relative to dir).
+func GenerateWorkspace(config ProjectConfig, dir, app string) ([]string, error) {
+	if config.Framework == "" {
+		config.Framework = FrameworkReact
+	}
+	if config.PackageManager == "" {
+		config.PackageManager = PackageManagerNpm
+	}
+
+	var created []string
+	files := append(append([]templateFile(nil), workspaceFiles...), workspacePackageManagerFiles[config.PackageManager]...)
+	for _, f := range files {
+		content, err := templateFS.ReadFile("templates/workspace/" + f.src)
+		if err != nil {
+			return nil, fmt.Errorf("reading template workspace/%s: %w", f.src, err)
+		}
+		rendered, err := renderTemplate(f.src, string(content), workspaceData{config, app})
+		if err != nil {
+			return nil, fmt.Errorf("rendering template %s: %w", f.src, err)
+		}
+		if err := writeFile(dir, f.dst, rendered); err != nil {
+			return nil, err
+		}
+		created = append(created, f.dst)
+	}
+
+	// The shared proto, as a standalone project has it
+	proto, err := Render(config, "proto/service.proto")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFile(dir, "proto/service.proto", proto); err != nil {
+		return nil, err
+	}
+	created = append(created, "proto/service.proto")
+
+	appFiles, err := AddWorkspaceApp(config, dir, app)
+	if err != nil {
+		return nil, err
+	}
+	return append(created, appFiles...), nil
+}
+
+// AddWorkspaceApp creates app in apps/<app> of the monorepo in root, with
+// codegen reading the workspace's proto. config holds the workspace's name
+// and module; the app's Go module is <module>/apps/<app>/server. It doesn't
+// add the app's server to go.work.
+// Returns the list of created files (relative to root).
+func AddWorkspaceApp(config ProjectConfig, root, app string) ([]string, error) {
+	config.Name = app
+	config.Module = path.Join(config.Module, "apps", app)
+	config.SharedProto = "../../proto/service.proto"
+
+	files, err := Generate(config, filepath.Join(root, "apps", app))
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range files {
+		files[i] = path.Join("apps", app, f)
+	}
+	return files, nil
+}
+
+func writeFile(dir, dst, content string) error {
+	outPath := filepath.Join(dir, filepath.FromSlash(dst))
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", dst, err)
+	}
+	if err := os.WriteFile(outPath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", dst, err)
+	}
+	return nil
+}
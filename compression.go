@@ -0,0 +1,102 @@
+package gapp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// StreamCompressor compresses individual stream messages. gzip is built in;
+// register others, such as zstd from github.com/klauspost/compress, with
+// RegisterStreamCompressor.
+type StreamCompressor interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[string]StreamCompressor{"gzip": gzipCompressor{}}
+)
+
+// RegisterStreamCompressor makes a compressor available for negotiation under
+// name, the value clients list in X-Stream-Accept-Compression.
+func RegisterStreamCompressor(name string, c StreamCompressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[name] = c
+}
+
+func lookupCompressor(name string) StreamCompressor {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	return compressors[name]
+}
+
+// negotiateCompression picks the first registered compressor from the
+// request's X-Stream-Accept-Compression list, in the client's order of
+// preference.
+func negotiateCompression(r *http.Request) (string, StreamCompressor) {
+	for _, name := range strings.Split(r.Header.Get("X-Stream-Accept-Compression"), ",") {
+		name = strings.TrimSpace(name)
+		if c := lookupCompressor(name); c != nil {
+			return name, c
+		}
+	}
+	return "", nil
+}
+
+// Flag byte that starts each message of a compressed stream.
+const (
+	frameRaw        byte = 0
+	frameCompressed byte = 1
+)
+
+// decodeFrame strips the flag byte from a message of a compressed stream and
+// decompresses it if needed.
+func decodeFrame(frame []byte, c StreamCompressor) ([]byte, error) {
+	if len(frame) == 0 {
+		return nil, fmt.Errorf("compressed stream frame has no flag byte")
+	}
+	switch frame[0] {
+	case frameRaw:
+		return frame[1:], nil
+	case frameCompressed:
+		return c.Decompress(frame[1:])
+	default:
+		return nil, fmt.Errorf("unknown stream frame flag %d", frame[0])
+	}
+}
+
+type gzipCompressor struct{}
+
+var gzipWriters = sync.Pool{
+	New: func() any { return gzip.NewWriter(nil) },
+}
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(zw)
+	zw.Reset(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
@@ -0,0 +1,212 @@
+package gapp
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// DefaultConfigFile is the config file LoadConfig reads when no path is
+// given and $GAPP_CONFIG is unset. It's optional.
+const DefaultConfigFile = "gapp.toml"
+
+// Config is the server configuration shared by gapp apps. LoadConfig fills
+// it from defaults, then an optional TOML file, then environment variables,
+// so deployments can override any file value.
+type Config struct {
+	Host     string `toml:"host"`      // $GAPP_HOST, defaults to all interfaces
+	Port     int    `toml:"port"`      // $PORT, defaults to 8080
+	Addr     string `toml:"addr"`      // $GAPP_ADDR, overrides Host and Port, e.g. "unix:/run/app.sock"
+	AppName  string `toml:"app_name"`  // $APP_NAME
+	DataRoot string `toml:"data_root"` // $DATA_ROOT, where the app keeps its files
+
+	PublicDir    string `toml:"public_dir"`    // $GAPP_PUBLIC_DIR, defaults to "public"
+	ManifestPath string `toml:"manifest_path"` // $GAPP_MANIFEST_PATH, defaults to <PublicDir>/.vite/manifest.json
+
+	CORSOrigins []string `toml:"cors_origins"` // $GAPP_CORS_ORIGINS (comma-separated); empty reflects the request origin
+
+	TLSCert string `toml:"tls_cert"` // $GAPP_TLS_CERT, serves HTTPS with TLSKey when set
+	TLSKey  string `toml:"tls_key"`  // $GAPP_TLS_KEY
+
+	ReadTimeout       time.Duration `toml:"read_timeout"`        // $GAPP_READ_TIMEOUT
+	ReadHeaderTimeout time.Duration `toml:"read_header_timeout"` // $GAPP_READ_HEADER_TIMEOUT
+	WriteTimeout      time.Duration `toml:"write_timeout"`       // $GAPP_WRITE_TIMEOUT
+	IdleTimeout       time.Duration `toml:"idle_timeout"`        // $GAPP_IDLE_TIMEOUT
+	ShutdownTimeout   time.Duration `toml:"shutdown_timeout"`    // $GAPP_SHUTDOWN_TIMEOUT
+}
+
+// LoadConfig loads the configuration from path, $GAPP_CONFIG or
+// DefaultConfigFile, in that order, and the environment. A missing file is
+// only an error when path or $GAPP_CONFIG names it explicitly.
+func LoadConfig(path string) (*Config, error) {
+	config := &Config{Port: 8080, PublicDir: "public"}
+
+	required := true
+	if path == "" {
+		path = os.Getenv("GAPP_CONFIG")
+	}
+	if path == "" {
+		path, required = DefaultConfigFile, false
+	}
+
+	md, err := toml.DecodeFile(path, config)
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && !required:
+	case err != nil:
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	default:
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("%s: unknown key %q", path, undecoded[0].String())
+		}
+	}
+
+	if err := config.applyEnv(); err != nil {
+		return nil, err
+	}
+	if config.ManifestPath == "" && config.PublicDir != "public" {
+		config.ManifestPath = filepath.Join(config.PublicDir, ".vite", "manifest.json")
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// applyEnv overrides the config with any environment variables that are set.
+func (c *Config) applyEnv() error {
+	stringVars := map[string]*string{
+		"GAPP_HOST":          &c.Host,
+		"GAPP_ADDR":          &c.Addr,
+		"APP_NAME":           &c.AppName,
+		"DATA_ROOT":          &c.DataRoot,
+		"GAPP_PUBLIC_DIR":    &c.PublicDir,
+		"GAPP_MANIFEST_PATH": &c.ManifestPath,
+		"GAPP_TLS_CERT":      &c.TLSCert,
+		"GAPP_TLS_KEY":       &c.TLSKey,
+	}
+	for name, field := range stringVars {
+		if value, ok := os.LookupEnv(name); ok {
+			*field = value
+		}
+	}
+
+	if value, ok := os.LookupEnv("PORT"); ok {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("PORT: invalid port %q", value)
+		}
+		c.Port = port
+	}
+
+	if value, ok := os.LookupEnv("GAPP_CORS_ORIGINS"); ok {
+		c.CORSOrigins = splitList(value)
+	}
+
+	durationVars := map[string]*time.Duration{
+		"GAPP_READ_TIMEOUT":        &c.ReadTimeout,
+		"GAPP_READ_HEADER_TIMEOUT": &c.ReadHeaderTimeout,
+		"GAPP_WRITE_TIMEOUT":       &c.WriteTimeout,
+		"GAPP_IDLE_TIMEOUT":        &c.IdleTimeout,
+		"GAPP_SHUTDOWN_TIMEOUT":    &c.ShutdownTimeout,
+	}
+	for name, field := range durationVars {
+		if value, ok := os.LookupEnv(name); ok {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*field = d
+		}
+	}
+	return nil
+}
+
+// Validate reports the first invalid setting.
+func (c *Config) Validate() error {
+	if c.Addr == "" && (c.Port < 1 || c.Port > 65535) {
+		return fmt.Errorf("port %d out of range", c.Port)
+	}
+	for _, origin := range c.CORSOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return fmt.Errorf("CORS origin %q must be \"*\" or scheme://host[:port]", origin)
+		}
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return errors.New("tls_cert and tls_key must be set together")
+	}
+	if c.ShutdownTimeout < 0 {
+		return errors.New("shutdown timeout must not be negative")
+	}
+	return nil
+}
+
+// ListenAddr returns the address to pass to ListenAndServe.
+func (c *Config) ListenAddr() string {
+	if c.Addr != "" {
+		return c.Addr
+	}
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// ServerConfig returns the server timeouts for WithServerConfig.
+func (c *Config) ServerConfig() ServerConfig {
+	return ServerConfig{
+		ReadTimeout:       c.ReadTimeout,
+		ReadHeaderTimeout: c.ReadHeaderTimeout,
+		WriteTimeout:      c.WriteTimeout,
+		IdleTimeout:       c.IdleTimeout,
+		ShutdownTimeout:   c.ShutdownTimeout,
+	}
+}
+
+// ServeOptions returns the ListenAndServeWithOptions options implied by the
+// config: its timeouts, and TLS when a certificate is set.
+func (c *Config) ServeOptions() []ServeOption {
+	opts := []ServeOption{WithServerConfig(c.ServerConfig())}
+	if c.TLSCert != "" {
+		opts = append(opts, WithTLS(c.TLSCert, c.TLSKey))
+	}
+	return opts
+}
+
+// DispatcherOptions returns the Dispatcher options implied by the config.
+func (c *Config) DispatcherOptions() []DispatcherOption {
+	var opts []DispatcherOption
+	if len(c.CORSOrigins) > 0 {
+		opts = append(opts, WithCORS(CORSConfig{AllowedOrigins: c.CORSOrigins}))
+	}
+	return opts
+}
+
+// PublicFS returns the client assets: the embedded ones registered with
+// UseEmbeddedAssets, or PublicDir on disk.
+func (c *Config) PublicFS() fs.FS {
+	if embedded := registeredEmbeddedAssets(); embedded != nil {
+		return embedded
+	}
+	return os.DirFS(c.PublicDir)
+}
+
+// splitList splits a comma-separated value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,148 @@
+package gapp
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DownloadHandler produces the file for a download RPC from the
+// proto-encoded request. Downloads can be fetched with GET, so they must not
+// have side effects.
+type DownloadHandler func(r *http.Request, method string, request []byte) (*Download, error)
+
+// Download is a file served by ServeDownload, either a seekable file that
+// supports Range requests and resumption, or a generated stream such as a
+// CSV export.
+type Download struct {
+	Name        string        // file name offered to the browser
+	ContentType string        // defaults from Name's extension
+	Content     io.Reader     // an io.ReadSeeker enables Range requests; closed after serving if it's an io.Closer
+	ModTime     time.Time     // enables If-Modified-Since and If-Range when set
+	Inline      bool          // display in the browser instead of saving
+	RateLimit   int64         // bytes per second, 0 for unlimited
+	Timeout     time.Duration // overrides the server's write timeout; 0 disables it, since large files outlast it
+}
+
+// DownloadFile opens the file at path as a Download named after it.
+func DownloadFile(path string) (*Download, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Download{Name: filepath.Base(path), Content: f, ModTime: info.ModTime()}, nil
+}
+
+// ServeDownload writes d to w with a Content-Disposition header. Seekable
+// content is served with http.ServeContent, which handles Range, If-Range
+// and HEAD requests.
+func ServeDownload(w http.ResponseWriter, r *http.Request, d *Download) {
+	if closer, ok := d.Content.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	var deadline time.Time
+	if d.Timeout > 0 {
+		deadline = time.Now().Add(d.Timeout)
+	}
+	http.NewResponseController(w).SetWriteDeadline(deadline)
+
+	disposition := "attachment"
+	if d.Inline {
+		disposition = "inline"
+	}
+	if d.Name != "" {
+		disposition = mime.FormatMediaType(disposition, map[string]string{"filename": d.Name})
+	}
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	contentType := d.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(d.Name))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+
+	if d.RateLimit > 0 {
+		w = &throttledWriter{ResponseWriter: w, ctx: r.Context(), rate: d.RateLimit, start: time.Now()}
+	}
+
+	if seeker, ok := d.Content.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, d.Name, d.ModTime, seeker)
+		return
+	}
+
+	w.Header().Set("Accept-Ranges", "none")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		io.Copy(w, d.Content)
+	}
+}
+
+// throttledWriter limits the bytes written per second, sleeping between
+// chunks so the average rate stays at or below rate.
+type throttledWriter struct {
+	http.ResponseWriter
+	ctx     context.Context
+	rate    int64
+	start   time.Time
+	written int64
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	chunk := max(int(t.rate/10), 1)
+	total := 0
+	for len(p) > 0 {
+		n := min(chunk, len(p))
+		written, err := t.ResponseWriter.Write(p[:n])
+		total += written
+		t.written += int64(written)
+		if err != nil {
+			return total, err
+		}
+		p = p[n:]
+
+		due := t.start.Add(time.Duration(float64(t.written) / float64(t.rate) * float64(time.Second)))
+		if wait := time.Until(due); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-t.ctx.Done():
+				timer.Stop()
+				return total, t.ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+	return total, nil
+}
+
+func (t *throttledWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
+
+// downloadRequest returns the proto-encoded request of a GET download, passed
+// as unpadded base64url in the "request" query parameter.
+func downloadRequest(r *http.Request) ([]byte, *RpcError) {
+	encoded := r.URL.Query().Get("request")
+	if encoded == "" {
+		return nil, nil
+	}
+	request, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrValidation("request must be unpadded base64url")
+	}
+	return request, nil
+}
@@ -0,0 +1,39 @@
+package gapp
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// RenderNotFound renders the configured NotFound route with a 404 status,
+// falling back to a plain text response when none is configured.
+// Apps can call it from their own handlers for unknown paths.
+func (p *PreloadEngine) RenderNotFound(w http.ResponseWriter, r *http.Request) {
+	if p.notFound == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	p.renderStatusPage(ctx, w, r, p.notFound, http.StatusNotFound)
+}
+
+// renderError renders the configured Error route with a 500 status,
+// falling back to a plain text response when none is configured.
+func (p *PreloadEngine) renderError(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	if p.errorRoute == nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	p.renderStatusPage(ctx, w, r, p.errorRoute, http.StatusInternalServerError)
+}
+
+// renderStatusPage runs the preloads of a status route and renders it with
+// the given status. The route's Pattern is not matched against the path.
+func (p *PreloadEngine) renderStatusPage(ctx context.Context, w http.ResponseWriter, r *http.Request, route *RouteSpec, status int) {
+	result := p.executeForRoute(ctx, r, route, nil)
+	p.renderHTML(ctx, w, r, result, p.resolveMeta(r, result), status)
+}
@@ -2,6 +2,7 @@ package gapp
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 )
 
@@ -13,6 +14,8 @@ const (
 	CodeUnauthenticated = "UNAUTHENTICATED"
 	CodePermissionDenied = "PERMISSION_DENIED"
 	CodeRateLimited     = "RATE_LIMITED"
+	CodePayloadTooLarge = "PAYLOAD_TOO_LARGE"
+	CodeDeadlineExceeded = "DEADLINE_EXCEEDED"
 	CodeInternal        = "INTERNAL"
 )
 
@@ -60,6 +63,14 @@ func ErrRateLimited(msg string) *RpcError {
 	return &RpcError{Code: CodeRateLimited, Message: msg}
 }
 
+func ErrPayloadTooLarge(msg string) *RpcError {
+	return &RpcError{Code: CodePayloadTooLarge, Message: msg}
+}
+
+func ErrDeadlineExceeded(msg string) *RpcError {
+	return &RpcError{Code: CodeDeadlineExceeded, Message: msg}
+}
+
 func ErrInternal(msg string) *RpcError {
 	return &RpcError{Code: CodeInternal, Message: msg}
 }
@@ -78,11 +89,25 @@ func httpStatusForCode(code string) int {
 		return http.StatusForbidden
 	case CodeRateLimited:
 		return http.StatusTooManyRequests
+	case CodePayloadTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case CodeDeadlineExceeded:
+		return http.StatusGatewayTimeout
 	default:
 		return http.StatusInternalServerError
 	}
 }
 
+// asRpcError returns err as an RpcError, hiding errors that aren't RpcErrors
+// behind a generic internal error.
+func asRpcError(err error) *RpcError {
+	var rpcErr *RpcError
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	return ErrInternal("Internal server error")
+}
+
 func writeRpcError(w http.ResponseWriter, rpcErr *RpcError) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(httpStatusForCode(rpcErr.Code))
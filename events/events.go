@@ -0,0 +1,205 @@
+// Package events is an in-process event bus for decoupling side effects from
+// request handling. Events are proto messages, delivered to the handlers
+// subscribed to their type:
+//
+//	events.Subscribe(func(ctx context.Context, e *pb.UserSignedUp) error {
+//		return mailer.SendWelcome(ctx, e.Email)
+//	}, events.Async())
+//	...
+//	events.Emit(r.Context(), &pb.UserSignedUp{Email: user.Email})
+//	...
+//	gapp.ListenAndServeWithOptions(addr, mux, gapp.OnShutdown(events.Default().Shutdown))
+//
+// Events live only in memory; use the jobs package for work that must
+// survive a restart.
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Handler handles events of type T.
+type Handler[T proto.Message] func(ctx context.Context, event T) error
+
+// SubscribeOption configures a subscription.
+type SubscribeOption func(*subscription)
+
+// Async delivers events to the handler in a new goroutine, so Emit doesn't
+// wait for it and its error doesn't reach the emitter. The handler's context
+// keeps the emitter's values but isn't cancelled with it, since the request
+// that emitted the event has usually finished by the time it runs.
+func Async() SubscribeOption {
+	return func(s *subscription) {
+		s.async = true
+	}
+}
+
+type subscription struct {
+	handle func(ctx context.Context, event proto.Message) error
+	async  bool
+}
+
+// Option configures a Bus.
+type Option func(*Bus)
+
+// WithLogger sets the logger for failed async handlers. Defaults to
+// slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(b *Bus) {
+		b.logger = logger
+	}
+}
+
+// Bus delivers emitted events to subscribers.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]*subscription
+	logger   *slog.Logger
+
+	closeMu sync.RWMutex
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+var defaultBus = New()
+
+// Default returns the process-wide Bus used by Subscribe and Emit.
+func Default() *Bus {
+	return defaultBus
+}
+
+// New creates an empty Bus.
+func New(opts ...Option) *Bus {
+	b := &Bus{
+		handlers: make(map[string][]*subscription),
+		logger:   slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Subscribe registers handler for events of type T on the default bus and
+// returns a function that removes it. Handlers run synchronously unless Async
+// is given.
+func Subscribe[T proto.Message](handler Handler[T], opts ...SubscribeOption) (unsubscribe func()) {
+	return SubscribeOn(defaultBus, handler, opts...)
+}
+
+// SubscribeOn is Subscribe on the given bus.
+func SubscribeOn[T proto.Message](b *Bus, handler Handler[T], opts ...SubscribeOption) (unsubscribe func()) {
+	var zero T
+	kind := string(zero.ProtoReflect().Descriptor().FullName())
+
+	sub := &subscription{
+		handle: func(ctx context.Context, event proto.Message) error {
+			return handler(ctx, event.(T))
+		},
+	}
+	for _, opt := range opts {
+		opt(sub)
+	}
+
+	b.mu.Lock()
+	b.handlers[kind] = append(b.handlers[kind], sub)
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		subs := b.handlers[kind]
+		for i, s := range subs {
+			if s == sub {
+				b.handlers[kind] = append(subs[:i:i], subs[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Emit publishes event on the default bus. See (*Bus).Emit.
+func Emit(ctx context.Context, event proto.Message) error {
+	return defaultBus.Emit(ctx, event)
+}
+
+// Emit delivers event to its type's handlers in subscription order. It runs
+// the synchronous handlers before returning and returns their errors; a
+// failing handler doesn't stop the others. Async handlers are started in the
+// background and their errors are logged.
+func (b *Bus) Emit(ctx context.Context, event proto.Message) error {
+	kind := string(event.ProtoReflect().Descriptor().FullName())
+
+	b.mu.RLock()
+	subs := b.handlers[kind]
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, sub := range subs {
+		if sub.async && b.startAsync() {
+			go func() {
+				defer b.wg.Done()
+				if err := safeHandle(context.WithoutCancel(ctx), sub, event); err != nil {
+					b.logger.Error("Event handler failed", "event", kind, "error", err)
+				}
+			}()
+			continue
+		}
+		if err := safeHandle(ctx, sub, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s handler: %w", kind, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown waits for running async handlers to finish, or for ctx to expire.
+// Events emitted afterwards are delivered synchronously. Its signature
+// matches gapp.OnShutdown; register it there rather than with gapp.OnDrain so
+// requests that finish during the drain can still emit.
+func (b *Bus) Shutdown(ctx context.Context) error {
+	b.closeMu.Lock()
+	b.closed = true
+	b.closeMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// startAsync reserves a background delivery. After Shutdown nothing waits
+// for background handlers, so it returns false and they run inline instead
+// of being lost.
+func (b *Bus) startAsync() bool {
+	b.closeMu.RLock()
+	defer b.closeMu.RUnlock()
+	if b.closed {
+		return false
+	}
+	b.wg.Add(1)
+	return true
+}
+
+// safeHandle runs the subscription's handler, turning a panic into an error.
+func safeHandle(ctx context.Context, sub *subscription, event proto.Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return sub.handle(ctx, event)
+}
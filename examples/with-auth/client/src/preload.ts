@@ -3,6 +3,7 @@ import {
   GetItemsRequest,
   GetItemsResponse,
 } from "./generated/service";
+import { SCHEMA_HASH } from "./generated/gapp_schema";
 
 const requestDecoders: DecoderMap = {
   GetItems: (reader) => GetItemsRequest.decode(reader),
@@ -13,5 +14,7 @@ const responseDecoders: DecoderMap = {
 };
 
 export function decodePreloaded() {
-  return decodeAllPreloaded(requestDecoders, responseDecoders);
+  return decodeAllPreloaded(requestDecoders, responseDecoders, {
+    schemaHash: SCHEMA_HASH,
+  });
 }
@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"crypto/rand"
-	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -24,13 +23,14 @@ type App struct {
 }
 
 func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	config, err := gapp.LoadConfig("")
+	if err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
 	}
 
 	// --- Auth setup ---
-	dataRoot := os.Getenv("DATA_ROOT")
+	dataRoot := config.DataRoot
 	if dataRoot == "" {
 		home, _ := os.UserHomeDir()
 		dataRoot = filepath.Join(home, ".with-auth-example")
@@ -50,14 +50,15 @@ func main() {
 	}
 
 	app := &App{}
-	dispatcher := gapp.NewDispatcher()
+	dispatcher := gapp.NewDispatcher(config.DispatcherOptions()...)
 
 	// Auth middleware: validate token on every request, store in context if valid.
 	// Uses gapp.AuthMiddleware which accepts any func(r) -> any.
-	dispatcher.Use(gapp.AuthMiddleware(func(r *http.Request) any {
+	validate := func(r *http.Request) any {
 		token, _ := siauth.ValidateAuthToken(r, auth)
 		return token // nil if not authenticated — that's fine
-	}))
+	}
+	dispatcher.Use(gapp.AuthMiddleware(validate))
 
 	// Public handler — anyone can read items
 	dispatcher.Unary["GetItems"] = func(w http.ResponseWriter, r *http.Request, method string, body []byte) ([]byte, error) {
@@ -94,7 +95,10 @@ func main() {
 	)
 
 	preload := gapp.NewPreloadEngine(gapp.PreloadEngineConfig{
-		Routes: pb.RoutePreloads,
+		Routes:       pb.RoutePreloads,
+		SchemaHash:   pb.SchemaHash,
+		AppName:      config.AppName,
+		ManifestPath: config.ManifestPath,
 		PreloadFunc: func(ctx context.Context, r *http.Request, method string, params map[string]string) (proto.Message, proto.Message, error) {
 			body, err := dispatcher.Unary[method](nil, r, method, nil)
 			if err != nil {
@@ -116,12 +120,17 @@ func main() {
 
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
-	})
+	// Health checks: /livez, /readyz and /health
+	gapp.Health().Register("preload", preload.HealthCheck)
+	gapp.Health().Mount(mux)
 
-	mux.Handle("/assets/", http.StripPrefix("/assets/", http.FileServer(http.Dir("public/assets"))))
+	assets := gapp.AssetHandlerFS(config.PublicFS())
+	mux.Handle("/assets/", assets)
+
+	// Service worker and web app manifest generated by `gapp build --pwa`
+	for _, file := range []string{"/sw.js", "/manifest.webmanifest", "/icon-192x192.png", "/icon-512x512.png"} {
+		mux.Handle(file, assets)
+	}
 
 	// Auth RPC endpoint — siauth has its own internal RPC dispatcher
 	mux.HandleFunc("/rpc/auth", authServer.HandleRpc)
@@ -130,11 +139,21 @@ func main() {
 	mux.Handle("/rpc", dispatcher)
 
 	mux.HandleFunc("/__preload", preload.HandlePreloadEndpoint)
+
+	// Dispatcher and preload introspection, for signed-in users only
+	mux.Handle("/__admin", gapp.AdminHandler(gapp.AdminConfig{
+		Dispatcher: dispatcher,
+		Preload:    preload,
+		Validate:   validate,
+	}))
 	mux.HandleFunc("/", preload.ServeHTML)
 
-	addr := ":" + port
-	slog.Info("Server starting", "url", "http://localhost:"+port)
-	if err := gapp.ListenAndServe(addr, mux); err != http.ErrServerClosed {
+	scheme := "http"
+	if config.TLSCert != "" {
+		scheme = "https"
+	}
+	slog.Info("Server starting", "url", fmt.Sprintf("%s://localhost:%d", scheme, config.Port))
+	if err := gapp.ListenAndServeWithOptions(config.ListenAddr(), mux, config.ServeOptions()...); err != http.ErrServerClosed {
 		slog.Error("Server error", "error", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,153 @@
+// Package gaptest calls a gapp Dispatcher in memory, for testing RPC
+// handlers without an HTTP server. Calls go through the Dispatcher's
+// middleware like real requests:
+//
+//	client := gaptest.NewClient(dispatcher).WithAuthToken(&Session{UserID: "42"})
+//
+//	resp, err := gaptest.Call[*pb.CreateItemRequest, *pb.CreateItemResponse](
+//		client, "CreateItem", &pb.CreateItemRequest{Title: "Milk"})
+//
+//	orders, err := gaptest.Stream[*pb.WatchOrdersRequest, *pb.Order](
+//		client, "WatchOrders", &pb.WatchOrdersRequest{})
+//
+// Errors returned by handlers come back as *gapp.RpcError. RenderPage renders
+// a PreloadEngine's pages for checking preloads and comparing HTML against
+// golden files.
+package gaptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/germtb/gapp"
+	"google.golang.org/protobuf/proto"
+)
+
+// Client sends requests to a Dispatcher in memory.
+type Client struct {
+	dispatcher *gapp.Dispatcher
+	header     http.Header
+	token      any
+}
+
+// NewClient creates a Client for d.
+func NewClient(d *gapp.Dispatcher) *Client {
+	return &Client{dispatcher: d, header: make(http.Header)}
+}
+
+// WithAuthToken returns a copy of the client whose requests carry token, as
+// if an AuthMiddleware had validated them. Handlers read it with
+// gapp.GetAuthToken and gapp.RequireAuth accepts it.
+func (c *Client) WithAuthToken(token any) *Client {
+	clone := c.clone()
+	clone.token = token
+	return clone
+}
+
+// WithHeader returns a copy of the client that sets a header on its
+// requests, for middleware that reads cookies or API keys, or to resume a
+// stream with X-Rpc-Resume-Token.
+func (c *Client) WithHeader(key, value string) *Client {
+	clone := c.clone()
+	clone.header.Set(key, value)
+	return clone
+}
+
+func (c *Client) clone() *Client {
+	return &Client{dispatcher: c.dispatcher, header: c.header.Clone(), token: c.token}
+}
+
+// Do sends body to method and returns the recorded response, for checks the
+// typed helpers don't cover, like response headers or uploads.
+func (c *Client) Do(method string, body []byte) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/rpc", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-protobuf")
+	r.Header.Set("X-Rpc-Method", method)
+	for key, values := range c.header {
+		r.Header[key] = values
+	}
+	if c.token != nil {
+		r = gapp.SetAuthToken(r, c.token)
+	}
+
+	rec := httptest.NewRecorder()
+	c.dispatcher.ServeHTTP(rec, r)
+	return rec
+}
+
+// Call makes a unary call to method and decodes its response.
+func Call[Req, Resp proto.Message](c *Client, method string, req Req) (Resp, error) {
+	var resp Resp
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return resp, fmt.Errorf("marshaling %s request: %w", method, err)
+	}
+
+	rec := c.Do(method, body)
+	if rec.Code != http.StatusOK {
+		return resp, responseError(rec)
+	}
+
+	resp = resp.ProtoReflect().New().Interface().(Resp)
+	if err := proto.Unmarshal(rec.Body.Bytes(), resp); err != nil {
+		return resp, fmt.Errorf("decoding %s response: %w", method, err)
+	}
+	return resp, nil
+}
+
+// Stream makes a streaming call to method and returns the messages sent
+// before the handler returned. If the stream ended with an error, the
+// messages before it are returned along with the error.
+func Stream[Req, Resp proto.Message](c *Client, method string, req Req) ([]Resp, error) {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling %s request: %w", method, err)
+	}
+	return Messages[Resp](c.Do(method, body))
+}
+
+// Messages decodes the stream recorded by rec, such as a StreamHandler's
+// response written to an httptest.ResponseRecorder. Keepalives and resume
+// tokens are skipped, and compressed frames are decompressed.
+func Messages[T proto.Message](rec *httptest.ResponseRecorder) ([]T, error) {
+	if rec.Code != http.StatusOK {
+		return nil, responseError(rec)
+	}
+
+	opts := []gapp.MessageReaderOption{gapp.WithDecompression(rec.Header().Get("X-Stream-Compression"))}
+	if rec.Header().Get("X-Stream-Heartbeat") != "" {
+		opts = append(opts, gapp.SkipKeepalives())
+	}
+	reader := gapp.NewMessageReader(rec.Body.Bytes(), opts...)
+
+	var zero T
+	var messages []T
+	for {
+		data, err := reader.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return messages, nil
+			}
+			return messages, err
+		}
+		msg := zero.ProtoReflect().New().Interface().(T)
+		if err := proto.Unmarshal(data, msg); err != nil {
+			return messages, fmt.Errorf("decoding stream message %d: %w", len(messages), err)
+		}
+		messages = append(messages, msg)
+	}
+}
+
+// responseError decodes the RpcError of a failed response.
+func responseError(rec *httptest.ResponseRecorder) error {
+	var rpcErr gapp.RpcError
+	if err := json.Unmarshal(rec.Body.Bytes(), &rpcErr); err != nil || rpcErr.Code == "" {
+		return fmt.Errorf("unexpected status %d: %s", rec.Code, bytes.TrimSpace(rec.Body.Bytes()))
+	}
+	return &rpcErr
+}
@@ -0,0 +1,184 @@
+package gaptest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/germtb/gapp"
+	"google.golang.org/protobuf/proto"
+)
+
+// PreloadType names the request and response messages of a preloaded method.
+type PreloadType struct {
+	Request  proto.Message
+	Response proto.Message
+}
+
+// DispatcherPreloadFunc returns a PreloadFunc that runs preloads against d's
+// unary handlers, the way the scaffolded server does, so a PreloadEngine can
+// be tested against fake handlers:
+//
+//	d := gapp.NewDispatcher()
+//	d.Unary["GetItems"] = fakeGetItems
+//	engine := gapp.NewPreloadEngine(gapp.PreloadEngineConfig{
+//		Routes: pb.RoutePreloads,
+//		PreloadFunc: gaptest.DispatcherPreloadFunc(d, map[string]gaptest.PreloadType{
+//			"GetItems": {Request: &pb.GetItemsRequest{}, Response: &pb.GetItemsResponse{}},
+//		}),
+//	})
+//
+// Requests are sent empty, since route params aren't part of them.
+func DispatcherPreloadFunc(d *gapp.Dispatcher, types map[string]PreloadType) gapp.PreloadFunc {
+	return func(ctx context.Context, r *http.Request, method string, params map[string]string) (proto.Message, proto.Message, error) {
+		t, ok := types[method]
+		if !ok {
+			return nil, nil, fmt.Errorf("no PreloadType for %s", method)
+		}
+		handler, ok := d.Unary[method]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown preload method: %s", method)
+		}
+
+		req := t.Request.ProtoReflect().New().Interface()
+		body, err := proto.Marshal(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := handler(httptest.NewRecorder(), r.WithContext(ctx), method, body)
+		if err != nil {
+			return nil, nil, err
+		}
+		resp := t.Response.ProtoReflect().New().Interface()
+		if err := proto.Unmarshal(data, resp); err != nil {
+			return nil, nil, err
+		}
+		return req, resp, nil
+	}
+}
+
+// Page is an HTML page rendered by a PreloadEngine.
+type Page struct {
+	Status    int
+	Header    http.Header
+	HTML      string
+	Preloaded map[string]gapp.PreloadedRpc // hydration payload, keyed by method
+}
+
+var (
+	preloadedPattern = regexp.MustCompile(`window\.__PRELOADED__ = (.*);\n`)
+	timestampPattern = regexp.MustCompile(`window\.__PRELOAD_TIMESTAMP__ = \s*\d+\s*;`)
+)
+
+// RenderPage serves path with engine.ServeHTML and parses the hydration
+// payload out of the page. Auth tokens and headers are taken from c, which
+// may be nil.
+func RenderPage(engine *gapp.PreloadEngine, c *Client, path string) (*Page, error) {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	if c != nil {
+		for key, values := range c.header {
+			r.Header[key] = values
+		}
+		if c.token != nil {
+			r = gapp.SetAuthToken(r, c.token)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTML(rec, r)
+
+	page := &Page{
+		Status:    rec.Code,
+		Header:    rec.Header(),
+		HTML:      rec.Body.String(),
+		Preloaded: make(map[string]gapp.PreloadedRpc),
+	}
+	if match := preloadedPattern.FindStringSubmatch(page.HTML); match != nil {
+		if err := json.Unmarshal([]byte(match[1]), &page.Preloaded); err != nil {
+			return page, fmt.Errorf("decoding preloaded data: %w", err)
+		}
+	}
+	return page, nil
+}
+
+// Normalized returns the page's HTML with the preload timestamp zeroed, so it
+// can be compared against a golden file.
+func (p *Page) Normalized() []byte {
+	return timestampPattern.ReplaceAll([]byte(p.HTML), []byte("window.__PRELOAD_TIMESTAMP__ = 0;"))
+}
+
+// PreloadedResponse decodes the preloaded response of method.
+func PreloadedResponse[T proto.Message](page *Page, method string) (T, error) {
+	var msg T
+	rpc, ok := page.Preloaded[method]
+	if !ok {
+		return msg, fmt.Errorf("%s was not preloaded", method)
+	}
+	msg = msg.ProtoReflect().New().Interface().(T)
+	return msg, DecodeProtoBytes(rpc.ResponseBytes, msg)
+}
+
+// PreloadedRequest decodes the request method was preloaded with.
+func PreloadedRequest[T proto.Message](page *Page, method string) (T, error) {
+	var msg T
+	rpc, ok := page.Preloaded[method]
+	if !ok {
+		return msg, fmt.Errorf("%s was not preloaded", method)
+	}
+	msg = msg.ProtoReflect().New().Interface().(T)
+	return msg, DecodeProtoBytes(rpc.RequestBytes, msg)
+}
+
+// DecodeProtoBytes reverses gapp.ToProtoBytes, decoding base64 gzipped proto
+// bytes into msg.
+func DecodeProtoBytes(encoded string, msg proto.Message) error {
+	if encoded == "" {
+		return nil
+	}
+	compressed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("decoding base64: %w", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return fmt.Errorf("decompressing: %w", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return fmt.Errorf("decompressing: %w", err)
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+// AssertGolden fails t if got differs from the golden file at path. Run the
+// tests with GAPTEST_UPDATE=1 to write the golden files instead.
+func AssertGolden(t testing.TB, path string, got []byte) {
+	t.Helper()
+	if os.Getenv("GAPTEST_UPDATE") != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (run with GAPTEST_UPDATE=1 to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (run with GAPTEST_UPDATE=1 to update it)\n--- got\n%s\n--- want\n%s", path, got, want)
+	}
+}
@@ -0,0 +1,73 @@
+package gaptest
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/germtb/gapp"
+)
+
+// ReplayResult compares a recorded call with its replay.
+type ReplayResult struct {
+	Call     gapp.RecordedCall
+	Response []byte
+	Error    *gapp.RpcError
+}
+
+// Matches reports whether the replay returned the recorded response, or
+// failed with the recorded error code.
+func (r ReplayResult) Matches() bool {
+	if r.Call.Error != nil || r.Error != nil {
+		return r.Call.Error != nil && r.Error != nil && r.Call.Error.Code == r.Error.Code
+	}
+	return bytes.Equal(r.Call.Response, r.Response)
+}
+
+// Replay re-sends recorded calls, from gapp.ReadRecording, through c in
+// order, with their recorded headers. Auth tokens aren't recorded; set them
+// on c with WithAuthToken.
+func Replay(c *Client, calls []gapp.RecordedCall) []ReplayResult {
+	results := make([]ReplayResult, 0, len(calls))
+	for _, call := range calls {
+		client := c
+		for key, value := range call.Header {
+			client = client.WithHeader(key, value)
+		}
+
+		result := ReplayResult{Call: call}
+		rec := client.Do(call.Method, call.Request)
+		if rec.Code == http.StatusOK {
+			result.Response = rec.Body.Bytes()
+		} else if err, ok := responseError(rec).(*gapp.RpcError); ok {
+			result.Error = err
+		} else {
+			result.Error = gapp.ErrInternal(rec.Body.String())
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// AssertReplay replays the recording at path and fails t for each call whose
+// result changed, turning recorded traffic into a regression test.
+func AssertReplay(t testing.TB, c *Client, path string) {
+	t.Helper()
+	calls, err := gapp.ReadRecording(path)
+	if err != nil {
+		t.Fatalf("reading recording: %v", err)
+	}
+	for i, result := range Replay(c, calls) {
+		if result.Matches() {
+			continue
+		}
+		switch {
+		case result.Error != nil:
+			t.Errorf("call %d (%s) failed with %v, recorded %v", i, result.Call.Method, result.Error, result.Call.Error)
+		case result.Call.Error != nil:
+			t.Errorf("call %d (%s) succeeded, recorded %v", i, result.Call.Method, result.Call.Error)
+		default:
+			t.Errorf("call %d (%s) returned a different response", i, result.Call.Method)
+		}
+	}
+}
@@ -1,5 +1,12 @@
 module github.com/germtb/gapp
 
-go 1.24
+go 1.24.0
 
-require google.golang.org/protobuf v1.36.5
+require (
+	github.com/BurntSushi/toml v1.5.0
+	golang.org/x/crypto v0.43.0
+	golang.org/x/net v0.45.0
+	google.golang.org/protobuf v1.36.5
+)
+
+require golang.org/x/text v0.30.0 // indirect
@@ -0,0 +1,177 @@
+package gapp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HealthChecker reports whether a dependency is usable. Signatures like
+// (*sql.DB).PingContext can be registered directly.
+type HealthChecker func(ctx context.Context) error
+
+// HealthChecks tracks the readiness checks and draining state of the server.
+// Liveness only reports that the process is serving; readiness also requires
+// every registered check to pass and the server not to be shutting down.
+type HealthChecks struct {
+	mu       sync.RWMutex
+	names    []string
+	checks   map[string]HealthChecker
+	draining atomic.Bool
+
+	// Timeout bounds each check. Defaults to 2s.
+	Timeout time.Duration
+}
+
+var defaultHealth = NewHealthChecks()
+
+// Health returns the process-wide HealthChecks. ListenAndServe marks it as
+// draining when a shutdown signal is received.
+func Health() *HealthChecks {
+	return defaultHealth
+}
+
+// NewHealthChecks creates an empty set of health checks.
+func NewHealthChecks() *HealthChecks {
+	return &HealthChecks{checks: make(map[string]HealthChecker), Timeout: 2 * time.Second}
+}
+
+// Register adds a readiness check, replacing any check with the same name.
+func (h *HealthChecks) Register(name string, check HealthChecker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.checks[name]; !ok {
+		h.names = append(h.names, name)
+	}
+	h.checks[name] = check
+}
+
+// SetDraining marks the server as shutting down, failing readiness so load
+// balancers stop routing new traffic while in-flight requests finish.
+func (h *HealthChecks) SetDraining(draining bool) {
+	h.draining.Store(draining)
+}
+
+// Draining reports whether the server is shutting down.
+func (h *HealthChecks) Draining() bool {
+	return h.draining.Load()
+}
+
+// HealthReport is the JSON body served by the readiness endpoint.
+type HealthReport struct {
+	Status string            `json:"status"` // "ok", "draining" or "unavailable"
+	Checks map[string]string `json:"checks,omitempty"`
+	Build  *BuildMetadata    `json:"build,omitempty"` // BuildInfo, when known
+}
+
+// Check runs every registered check concurrently and reports the result.
+// Checks still running after Timeout fail with context.DeadlineExceeded.
+func (h *HealthChecks) Check(ctx context.Context) HealthReport {
+	h.mu.RLock()
+	names := append([]string(nil), h.names...)
+	checks := make([]HealthChecker, len(names))
+	for i, name := range names {
+		checks[i] = h.checks[name]
+	}
+	h.mu.RUnlock()
+
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Checks that ignore ctx are abandoned at the deadline rather than
+	// holding up the report
+	type result struct {
+		i   int
+		err error
+	}
+	done := make(chan result, len(checks))
+	for i, check := range checks {
+		go func() {
+			done <- result{i, check(ctx)}
+		}()
+	}
+	results := make([]error, len(checks))
+	finished := make([]bool, len(checks))
+wait:
+	for range checks {
+		select {
+		case res := <-done:
+			results[res.i] = res.err
+			finished[res.i] = true
+		case <-ctx.Done():
+			for i := range results {
+				if !finished[i] {
+					results[i] = ctx.Err()
+				}
+			}
+			break wait
+		}
+	}
+
+	report := HealthReport{Status: "ok"}
+	if !BuildInfo.IsZero() {
+		build := BuildInfo
+		report.Build = &build
+	}
+	if len(names) > 0 {
+		report.Checks = make(map[string]string, len(names))
+	}
+	for i, name := range names {
+		if err := results[i]; err != nil {
+			report.Checks[name] = err.Error()
+			report.Status = "unavailable"
+		} else {
+			report.Checks[name] = "ok"
+		}
+	}
+	if h.Draining() {
+		report.Status = "draining"
+	}
+	return report
+}
+
+// LivezHandler responds 200 as long as the process can serve requests.
+func (h *HealthChecks) LivezHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, HealthReport{Status: "ok"})
+	})
+}
+
+// ReadyzHandler responds 200 when every check passes and the server isn't
+// draining, and 503 otherwise.
+func (h *HealthChecks) ReadyzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, h.Check(r.Context()))
+	})
+}
+
+// Mount registers /livez, /readyz and /health (an alias of /readyz) on mux.
+func (h *HealthChecks) Mount(mux *http.ServeMux) {
+	mux.Handle("/livez", h.LivezHandler())
+	mux.Handle("/readyz", h.ReadyzHandler())
+	mux.Handle("/health", h.ReadyzHandler())
+}
+
+func writeHealthReport(w http.ResponseWriter, report HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// HealthCheck is a preload self-test for Health().Register: it renders the
+// page template with no preloaded data, catching broken custom templates.
+func (p *PreloadEngine) HealthCheck(ctx context.Context) error {
+	data := p.templateData(nil, map[string]PreloadedRpc{}, p.defaultMeta)
+	return p.tmpl.Execute(io.Discard, data)
+}
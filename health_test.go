@@ -0,0 +1,37 @@
+package gapp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHealthCheckTimeout(t *testing.T) {
+	h := NewHealthChecks()
+	h.Timeout = 20 * time.Millisecond
+	release := make(chan struct{})
+	defer close(release)
+	h.Register("db", func(ctx context.Context) error { return nil })
+	h.Register("cache", func(ctx context.Context) error { return errors.New("connection refused") })
+	// Ignores its context, like a client without deadline support
+	h.Register("queue", func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+
+	start := time.Now()
+	report := h.Check(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Check took %s with a %s timeout", elapsed, h.Timeout)
+	}
+	if report.Status != "unavailable" {
+		t.Errorf("Status = %s, want unavailable", report.Status)
+	}
+	want := map[string]string{"db": "ok", "cache": "connection refused", "queue": context.DeadlineExceeded.Error()}
+	for name, status := range want {
+		if report.Checks[name] != status {
+			t.Errorf("Checks[%s] = %q, want %q", name, report.Checks[name], status)
+		}
+	}
+}
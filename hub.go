@@ -0,0 +1,353 @@
+package gapp
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+	"google.golang.org/protobuf/proto"
+)
+
+// TopicAuthFunc decides whether the client that opened the WebSocket may
+// subscribe to topic. Return an *RpcError (e.g. ErrPermissionDenied) to send
+// its code to the client; any other error is reported as permission denied.
+type TopicAuthFunc func(r *http.Request, topic string) error
+
+// HubConfig configures a Hub.
+type HubConfig struct {
+	// Authorize checks every subscription. Defaults to allowing all topics,
+	// so apps with private topics must set it.
+	Authorize TopicAuthFunc
+
+	// AllowedOrigins lists the browser origins that may connect, as
+	// scheme://host[:port]. Defaults to the server's own origin, which
+	// prevents other sites from subscribing with the user's cookies.
+	AllowedOrigins []string
+
+	SendBuffer   int           // messages queued per connection before it's dropped as too slow, default 64
+	PingInterval time.Duration // keepalive interval, default 30s
+	Logger       *slog.Logger  // defaults to slog.Default()
+}
+
+// Hub fans out published proto messages to browsers subscribed to a topic
+// over a WebSocket. Mount it on a path such as /ws and subscribe with the
+// client's createHubClient:
+//
+//	hub := gapp.NewHub(gapp.HubConfig{Authorize: canSubscribe})
+//	mux.Handle("/ws", hub)
+//	...
+//	hub.Publish("orders/"+order.Id, order)
+//
+// Messages are delivered at most once; clients that reconnect refetch
+// whatever they may have missed.
+type Hub struct {
+	config HubConfig
+	server websocket.Server
+
+	mu     sync.RWMutex
+	topics map[string]map[*hubConn]struct{}
+	conns  map[*hubConn]struct{}
+	closed bool
+}
+
+type hubConn struct {
+	ws        *websocket.Conn
+	send      chan hubFrame
+	done      chan struct{}
+	closeOnce sync.Once
+	topics    map[string]struct{} // guarded by Hub.mu
+}
+
+type hubFrame struct {
+	binary []byte // a published message
+	text   []byte // a JSON control message
+}
+
+// hubControl is a JSON control message. Clients send "subscribe" and
+// "unsubscribe"; the hub replies with "subscribed" or "error", broadcasts
+// "invalidate" and sends "ping" as a keepalive.
+type hubControl struct {
+	Op      string   `json:"op"`
+	Topic   string   `json:"topic,omitempty"`
+	Code    string   `json:"code,omitempty"`
+	Message string   `json:"message,omitempty"`
+	Methods []string `json:"methods,omitempty"`
+}
+
+// NewHub creates a Hub.
+func NewHub(config HubConfig) *Hub {
+	if config.SendBuffer <= 0 {
+		config.SendBuffer = 64
+	}
+	if config.PingInterval <= 0 {
+		config.PingInterval = 30 * time.Second
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+
+	h := &Hub{
+		config: config,
+		topics: make(map[string]map[*hubConn]struct{}),
+		conns:  make(map[*hubConn]struct{}),
+	}
+	h.server = websocket.Server{Handshake: h.checkOrigin, Handler: h.serveConn}
+
+	liveHubs.mu.Lock()
+	liveHubs.set[h] = struct{}{}
+	liveHubs.mu.Unlock()
+	return h
+}
+
+// ServeHTTP upgrades the request to a WebSocket and serves subscriptions on it.
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.server.ServeHTTP(w, r)
+}
+
+// Publish sends msg to every client subscribed to topic. Clients too slow to
+// keep up are disconnected rather than blocking the publisher.
+func (h *Hub) Publish(topic string, msg proto.Message) error {
+	if len(topic) > math.MaxUint16 {
+		return errors.New("gapp: hub topic too long")
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	frame := hubFrame{binary: encodeHubMessage(topic, data)}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for c := range h.topics[topic] {
+		select {
+		case c.send <- frame:
+		default:
+			h.config.Logger.Warn("Hub client too slow, disconnecting", "topic", topic)
+			c.drop()
+		}
+	}
+	return nil
+}
+
+// Subscribers returns the number of connections subscribed to topic.
+func (h *Hub) Subscribers(topic string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.topics[topic])
+}
+
+// Shutdown closes every connection and rejects new ones. Its signature
+// matches OnDrain, so open WebSockets don't hold up graceful shutdown.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	liveHubs.mu.Lock()
+	delete(liveHubs.set, h)
+	liveHubs.mu.Unlock()
+
+	h.mu.Lock()
+	h.closed = true
+	conns := make([]*hubConn, 0, len(h.conns))
+	for c := range h.conns {
+		conns = append(conns, c)
+	}
+	h.mu.Unlock()
+
+	for _, c := range conns {
+		c.close()
+	}
+	return nil
+}
+
+func (h *Hub) checkOrigin(config *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// Not a browser, so there are no ambient cookies to abuse
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	config.Origin = u
+
+	if len(h.config.AllowedOrigins) == 0 {
+		if u.Host != r.Host {
+			return errors.New("cross-origin WebSocket rejected")
+		}
+		return nil
+	}
+	if !slices.Contains(h.config.AllowedOrigins, origin) {
+		return errors.New("WebSocket origin not allowed")
+	}
+	return nil
+}
+
+func (h *Hub) serveConn(ws *websocket.Conn) {
+	ws.PayloadType = websocket.BinaryFrame
+	c := &hubConn{
+		ws:     ws,
+		send:   make(chan hubFrame, h.config.SendBuffer),
+		done:   make(chan struct{}),
+		topics: make(map[string]struct{}),
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		ws.Close()
+		return
+	}
+	h.conns[c] = struct{}{}
+	h.mu.Unlock()
+
+	defer h.remove(c)
+	go h.writeLoop(c)
+
+	r := ws.Request()
+	for {
+		var raw string
+		if err := websocket.Message.Receive(ws, &raw); err != nil {
+			return
+		}
+		var msg hubControl
+		if err := json.Unmarshal([]byte(raw), &msg); err != nil || msg.Topic == "" {
+			h.reply(c, hubControl{Op: "error", Code: CodeValidationError, Message: "invalid control message"})
+			continue
+		}
+
+		switch msg.Op {
+		case "subscribe":
+			if err := h.authorize(r, msg.Topic); err != nil {
+				h.reply(c, hubControl{Op: "error", Topic: msg.Topic, Code: err.Code, Message: err.Message})
+				continue
+			}
+			h.subscribe(c, msg.Topic)
+			h.reply(c, hubControl{Op: "subscribed", Topic: msg.Topic})
+		case "unsubscribe":
+			h.unsubscribe(c, msg.Topic)
+		default:
+			h.reply(c, hubControl{Op: "error", Topic: msg.Topic, Code: CodeValidationError, Message: "unknown op " + msg.Op})
+		}
+	}
+}
+
+func (h *Hub) authorize(r *http.Request, topic string) *RpcError {
+	if h.config.Authorize == nil {
+		return nil
+	}
+	err := h.config.Authorize(r, topic)
+	if err == nil {
+		return nil
+	}
+	var rpcErr *RpcError
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	return ErrPermissionDenied("not allowed to subscribe to " + topic)
+}
+
+func (h *Hub) writeLoop(c *hubConn) {
+	ticker := time.NewTicker(h.config.PingInterval)
+	defer ticker.Stop()
+
+	ping, _ := json.Marshal(hubControl{Op: "ping"})
+	for {
+		var err error
+		select {
+		case <-c.done:
+			return
+		case frame := <-c.send:
+			if frame.binary != nil {
+				err = websocket.Message.Send(c.ws, frame.binary)
+			} else {
+				err = websocket.Message.Send(c.ws, string(frame.text))
+			}
+		case <-ticker.C:
+			err = websocket.Message.Send(c.ws, string(ping))
+		}
+		if err != nil {
+			c.close()
+			return
+		}
+	}
+}
+
+func (h *Hub) reply(c *hubConn, msg hubControl) {
+	data, _ := json.Marshal(msg)
+	select {
+	case c.send <- hubFrame{text: data}:
+	default:
+		c.drop()
+	}
+}
+
+func (h *Hub) subscribe(c *hubConn, topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	subs, ok := h.topics[topic]
+	if !ok {
+		subs = make(map[*hubConn]struct{})
+		h.topics[topic] = subs
+	}
+	subs[c] = struct{}{}
+	c.topics[topic] = struct{}{}
+}
+
+func (h *Hub) unsubscribe(c *hubConn, topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.unsubscribeLocked(c, topic)
+}
+
+func (h *Hub) unsubscribeLocked(c *hubConn, topic string) {
+	delete(c.topics, topic)
+	if subs, ok := h.topics[topic]; ok {
+		delete(subs, c)
+		if len(subs) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+}
+
+func (h *Hub) remove(c *hubConn) {
+	c.close()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for topic := range c.topics {
+		h.unsubscribeLocked(c, topic)
+	}
+	delete(h.conns, c)
+}
+
+func (c *hubConn) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.ws.Close()
+	})
+}
+
+// drop closes a connection that isn't keeping up. Its writer may be stuck
+// sending to the client, and the close frame would wait behind it, so the
+// pending write is failed first.
+func (c *hubConn) drop() {
+	c.ws.SetWriteDeadline(time.Now())
+	c.close()
+}
+
+// encodeHubMessage frames a published message as a 2-byte big-endian topic
+// length, the topic, then the proto-encoded message.
+func encodeHubMessage(topic string, data []byte) []byte {
+	frame := make([]byte, 2+len(topic)+len(data))
+	binary.BigEndian.PutUint16(frame, uint16(len(topic)))
+	copy(frame[2:], topic)
+	copy(frame[2+len(topic):], data)
+	return frame
+}
@@ -0,0 +1,241 @@
+package gapp
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// startHub serves a hub with config and returns it with a dial function for
+// clients.
+func startHub(t *testing.T, config HubConfig) (*Hub, func() *websocket.Conn) {
+	t.Helper()
+	if config.Logger == nil {
+		config.Logger = slog.New(slog.DiscardHandler)
+	}
+	hub := NewHub(config)
+	server := httptest.NewServer(hub)
+	t.Cleanup(func() {
+		hub.Shutdown(t.Context())
+		server.Close()
+	})
+	return hub, func() *websocket.Conn {
+		t.Helper()
+		ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http"), "", server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { ws.Close() })
+		return ws
+	}
+}
+
+func sendControl(t *testing.T, ws *websocket.Conn, op, topic string) {
+	t.Helper()
+	data, _ := json.Marshal(hubControl{Op: op, Topic: topic})
+	if err := websocket.Message.Send(ws, string(data)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// receiveFrame returns the next control message or published message on ws.
+func receiveFrame(t *testing.T, ws *websocket.Conn) (control hubControl, topic, value string) {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var data []byte
+	if err := websocket.Message.Receive(ws, &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > 0 && data[0] == '{' {
+		if err := json.Unmarshal(data, &control); err != nil {
+			t.Fatal(err)
+		}
+		return control, "", ""
+	}
+	n := int(binary.BigEndian.Uint16(data))
+	var msg wrapperspb.StringValue
+	if err := proto.Unmarshal(data[2+n:], &msg); err != nil {
+		t.Fatal(err)
+	}
+	return hubControl{}, string(data[2 : 2+n]), msg.GetValue()
+}
+
+func waitForSubscribers(t *testing.T, hub *Hub, topic string, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for hub.Subscribers(topic) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Subscribers(%q) = %d, want %d", topic, hub.Subscribers(topic), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHubSubscribePublish(t *testing.T) {
+	hub, dial := startHub(t, HubConfig{
+		Authorize: func(r *http.Request, topic string) error {
+			if strings.HasPrefix(topic, "private/") {
+				return errors.New("nope")
+			}
+			return nil
+		},
+	})
+	alice, bob := dial(), dial()
+
+	sendControl(t, alice, "subscribe", "orders/1")
+	if control, _, _ := receiveFrame(t, alice); control.Op != "subscribed" || control.Topic != "orders/1" {
+		t.Fatalf("subscribe reply = %+v", control)
+	}
+	sendControl(t, bob, "subscribe", "private/2")
+	if control, _, _ := receiveFrame(t, bob); control.Op != "error" || control.Code != CodePermissionDenied {
+		t.Fatalf("unauthorized subscribe reply = %+v", control)
+	}
+	sendControl(t, bob, "subscribe", "orders/2")
+	receiveFrame(t, bob)
+
+	hub.Publish("orders/1", wrapperspb.String("shipped"))
+	hub.Publish("orders/2", wrapperspb.String("packed"))
+	hub.Publish("private/2", wrapperspb.String("secret"))
+	if _, topic, value := receiveFrame(t, alice); topic != "orders/1" || value != "shipped" {
+		t.Errorf("alice got %s %q, want orders/1 shipped", topic, value)
+	}
+	if _, topic, value := receiveFrame(t, bob); topic != "orders/2" || value != "packed" {
+		t.Errorf("bob got %s %q, want orders/2 packed", topic, value)
+	}
+	if n := hub.Subscribers("private/2"); n != 0 {
+		t.Errorf("Subscribers(private/2) = %d, want 0", n)
+	}
+}
+
+func TestHubUnsubscribe(t *testing.T) {
+	hub, dial := startHub(t, HubConfig{})
+	alice, bob := dial(), dial()
+	for _, ws := range []*websocket.Conn{alice, bob} {
+		sendControl(t, ws, "subscribe", "orders/1")
+		receiveFrame(t, ws)
+	}
+	if n := hub.Subscribers("orders/1"); n != 2 {
+		t.Fatalf("Subscribers = %d, want 2", n)
+	}
+
+	sendControl(t, alice, "unsubscribe", "orders/1")
+	waitForSubscribers(t, hub, "orders/1", 1)
+	hub.Publish("orders/1", wrapperspb.String("shipped"))
+	if _, _, value := receiveFrame(t, bob); value != "shipped" {
+		t.Errorf("bob got %q, want shipped", value)
+	}
+
+	// Alice only gets what she subscribes to next
+	sendControl(t, alice, "subscribe", "orders/2")
+	receiveFrame(t, alice)
+	hub.Publish("orders/2", wrapperspb.String("packed"))
+	if _, topic, _ := receiveFrame(t, alice); topic != "orders/2" {
+		t.Errorf("alice got a message for %s after unsubscribing", topic)
+	}
+
+	// Disconnecting unsubscribes from everything
+	bob.Close()
+	waitForSubscribers(t, hub, "orders/1", 0)
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	hub, dial := startHub(t, HubConfig{SendBuffer: 1})
+	slow, fast := dial(), dial()
+	for _, ws := range []*websocket.Conn{slow, fast} {
+		sendControl(t, ws, "subscribe", "feed")
+		receiveFrame(t, ws)
+	}
+
+	// Fast reads each message before the next is published while slow reads
+	// nothing, until its socket buffers and send queue fill up
+	received := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var data []byte
+		for websocket.Message.Receive(fast, &data) == nil {
+			received <- struct{}{}
+		}
+	}()
+	big := wrapperspb.String(strings.Repeat("x", 256<<10))
+	timeout := time.After(5 * time.Second)
+	for hub.Subscribers("feed") == 2 {
+		if err := hub.Publish("feed", big); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-received:
+		case <-timeout:
+			t.Fatal("slow subscriber wasn't dropped")
+		}
+	}
+	if n := hub.Subscribers("feed"); n != 1 {
+		t.Errorf("Subscribers = %d, want the fast one", n)
+	}
+
+	// The slow connection is closed once its queued messages are read
+	slow.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var data []byte
+	var err error
+	for err == nil {
+		err = websocket.Message.Receive(slow, &data)
+	}
+	var netErr interface{ Timeout() bool }
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		t.Error("slow connection still open")
+	}
+	fast.Close()
+	wg.Wait()
+}
+
+func TestHubConcurrentPublishUnsubscribe(t *testing.T) {
+	hub, dial := startHub(t, HubConfig{SendBuffer: 1024})
+	var readers, writers sync.WaitGroup
+	for range 4 {
+		ws := dial()
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			var data []byte
+			for websocket.Message.Receive(ws, &data) == nil {
+			}
+		}()
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			for range 50 {
+				for _, op := range []string{"subscribe", "unsubscribe"} {
+					data, _ := json.Marshal(hubControl{Op: op, Topic: "feed"})
+					websocket.Message.Send(ws, string(data))
+				}
+			}
+		}()
+	}
+	for range 4 {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			for range 100 {
+				hub.Publish("feed", wrapperspb.String("tick"))
+				hub.Subscribers("feed")
+			}
+		}()
+	}
+	writers.Wait()
+
+	hub.Shutdown(t.Context())
+	readers.Wait()
+	waitForSubscribers(t, hub, "feed", 0)
+}
@@ -0,0 +1,50 @@
+package gapp
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// liveHubs are the hubs created by NewHub that haven't been shut down, which
+// Invalidate broadcasts to.
+var liveHubs = struct {
+	mu  sync.Mutex
+	set map[*Hub]struct{}
+}{set: make(map[*Hub]struct{})}
+
+// Invalidate tells every client connected to any Hub that the results of the
+// given RPC methods are stale. Call it from mutation handlers, e.g.
+// gapp.Invalidate("GetItems") after creating an item; clients using
+// createInvalidationSync then refetch those methods so their stores update.
+func Invalidate(methods ...string) {
+	liveHubs.mu.Lock()
+	hubs := make([]*Hub, 0, len(liveHubs.set))
+	for h := range liveHubs.set {
+		hubs = append(hubs, h)
+	}
+	liveHubs.mu.Unlock()
+
+	for _, h := range hubs {
+		h.Invalidate(methods...)
+	}
+}
+
+// Invalidate tells every client connected to this hub that the results of
+// the given RPC methods are stale. See Invalidate.
+func (h *Hub) Invalidate(methods ...string) {
+	if len(methods) == 0 {
+		return
+	}
+	data, _ := json.Marshal(hubControl{Op: "invalidate", Methods: methods})
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for c := range h.conns {
+		select {
+		case c.send <- hubFrame{text: data}:
+		default:
+			h.config.Logger.Warn("Hub client too slow, disconnecting", "op", "invalidate")
+			c.close()
+		}
+	}
+}
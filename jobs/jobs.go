@@ -0,0 +1,342 @@
+// Package jobs runs background work outside the request that scheduled it.
+//
+// Jobs carry a proto message payload and are dispatched to the handler
+// registered for the message type. Failed jobs are retried with exponential
+// backoff, and jobs survive restarts when the Store is persistent:
+//
+//	store, err := jobs.NewSQLStore(ctx, db)
+//	...
+//	queue := jobs.New(store)
+//	jobs.Handle(queue, func(ctx context.Context, email *pb.SendEmail) error {
+//		return mailer.Send(ctx, email)
+//	})
+//	queue.Start(context.Background())
+//	...
+//	queue.Enqueue(r.Context(), &pb.SendEmail{To: user.Email})
+//	...
+//	gapp.ListenAndServeWithOptions(addr, mux, gapp.OnDrain(queue.Shutdown))
+package jobs
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Job is a unit of background work.
+type Job struct {
+	ID          string
+	Kind        string // full name of the payload's proto message
+	Payload     []byte // proto-encoded payload
+	Attempts    int    // attempts so far, including the running one
+	MaxAttempts int
+	RunAt       time.Time
+	LastError   string
+	CreatedAt   time.Time
+}
+
+// Store persists jobs. Implementations must be safe for concurrent use.
+type Store interface {
+	// Enqueue adds a pending job.
+	Enqueue(ctx context.Context, job *Job) error
+	// Claim returns the next job due at now, leasing it for lease and
+	// incrementing its attempts. A job whose lease expires (e.g. because the
+	// process died) can be claimed again. It returns nil if no job is due.
+	Claim(ctx context.Context, now time.Time, lease time.Duration) (*Job, error)
+	// Complete removes a finished job.
+	Complete(ctx context.Context, id string) error
+	// Retry makes a failed job pending again at runAt.
+	Retry(ctx context.Context, id string, runAt time.Time, lastError string) error
+	// Fail marks a job as permanently failed, keeping it for inspection.
+	Fail(ctx context.Context, id string, lastError string) error
+}
+
+// BackoffFunc returns the delay before retrying a job after attempt failed.
+type BackoffFunc func(attempt int) time.Duration
+
+// DefaultBackoff doubles the delay from 1s up to 10m, with ±20% jitter.
+func DefaultBackoff(attempt int) time.Duration {
+	delay := time.Second << min(attempt-1, 10)
+	delay = min(delay, 10*time.Minute)
+	jitter := time.Duration(rand.Int64N(int64(delay)/5*2+1)) - delay/5
+	return delay + jitter
+}
+
+// Option configures a Queue.
+type Option func(*Queue)
+
+// WithWorkers sets the number of concurrent workers. Defaults to 4.
+func WithWorkers(n int) Option {
+	return func(q *Queue) {
+		q.workers = n
+	}
+}
+
+// WithPollInterval sets how often idle workers check the store for due jobs.
+// Jobs enqueued through the same Queue wake a worker immediately. Defaults to 1s.
+func WithPollInterval(d time.Duration) Option {
+	return func(q *Queue) {
+		q.pollInterval = d
+	}
+}
+
+// WithBackoff sets the retry delay policy. Defaults to DefaultBackoff.
+func WithBackoff(backoff BackoffFunc) Option {
+	return func(q *Queue) {
+		q.backoff = backoff
+	}
+}
+
+// WithLease sets how long a claimed job is reserved for its worker before
+// another worker may claim it again. Defaults to 5m; keep it longer than
+// the slowest job.
+func WithLease(d time.Duration) Option {
+	return func(q *Queue) {
+		q.lease = d
+	}
+}
+
+// WithLogger sets the logger for job failures. Defaults to slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(q *Queue) {
+		q.logger = logger
+	}
+}
+
+// EnqueueOption configures a single job.
+type EnqueueOption func(*Job)
+
+// Delay runs the job no earlier than d from now.
+func Delay(d time.Duration) EnqueueOption {
+	return func(j *Job) {
+		j.RunAt = j.RunAt.Add(d)
+	}
+}
+
+// MaxAttempts sets how many times the job is tried before it's marked as
+// failed. Defaults to 5.
+func MaxAttempts(n int) EnqueueOption {
+	return func(j *Job) {
+		j.MaxAttempts = n
+	}
+}
+
+// Queue dispatches jobs from a Store to registered handlers.
+type Queue struct {
+	store        Store
+	workers      int
+	pollInterval time.Duration
+	backoff      BackoffFunc
+	lease        time.Duration
+	logger       *slog.Logger
+
+	mu       sync.RWMutex
+	handlers map[string]func(ctx context.Context, payload []byte) error
+
+	wake      chan struct{}
+	stop      chan struct{}
+	stopOnce  sync.Once
+	jobCtx    context.Context
+	cancelJob context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// New creates a Queue backed by store.
+func New(store Store, opts ...Option) *Queue {
+	q := &Queue{
+		store:        store,
+		workers:      4,
+		pollInterval: time.Second,
+		backoff:      DefaultBackoff,
+		lease:        5 * time.Minute,
+		logger:       slog.Default(),
+		handlers:     make(map[string]func(ctx context.Context, payload []byte) error),
+		wake:         make(chan struct{}, 1),
+		stop:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	return q
+}
+
+// Handle registers handler for jobs whose payload is a T.
+func Handle[T proto.Message](q *Queue, handler func(ctx context.Context, payload T) error) {
+	var zero T
+	kind := string(zero.ProtoReflect().Descriptor().FullName())
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = func(ctx context.Context, data []byte) error {
+		payload := zero.ProtoReflect().New().Interface().(T)
+		if err := proto.Unmarshal(data, payload); err != nil {
+			return fmt.Errorf("decoding %s payload: %w", kind, err)
+		}
+		return handler(ctx, payload)
+	}
+}
+
+// Enqueue schedules payload to be handled in the background.
+func (q *Queue) Enqueue(ctx context.Context, payload proto.Message, opts ...EnqueueOption) (*Job, error) {
+	data, err := proto.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	job := &Job{
+		ID:          newID(),
+		Kind:        string(payload.ProtoReflect().Descriptor().FullName()),
+		Payload:     data,
+		MaxAttempts: 5,
+		RunAt:       now,
+		CreatedAt:   now,
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+
+	if err := q.store.Enqueue(ctx, job); err != nil {
+		return nil, err
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Start launches the workers. Call Shutdown to stop them.
+func (q *Queue) Start(ctx context.Context) {
+	q.jobCtx, q.cancelJob = context.WithCancel(context.WithoutCancel(ctx))
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work(ctx)
+	}
+}
+
+// Shutdown stops claiming new jobs and waits for running ones to finish.
+// If ctx expires first, running jobs' contexts are cancelled and it returns
+// ctx.Err(); interrupted jobs are retried once their lease expires.
+// Its signature matches gapp.OnDrain and gapp.OnShutdown.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.stopOnce.Do(func() { close(q.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		if q.cancelJob != nil {
+			q.cancelJob()
+		}
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) work(ctx context.Context) {
+	defer q.wg.Done()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		job, err := q.store.Claim(ctx, time.Now(), q.lease)
+		if err != nil {
+			q.logger.Error("Failed to claim job", "error", err)
+		}
+		if job != nil {
+			q.run(job)
+			continue
+		}
+
+		timer.Reset(q.pollInterval)
+		select {
+		case <-q.stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+func (q *Queue) run(job *Job) {
+	q.mu.RLock()
+	handler, ok := q.handlers[job.Kind]
+	q.mu.RUnlock()
+
+	// Store calls use a fresh context so the outcome is recorded even when
+	// the job was cancelled by Shutdown.
+	storeCtx := context.WithoutCancel(q.jobCtx)
+
+	if !ok {
+		msg := "no handler registered for " + job.Kind
+		q.logger.Error("Job failed", "id", job.ID, "kind", job.Kind, "error", msg)
+		if err := q.store.Fail(storeCtx, job.ID, msg); err != nil {
+			q.logger.Error("Failed to record job failure", "id", job.ID, "error", err)
+		}
+		return
+	}
+
+	err := safeCall(q.jobCtx, handler, job.Payload)
+	if err == nil {
+		if err := q.store.Complete(storeCtx, job.ID); err != nil {
+			q.logger.Error("Failed to complete job", "id", job.ID, "error", err)
+		}
+		return
+	}
+
+	if job.Attempts >= job.MaxAttempts {
+		q.logger.Error("Job failed permanently", "id", job.ID, "kind", job.Kind, "attempts", job.Attempts, "error", err)
+		if err := q.store.Fail(storeCtx, job.ID, err.Error()); err != nil {
+			q.logger.Error("Failed to record job failure", "id", job.ID, "error", err)
+		}
+		return
+	}
+
+	runAt := time.Now().Add(q.backoff(job.Attempts))
+	q.logger.Warn("Job failed, retrying", "id", job.ID, "kind", job.Kind, "attempt", job.Attempts, "runAt", runAt, "error", err)
+	if err := q.store.Retry(storeCtx, job.ID, runAt, err.Error()); err != nil {
+		q.logger.Error("Failed to schedule job retry", "id", job.ID, "error", err)
+	}
+}
+
+// safeCall runs handler, turning a panic into an error.
+func safeCall(ctx context.Context, handler func(context.Context, []byte) error, payload []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, payload)
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	cryptorand.Read(b)
+	return hex.EncodeToString(b)
+}
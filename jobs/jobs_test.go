@@ -0,0 +1,193 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// eventually fails the test if cond doesn't hold within a few seconds.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (s *MemoryStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.jobs)
+}
+
+func TestEnqueueRun(t *testing.T) {
+	store := NewMemoryStore()
+	queue := New(store, WithPollInterval(time.Hour))
+	got := make(chan string, 1)
+	Handle(queue, func(ctx context.Context, payload *wrapperspb.StringValue) error {
+		got <- payload.GetValue()
+		return nil
+	})
+	queue.Start(context.Background())
+	defer queue.Shutdown(context.Background())
+
+	// Enqueuing wakes an idle worker rather than waiting for the next poll
+	job, err := queue.Enqueue(context.Background(), wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Kind != "google.protobuf.StringValue" || job.MaxAttempts != 5 {
+		t.Errorf("Enqueue = kind %s, %d max attempts", job.Kind, job.MaxAttempts)
+	}
+	select {
+	case value := <-got:
+		if value != "hello" {
+			t.Errorf("handler got %q, want hello", value)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("job didn't run")
+	}
+	eventually(t, "the job to complete", func() bool { return store.len() == 0 })
+}
+
+func TestRetryUntilMaxAttempts(t *testing.T) {
+	store := NewMemoryStore()
+	var mu sync.Mutex
+	var backoffs []int
+	queue := New(store, WithPollInterval(time.Millisecond), WithBackoff(func(attempt int) time.Duration {
+		mu.Lock()
+		defer mu.Unlock()
+		backoffs = append(backoffs, attempt)
+		return 0
+	}))
+	calls := make(map[string]int)
+	Handle(queue, func(ctx context.Context, payload *wrapperspb.StringValue) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[payload.GetValue()]++
+		if payload.GetValue() == "flaky" && calls["flaky"] == 2 {
+			return nil
+		}
+		if payload.GetValue() == "panics" {
+			panic("boom")
+		}
+		return errors.New("unavailable")
+	})
+	queue.Start(context.Background())
+	defer queue.Shutdown(context.Background())
+
+	for _, value := range []string{"flaky", "broken", "panics"} {
+		if _, err := queue.Enqueue(context.Background(), wrapperspb.String(value), MaxAttempts(3)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	eventually(t, "the jobs to finish", func() bool {
+		return store.len() == 2 && len(store.Failed()) == 2
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls["flaky"] != 2 || calls["broken"] != 3 || calls["panics"] != 3 {
+		t.Errorf("calls = %v, want flaky 2, broken and panics 3", calls)
+	}
+	// Each failure but the last is retried after a backoff
+	if len(backoffs) != 5 {
+		t.Errorf("backoff calls = %v, want 5", backoffs)
+	}
+	lastErrors := make(map[string]bool)
+	for _, job := range store.Failed() {
+		if job.Attempts != 3 {
+			t.Errorf("failed job has %d attempts, want 3", job.Attempts)
+		}
+		lastErrors[job.LastError] = true
+	}
+	if !lastErrors["unavailable"] || !lastErrors["panic: boom"] {
+		t.Errorf("failed jobs' last errors = %v, want unavailable and panic: boom", lastErrors)
+	}
+}
+
+func TestUnknownKindFails(t *testing.T) {
+	store := NewMemoryStore()
+	queue := New(store, WithPollInterval(time.Millisecond))
+	queue.Start(context.Background())
+	defer queue.Shutdown(context.Background())
+
+	if _, err := queue.Enqueue(context.Background(), wrapperspb.Int64(1)); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the job to fail", func() bool { return len(store.Failed()) == 1 })
+	if failed := store.Failed()[0]; failed.LastError != "no handler registered for google.protobuf.Int64Value" {
+		t.Errorf("LastError = %q", failed.LastError)
+	}
+}
+
+func TestShutdownDrains(t *testing.T) {
+	store := NewMemoryStore()
+	queue := New(store, WithPollInterval(time.Millisecond), WithWorkers(1))
+	started := make(chan struct{})
+	release := make(chan struct{})
+	Handle(queue, func(ctx context.Context, payload *wrapperspb.StringValue) error {
+		close(started)
+		<-release
+		return nil
+	})
+	queue.Start(context.Background())
+	if _, err := queue.Enqueue(context.Background(), wrapperspb.String("slow")); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- queue.Shutdown(context.Background()) }()
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown = %v before the running job finished", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// Jobs enqueued while draining aren't claimed
+	if _, err := queue.Enqueue(context.Background(), wrapperspb.String("late")); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if err := <-shutdown; err != nil {
+		t.Errorf("Shutdown = %v", err)
+	}
+	if store.len() != 1 {
+		t.Errorf("store has %d jobs after shutdown, want the late one", store.len())
+	}
+}
+
+func TestShutdownTimeoutCancelsJobs(t *testing.T) {
+	store := NewMemoryStore()
+	queue := New(store, WithPollInterval(time.Millisecond), WithBackoff(func(int) time.Duration { return time.Hour }))
+	started := make(chan struct{})
+	Handle(queue, func(ctx context.Context, payload *wrapperspb.StringValue) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	queue.Start(context.Background())
+	if _, err := queue.Enqueue(context.Background(), wrapperspb.String("stuck")); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := queue.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v, want context.DeadlineExceeded", err)
+	}
+	// The interrupted job is kept for a retry
+	if store.len() != 1 || len(store.Failed()) != 0 {
+		t.Errorf("store has %d jobs, %d failed, want 1 pending", store.len(), len(store.Failed()))
+	}
+}
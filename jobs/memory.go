@@ -0,0 +1,101 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type memoryJob struct {
+	job         Job
+	failed      bool
+	lockedUntil time.Time
+}
+
+// MemoryStore keeps jobs in memory. Jobs are lost on restart, so use it for
+// development, tests, or work that's safe to drop.
+type MemoryStore struct {
+	mu   sync.Mutex
+	jobs map[string]*memoryJob
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: make(map[string]*memoryJob)}
+}
+
+func (s *MemoryStore) Enqueue(ctx context.Context, job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.ID] = &memoryJob{job: *job}
+	return nil
+}
+
+func (s *MemoryStore) Claim(ctx context.Context, now time.Time, lease time.Duration) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next *memoryJob
+	for _, j := range s.jobs {
+		if j.failed || j.job.RunAt.After(now) || j.lockedUntil.After(now) {
+			continue
+		}
+		if next == nil || j.job.RunAt.Before(next.job.RunAt) {
+			next = j
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+
+	next.lockedUntil = now.Add(lease)
+	next.job.Attempts++
+	job := next.job
+	return &job, nil
+}
+
+func (s *MemoryStore) Complete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	return nil
+}
+
+func (s *MemoryStore) Retry(ctx context.Context, id string, runAt time.Time, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return fmt.Errorf("job %s not found", id)
+	}
+	j.job.RunAt = runAt
+	j.job.LastError = lastError
+	j.lockedUntil = time.Time{}
+	return nil
+}
+
+func (s *MemoryStore) Fail(ctx context.Context, id string, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return fmt.Errorf("job %s not found", id)
+	}
+	j.failed = true
+	j.job.LastError = lastError
+	return nil
+}
+
+// Failed returns the jobs that exhausted their attempts.
+func (s *MemoryStore) Failed() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var failed []Job
+	for _, j := range s.jobs {
+		if j.failed {
+			failed = append(failed, j.job)
+		}
+	}
+	return failed
+}
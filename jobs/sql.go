@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SQLStore persists jobs in a SQL database using SQLite syntax. It works with
+// any database/sql SQLite driver (e.g. modernc.org/sqlite or
+// github.com/mattn/go-sqlite3), which the app imports and opens itself.
+type SQLStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSQLStore creates a store in db, creating the gapp_jobs table if needed.
+func NewSQLStore(ctx context.Context, db *sql.DB) (*SQLStore, error) {
+	s := &SQLStore{db: db, table: "gapp_jobs"}
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+s.table+` (
+	id TEXT PRIMARY KEY,
+	kind TEXT NOT NULL,
+	payload BLOB NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	max_attempts INTEGER NOT NULL,
+	run_at INTEGER NOT NULL,
+	locked_until INTEGER NOT NULL DEFAULT 0,
+	failed INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL
+)`)
+	if err != nil {
+		return nil, fmt.Errorf("creating %s table: %w", s.table, err)
+	}
+	_, err = db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS `+s.table+`_due ON `+s.table+` (failed, run_at)`)
+	if err != nil {
+		return nil, fmt.Errorf("creating %s index: %w", s.table, err)
+	}
+	return s, nil
+}
+
+func (s *SQLStore) Enqueue(ctx context.Context, job *Job) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO `+s.table+` (id, kind, payload, attempts, max_attempts, run_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		job.ID, job.Kind, job.Payload, job.Attempts, job.MaxAttempts, job.RunAt.UnixMilli(), job.CreatedAt.UnixMilli())
+	return err
+}
+
+func (s *SQLStore) Claim(ctx context.Context, now time.Time, lease time.Duration) (*Job, error) {
+	nowMs := now.UnixMilli()
+	for {
+		var job Job
+		var runAt, createdAt int64
+		err := s.db.QueryRowContext(ctx,
+			`SELECT id, kind, payload, attempts, max_attempts, run_at, last_error, created_at FROM `+s.table+`
+			WHERE failed = 0 AND run_at <= ? AND locked_until <= ? ORDER BY run_at LIMIT 1`,
+			nowMs, nowMs,
+		).Scan(&job.ID, &job.Kind, &job.Payload, &job.Attempts, &job.MaxAttempts, &runAt, &job.LastError, &createdAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Only one worker wins the lease; the others look for the next job
+		res, err := s.db.ExecContext(ctx,
+			`UPDATE `+s.table+` SET locked_until = ?, attempts = attempts + 1 WHERE id = ? AND locked_until <= ? AND failed = 0`,
+			now.Add(lease).UnixMilli(), job.ID, nowMs)
+		if err != nil {
+			return nil, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return nil, err
+		} else if n == 0 {
+			continue
+		}
+
+		job.Attempts++
+		job.RunAt = time.UnixMilli(runAt)
+		job.CreatedAt = time.UnixMilli(createdAt)
+		return &job, nil
+	}
+}
+
+func (s *SQLStore) Complete(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM `+s.table+` WHERE id = ?`, id)
+	return err
+}
+
+func (s *SQLStore) Retry(ctx context.Context, id string, runAt time.Time, lastError string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE `+s.table+` SET run_at = ?, locked_until = 0, last_error = ? WHERE id = ?`,
+		runAt.UnixMilli(), lastError, id)
+	return err
+}
+
+func (s *SQLStore) Fail(ctx context.Context, id string, lastError string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE `+s.table+` SET failed = 1, locked_until = 0, last_error = ? WHERE id = ?`,
+		lastError, id)
+	return err
+}
@@ -2,49 +2,278 @@ package gapp
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 )
 
-// ListenAndServe starts an HTTP server and blocks until a SIGINT or SIGTERM
+// ShutdownFunc is teardown logic run during graceful shutdown. The context
+// expires when the shutdown timeout is reached.
+type ShutdownFunc func(ctx context.Context) error
+
+// ServeOption configures ListenAndServeWithOptions.
+type ServeOption interface {
+	applyServe(o *serveOptions)
+}
+
+type serveOption func(*serveOptions)
+
+func (o serveOption) applyServe(opts *serveOptions) { o(opts) }
+
+type serveOptions struct {
+	server     ServerConfig
+	tls        *tlsOptions
+	h2c        bool
+	http3      HTTP3ServerFunc
+	listeners  []extraListener
+	onDrain    []ShutdownFunc
+	onShutdown []ShutdownFunc
+	logger     *slog.Logger
+}
+
+// ServerConfig tunes the underlying http.Server. Zero values use the
+// defaults below; a negative ReadHeaderTimeout or IdleTimeout disables it.
+type ServerConfig struct {
+	ReadTimeout       time.Duration // whole request including body, default none
+	ReadHeaderTimeout time.Duration // default 10s
+	WriteTimeout      time.Duration // default none; streams clear it in StreamAdapter.SendHeaders
+	IdleTimeout       time.Duration // keep-alive connections, default 120s
+	MaxHeaderBytes    int           // default http.DefaultMaxHeaderBytes
+	ShutdownTimeout   time.Duration // budget for drain hooks, shutdown and shutdown hooks, default 30s
+}
+
+// WithServerConfig sets the server timeouts and shutdown budget.
+func WithServerConfig(config ServerConfig) ServeOption {
+	return serveOption(func(o *serveOptions) {
+		o.server = config
+	})
+}
+
+func (c ServerConfig) withDefaults() ServerConfig {
+	if c.ReadHeaderTimeout == 0 {
+		c.ReadHeaderTimeout = 10 * time.Second
+	}
+	if c.IdleTimeout == 0 {
+		c.IdleTimeout = 120 * time.Second
+	}
+	if c.ShutdownTimeout <= 0 {
+		c.ShutdownTimeout = 30 * time.Second
+	}
+	return c
+}
+
+// OnDrain registers fn to run as soon as a shutdown signal is received, while
+// the server is still finishing in-flight requests. Use it to end long-lived
+// work such as streams or subscriptions so that draining can complete.
+func OnDrain(fn ShutdownFunc) ServeOption {
+	return serveOption(func(o *serveOptions) {
+		o.onDrain = append(o.onDrain, fn)
+	})
+}
+
+// OnShutdown registers fn to run after the server has stopped handling
+// requests, e.g. to close database pools or flush queues. Hooks run in
+// reverse registration order, like deferred calls.
+func OnShutdown(fn ShutdownFunc) ServeOption {
+	return serveOption(func(o *serveOptions) {
+		o.onShutdown = append(o.onShutdown, fn)
+	})
+}
+
+func (c ServerConfig) newServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       c.ReadTimeout,
+		ReadHeaderTimeout: c.ReadHeaderTimeout,
+		WriteTimeout:      c.WriteTimeout,
+		IdleTimeout:       c.IdleTimeout,
+		MaxHeaderBytes:    c.MaxHeaderBytes,
+	}
+}
+
+type extraListener struct {
+	addr    string
+	handler http.Handler
+}
+
+// WithListener additionally serves handler on addr, e.g. an admin or metrics
+// handler on a localhost-only port next to the public one. It shares the
+// ServerConfig and shutdown, but not TLS or the HTTP/2 and HTTP/3 options.
+// addr may be a unix domain socket written as "unix:/path/to.sock".
+func WithListener(addr string, handler http.Handler) ServeOption {
+	return serveOption(func(o *serveOptions) {
+		o.listeners = append(o.listeners, extraListener{addr: addr, handler: handler})
+	})
+}
+
+// listener is one server run by ListenAndServeWithOptions.
+type listener struct {
+	addr     string
+	protocol string
+	serve    func() error
+	shutdown func(ctx context.Context) error
+}
+
+// httpListener serves server on its address, which may be a unix domain
+// socket written as "unix:/path/to.sock". It serves HTTPS if server has a
+// TLSConfig.
+func httpListener(server *http.Server) listener {
+	l := listener{addr: server.Addr, protocol: "http", shutdown: server.Shutdown}
+	useTLS := server.TLSConfig != nil
+	if useTLS {
+		l.protocol = "https"
+	}
+
+	path, isUnix := strings.CutPrefix(server.Addr, "unix:")
+	if !isUnix {
+		l.serve = server.ListenAndServe
+		if useTLS {
+			l.serve = func() error { return server.ListenAndServeTLS("", "") }
+		}
+		return l
+	}
+
+	l.serve = func() error {
+		// Remove a stale socket left by a previous run that didn't exit cleanly
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(path)
+		}
+		ln, err := net.Listen("unix", path)
+		if err != nil {
+			return err
+		}
+		if useTLS {
+			return server.ServeTLS(ln, "", "")
+		}
+		return server.Serve(ln)
+	}
+	return l
+}
+
+// ListenAndServe starts an HTTP server on addr, a TCP address or a unix domain
+// socket written as "unix:/path/to.sock", and blocks until a SIGINT or SIGTERM
 // signal is received, at which point it initiates a graceful shutdown with a
-// 30-second timeout. Returns http.ErrServerClosed on clean shutdown.
+// 30-second timeout. Tasks registered with Schedule run while the server is
+// up. Returns http.ErrServerClosed on clean shutdown.
+// Use ListenAndServeWithOptions to tune timeouts or add shutdown hooks.
 func ListenAndServe(addr string, handler http.Handler) error {
-	server := &http.Server{
-		Addr:    addr,
-		Handler: handler,
+	return ListenAndServeWithOptions(addr, handler)
+}
+
+// ListenAndServeWithOptions is ListenAndServe with server tuning and
+// drain and shutdown hooks.
+// It returns http.ErrServerClosed on clean shutdown, or the errors of the
+// shutdown and any failed hooks, after the error of a server that failed.
+func ListenAndServeWithOptions(addr string, handler http.Handler, opts ...ServeOption) error {
+	options := serveOptions{logger: slog.Default()}
+	for _, opt := range opts {
+		opt.applyServe(&options)
+	}
+	logger := options.logger
+	if logger == nil {
+		logger = slog.Default()
 	}
 
-	errCh := make(chan error, 1)
-	go func() {
-		slog.Info("Server starting", "addr", addr)
-		errCh <- server.ListenAndServe()
-	}()
+	config := options.server.withDefaults()
+	if options.http3 != nil {
+		handler = advertiseHTTP3(addr, handler)
+	}
+	server := config.newServer(addr, handler)
+	if options.h2c {
+		enableH2C(server)
+	}
+
+	var extra []*http.Server
+	if options.tls != nil {
+		var err error
+		if extra, err = options.tls.configure(server, config); err != nil {
+			return err
+		}
+	} else if options.http3 != nil {
+		return errors.New("gapp: HTTP/3 requires WithTLS or WithAutocert")
+	}
+	for _, l := range options.listeners {
+		extra = append(extra, config.newServer(l.addr, l.handler))
+	}
+
+	listeners := []listener{httpListener(server)}
+	for _, s := range extra {
+		listeners = append(listeners, httpListener(s))
+	}
+	if options.http3 != nil {
+		listeners = append(listeners, http3Listener(options.http3(addr, handler, server.TLSConfig), addr))
+	}
+
+	errCh := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func() {
+			logger.Info("Server starting", "addr", l.addr, "protocol", l.protocol)
+			errCh <- l.serve()
+		}()
+	}
 
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 
+	DefaultScheduler().Start()
+
+	// A server failing to start or crashing shuts down the others like a
+	// signal, so none is left bound and the hooks still run
+	var errs []error
 	select {
 	case err := <-errCh:
-		// Server failed to start or crashed
-		return err
+		logger.Error("Server failed, shutting down", "error", err)
+		errs = append(errs, err)
 	case sig := <-sigCh:
-		slog.Info("Shutdown signal received", "signal", sig)
+		logger.Info("Shutdown signal received", "signal", sig)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// Fail readiness so load balancers stop sending new traffic
+	Health().SetDraining(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
 	defer cancel()
 
-	slog.Info("Shutting down gracefully...")
-	if err := server.Shutdown(ctx); err != nil {
-		slog.Error("Graceful shutdown failed", "error", err)
-		return err
+	if err := DefaultScheduler().Stop(ctx); err != nil {
+		logger.Error("Scheduled tasks did not finish", "error", err)
+		errs = append(errs, err)
+	}
+	for _, fn := range options.onDrain {
+		if err := fn(ctx); err != nil {
+			logger.Error("Drain hook failed", "error", err)
+			errs = append(errs, err)
+		}
+	}
+
+	logger.Info("Shutting down gracefully...")
+	for _, l := range listeners {
+		if err := l.shutdown(ctx); err != nil {
+			logger.Error("Graceful shutdown failed", "addr", l.addr, "error", err)
+			errs = append(errs, err)
+		}
+	}
+
+	for i := len(options.onShutdown) - 1; i >= 0; i-- {
+		if err := options.onShutdown[i](ctx); err != nil {
+			logger.Error("Shutdown hook failed", "error", err)
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
 	}
 
-	slog.Info("Server stopped")
+	logger.Info("Server stopped")
 	return http.ErrServerClosed
 }
@@ -0,0 +1,25 @@
+package gapp
+
+import "log/slog"
+
+// WithLogger routes the logs of a Dispatcher, PreloadEngine or
+// ListenAndServeWithOptions to logger instead of slog.Default(), e.g. to add
+// fields or silence them in tests:
+//
+//	logger := slog.Default().With("component", "gapp")
+//	d := gapp.NewDispatcher(gapp.WithLogger(logger))
+//	preload := gapp.NewPreloadEngine(config, gapp.WithLogger(logger))
+//	gapp.ListenAndServeWithOptions(addr, mux, gapp.WithLogger(logger))
+func WithLogger(logger *slog.Logger) LoggerOption {
+	return LoggerOption{logger: logger}
+}
+
+// LoggerOption is the DispatcherOption, PreloadOption and ServeOption
+// returned by WithLogger.
+type LoggerOption struct {
+	logger *slog.Logger
+}
+
+func (o LoggerOption) applyDispatcher(d *Dispatcher) { d.logger = o.logger }
+func (o LoggerOption) applyPreload(p *PreloadEngine) { p.logger = o.logger }
+func (o LoggerOption) applyServe(opts *serveOptions) { opts.logger = o.logger }
@@ -2,6 +2,8 @@ package gapp
 
 import (
 	"encoding/binary"
+	"encoding/json"
+	"fmt"
 	"io"
 )
 
@@ -9,36 +11,160 @@ import (
 // Each message is expected to be preceded by a 4-byte big-endian length prefix,
 // matching the format used by StreamAdapter.Send and the client streaming transport.
 type MessageReader struct {
-	data   []byte
-	offset int
+	data           []byte
+	offset         int
+	maxSize        int // 0 for no limit
+	skipKeepalives bool
+	compressor     StreamCompressor
+	resumeToken    string
+	err            error
+}
+
+// FrameError reports a malformed frame, such as one whose length prefix was
+// corrupted. Truncated frames also match io.ErrUnexpectedEOF.
+type FrameError struct {
+	Offset int    // offset of the frame's length prefix in the data
+	Length uint32 // length declared by the prefix
+	Reason string
+
+	truncated bool
+}
+
+func (e *FrameError) Error() string {
+	if e.Length == 0 && e.truncated {
+		return fmt.Sprintf("gapp: invalid stream frame at offset %d: %s", e.Offset, e.Reason)
+	}
+	return fmt.Sprintf("gapp: invalid stream frame at offset %d (declared length %d): %s", e.Offset, e.Length, e.Reason)
+}
+
+func (e *FrameError) Unwrap() error {
+	if e.truncated {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// MessageReaderOption configures a MessageReader.
+type MessageReaderOption func(*MessageReader)
+
+// WithMaxMessageSize sets the largest message in bytes the reader accepts.
+// Larger declared lengths fail with a FrameError. Zero, the default, is no
+// limit: frames longer than the data are reported as truncated either way.
+func WithMaxMessageSize(bytes int) MessageReaderOption {
+	return func(r *MessageReader) {
+		r.maxSize = bytes
+	}
+}
+
+// SkipKeepalives makes Next skip zero-length frames, which a StreamAdapter
+// with WithHeartbeat sends as keepalives (advertised by its
+// X-Stream-Heartbeat response header).
+func SkipKeepalives() MessageReaderOption {
+	return func(r *MessageReader) {
+		r.skipKeepalives = true
+	}
+}
+
+// WithDecompression reads the frames of a stream compressed with the named
+// compressor, the value of the response's X-Stream-Compression header. An
+// empty name leaves frames as they are. Next returns an error for unregistered
+// compressors.
+func WithDecompression(name string) MessageReaderOption {
+	return func(r *MessageReader) {
+		if name != "" {
+			r.compressor = lookupCompressor(name)
+			if r.compressor == nil {
+				r.err = fmt.Errorf("unknown stream compression %q", name)
+			}
+		}
+	}
 }
 
 // NewMessageReader creates a MessageReader over the given data.
-func NewMessageReader(data []byte) *MessageReader {
-	return &MessageReader{data: data}
+func NewMessageReader(data []byte, opts ...MessageReaderOption) *MessageReader {
+	r := &MessageReader{data: data}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 // Next returns the next message from the buffer.
-// Returns io.EOF when no more messages are available.
+// Returns io.EOF when no more messages are available, the stream's *RpcError
+// if it ended with an error trailer, or a *FrameError for malformed data.
 func (r *MessageReader) Next() ([]byte, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	for {
+		msg, token, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if token {
+			r.resumeToken = string(msg)
+			continue
+		}
+		if len(msg) == 0 && r.skipKeepalives {
+			continue
+		}
+		if r.compressor != nil {
+			return decodeFrame(msg, r.compressor)
+		}
+		return msg, nil
+	}
+}
+
+// ResumeToken returns the last resume token read from the stream, to send
+// in X-Rpc-Resume-Token when reconnecting.
+func (r *MessageReader) ResumeToken() string {
+	return r.resumeToken
+}
+
+// next returns the next frame and whether it's a resume token.
+func (r *MessageReader) next() ([]byte, bool, error) {
 	if r.offset >= len(r.data) {
-		return nil, io.EOF
+		return nil, false, io.EOF
 	}
 
 	remaining := r.data[r.offset:]
 
 	if len(remaining) < 4 {
-		return nil, io.ErrUnexpectedEOF
+		return nil, false, &FrameError{Offset: r.offset, Reason: "truncated length prefix", truncated: true}
 	}
 
-	length := binary.BigEndian.Uint32(remaining[:4])
+	prefix := binary.BigEndian.Uint32(remaining[:4])
+	length := prefix &^ (trailerFlag | tokenFlag)
 
-	if len(remaining) < 4+int(length) {
-		return nil, io.ErrUnexpectedEOF
+	if r.maxSize > 0 && uint64(length) > uint64(r.maxSize) {
+		return nil, false, &FrameError{
+			Offset: r.offset,
+			Length: length,
+			Reason: fmt.Sprintf("exceeds the maximum message size of %d bytes", r.maxSize),
+		}
+	}
+	if len(remaining)-4 < int(length) {
+		return nil, false, &FrameError{
+			Offset:    r.offset,
+			Length:    length,
+			Reason:    fmt.Sprintf("truncated, only %d bytes follow the prefix", len(remaining)-4),
+			truncated: true,
+		}
 	}
 
 	msg := remaining[4 : 4+length]
 	r.offset += 4 + int(length)
 
-	return msg, nil
+	if prefix&trailerFlag != 0 {
+		// The stream failed; nothing follows the trailer
+		r.offset = len(r.data)
+		var rpcErr RpcError
+		if err := json.Unmarshal(msg, &rpcErr); err != nil {
+			return nil, false, fmt.Errorf("invalid stream error trailer: %w", err)
+		}
+		r.err = &rpcErr
+		return nil, false, r.err
+	}
+
+	return msg, prefix&tokenFlag != 0, nil
 }
@@ -0,0 +1,122 @@
+package gapp
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+// frame returns data with a length prefix carrying flags.
+func frame(flags uint32, data string) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, flags|uint32(len(data))), data...)
+}
+
+func frames(parts ...[]byte) []byte {
+	var data []byte
+	for _, part := range parts {
+		data = append(data, part...)
+	}
+	return data
+}
+
+func TestMessageReader(t *testing.T) {
+	r := NewMessageReader(frames(
+		frame(0, "one"),
+		frame(tokenFlag, "offset-1"),
+		frame(0, ""),
+		frame(0, "two"),
+	))
+	for _, want := range []string{"one", "", "two"} {
+		msg, err := r.Next()
+		if err != nil || string(msg) != want {
+			t.Fatalf("Next = %q, %v, want %q", msg, err, want)
+		}
+	}
+	if r.ResumeToken() != "offset-1" {
+		t.Errorf("ResumeToken = %q, want offset-1", r.ResumeToken())
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Next at the end = %v, want io.EOF", err)
+	}
+}
+
+func TestMessageReaderSkipKeepalives(t *testing.T) {
+	r := NewMessageReader(frames(frame(0, ""), frame(0, "one"), frame(0, ""), frame(0, "")), SkipKeepalives())
+	if msg, err := r.Next(); err != nil || string(msg) != "one" {
+		t.Fatalf("Next = %q, %v, want one", msg, err)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Next after trailing keepalives = %v, want io.EOF", err)
+	}
+}
+
+func TestMessageReaderTrailer(t *testing.T) {
+	r := NewMessageReader(frames(
+		frame(0, "one"),
+		frame(trailerFlag, `{"code":"NOT_FOUND","message":"gone"}`),
+		frame(0, "ignored"),
+	))
+	if msg, err := r.Next(); err != nil || string(msg) != "one" {
+		t.Fatalf("Next = %q, %v, want one", msg, err)
+	}
+	for range 2 {
+		var rpcErr *RpcError
+		if _, err := r.Next(); !errors.As(err, &rpcErr) || rpcErr.Code != CodeNotFound || rpcErr.Message != "gone" {
+			t.Errorf("Next after the trailer = %v, want NOT_FOUND: gone", err)
+		}
+	}
+}
+
+func TestMessageReaderFrameErrors(t *testing.T) {
+	oversized := frame(0, string(make([]byte, 2048)))
+
+	// Without a limit, only frames longer than the data fail
+	if msg, err := NewMessageReader(oversized).Next(); err != nil || len(msg) != 2048 {
+		t.Errorf("Next without a limit = %d bytes, %v, want 2048 bytes", len(msg), err)
+	}
+
+	for _, tt := range []struct {
+		name      string
+		data      []byte
+		opts      []MessageReaderOption
+		want      FrameError
+		truncated bool
+	}{
+		{
+			name:      "truncated prefix",
+			data:      frames(frame(0, "one"), []byte{0, 0}),
+			want:      FrameError{Offset: 7, Reason: "truncated length prefix"},
+			truncated: true,
+		},
+		{
+			name:      "truncated frame",
+			data:      frame(0, "hello")[:6],
+			want:      FrameError{Offset: 0, Length: 5, Reason: "truncated, only 2 bytes follow the prefix"},
+			truncated: true,
+		},
+		{
+			name: "oversized frame",
+			data: oversized,
+			opts: []MessageReaderOption{WithMaxMessageSize(1024)},
+			want: FrameError{Offset: 0, Length: 2048, Reason: "exceeds the maximum message size of 1024 bytes"},
+		},
+	} {
+		r := NewMessageReader(tt.data, tt.opts...)
+		var err error
+		for err == nil {
+			_, err = r.Next()
+		}
+		var frameErr *FrameError
+		if !errors.As(err, &frameErr) {
+			t.Errorf("%s: Next = %v, want a FrameError", tt.name, err)
+			continue
+		}
+		if frameErr.Offset != tt.want.Offset || frameErr.Length != tt.want.Length || frameErr.Reason != tt.want.Reason {
+			t.Errorf("%s: Next = %+v, want %+v", tt.name, *frameErr, tt.want)
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) != tt.truncated {
+			t.Errorf("%s: errors.Is(%v, io.ErrUnexpectedEOF) = %t, want %t", tt.name, err, !tt.truncated, tt.truncated)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package gapp
+
+import (
+	"net/http"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// RouteMeta holds the HTML head metadata rendered for a route.
+// String values may contain :param placeholders, which are substituted with
+// the matched route parameters (e.g. Title: "User :id").
+type RouteMeta struct {
+	Title       string
+	Description string
+	Image       string            // og:image URL
+	Type        string            // og:type, defaults to "website"
+	URL         string            // og:url / canonical URL
+	Extra       map[string]string // additional <meta name="..." content="..."> tags
+}
+
+// MetaFunc computes route metadata from the matched route params and the
+// decoded preload responses (keyed by RPC method). It receives the static
+// RouteMeta (already substituted) so it can override individual fields.
+type MetaFunc func(r *http.Request, params map[string]string, responses map[string]proto.Message, meta RouteMeta) RouteMeta
+
+// withDefaults fills empty fields from the given fallback metadata.
+func (m RouteMeta) withDefaults(fallback RouteMeta) RouteMeta {
+	if m.Title == "" {
+		m.Title = fallback.Title
+	}
+	if m.Description == "" {
+		m.Description = fallback.Description
+	}
+	if m.Image == "" {
+		m.Image = fallback.Image
+	}
+	if m.Type == "" {
+		m.Type = fallback.Type
+	}
+	if m.URL == "" {
+		m.URL = fallback.URL
+	}
+	if len(fallback.Extra) > 0 {
+		extra := make(map[string]string, len(fallback.Extra)+len(m.Extra))
+		for k, v := range fallback.Extra {
+			extra[k] = v
+		}
+		for k, v := range m.Extra {
+			extra[k] = v
+		}
+		m.Extra = extra
+	}
+	return m
+}
+
+// substitute replaces :param placeholders in all metadata values.
+func (m RouteMeta) substitute(params map[string]string) RouteMeta {
+	if len(params) == 0 {
+		return m
+	}
+	m.Title = substituteValue(m.Title, params)
+	m.Description = substituteValue(m.Description, params)
+	m.Image = substituteValue(m.Image, params)
+	m.URL = substituteValue(m.URL, params)
+	if len(m.Extra) > 0 {
+		m.Extra = SubstituteParams(m.Extra, params)
+	}
+	return m
+}
+
+// resolveMeta computes the final metadata for a matched route.
+func (p *PreloadEngine) resolveMeta(r *http.Request, result preloadResult) RouteMeta {
+	meta := p.defaultMeta
+	if result.route != nil {
+		meta = result.route.Meta.substitute(result.params).withDefaults(p.defaultMeta)
+		if result.route.MetaFunc != nil {
+			meta = result.route.MetaFunc(r, result.params, result.responses, meta).withDefaults(p.defaultMeta)
+		}
+	}
+	if meta.Type == "" {
+		meta.Type = "website"
+	}
+	return meta
+}
@@ -0,0 +1,53 @@
+package gapp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// NDJSON streams, negotiated with "Accept: application/x-ndjson", send a JSON
+// object per line for curl and log tooling, in grpc-gateway's layout:
+//
+//	{"result": {...}}            a message, as protojson
+//	{"resumeToken": "..."}       a resume token
+//	{"error": {"code": ...}}     the error trailer
+//
+// Keepalives are empty lines.
+
+var errNDJSONBytes = errors.New("gapp: the client negotiated an NDJSON stream, which needs SendMessage")
+
+// acceptsNDJSON reports whether the request asks for an NDJSON stream.
+func acceptsNDJSON(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, _ := mime.ParseMediaType(strings.TrimSpace(accept)); mediaType == "application/x-ndjson" {
+			return true
+		}
+	}
+	return false
+}
+
+func ndjsonResult(msg proto.Message) ([]byte, error) {
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling stream message: %w", err)
+	}
+	return json.Marshal(map[string]json.RawMessage{"result": data})
+}
+
+// ndjsonControl returns the line for a token or trailer frame.
+func ndjsonControl(flag uint32, data []byte) []byte {
+	var line []byte
+	if flag == tokenFlag {
+		line, _ = json.Marshal(map[string]string{"resumeToken": string(data)})
+	} else {
+		line, _ = json.Marshal(map[string]json.RawMessage{"error": data})
+	}
+	return line
+}
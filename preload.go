@@ -8,6 +8,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"html/template"
+	"io/fs"
 	"log/slog"
 	"net/http"
 	"os"
@@ -22,15 +23,23 @@ import (
 type ViteManifest map[string]ViteManifestEntry
 
 type ViteManifestEntry struct {
-	File string   `json:"file"`
-	Src  string   `json:"src"`
-	CSS  []string `json:"css"`
+	File           string   `json:"file"`
+	Src            string   `json:"src"`
+	Name           string   `json:"name"`
+	IsEntry        bool     `json:"isEntry"`
+	IsDynamicEntry bool     `json:"isDynamicEntry"`
+	CSS            []string `json:"css"`
+	Imports        []string `json:"imports"`
+	DynamicImports []string `json:"dynamicImports"`
 }
 
 // Assets holds the resolved asset paths from Vite manifest
 type Assets struct {
-	JS  string
-	CSS string
+	JS       string   // primary entry script
+	CSS      string   // primary stylesheet
+	Scripts  []string // entry scripts, in order
+	Preloads []string // statically imported chunks, for <link rel="modulepreload">
+	Styles   []string // stylesheets of the entries and all their imported chunks
 }
 
 //go:embed template.html
@@ -43,38 +52,54 @@ type PreloadedRpc struct {
 }
 
 // ToProtoBytes marshals a proto message, gzip-compresses it, and base64-encodes the result.
+// Failures are logged to slog.Default() and return "".
 func ToProtoBytes(v any) string {
+	return toProtoBytes(slog.Default(), v)
+}
+
+// toProtoBytes is ToProtoBytes logging to logger.
+func toProtoBytes(logger *slog.Logger, v any) string {
 	if v == nil {
 		return ""
 	}
 	if msg, ok := v.(proto.Message); ok {
 		protoBytes, err := proto.Marshal(msg)
 		if err != nil {
-			slog.Error("Failed to marshal proto message", "error", err)
+			logger.Error("Failed to marshal proto message", "error", err)
 			return ""
 		}
 
 		var buf bytes.Buffer
 		gz := gzip.NewWriter(&buf)
 		if _, err := gz.Write(protoBytes); err != nil {
-			slog.Error("Failed to gzip compress", "error", err)
+			logger.Error("Failed to gzip compress", "error", err)
 			return ""
 		}
 		if err := gz.Close(); err != nil {
-			slog.Error("Failed to close gzip writer", "error", err)
+			logger.Error("Failed to close gzip writer", "error", err)
 			return ""
 		}
 
 		return base64.StdEncoding.EncodeToString(buf.Bytes())
 	}
-	slog.Error("ToProtoBytes called with non-proto value")
+	logger.Error("ToProtoBytes called with non-proto value")
 	return ""
 }
 
 // RouteSpec defines preload configuration for a route pattern.
 type RouteSpec struct {
-	Pattern string
-	Rpcs    []RpcSpec
+	Pattern  string
+	Rpcs     []RpcSpec
+	Meta     RouteMeta // static head metadata, :param placeholders are substituted
+	MetaFunc MetaFunc  // optional, computes metadata from the preloaded responses
+
+	// RedirectTo turns the route into a redirect rule evaluated before
+	// preloading, e.g. Pattern "/old/:id" with RedirectTo "/new/:id".
+	RedirectTo     string
+	RedirectStatus int // http.StatusMovedPermanently or http.StatusFound (default)
+
+	Entry  string   // manifest key of the route's entry, defaults to the engine's Entry
+	Chunks []string // manifest keys of dynamic-import chunks to preload for the route
 }
 
 // RpcSpec defines an RPC to preload with optional parameter mappings.
@@ -93,58 +118,221 @@ type PreloadEngine struct {
 	Routes      []RouteSpec
 	PreloadFunc PreloadFunc
 	tmpl        *template.Template
-	assets      Assets
+	appName     string
+	defaultMeta RouteMeta
+	data        any
+	streamHTML  bool
+	earlyHints  bool
+	ssr         SSRFunc
+	notFound    *RouteSpec
+	errorRoute  *RouteSpec
+	version     AppVersion
+	logger      *slog.Logger
+	slowPreload time.Duration
+	onSlow      SlowCallFunc
+	pwa         bool
+
+	assetsMu              sync.RWMutex
+	assets                Assets
+	manifest              ViteManifest
+	manifestPath          string
+	assetsFS              fs.FS
+	entry                 string
+	manifestModTime       time.Time
+	manifestCheckedAt     time.Time
+	manifestCheckInterval time.Duration
 }
 
 type PreloadEngineConfig struct {
 	Routes       []RouteSpec
 	PreloadFunc  PreloadFunc
-	ManifestPath string // path to .vite/manifest.json, defaults to "public/.vite/manifest.json"
-	AppName      string // defaults to "App"
+	ManifestPath string    // path to .vite/manifest.json, defaults to "public/.vite/manifest.json"
+	AssetsFS     fs.FS     // built client assets (see EmbeddedAssets); ManifestPath is then relative to it
+	AppName      string    // defaults to $APP_NAME, then "App"
+	DefaultMeta  RouteMeta // fallback metadata for routes that don't set their own, Title defaults to AppName
+
+	// ManifestCheckInterval controls how often the manifest mtime is checked
+	// for changes. Defaults to 1s; a negative value disables automatic reloads.
+	ManifestCheckInterval time.Duration
+
+	Template     string           // custom HTML template source, overrides the embedded template.html
+	TemplatePath string           // path to a custom HTML template file, used when Template is empty
+	FuncMap      template.FuncMap // extra functions available to the template
+	Data         any              // app-specific data exposed to the template as .Data
+
+	// StreamHTML flushes the "head" template before preloads complete and
+	// streams the "preload" template once they finish. Routes with a MetaFunc
+	// are always rendered buffered, since their head depends on the responses.
+	StreamHTML bool
+
+	// EarlyHints sends a 103 Early Hints response with Link preload headers
+	// for the JS/CSS assets before running the preloads.
+	EarlyHints bool
+
+	// SSR renders the app server-side once preloads complete. Its markup is
+	// spliced into the template as .SSRHTML and .SSRHead.
+	SSR SSRFunc
+
+	// NotFound is rendered with a 404 status instead of a bare text response
+	// for paths ServeHTML refuses to serve, and by RenderNotFound.
+	NotFound *RouteSpec
+
+	// Error is rendered with a 500 status when the page template fails.
+	Error *RouteSpec
+
+	Entry string // manifest key of the default entry, defaults to "index.html"
+
+	SchemaHash string // proto schema hash from codegen (generated.SchemaHash)
+	BuildID    string // app build identifier, defaults to $GAPP_BUILD_ID, then BuildInfo.Commit
+
+	// SlowPreloadThreshold logs a warning for preload RPCs that take longer,
+	// and passes them to OnSlowPreload if set. Zero disables the check.
+	SlowPreloadThreshold time.Duration
+	OnSlowPreload        SlowCallFunc
+
+	// PWA links /manifest.webmanifest and registers the /sw.js service worker
+	// generated by `gapp build --pwa`.
+	PWA bool
+}
+
+// AppVersion is embedded in the rendered HTML as window.__GAPP_VERSION__ so
+// the client can detect a stale bundle before decoding preloaded payloads.
+type AppVersion struct {
+	SchemaHash string         `json:"schemaHash"`
+	BuildID    string         `json:"buildId"`
+	Build      *BuildMetadata `json:"build,omitempty"` // BuildInfo, when known
+}
+
+// TemplateData is the data passed to the HTML template. Custom templates must
+// render PreloadedJSON and the asset tags for the client to hydrate.
+type TemplateData struct {
+	PreloadedJSON template.JS
+	Timestamp     int64
+	AssetsJS      string
+	AssetsCSS     string
+	Assets        Assets
+	AppName       string
+	Meta          RouteMeta
+	Data          any
+	SSRHTML       template.HTML // server-rendered app markup, empty without SSR
+	SSRHead       template.HTML // server-rendered head tags, empty without SSR
+	Version       AppVersion
+	PWA           bool // link the web app manifest and register the service worker
 }
 
-func NewPreloadEngine(config PreloadEngineConfig) *PreloadEngine {
-	tmpl := template.Must(template.ParseFS(templateFS, "template.html"))
+// PreloadOption configures a PreloadEngine.
+type PreloadOption interface {
+	applyPreload(p *PreloadEngine)
+}
+
+func NewPreloadEngine(config PreloadEngineConfig, opts ...PreloadOption) *PreloadEngine {
+	tmpl := template.Must(parseTemplate(config))
+	assetsFS := config.AssetsFS
+	if assetsFS == nil && config.ManifestPath == "" {
+		assetsFS = registeredEmbeddedAssets()
+	}
 	manifestPath := config.ManifestPath
 	if manifestPath == "" {
 		manifestPath = "public/.vite/manifest.json"
+		if assetsFS != nil {
+			manifestPath = ".vite/manifest.json"
+		}
 	}
-	assets := LoadAssetsFromManifest(manifestPath)
-	return &PreloadEngine{
-		Routes:      config.Routes,
-		PreloadFunc: config.PreloadFunc,
-		tmpl:        tmpl,
-		assets:      assets,
+	entry := config.Entry
+	if entry == "" {
+		entry = "index.html"
+	}
+	checkInterval := config.ManifestCheckInterval
+	if checkInterval == 0 {
+		checkInterval = time.Second
 	}
-}
 
-// LoadAssetsFromManifest reads the Vite manifest to get hashed asset filenames.
-func LoadAssetsFromManifest(manifestPath string) Assets {
-	assets := Assets{
-		JS:  "/assets/index.js",
-		CSS: "/assets/index.css",
+	appName := config.AppName
+	if appName == "" {
+		appName = os.Getenv("APP_NAME")
+	}
+	if appName == "" {
+		appName = "App"
+	}
+	defaultMeta := config.DefaultMeta
+	if defaultMeta.Title == "" {
+		defaultMeta.Title = appName
+	}
+	if defaultMeta.Description == "" {
+		defaultMeta.Description = appName
 	}
 
-	data, err := os.ReadFile(manifestPath)
-	if err != nil {
-		slog.Info("Vite manifest not found, using default assets", "error", err)
-		return assets
+	buildID := config.BuildID
+	if buildID == "" {
+		buildID = os.Getenv("GAPP_BUILD_ID")
+	}
+	if buildID == "" {
+		buildID = BuildInfo.Commit
+	}
+	var build *BuildMetadata
+	if !BuildInfo.IsZero() {
+		info := BuildInfo
+		build = &info
 	}
 
-	var manifest ViteManifest
-	if err := json.Unmarshal(data, &manifest); err != nil {
-		slog.Error("Failed to parse Vite manifest", "error", err)
-		return assets
+	p := &PreloadEngine{
+		Routes:      config.Routes,
+		PreloadFunc: config.PreloadFunc,
+		tmpl:        tmpl,
+		appName:     appName,
+		defaultMeta: defaultMeta,
+		data:        config.Data,
+		streamHTML:  config.StreamHTML,
+		earlyHints:  config.EarlyHints,
+		ssr:         config.SSR,
+		notFound:    config.NotFound,
+		errorRoute:  config.Error,
+		version:     AppVersion{SchemaHash: config.SchemaHash, BuildID: buildID, Build: build},
+		slowPreload: config.SlowPreloadThreshold,
+		onSlow:      config.OnSlowPreload,
+		pwa:         config.PWA,
+
+		manifestPath:          manifestPath,
+		assetsFS:              assetsFS,
+		entry:                 entry,
+		manifestCheckInterval: checkInterval,
+	}
+	for _, opt := range opts {
+		opt.applyPreload(p)
 	}
+	if p.streamHTML && (tmpl.Lookup("head") == nil || tmpl.Lookup("preload") == nil) {
+		p.log().Warn("StreamHTML requires \"head\" and \"preload\" templates, falling back to buffered rendering")
+		p.streamHTML = false
+	}
+	p.ReloadAssets()
+	return p
+}
 
-	if entry, ok := manifest["index.html"]; ok {
-		assets.JS = "/" + entry.File
-		if len(entry.CSS) > 0 {
-			assets.CSS = "/" + entry.CSS[0]
+// parseTemplate parses the custom template from the config, falling back to
+// the embedded template.html.
+func parseTemplate(config PreloadEngineConfig) (*template.Template, error) {
+	tmpl := template.New("template.html").Funcs(config.FuncMap)
+	switch {
+	case config.Template != "":
+		return tmpl.Parse(config.Template)
+	case config.TemplatePath != "":
+		data, err := os.ReadFile(config.TemplatePath)
+		if err != nil {
+			return nil, err
 		}
-		slog.Info("Loaded assets from Vite manifest", "js", assets.JS, "css", assets.CSS)
+		return tmpl.Parse(string(data))
+	default:
+		return tmpl.ParseFS(templateFS, "template.html")
 	}
+}
 
+// LoadAssetsFromManifest reads the Vite manifest to get hashed asset filenames.
+func LoadAssetsFromManifest(manifestPath string, opts ...PreloadOption) Assets {
+	p := &PreloadEngine{}
+	for _, opt := range opts {
+		opt.applyPreload(p)
+	}
+	assets, _ := loadEntryAssets(p.log(), nil, manifestPath, "index.html")
 	return assets
 }
 
@@ -153,15 +341,31 @@ func (p *PreloadEngine) ServeHTML(w http.ResponseWriter, r *http.Request) {
 	if strings.HasPrefix(r.URL.Path, "/assets/") ||
 		strings.HasPrefix(r.URL.Path, "/rpc") ||
 		strings.HasPrefix(r.URL.Path, "/__preload") {
-		http.NotFound(w, r)
+		p.RenderNotFound(w, r)
+		return
+	}
+
+	route, params := MatchRoute(p.Routes, r.URL.Path)
+	if route != nil && route.RedirectTo != "" {
+		redirect(w, r, route, params)
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
 	defer cancel()
 
-	preloaded := p.executeForPath(ctx, r)
-	p.renderHTML(w, preloaded)
+	writeAssetLinks(w, p.assetsFor(route))
+	if p.earlyHints {
+		w.WriteHeader(http.StatusEarlyHints)
+	}
+
+	if p.streamHTML && (route == nil || route.MetaFunc == nil) {
+		p.streamRenderHTML(ctx, w, r)
+		return
+	}
+
+	result := p.executeForPath(ctx, r)
+	p.renderHTML(ctx, w, r, result, p.resolveMeta(r, result), http.StatusOK)
 }
 
 // HandlePreloadEndpoint handles the /__preload?path=... endpoint used by the Vite plugin in dev mode.
@@ -177,23 +381,42 @@ func (p *PreloadEngine) HandlePreloadEndpoint(w http.ResponseWriter, r *http.Req
 	fakeReq := r.Clone(ctx)
 	fakeReq.URL.Path = path
 
-	preloaded := p.executeForPath(ctx, fakeReq)
+	result := p.executeForPath(ctx, fakeReq)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", r.Header.Get("Origin"))
 	w.Header().Set("Access-Control-Allow-Credentials", "true")
-	json.NewEncoder(w).Encode(preloaded)
+	json.NewEncoder(w).Encode(result.rpcs)
 }
 
-func (p *PreloadEngine) executeForPath(ctx context.Context, r *http.Request) map[string]PreloadedRpc {
-	preloaded := make(map[string]PreloadedRpc)
-	var mu sync.Mutex
-	var wg sync.WaitGroup
+// preloadResult holds the outcome of preloading a single request path.
+type preloadResult struct {
+	route     *RouteSpec
+	params    map[string]string
+	rpcs      map[string]PreloadedRpc
+	responses map[string]proto.Message
+}
 
+func (p *PreloadEngine) executeForPath(ctx context.Context, r *http.Request) preloadResult {
 	route, routeParams := MatchRoute(p.Routes, r.URL.Path)
-	if route == nil {
-		return preloaded
+	if route == nil || route.RedirectTo != "" {
+		return preloadResult{
+			rpcs:      make(map[string]PreloadedRpc),
+			responses: make(map[string]proto.Message),
+		}
 	}
+	return p.executeForRoute(ctx, r, route, routeParams)
+}
+
+func (p *PreloadEngine) executeForRoute(ctx context.Context, r *http.Request, route *RouteSpec, routeParams map[string]string) preloadResult {
+	result := preloadResult{
+		route:     route,
+		params:    routeParams,
+		rpcs:      make(map[string]PreloadedRpc),
+		responses: make(map[string]proto.Message),
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
 
 	for _, rpcSpec := range route.Rpcs {
 		rpcSpec := rpcSpec
@@ -205,58 +428,166 @@ func (p *PreloadEngine) executeForPath(ctx context.Context, r *http.Request) map
 			rpcParams := SubstituteParams(rpcSpec.Params, routeParams)
 
 			if HasUnsubstitutedParam(rpcParams) {
-				slog.Info("Preload: Skipping - unsubstituted params", "method", rpcSpec.Method, "params", rpcParams)
+				p.log().Info("Preload: Skipping - unsubstituted params", "method", rpcSpec.Method, "params", rpcParams)
 				return
 			}
 
+			start := time.Now()
 			req, resp, err := p.PreloadFunc(ctx, r, rpcSpec.Method, rpcParams)
+			if duration := time.Since(start); p.slowPreload > 0 && duration > p.slowPreload {
+				reportSlow(p.log(), SlowCall{
+					Kind:      "preload",
+					Method:    rpcSpec.Method,
+					Path:      r.URL.Path,
+					Duration:  duration,
+					Threshold: p.slowPreload,
+					Params:    rpcParams,
+				}, p.onSlow)
+			}
 			if err != nil {
-				slog.Info("Preload: Failed", "method", rpcSpec.Method, "error", err)
+				p.log().Info("Preload: Failed", "method", rpcSpec.Method, "error", err)
 				return
 			}
 
 			mu.Lock()
-			preloaded[rpcSpec.Method] = PreloadedRpc{
-				RequestBytes:  ToProtoBytes(req),
-				ResponseBytes: ToProtoBytes(resp),
+			result.rpcs[rpcSpec.Method] = PreloadedRpc{
+				RequestBytes:  toProtoBytes(p.log(), req),
+				ResponseBytes: toProtoBytes(p.log(), resp),
 			}
+			result.responses[rpcSpec.Method] = resp
 			mu.Unlock()
 		}()
 	}
 
 	wg.Wait()
-	return preloaded
+	return result
 }
 
-func (p *PreloadEngine) renderHTML(w http.ResponseWriter, preloaded map[string]PreloadedRpc) {
-	jsonBytes, _ := json.Marshal(preloaded)
+func (p *PreloadEngine) templateData(route *RouteSpec, rpcs map[string]PreloadedRpc, meta RouteMeta) TemplateData {
+	jsonBytes, _ := json.Marshal(rpcs)
+	assets := p.assetsFor(route)
 
-	appName := os.Getenv("APP_NAME")
-	if appName == "" {
-		appName = "App"
-	}
-
-	data := struct {
-		PreloadedJSON template.JS
-		Timestamp     int64
-		AssetsJS      string
-		AssetsCSS     string
-		AppName       string
-	}{
+	return TemplateData{
 		PreloadedJSON: template.JS(jsonBytes),
 		Timestamp:     time.Now().UnixMilli(),
-		AssetsJS:      p.assets.JS,
-		AssetsCSS:     p.assets.CSS,
-		AppName:       appName,
+		AssetsJS:      assets.JS,
+		AssetsCSS:     assets.CSS,
+		Assets:        assets,
+		AppName:       p.appName,
+		Meta:          meta,
+		Data:          p.data,
+		Version:       p.version,
+		PWA:           p.pwa,
+	}
+}
+
+// writeAssetLinks adds Link preload headers for the resolved JS/CSS assets.
+// They are sent with the 103 Early Hints response and kept on the final one.
+func writeAssetLinks(w http.ResponseWriter, assets Assets) {
+	for _, js := range assets.Scripts {
+		w.Header().Add("Link", "<"+js+">; rel=modulepreload; crossorigin")
+	}
+	for _, js := range assets.Preloads {
+		w.Header().Add("Link", "<"+js+">; rel=modulepreload; crossorigin")
+	}
+	for _, css := range assets.Styles {
+		w.Header().Add("Link", "<"+css+">; rel=preload; as=style; crossorigin")
 	}
+}
 
+func setHTMLHeaders(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+}
+
+func (p *PreloadEngine) renderHTML(ctx context.Context, w http.ResponseWriter, r *http.Request, result preloadResult, meta RouteMeta, status int) {
+	data := p.templateData(result.route, result.rpcs, meta)
+	p.applySSR(ctx, r, result, &data)
 
-	if err := p.tmpl.Execute(w, data); err != nil {
-		slog.Error("Failed to render HTML template", "error", err)
-		http.Error(w, "Internal Server Error", 500)
+	var buf bytes.Buffer
+	if err := p.tmpl.Execute(&buf, data); err != nil {
+		p.log().Error("Failed to render HTML template", "error", err)
+		if status == http.StatusOK {
+			p.renderError(ctx, w, r)
+		} else {
+			http.Error(w, http.StatusText(status), status)
+		}
+		return
 	}
+
+	setHTMLHeaders(w)
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+// streamRenderHTML writes and flushes the "head" template immediately, then
+// runs the preloads and writes the "preload" template with their results.
+func (p *PreloadEngine) streamRenderHTML(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	route, params := MatchRoute(p.Routes, r.URL.Path)
+	meta := p.resolveMeta(r, preloadResult{route: route, params: params})
+
+	var head bytes.Buffer
+	if err := p.tmpl.ExecuteTemplate(&head, "head", p.templateData(route, nil, meta)); err != nil {
+		p.log().Error("Failed to render HTML head template", "error", err)
+		p.renderError(ctx, w, r)
+		return
+	}
+
+	setHTMLHeaders(w)
+	w.Write(head.Bytes())
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
+	result := p.executeForPath(ctx, r)
+	data := p.templateData(result.route, result.rpcs, meta)
+	p.applySSR(ctx, r, result, &data)
+
+	// The status line is already sent, so failures can only be logged.
+	if err := p.tmpl.ExecuteTemplate(w, "preload", data); err != nil {
+		p.log().Error("Failed to render HTML preload template", "error", err)
+	}
+}
+
+// redirect sends the client to the route's RedirectTo target, substituting
+// route params and preserving the query string. Optional params that weren't
+// matched are dropped from the target.
+func redirect(w http.ResponseWriter, r *http.Request, route *RouteSpec, params map[string]string) {
+	target, _ := fillPattern(route.RedirectTo, params)
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+
+	status := route.RedirectStatus
+	if status == 0 {
+		status = http.StatusFound
+	}
+	http.Redirect(w, r, target, status)
+}
+
+// fillPattern substitutes params into a route pattern, dropping segments for
+// params that weren't given. It reports false if a required param is missing.
+func fillPattern(pattern string, params map[string]string) (string, bool) {
+	complete := true
+	var parts []string
+	for _, part := range strings.Split(pattern, "/") {
+		if strings.HasPrefix(part, ":") {
+			value, ok := params[strings.TrimSuffix(strings.TrimPrefix(part, ":"), "?")]
+			if !ok {
+				if !strings.HasSuffix(part, "?") {
+					complete = false
+				}
+				continue
+			}
+			part = value
+		}
+		parts = append(parts, part)
+	}
+	path := strings.Join(parts, "/")
+	if path == "" {
+		path = "/"
+	}
+	return path, complete
 }
 
 // MatchRoute finds the first matching route for a given path.
@@ -320,14 +651,19 @@ func SubstituteParams(rpcParams map[string]string, routeParams map[string]string
 	}
 	result := make(map[string]string)
 	for key, value := range rpcParams {
-		for paramName, paramValue := range routeParams {
-			value = strings.ReplaceAll(value, ":"+paramName, paramValue)
-		}
-		result[key] = value
+		result[key] = substituteValue(value, routeParams)
 	}
 	return result
 }
 
+// substituteValue replaces :param placeholders in a single value.
+func substituteValue(value string, routeParams map[string]string) string {
+	for paramName, paramValue := range routeParams {
+		value = strings.ReplaceAll(value, ":"+paramName, paramValue)
+	}
+	return value
+}
+
 // HasUnsubstitutedParam checks if any parameter values still contain unresolved :param placeholders.
 func HasUnsubstitutedParam(params map[string]string) bool {
 	for _, v := range params {
@@ -337,3 +673,10 @@ func HasUnsubstitutedParam(params map[string]string) bool {
 	}
 	return false
 }
+
+func (p *PreloadEngine) log() *slog.Logger {
+	if p.logger != nil {
+		return p.logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,68 @@
+package gapp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// WithH2C enables HTTP/2 over cleartext TCP alongside HTTP/1.1, for servers
+// behind L4 load balancers that don't terminate TLS. Streaming RPCs and
+// parallel preload fetches then share one multiplexed connection.
+func WithH2C() ServeOption {
+	return serveOption(func(o *serveOptions) {
+		o.h2c = true
+	})
+}
+
+func enableH2C(server *http.Server) {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	server.Protocols = &protocols
+}
+
+// HTTP3Server is a QUIC server such as *http3.Server from
+// github.com/quic-go/quic-go/http3.
+type HTTP3Server interface {
+	ListenAndServe() error
+	Shutdown(ctx context.Context) error
+}
+
+// HTTP3ServerFunc builds the HTTP/3 server for addr from the handler and the
+// TLS configuration of the HTTPS server. With quic-go:
+//
+//	gapp.WithHTTP3(func(addr string, h http.Handler, cfg *tls.Config) gapp.HTTP3Server {
+//		return &http3.Server{Addr: addr, Handler: h, TLSConfig: http3.ConfigureTLSConfig(cfg)}
+//	})
+type HTTP3ServerFunc func(addr string, handler http.Handler, tlsConfig *tls.Config) HTTP3Server
+
+// WithHTTP3 additionally serves HTTP/3 over QUIC (UDP) on the same address
+// and advertises it with an Alt-Svc header. It's experimental and requires
+// WithTLS or WithAutocert. gapp doesn't bundle a QUIC implementation, so
+// newServer adapts one.
+func WithHTTP3(newServer HTTP3ServerFunc) ServeOption {
+	return serveOption(func(o *serveOptions) {
+		o.http3 = newServer
+	})
+}
+
+func http3Listener(server HTTP3Server, addr string) listener {
+	return listener{addr: addr, protocol: "http3", serve: server.ListenAndServe, shutdown: server.Shutdown}
+}
+
+// advertiseHTTP3 adds the Alt-Svc header pointing browsers at the QUIC port.
+func advertiseHTTP3(addr string, next http.Handler) http.Handler {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil || port == "" {
+		port = "443"
+	}
+	altSvc := fmt.Sprintf(`h3=":%s"; ma=86400`, port)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Alt-Svc", altSvc)
+		next.ServeHTTP(w, r)
+	})
+}
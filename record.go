@@ -0,0 +1,164 @@
+package gapp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// RecordedCall is a unary RPC captured by a Recorder. Recordings are JSON
+// Lines files with one RecordedCall per line.
+type RecordedCall struct {
+	Time     time.Time         `json:"time"`
+	Method   string            `json:"method"`
+	Request  []byte            `json:"request"`
+	Response []byte            `json:"response,omitempty"`
+	Error    *RpcError         `json:"error,omitempty"`
+	Duration time.Duration     `json:"duration"`
+	Header   map[string]string `json:"header,omitempty"` // request headers listed in RecordConfig.Headers
+}
+
+// RecordConfig controls what a Recorder captures.
+type RecordConfig struct {
+	Path       string   // file recordings are appended to
+	Methods    []string // methods to record, all when empty
+	SampleRate float64  // fraction of calls recorded, default 1
+	Headers    []string // request headers kept with each call; cookies and Authorization are never kept
+}
+
+// Recorder captures unary RPC traffic to disk, to debug production issues or
+// build regression suites replayed with gaptest.Replay. Requests and
+// responses are stored as-is, so record where that data may be kept.
+// Streaming, upload and download calls are not recorded.
+type Recorder struct {
+	config RecordConfig
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewRecorder opens config.Path for appending.
+func NewRecorder(config RecordConfig) (*Recorder, error) {
+	if config.SampleRate <= 0 {
+		config.SampleRate = 1
+	}
+	file, err := os.OpenFile(config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("opening recording: %w", err)
+	}
+	return &Recorder{config: config, file: file}, nil
+}
+
+// Middleware records the calls passing through it, for Dispatcher.Use.
+func (rec *Recorder) Middleware() Middleware {
+	return func(next RpcHandler) RpcHandler {
+		return func(w http.ResponseWriter, r *http.Request, method string, body []byte) ([]byte, error) {
+			if !rec.wants(r, method) {
+				return next(w, r, method, body)
+			}
+
+			start := time.Now()
+			resp, err := next(w, r, method, body)
+			if resp == nil && err == nil {
+				// The handler wrote its own response, like a stream or download
+				return resp, err
+			}
+
+			call := RecordedCall{
+				Time:     start,
+				Method:   method,
+				Request:  body,
+				Response: resp,
+				Duration: time.Since(start),
+			}
+			if err != nil {
+				call.Error = asRpcError(err)
+			}
+			for _, name := range rec.config.Headers {
+				if value := r.Header.Get(name); value != "" && !sensitiveHeader(name) {
+					if call.Header == nil {
+						call.Header = make(map[string]string)
+					}
+					call.Header[http.CanonicalHeaderKey(name)] = value
+				}
+			}
+			rec.write(call)
+			return resp, err
+		}
+	}
+}
+
+func (rec *Recorder) wants(r *http.Request, method string) bool {
+	if r.Method != http.MethodPost || isUploadCall(r) {
+		return false
+	}
+	if len(rec.config.Methods) > 0 && !slices.Contains(rec.config.Methods, method) {
+		return false
+	}
+	return rec.config.SampleRate >= 1 || rand.Float64() < rec.config.SampleRate
+}
+
+func (rec *Recorder) write(call RecordedCall) {
+	line, err := json.Marshal(call)
+	if err != nil {
+		return
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.file == nil {
+		return
+	}
+	rec.file.Write(append(line, '\n'))
+}
+
+// Shutdown closes the recording. Its signature matches OnShutdown.
+func (rec *Recorder) Shutdown(ctx context.Context) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.file == nil {
+		return nil
+	}
+	err := rec.file.Close()
+	rec.file = nil
+	return err
+}
+
+// ReadRecording reads the calls recorded at path.
+func ReadRecording(path string) ([]RecordedCall, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var calls []RecordedCall
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var call RecordedCall
+		if err := json.Unmarshal(scanner.Bytes(), &call); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		calls = append(calls, call)
+	}
+	return calls, scanner.Err()
+}
+
+func sensitiveHeader(name string) bool {
+	switch http.CanonicalHeaderKey(name) {
+	case "Authorization", "Cookie", "Proxy-Authorization":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package gapp
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+type responseMetaKeyType struct{}
+
+var responseMetaKey = responseMetaKeyType{}
+
+// ResponseMetadata is metadata a handler sends with its response, such as a
+// pagination cursor or cache hints, as HTTP headers. It's safe for
+// concurrent use.
+type ResponseMetadata struct {
+	mu     sync.Mutex
+	header http.Header
+	sent   bool
+}
+
+// ResponseMeta returns the metadata of the response to the RPC call of ctx.
+// Handlers set it rather than the headers of their ResponseWriter, which
+// middleware may wrap, buffer or replace:
+//
+//	gapp.ResponseMeta(ctx).Set("X-Next-Cursor", cursor)
+//
+// The Dispatcher sends it with the response, successful or not, so streams
+// must set it before their first message; later changes are dropped. Outside
+// RPC calls, such as in preloads, it's discarded.
+func ResponseMeta(ctx context.Context) *ResponseMetadata {
+	if meta, ok := ctx.Value(responseMetaKey).(*ResponseMetadata); ok {
+		return meta
+	}
+	return &ResponseMetadata{header: make(http.Header)}
+}
+
+// Set sets the value of key, replacing any others.
+func (m *ResponseMetadata) Set(key, value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.sent {
+		m.header.Set(key, value)
+	}
+}
+
+// Add adds value to the values of key.
+func (m *ResponseMetadata) Add(key, value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.sent {
+		m.header.Add(key, value)
+	}
+}
+
+// Del deletes the values of key.
+func (m *ResponseMetadata) Del(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.sent {
+		m.header.Del(key)
+	}
+}
+
+// Get returns the first value of key, or "" if it has none.
+func (m *ResponseMetadata) Get(key string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.header.Get(key)
+}
+
+// send copies the metadata into header, once, exposing it to cross-origin
+// clients when CORS applies.
+func (m *ResponseMetadata) send(header http.Header) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sent {
+		return
+	}
+	m.sent = true
+	cors := header.Get("Access-Control-Allow-Origin") != ""
+	for key, values := range m.header {
+		header[key] = values
+		if cors {
+			header.Add("Access-Control-Expose-Headers", key)
+		}
+	}
+}
+
+// metaResponse is the ResponseWriter the Dispatcher passes to handlers. It
+// sends the call's ResponseMetadata when the response starts.
+type metaResponse struct {
+	http.ResponseWriter
+	meta *ResponseMetadata
+}
+
+func (m *metaResponse) WriteHeader(code int) {
+	m.meta.send(m.ResponseWriter.Header())
+	m.ResponseWriter.WriteHeader(code)
+}
+
+func (m *metaResponse) Write(p []byte) (int, error) {
+	m.meta.send(m.ResponseWriter.Header())
+	return m.ResponseWriter.Write(p)
+}
+
+func (m *metaResponse) Flush() {
+	m.meta.send(m.ResponseWriter.Header())
+	http.NewResponseController(m.ResponseWriter).Flush()
+}
+
+func (m *metaResponse) Unwrap() http.ResponseWriter {
+	return m.ResponseWriter
+}
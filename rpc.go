@@ -1,18 +1,24 @@
 package gapp
 
 import (
+	"context"
 	"errors"
 	"io"
 	"log/slog"
 	"net/http"
+	"time"
 )
 
 // UnaryHandler handles a unary RPC call. It receives the method name and request body,
 // and returns the serialized response bytes or an error.
 type UnaryHandler func(w http.ResponseWriter, r *http.Request, method string, body []byte) ([]byte, error)
 
-// StreamHandler handles a streaming RPC call. It receives the method name, request body,
-// and a StreamAdapter for sending responses. It should return nil after writing to the stream.
+// StreamHandler handles a streaming RPC call. It receives the method name and request body,
+// and writes responses with a StreamAdapter from NewRequestStreamAdapter. It should return
+// nil after writing to the stream, or ErrClientGone when the client disconnects.
+// Other errors returned after the stream started are sent to the client in an
+// error trailer (see StreamAdapter.SendError). StreamHandlerFor builds one from
+// a typed handler.
 type StreamHandler func(w http.ResponseWriter, r *http.Request, method string, body []byte) error
 
 // RpcHandler is the callback signature used by middleware and the dispatcher.
@@ -29,21 +35,52 @@ type CORSConfig struct {
 }
 
 // DispatcherOption configures a Dispatcher.
-type DispatcherOption func(*Dispatcher)
+type DispatcherOption interface {
+	applyDispatcher(d *Dispatcher)
+}
+
+type dispatcherOption func(*Dispatcher)
+
+func (o dispatcherOption) applyDispatcher(d *Dispatcher) { o(d) }
+
+// WithSlowThreshold logs a warning for unary RPCs that take longer than
+// threshold and passes them to onSlow, which may be nil. Streaming and
+// download RPCs are exempt, since they are long-lived by design.
+func WithSlowThreshold(threshold time.Duration, onSlow SlowCallFunc) DispatcherOption {
+	return dispatcherOption(func(d *Dispatcher) {
+		d.slowThreshold = threshold
+		d.onSlow = onSlow
+	})
+}
 
 // WithCORS sets the CORS configuration for the dispatcher.
 func WithCORS(config CORSConfig) DispatcherOption {
-	return func(d *Dispatcher) {
+	return dispatcherOption(func(d *Dispatcher) {
 		d.cors = &config
-	}
+	})
 }
 
 // Dispatcher routes RPC calls to registered handlers.
 type Dispatcher struct {
 	Unary       map[string]UnaryHandler
 	Streaming   map[string]StreamHandler
+	Uploads     map[string]UploadHandler
+	Downloads   map[string]DownloadHandler
 	middlewares []Middleware
 	cors        *CORSConfig
+	stats       *rpcStats
+	logger      *slog.Logger
+	uploads     UploadConfig
+
+	// NotFoundHandler, if set, handles calls to methods without a handler,
+	// behind the middlewares, instead of failing them with CodeNotFound, e.g.
+	// to proxy them to a legacy backend or shadow them. As for a
+	// UnaryHandler, nil bytes mean it wrote the response itself. GET links
+	// only reach Downloads.
+	NotFoundHandler UnaryHandler
+
+	slowThreshold time.Duration
+	onSlow        SlowCallFunc
 }
 
 // NewDispatcher creates a new Dispatcher with the given options.
@@ -51,15 +88,19 @@ func NewDispatcher(opts ...DispatcherOption) *Dispatcher {
 	d := &Dispatcher{
 		Unary:     make(map[string]UnaryHandler),
 		Streaming: make(map[string]StreamHandler),
+		Uploads:   make(map[string]UploadHandler),
+		Downloads: make(map[string]DownloadHandler),
+		stats:     newRpcStats(),
 	}
 	for _, opt := range opts {
-		opt(d)
+		opt.applyDispatcher(d)
 	}
 	return d
 }
 
 // Use adds a middleware to the dispatcher. Middlewares are applied in order:
 // first added = outermost (runs first), last added = innermost (runs last, closest to handler).
+// Wrap m with Skip or SkipIf to exempt some methods from it.
 func (d *Dispatcher) Use(m Middleware) {
 	d.middlewares = append(d.middlewares, m)
 }
@@ -77,6 +118,26 @@ func (d *Dispatcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		if h, ok := d.Unary[method]; ok {
 			return h(w, r, method, body)
 		}
+		if h, ok := d.Uploads[method]; ok {
+			// Read after the middlewares so unauthorized uploads aren't spooled
+			upload, err := d.readUpload(r)
+			if err != nil {
+				return nil, err
+			}
+			defer upload.close()
+			return h(w, r, method, upload)
+		}
+		if h, ok := d.Downloads[method]; ok {
+			download, err := h(r, method, body)
+			if err != nil {
+				return nil, err
+			}
+			ServeDownload(w, r, download)
+			return nil, nil
+		}
+		if d.NotFoundHandler != nil {
+			return d.NotFoundHandler(w, r, method, body)
+		}
 		return nil, ErrNotFound("unknown RPC method: " + method)
 	}
 
@@ -96,27 +157,98 @@ func (d *Dispatcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	method := r.Header.Get("X-Rpc-Method")
 
-	body, bodyErr := io.ReadAll(r.Body)
-	if bodyErr != nil {
-		slog.Error("Failed to read request body", "error", bodyErr)
-		writeRpcError(w, ErrValidation("Failed to read request body"))
-		return
+	// Downloads may be plain GET links, with the method and request in the
+	// query. Only downloads are callable that way, so links can't trigger
+	// side effects.
+	_, download := d.Downloads[method]
+	get := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if method == "" && get {
+		method = r.URL.Query().Get("method")
+		if _, download = d.Downloads[method]; !download {
+			writeRpcError(w, ErrNotFound("unknown download method: "+method))
+			return
+		}
+	}
+
+	var body []byte
+	if download && get {
+		var rpcErr *RpcError
+		if body, rpcErr = downloadRequest(r); rpcErr != nil {
+			writeRpcError(w, rpcErr)
+			return
+		}
+	} else if _, upload := d.Uploads[method]; upload {
+		// Upload bodies are streamed to the handler instead of read up
+		// front, so middlewares see no request body (see isUploadCall)
+		r = r.WithContext(context.WithValue(r.Context(), uploadCallKey, true))
+	} else {
+		var bodyErr error
+		body, bodyErr = io.ReadAll(r.Body)
+		if bodyErr != nil {
+			d.log().Error("Failed to read request body", "error", bodyErr)
+			writeRpcError(w, ErrValidation("Failed to read request body"))
+			return
+		}
 	}
 	defer r.Body.Close()
 
-	slog.Info("Handling RPC", "method", method)
+	d.log().Info("Handling RPC", "method", method)
+
+	// Handlers set their ResponseMeta through the context, and it's sent
+	// whichever writer the middlewares hand them
+	meta := &ResponseMetadata{header: make(http.Header)}
+	r = r.WithContext(context.WithValue(r.Context(), responseMetaKey, meta))
+	w = &metaResponse{ResponseWriter: w, meta: meta}
 
+	// Streaming handlers get a writer that finds their StreamAdapter, so a
+	// failure after the stream started can still reach the client
+	var stream *streamResponse
+	if _, ok := d.Streaming[method]; ok {
+		stream = &streamResponse{ResponseWriter: w}
+		w = stream
+	}
+
+	start := time.Now()
 	responseBytes, err := handler(w, r, method, body)
+	duration := time.Since(start)
+	if stream != nil {
+		// After the error trailer below, if any
+		defer stream.close()
+	}
+	if err != nil && r.Context().Err() != nil && errors.Is(err, context.Canceled) {
+		// The handler stopped on its context because the client went away
+		err = ErrClientGone
+	}
+	if errors.Is(err, ErrClientGone) {
+		d.record(method, duration, nil)
+	} else {
+		d.record(method, duration, err)
+	}
+
+	if d.slowThreshold > 0 && duration > d.slowThreshold && stream == nil && !download {
+		reportSlow(d.log(), SlowCall{
+			Kind:      "rpc",
+			Method:    method,
+			Path:      r.URL.Path,
+			Duration:  duration,
+			Threshold: d.slowThreshold,
+			BodySize:  len(body),
+		}, d.onSlow)
+	}
+
+	if errors.Is(err, ErrClientGone) {
+		d.log().Info("Stream client disconnected", "method", method)
+		return
+	}
 
 	if err != nil {
-		slog.Error("Failed to handle request", "error", err, "method", method, "bodySize", len(body))
+		d.log().Error("Failed to handle request", "error", err, "method", method, "bodySize", len(body))
 
-		var rpcErr *RpcError
-		if errors.As(err, &rpcErr) {
-			writeRpcError(w, rpcErr)
-		} else {
-			writeRpcError(w, ErrInternal("Internal server error"))
+		if stream != nil && stream.started() {
+			stream.adapter.SendError(err)
+			return
 		}
+		writeRpcError(w, asRpcError(err))
 		return
 	}
 
@@ -129,6 +261,13 @@ func (d *Dispatcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Write(responseBytes)
 }
 
+func (d *Dispatcher) log() *slog.Logger {
+	if d.logger != nil {
+		return d.logger
+	}
+	return slog.Default()
+}
+
 func applyCORS(w http.ResponseWriter, r *http.Request, cors *CORSConfig) {
 	origin := r.Header.Get("Origin")
 
@@ -160,7 +299,9 @@ func applyCORS(w http.ResponseWriter, r *http.Request, cors *CORSConfig) {
 		}
 	}
 
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	// Lets cross-origin clients see how a stream is framed
+	w.Header().Set("Access-Control-Expose-Headers", "X-Stream-Heartbeat, X-Stream-Compression")
 
 	if cors != nil && len(cors.AllowedHeaders) > 0 {
 		headers := ""
@@ -172,6 +313,6 @@ func applyCORS(w http.ResponseWriter, r *http.Request, cors *CORSConfig) {
 		}
 		w.Header().Set("Access-Control-Allow-Headers", headers)
 	} else {
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Access-Control-Allow-Headers, Authorization, X-Requested-With, X-Rpc-Method")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Access-Control-Allow-Headers, Authorization, X-Requested-With, X-Rpc-Method, X-Rpc-Request, X-Upload-Filename, X-Stream-Accept-Compression, X-Rpc-Resume-Token")
 	}
 }
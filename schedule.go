@@ -0,0 +1,392 @@
+package gapp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TaskFunc is a scheduled task. The context is cancelled if the task is
+// still running when the shutdown timeout is reached.
+type TaskFunc func(ctx context.Context) error
+
+// ScheduleOption configures a scheduled task.
+type ScheduleOption func(*scheduledTask)
+
+// TaskName names the task in logs. Defaults to the schedule spec.
+func TaskName(name string) ScheduleOption {
+	return func(t *scheduledTask) {
+		t.name = name
+	}
+}
+
+// Jitter delays each run by a random duration up to d, so replicas sharing a
+// schedule don't all hit a dependency at the same instant.
+func Jitter(d time.Duration) ScheduleOption {
+	return func(t *scheduledTask) {
+		t.jitter = d
+	}
+}
+
+// AllowOverlap lets a run start while the previous one is still going. By
+// default such runs are skipped.
+func AllowOverlap() ScheduleOption {
+	return func(t *scheduledTask) {
+		t.allowOverlap = true
+	}
+}
+
+type scheduledTask struct {
+	name         string
+	schedule     *cronSchedule
+	fn           TaskFunc
+	jitter       time.Duration
+	allowOverlap bool
+	running      atomic.Int32
+}
+
+// Scheduler runs tasks on cron schedules inside the server process. Every
+// replica runs every task, so tasks that must run once per cluster need their
+// own locking.
+type Scheduler struct {
+	mu      sync.Mutex
+	tasks   []*scheduledTask
+	started bool
+	stop    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+
+	// Logger records task failures and skipped runs. Defaults to slog.Default().
+	Logger *slog.Logger
+}
+
+var defaultScheduler = NewScheduler()
+
+// DefaultScheduler returns the process-wide Scheduler used by Schedule.
+// ListenAndServe starts it once the server is listening and stops it when a
+// shutdown signal is received.
+func DefaultScheduler() *Scheduler {
+	return defaultScheduler
+}
+
+// NewScheduler creates an empty Scheduler.
+func NewScheduler() *Scheduler {
+	return &Scheduler{}
+}
+
+// Schedule runs fn on the default scheduler whenever spec matches. See
+// (*Scheduler).Schedule.
+func Schedule(spec string, fn TaskFunc, opts ...ScheduleOption) error {
+	return defaultScheduler.Schedule(spec, fn, opts...)
+}
+
+// Schedule runs fn whenever spec matches. spec is a standard five-field cron
+// expression ("minute hour day-of-month month day-of-week", e.g. "0 * * * *"
+// for hourly), one of @yearly, @monthly, @weekly, @daily or @hourly, or
+// "@every <duration>". Times are in the local time zone.
+func (s *Scheduler) Schedule(spec string, fn TaskFunc, opts ...ScheduleOption) error {
+	schedule, err := parseCron(spec)
+	if err != nil {
+		return err
+	}
+	task := &scheduledTask{name: spec, schedule: schedule, fn: fn}
+	for _, opt := range opts {
+		opt(task)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks = append(s.tasks, task)
+	if s.started {
+		s.wg.Add(1)
+		go s.loop(task, s.stop)
+	}
+	return nil
+}
+
+// Start begins running the scheduled tasks. Tasks scheduled afterwards start
+// immediately. Calling Start on a running scheduler does nothing.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+	s.stop = make(chan struct{})
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	for _, task := range s.tasks {
+		s.wg.Add(1)
+		go s.loop(task, s.stop)
+	}
+}
+
+// Stop stops starting new runs and waits for running tasks to finish. If ctx
+// expires first, the running tasks' contexts are cancelled and it returns
+// ctx.Err(). Its signature matches OnDrain and OnShutdown.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return nil
+	}
+	s.started = false
+	close(s.stop)
+	cancel := s.cancel
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		cancel()
+		return nil
+	case <-ctx.Done():
+		cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) log() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
+
+func (s *Scheduler) loop(task *scheduledTask, stop chan struct{}) {
+	defer s.wg.Done()
+
+	for {
+		next := task.schedule.next(time.Now())
+		if next.IsZero() {
+			s.log().Warn("Scheduled task never matches", "task", task.name)
+			return
+		}
+		delay := time.Until(next)
+		if task.jitter > 0 {
+			delay += rand.N(task.jitter)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if task.allowOverlap {
+			task.running.Add(1)
+		} else if !task.running.CompareAndSwap(0, 1) {
+			s.log().Warn("Scheduled task still running, skipping run", "task", task.name)
+			continue
+		}
+		s.wg.Add(1)
+		go s.run(task)
+	}
+}
+
+func (s *Scheduler) run(task *scheduledTask) {
+	defer s.wg.Done()
+	defer task.running.Add(-1)
+
+	s.mu.Lock()
+	ctx := s.ctx
+	s.mu.Unlock()
+
+	start := time.Now()
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return task.fn(ctx)
+	}()
+	if err != nil {
+		s.log().Error("Scheduled task failed", "task", task.name, "duration", time.Since(start), "error", err)
+		return
+	}
+	s.log().Debug("Scheduled task finished", "task", task.name, "duration", time.Since(start))
+}
+
+// cronSchedule is a parsed cron expression. Each field is a bitmask of the
+// matching values.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+	every                         time.Duration // set for "@every"
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    []string // value names, indexed from min
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+func parseCron(spec string) (*cronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || every <= 0 {
+			return nil, fmt.Errorf("schedule %q: invalid interval", spec)
+		}
+		return &cronSchedule{every: every}, nil
+	}
+	expr := spec
+	if descriptor, ok := cronDescriptors[spec]; ok {
+		expr = descriptor
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("schedule %q: expected %d fields, got %d", spec, len(cronFields), len(parts))
+	}
+	masks := make([]uint64, len(parts))
+	for i, part := range parts {
+		mask, err := cronFields[i].parse(part)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", spec, err)
+		}
+		masks[i] = mask
+	}
+
+	// Sunday may be written as 0 or 7
+	dow := masks[4]
+	if dow&(1<<7) != 0 {
+		dow |= 1
+	}
+	return &cronSchedule{
+		minute:  masks[0],
+		hour:    masks[1],
+		dom:     masks[2],
+		month:   masks[3],
+		dow:     dow,
+		domStar: strings.HasPrefix(parts[2], "*"),
+		dowStar: strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+// parse parses a comma-separated list of values, ranges and steps.
+func (f cronField) parse(s string) (uint64, error) {
+	var mask uint64
+	for _, item := range strings.Split(s, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid %s step %q", f.name, stepPart)
+			}
+			step = n
+		}
+
+		var lo, hi int
+		if rangePart == "*" {
+			lo, hi = f.min, f.max
+		} else {
+			loPart, hiPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = f.value(loPart); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(hiPart); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = f.max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("invalid %s range %q", f.name, rangePart)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			mask |= 1 << v
+		}
+	}
+	return mask, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q", f.name, s)
+	}
+	return v, nil
+}
+
+// next returns the first matching time after t, or the zero time if the
+// schedule never matches (e.g. February 30th).
+func (c *cronSchedule) next(t time.Time) time.Time {
+	if c.every > 0 {
+		return t.Truncate(c.every).Add(c.every)
+	}
+
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches applies cron's rule that when both day fields are restricted, a
+// day matching either one matches.
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,93 @@
+package gapp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// A Thursday
+	from := time.Date(2026, time.January, 15, 10, 30, 0, 0, time.UTC)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	for _, tt := range []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"*/15 * * * *", from, at(time.January, 15, 10, 45)},
+		{"30 10 * * *", from, at(time.January, 16, 10, 30)},
+		{"5,10 0 * * *", from, at(time.January, 16, 0, 5)},
+		{"0 9-17 * * *", from, at(time.January, 15, 11, 0)},
+		{"0 9-17/4 * * *", from, at(time.January, 15, 13, 0)},
+		{"0 0 10/10 * *", from, at(time.January, 20, 0, 0)},
+		{"0 0 */10 * *", from, at(time.January, 21, 0, 0)},
+
+		// Month and day names, in any case
+		{"0 0 * JUN-aug *", from, at(time.June, 1, 0, 0)},
+		{"0 0 1 jan *", from, time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * mon-fri", from, at(time.January, 15, 12, 0)},
+		{"0 12 * * Sat", from, at(time.January, 17, 12, 0)},
+		{"0 0 * * 7", from, at(time.January, 18, 0, 0)},
+
+		// Restricting both day fields matches either
+		{"0 0 13 * fri", from, at(time.January, 16, 0, 0)},
+		{"0 0 13 * fri", at(time.January, 16, 0, 0), at(time.January, 23, 0, 0)},
+		{"0 0 20 * mon", from, at(time.January, 19, 0, 0)},
+		{"0 0 20 * mon", at(time.January, 19, 0, 0), at(time.January, 20, 0, 0)},
+		// A step on * still restricts, but isn't a restriction for the rule
+		{"0 0 */10 * mon", from, at(time.May, 11, 0, 0)},
+
+		{"@hourly", from, at(time.January, 15, 11, 0)},
+		{"@daily", from, at(time.January, 16, 0, 0)},
+		{"@midnight", from, at(time.January, 16, 0, 0)},
+		{"@weekly", from, at(time.January, 18, 0, 0)},
+		{"@monthly", from, at(time.February, 1, 0, 0)},
+		{"@yearly", from, time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 15m", from, at(time.January, 15, 10, 45)},
+		{"@every 15m", from.Add(time.Second), at(time.January, 15, 10, 45)},
+
+		// Never matches
+		{"0 0 30 2 *", from, time.Time{}},
+	} {
+		schedule, err := parseCron(tt.spec)
+		if err != nil {
+			t.Errorf("parseCron(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := schedule.next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("next(%q, %s) = %s, want %s", tt.spec, tt.from.Format(time.DateTime), got.Format(time.DateTime), tt.want.Format(time.DateTime))
+		}
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"1-x * * * *",
+		"* * * foo *",
+		"* * * * funday",
+		"@every",
+		"@every soon",
+		"@every -1m",
+		"@every 0s",
+		"@fortnightly",
+	} {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("parseCron(%q) succeeded, want an error", spec)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package gapp
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SitemapParamsFunc enumerates the param sets of a dynamic route, typically
+// by calling the RPC that lists the underlying records. Each map fills the
+// route's :params to produce one sitemap URL.
+type SitemapParamsFunc func(ctx context.Context) ([]map[string]string, error)
+
+// SitemapConfig configures SitemapHandler.
+type SitemapConfig struct {
+	BaseURL string                       // absolute origin, e.g. "https://example.com"; defaults to the request's
+	Params  map[string]SitemapParamsFunc // enumerators for dynamic routes, keyed by route pattern
+	Exclude []string                     // route patterns to leave out
+
+	// Timeout bounds the Params calls. Defaults to 10s.
+	Timeout time.Duration
+
+	Logger *slog.Logger // defaults to the engine's logger, then slog.Default()
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc string `xml:"loc"`
+}
+
+// SitemapHandler serves sitemap.xml for the engine's routes. See SitemapHandler.
+func (p *PreloadEngine) SitemapHandler(config SitemapConfig) http.Handler {
+	if config.Logger == nil {
+		config.Logger = p.log()
+	}
+	return SitemapHandler(p.Routes, config)
+}
+
+// SitemapHandler serves a sitemap.xml listing every route. Static routes are
+// listed as-is; routes with required :params are only listed when config.Params
+// has an enumerator for their pattern. Redirect routes are skipped.
+func SitemapHandler(routes []RouteSpec, config SitemapConfig) http.Handler {
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		base := strings.TrimSuffix(config.BaseURL, "/")
+		if base == "" {
+			base = requestOrigin(r)
+		}
+
+		set := sitemapURLSet{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+		seen := make(map[string]bool)
+		add := func(path string) {
+			if !seen[path] {
+				seen[path] = true
+				set.URLs = append(set.URLs, sitemapURL{Loc: base + path})
+			}
+		}
+
+		for _, route := range routes {
+			if route.RedirectTo != "" || slices.Contains(config.Exclude, route.Pattern) {
+				continue
+			}
+
+			enumerate, ok := config.Params[route.Pattern]
+			if !ok {
+				if path, complete := fillPattern(route.Pattern, nil); complete {
+					add(path)
+				}
+				continue
+			}
+
+			paramSets, err := enumerate(ctx)
+			if err != nil {
+				logger.Error("Sitemap params failed", "pattern", route.Pattern, "error", err)
+				continue
+			}
+			for _, params := range paramSets {
+				escaped := make(map[string]string, len(params))
+				for k, v := range params {
+					escaped[k] = url.PathEscape(v)
+				}
+				if path, complete := fillPattern(route.Pattern, escaped); complete {
+					add(path)
+				}
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.Write([]byte(xml.Header))
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+		if err := enc.Encode(set); err != nil {
+			logger.Error("Failed to write sitemap", "error", err)
+		}
+	})
+}
+
+// RobotsConfig configures RobotsHandler.
+type RobotsConfig struct {
+	Allow    []string // paths crawlers may visit
+	Disallow []string // paths crawlers must skip, defaults to the RPC and preload endpoints
+	Sitemap  string   // sitemap URL; a path like "/sitemap.xml" is resolved against the request
+}
+
+// RobotsHandler serves a robots.txt for all user agents.
+func RobotsHandler(config RobotsConfig) http.Handler {
+	disallow := config.Disallow
+	if disallow == nil {
+		disallow = []string{"/rpc", "/__preload"}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var b strings.Builder
+		b.WriteString("User-agent: *\n")
+		for _, path := range config.Allow {
+			fmt.Fprintf(&b, "Allow: %s\n", path)
+		}
+		for _, path := range disallow {
+			fmt.Fprintf(&b, "Disallow: %s\n", path)
+		}
+		if sitemap := config.Sitemap; sitemap != "" {
+			if strings.HasPrefix(sitemap, "/") {
+				sitemap = requestOrigin(r) + sitemap
+			}
+			fmt.Fprintf(&b, "\nSitemap: %s\n", sitemap)
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(b.String()))
+	})
+}
+
+// requestOrigin returns the scheme and host the request was made to,
+// honoring X-Forwarded-Proto from a reverse proxy.
+func requestOrigin(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,35 @@
+package gapp
+
+import "net/http"
+
+// Skip wraps m so calls to methods bypass it, e.g. to keep tracing off
+// health checks or auth off public RPCs without splitting the chain:
+//
+//	d.Use(gapp.Skip(tracing, "Ping", "GetBuildInfo"))
+func Skip(m Middleware, methods ...string) Middleware {
+	skipped := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		skipped[method] = true
+	}
+	return SkipIf(m, func(r *http.Request, method string) bool {
+		return skipped[method]
+	})
+}
+
+// SkipIf wraps m so the calls skip returns true for bypass it and go straight
+// to the rest of the chain:
+//
+//	d.Use(gapp.SkipIf(validation, func(r *http.Request, method string) bool {
+//		return strings.HasPrefix(method, "Internal")
+//	}))
+func SkipIf(m Middleware, skip func(r *http.Request, method string) bool) Middleware {
+	return func(next RpcHandler) RpcHandler {
+		wrapped := m(next)
+		return func(w http.ResponseWriter, r *http.Request, method string, body []byte) ([]byte, error) {
+			if skip(r, method) {
+				return next(w, r, method, body)
+			}
+			return wrapped(w, r, method, body)
+		}
+	}
+}
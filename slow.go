@@ -0,0 +1,40 @@
+package gapp
+
+import (
+	"log/slog"
+	"time"
+)
+
+// SlowCall describes an RPC or preload that exceeded its latency threshold.
+type SlowCall struct {
+	Kind      string            // "rpc" or "preload"
+	Method    string            // RPC method name
+	Path      string            // request path
+	Duration  time.Duration     // how long the call took
+	Threshold time.Duration     // the threshold it exceeded
+	Params    map[string]string // substituted preload params, nil for RPCs
+	BodySize  int               // request body size, zero for preloads
+}
+
+// SlowCallFunc is notified of slow calls, e.g. to feed metrics or alerts.
+type SlowCallFunc func(call SlowCall)
+
+// reportSlow logs call as a warning and passes it to onSlow, if set.
+func reportSlow(logger *slog.Logger, call SlowCall, onSlow SlowCallFunc) {
+	attrs := []any{"method", call.Method, "path", call.Path, "duration", call.Duration, "threshold", call.Threshold}
+	if call.Params != nil {
+		attrs = append(attrs, "params", call.Params)
+	}
+	if call.Kind == "rpc" {
+		attrs = append(attrs, "bodySize", call.BodySize)
+	}
+
+	if call.Kind == "preload" {
+		logger.Warn("Slow preload", attrs...)
+	} else {
+		logger.Warn("Slow RPC", attrs...)
+	}
+	if onSlow != nil {
+		onSlow(call)
+	}
+}
@@ -0,0 +1,87 @@
+package gapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+)
+
+// SSRRequest is the input passed to an SSRFunc.
+type SSRRequest struct {
+	Path      string                  `json:"path"`
+	Pattern   string                  `json:"pattern"`
+	Params    map[string]string       `json:"params"`
+	Preloaded map[string]PreloadedRpc `json:"preloaded"`
+}
+
+// SSRResult is the markup returned by an SSRFunc.
+// HTML is spliced into the root element and Head is appended to <head>.
+type SSRResult struct {
+	HTML string `json:"html"`
+	Head string `json:"head"`
+}
+
+// SSRFunc renders the app server-side from the preloaded RPC payloads.
+// On error the page falls back to client-only rendering.
+type SSRFunc func(ctx context.Context, r *http.Request, req SSRRequest) (SSRResult, error)
+
+// NewHTTPRenderer returns an SSRFunc that POSTs the SSRRequest as JSON to a
+// render process (e.g. a Node or Bun server) and expects an SSRResult as JSON.
+func NewHTTPRenderer(url string) SSRFunc {
+	return func(ctx context.Context, r *http.Request, req SSRRequest) (SSRResult, error) {
+		body, err := json.Marshal(req)
+		if err != nil {
+			return SSRResult{}, err
+		}
+
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return SSRResult{}, err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(httpReq)
+		if err != nil {
+			return SSRResult{}, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return SSRResult{}, fmt.Errorf("render server returned %s", resp.Status)
+		}
+
+		var result SSRResult
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return SSRResult{}, fmt.Errorf("decoding render response: %w", err)
+		}
+		return result, nil
+	}
+}
+
+// applySSR runs the SSR hook, if configured, and stores its markup in data.
+func (p *PreloadEngine) applySSR(ctx context.Context, r *http.Request, result preloadResult, data *TemplateData) {
+	if p.ssr == nil {
+		return
+	}
+
+	req := SSRRequest{
+		Path:      r.URL.Path,
+		Params:    result.params,
+		Preloaded: result.rpcs,
+	}
+	if result.route != nil {
+		req.Pattern = result.route.Pattern
+	}
+
+	rendered, err := p.ssr(ctx, r, req)
+	if err != nil {
+		p.log().Error("SSR failed, falling back to client rendering", "path", r.URL.Path, "error", err)
+		return
+	}
+
+	data.SSRHTML = template.HTML(rendered.HTML)
+	data.SSRHead = template.HTML(rendered.Head)
+}
@@ -1,51 +1,421 @@
 package gapp
 
 import (
+	"context"
 	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
 )
 
+// ErrClientGone is returned by StreamAdapter.Send once the client has
+// disconnected. Stream handlers should stop producing and return it, or
+// their context's error after Done; the Dispatcher doesn't log either as a
+// failure.
+var ErrClientGone = errors.New("gapp: stream client disconnected")
+
 // StreamAdapter provides length-prefixed streaming over HTTP responses.
 // Each message is sent with a 4-byte big-endian length prefix followed by
-// the protobuf-encoded message bytes.
+// the protobuf-encoded message bytes. When the client negotiates compression,
+// the length covers a flag byte (0 raw, 1 compressed) and then the message.
+// A stream that fails ends with a trailer frame: a length prefix with its high
+// bit set followed by the RpcError as JSON. A prefix with the next bit set
+// carries a resume token (see SendWithToken). Clients that accept
+// application/x-ndjson get newline-delimited JSON instead; see SendMessage.
 type StreamAdapter struct {
-	response http.ResponseWriter
+	response    http.ResponseWriter
+	ctx         context.Context
+	resumeToken string
+
+	heartbeat time.Duration
+	ndjson    bool
+
+	compression   string
+	compressor    StreamCompressor
+	compressAbove int
+
+	mu        sync.Mutex // serializes writes between Send and the heartbeat
+	started   bool
+	closed    bool
+	ended     bool // the error trailer was sent
+	lastWrite time.Time
+
+	stopHeartbeat chan struct{} // closed by Close
+	heartbeatDone chan struct{} // closed when the keepalive goroutine exits
+}
+
+// StreamOption configures a StreamAdapter.
+type StreamOption func(*StreamAdapter)
+
+// WithHeartbeat sends a zero-length keepalive frame whenever the stream has
+// been idle for interval, so proxies and load balancers with idle timeouts
+// (often 30-60s) don't cut it off. The client skips these frames, which means
+// a stream with heartbeats can't carry empty messages. The Dispatcher closes
+// the stream, stopping the heartbeat, when the handler returns; streams
+// served outside it must call Close.
+func WithHeartbeat(interval time.Duration) StreamOption {
+	return func(sa *StreamAdapter) {
+		sa.heartbeat = interval
+	}
+}
+
+// WithCompressionThreshold sets the size in bytes above which messages are
+// compressed when the client negotiated compression; smaller messages are
+// sent raw since compressing them costs more than it saves. Defaults to 1 KiB.
+// A negative threshold disables compression.
+func WithCompressionThreshold(bytes int) StreamOption {
+	return func(sa *StreamAdapter) {
+		sa.compressAbove = bytes
+	}
 }
 
+const defaultCompressionThreshold = 1 << 10
+
+// NewStreamAdapter creates a StreamAdapter writing to w. Prefer
+// NewRequestStreamAdapter, which can detect client disconnects.
 func NewStreamAdapter(w http.ResponseWriter) *StreamAdapter {
-	return &StreamAdapter{
+	sa := &StreamAdapter{
 		response: w,
+		ctx:      context.Background(),
+	}
+	registerStream(w, sa)
+	return sa
+}
+
+// NewRequestStreamAdapter creates a StreamAdapter answering r, whose context
+// is cancelled when the client disconnects. If r lists a registered
+// compressor in X-Stream-Accept-Compression, large messages are compressed
+// with it; if it accepts application/x-ndjson, the stream is sent as JSON.
+func NewRequestStreamAdapter(w http.ResponseWriter, r *http.Request, opts ...StreamOption) *StreamAdapter {
+	sa := &StreamAdapter{
+		response:      w,
+		ctx:           r.Context(),
+		resumeToken:   r.Header.Get("X-Rpc-Resume-Token"),
+		compressAbove: defaultCompressionThreshold,
+	}
+	for _, opt := range opts {
+		opt(sa)
 	}
+	sa.ndjson = acceptsNDJSON(r)
+	if sa.compressAbove >= 0 && !sa.ndjson {
+		sa.compression, sa.compressor = negotiateCompression(r)
+	}
+	registerStream(w, sa)
+	return sa
+}
+
+// Context returns the request context, which is cancelled when the client
+// disconnects. Pass it to the work producing the stream.
+func (sa *StreamAdapter) Context() context.Context {
+	return sa.ctx
+}
+
+// Done is closed when the client disconnects, for select loops that wait on
+// other events between messages.
+func (sa *StreamAdapter) Done() <-chan struct{} {
+	return sa.ctx.Done()
+}
+
+// ResumeToken returns the token a reconnecting client sent in
+// X-Rpc-Resume-Token: the last one passed to SendWithToken before its
+// connection dropped. Handlers should continue the stream after that point.
+// It's empty for a new stream.
+func (sa *StreamAdapter) ResumeToken() string {
+	return sa.resumeToken
 }
 
 // SendHeaders writes streaming response headers and flushes them to the client.
+// It also clears the server's write deadline, since a stream may legitimately
+// outlive ServerConfig.WriteTimeout.
 func (sa *StreamAdapter) SendHeaders() error {
-	sa.response.Header().Set("Content-Type", "application/x-protobuf-stream")
+	http.NewResponseController(sa.response).SetWriteDeadline(time.Time{})
+
+	if sa.ndjson {
+		sa.response.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		sa.response.Header().Set("Content-Type", "application/x-protobuf-stream")
+	}
 	sa.response.Header().Set("Transfer-Encoding", "chunked")
 	sa.response.Header().Set("X-Content-Type-Options", "nosniff")
+	if sa.heartbeat > 0 {
+		// Tells the client that zero-length frames are keepalives
+		sa.response.Header().Set("X-Stream-Heartbeat", sa.heartbeat.String())
+	}
+	if sa.compressor != nil {
+		sa.response.Header().Set("X-Stream-Compression", sa.compression)
+	}
 	sa.response.WriteHeader(http.StatusOK)
 
 	if flusher, ok := sa.response.(http.Flusher); ok {
 		flusher.Flush()
 	}
+
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+	sa.started = true
+	if sa.heartbeat > 0 && sa.heartbeatDone == nil && !sa.closed {
+		sa.lastWrite = time.Now()
+		sa.stopHeartbeat = make(chan struct{})
+		sa.heartbeatDone = make(chan struct{})
+		go sa.keepalive(sa.stopHeartbeat, sa.heartbeatDone)
+	}
 	return nil
 }
 
-// Send writes a length-prefixed message to the stream.
+// Send writes a length-prefixed message to the stream. It returns
+// ErrClientGone if the client has disconnected. NDJSON streams can't carry
+// encoded messages; handlers that support them use SendMessage.
 func (sa *StreamAdapter) Send(data []byte) error {
-	length := uint32(len(data))
-	if err := binary.Write(sa.response, binary.BigEndian, length); err != nil {
-		return err
+	if sa.ndjson {
+		return errNDJSONBytes
+	}
+	if sa.compressor != nil {
+		frame, err := sa.encodeFrame(data)
+		if err != nil {
+			return err
+		}
+		data = frame
 	}
 
-	_, err := sa.response.Write(data)
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+	return sa.writeFrame(data)
+}
+
+// SendMessage marshals msg and writes it to the stream, as protojson if the
+// client negotiated NDJSON.
+func (sa *StreamAdapter) SendMessage(msg proto.Message) error {
+	if !sa.ndjson {
+		data, err := proto.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("marshaling stream message: %w", err)
+		}
+		return sa.Send(data)
+	}
+
+	line, err := ndjsonResult(msg)
 	if err != nil {
 		return err
 	}
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+	return sa.writeFrame(line)
+}
+
+// SendWithToken sends data followed by a frame carrying token, the position
+// after data in whatever the handler streams, such as an offset or the ID of
+// the last row. If the connection drops, the client reconnects with the last
+// token it received.
+func (sa *StreamAdapter) SendWithToken(data []byte, token string) error {
+	if err := sa.Send(data); err != nil {
+		return err
+	}
+	return sa.sendToken(token)
+}
+
+// sendToken writes a resume token frame.
+func (sa *StreamAdapter) sendToken(token string) error {
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+	if sa.closed {
+		return ErrClientGone
+	}
+	return sa.writeControl(tokenFlag, []byte(token))
+}
+
+// Flags in the length prefix of a stream's control frames.
+const (
+	trailerFlag = 1 << 31 // terminal error frame
+	tokenFlag   = 1 << 30 // resume token frame
+)
+
+// SendError ends the stream with a trailer frame carrying err, so the client
+// gets a structured error instead of a truncated stream. Errors that aren't
+// RpcErrors are sent as a generic internal error. The Dispatcher calls it for
+// errors returned by streaming handlers after the stream started. It does
+// nothing before SendHeaders; return the error from the handler instead.
+func (sa *StreamAdapter) SendError(err error) error {
+	data, jsonErr := json.Marshal(asRpcError(err))
+	if jsonErr != nil {
+		return jsonErr
+	}
+
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+	// Closing the stream doesn't prevent the trailer, since handlers usually
+	// defer Close and then return the error
+	if sa.ended || !sa.started {
+		return nil
+	}
+	err = sa.writeControl(trailerFlag, data)
+	sa.ended = true
+	sa.closed = true
+	return err
+}
+
+// Close stops the heartbeat, waiting for it to finish writing, so nothing
+// writes to the response after Close returns. The stream must not be used
+// afterwards.
+func (sa *StreamAdapter) Close() error {
+	sa.mu.Lock()
+	sa.closed = true
+	stop, done := sa.stopHeartbeat, sa.heartbeatDone
+	sa.stopHeartbeat = nil
+	sa.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+	}
+	if done != nil {
+		<-done
+	}
+	return nil
+}
+
+// encodeFrame prefixes data with its flag byte, compressing it if it's over
+// the threshold and compression actually shrinks it.
+func (sa *StreamAdapter) encodeFrame(data []byte) ([]byte, error) {
+	if len(data) > sa.compressAbove {
+		compressed, err := sa.compressor.Compress(data)
+		if err != nil {
+			return nil, fmt.Errorf("compressing stream message: %w", err)
+		}
+		if len(compressed) < len(data) {
+			return append([]byte{frameCompressed}, compressed...), nil
+		}
+	}
+	return append([]byte{frameRaw}, data...), nil
+}
+
+// writeFrame writes a length-prefixed frame, or a line of an NDJSON stream.
+// sa.mu must be held.
+func (sa *StreamAdapter) writeFrame(data []byte) error {
+	if sa.closed {
+		return ErrClientGone
+	}
+	if sa.ndjson {
+		return sa.write(data, []byte("\n"))
+	}
+	return sa.writePrefixed(uint32(len(data)), data)
+}
+
+// writeControl writes a token or trailer frame with the given flag. sa.mu
+// must be held.
+func (sa *StreamAdapter) writeControl(flag uint32, data []byte) error {
+	if sa.ndjson {
+		return sa.write(ndjsonControl(flag, data), []byte("\n"))
+	}
+	return sa.writePrefixed(flag|uint32(len(data)), data)
+}
+
+// writePrefixed writes prefix and data. sa.mu must be held.
+func (sa *StreamAdapter) writePrefixed(prefix uint32, data []byte) error {
+	return sa.write(binary.BigEndian.AppendUint32(nil, prefix), data)
+}
+
+// write writes chunks and flushes them. sa.mu must be held.
+func (sa *StreamAdapter) write(chunks ...[]byte) error {
+	if sa.ctx.Err() != nil {
+		return ErrClientGone
+	}
+
+	for _, chunk := range chunks {
+		if _, err := sa.response.Write(chunk); err != nil {
+			return fmt.Errorf("%w: %w", ErrClientGone, err)
+		}
+	}
 
 	if flusher, ok := sa.response.(http.Flusher); ok {
 		flusher.Flush()
 	}
+	sa.lastWrite = time.Now()
 
 	return nil
 }
+
+// keepalive sends an empty frame whenever the stream has been idle for the
+// heartbeat interval, until the stream is closed or the client disconnects.
+func (sa *StreamAdapter) keepalive(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(sa.heartbeat / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sa.ctx.Done():
+			return
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		sa.mu.Lock()
+		if sa.closed {
+			sa.mu.Unlock()
+			return
+		}
+		var err error
+		if time.Since(sa.lastWrite) >= sa.heartbeat {
+			err = sa.writeFrame(nil)
+		}
+		sa.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// streamResponse is the ResponseWriter the Dispatcher passes to streaming
+// handlers. The handler's StreamAdapter registers itself with it, so the
+// Dispatcher can end a failed stream with an error trailer.
+type streamResponse struct {
+	http.ResponseWriter
+	adapter *StreamAdapter
+}
+
+func (s *streamResponse) Flush() {
+	http.NewResponseController(s.ResponseWriter).Flush()
+}
+
+func (s *streamResponse) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// close closes the handler's stream, if it made one, once the handler
+// returned, so its heartbeat can't outlive the response.
+func (s *streamResponse) close() {
+	if s.adapter != nil {
+		s.adapter.Close()
+	}
+}
+
+// started reports whether the handler's stream has sent its headers.
+func (s *streamResponse) started() bool {
+	if s.adapter == nil {
+		return false
+	}
+	s.adapter.mu.Lock()
+	defer s.adapter.mu.Unlock()
+	return s.adapter.started
+}
+
+// registerStream records sa with the Dispatcher's streamResponse, looking
+// through ResponseWriters wrapped by middleware.
+func registerStream(w http.ResponseWriter, sa *StreamAdapter) {
+	for w != nil {
+		if s, ok := w.(*streamResponse); ok {
+			s.adapter = sa
+			return
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		w = unwrapper.Unwrap()
+	}
+}
@@ -0,0 +1,122 @@
+package gapp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// UnaryWithTimeout registers h for method with a deadline. The handler's
+// context is canceled after timeout and the call fails with
+// CodeDeadlineExceeded right away, so a slow handler doesn't hold the
+// client's connection even if it ignores its context:
+//
+//	d.UnaryWithTimeout("Report", 10*time.Second, reportHandler)
+//
+// Headers and writes made by the handler are kept until it returns, and
+// dropped if it outlives its deadline.
+func (d *Dispatcher) UnaryWithTimeout(method string, timeout time.Duration, h UnaryHandler) {
+	d.Unary[method] = func(w http.ResponseWriter, r *http.Request, method string, body []byte) ([]byte, error) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		tw := &timeoutWriter{w: w, header: w.Header().Clone()}
+		type result struct {
+			data  []byte
+			err   error
+			panic any
+		}
+		done := make(chan result, 1)
+		go func() {
+			var res result
+			defer func() {
+				if p := recover(); p != nil {
+					res.panic = p
+				}
+				done <- res
+			}()
+			res.data, res.err = h(tw, r.WithContext(ctx), method, body)
+		}()
+
+		select {
+		case res := <-done:
+			if res.panic != nil {
+				panic(res.panic)
+			}
+			tw.flush()
+			return res.data, res.err
+		case <-ctx.Done():
+			tw.timeout()
+			if r.Context().Err() != nil {
+				// The client went away before the deadline
+				return nil, ErrClientGone
+			}
+			go func() {
+				if res := <-done; res.panic != nil {
+					d.log().Error("Handler panicked after its deadline", "method", method, "panic", fmt.Sprint(res.panic))
+				}
+			}()
+			return nil, ErrDeadlineExceeded(fmt.Sprintf("%s did not finish within %s", method, timeout))
+		}
+	}
+}
+
+// timeoutWriter buffers a handler's response so it can be discarded if the
+// handler times out while still writing.
+type timeoutWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	code     int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.code != 0 {
+		return
+	}
+	tw.code = code
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) timeout() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.timedOut = true
+}
+
+// flush copies the buffered response to the underlying writer.
+func (tw *timeoutWriter) flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	dst := tw.w.Header()
+	for key, values := range tw.header {
+		dst[key] = values
+	}
+	if tw.code != 0 {
+		tw.w.WriteHeader(tw.code)
+		tw.w.Write(tw.buf.Bytes())
+	}
+}
@@ -0,0 +1,82 @@
+package gapp
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// AutocertConfig configures automatic certificates from Let's Encrypt.
+type AutocertConfig struct {
+	Domains  []string // hostnames to request certificates for, required
+	Email    string   // contact address for the ACME account, optional
+	CacheDir string   // where certificates are stored across restarts, defaults to "certs"
+
+	// HTTPAddr is the plain HTTP listener that answers HTTP-01 challenges and
+	// redirects everything else to HTTPS. Defaults to ":80"; "-" disables it
+	// and relies on the TLS-ALPN-01 challenge alone.
+	HTTPAddr string
+}
+
+type tlsOptions struct {
+	certFile string
+	keyFile  string
+	autocert *AutocertConfig
+}
+
+// WithTLS serves HTTPS using the given certificate and key files.
+func WithTLS(certFile, keyFile string) ServeOption {
+	return serveOption(func(o *serveOptions) {
+		o.tls = &tlsOptions{certFile: certFile, keyFile: keyFile}
+	})
+}
+
+// WithAutocert serves HTTPS with certificates obtained and renewed
+// automatically from Let's Encrypt. The server address should be ":443".
+func WithAutocert(config AutocertConfig) ServeOption {
+	return serveOption(func(o *serveOptions) {
+		o.tls = &tlsOptions{autocert: &config}
+	})
+}
+
+// ListenAndServeTLS is ListenAndServe over HTTPS with the given certificate
+// and key files.
+func ListenAndServeTLS(addr, certFile, keyFile string, handler http.Handler) error {
+	return ListenAndServeWithOptions(addr, handler, WithTLS(certFile, keyFile))
+}
+
+// configure sets up server.TLSConfig, returning any additional servers to
+// run alongside it.
+func (t *tlsOptions) configure(server *http.Server, config ServerConfig) ([]*http.Server, error) {
+	if t.autocert == nil {
+		cert, err := tls.LoadX509KeyPair(t.certFile, t.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading TLS certificate: %w", err)
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		return nil, nil
+	}
+
+	cacheDir := t.autocert.CacheDir
+	if cacheDir == "" {
+		cacheDir = "certs"
+	}
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(t.autocert.Domains...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      t.autocert.Email,
+	}
+	server.TLSConfig = manager.TLSConfig()
+
+	httpAddr := t.autocert.HTTPAddr
+	if httpAddr == "" {
+		httpAddr = ":80"
+	}
+	if httpAddr == "-" {
+		return nil, nil
+	}
+	return []*http.Server{config.newServer(httpAddr, manager.HTTPHandler(nil))}, nil
+}
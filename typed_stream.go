@@ -0,0 +1,83 @@
+package gapp
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// TypedStream is a StreamAdapter that sends messages of type T, marshaling
+// each one. Response headers are sent with the first message, so a handler
+// can still fail with a regular RpcError until then.
+type TypedStream[T proto.Message] struct {
+	*StreamAdapter
+	headers sync.Once
+}
+
+// NewTypedStream wraps sa, which mustn't have sent its headers yet.
+func NewTypedStream[T proto.Message](sa *StreamAdapter) *TypedStream[T] {
+	return &TypedStream[T]{StreamAdapter: sa}
+}
+
+// Send marshals msg and writes it to the stream. It returns ErrClientGone if
+// the client has disconnected.
+func (s *TypedStream[T]) Send(msg T) error {
+	s.headers.Do(func() { s.SendHeaders() })
+	return s.SendMessage(msg)
+}
+
+// SendWithToken is Send followed by a resume token. See
+// StreamAdapter.SendWithToken.
+func (s *TypedStream[T]) SendWithToken(msg T, token string) error {
+	if err := s.Send(msg); err != nil {
+		return err
+	}
+	return s.sendToken(token)
+}
+
+// StreamHandlerFor adapts a typed streaming handler to a StreamHandler for
+// Dispatcher.Streaming. It decodes the request, opens the stream with opts,
+// and closes it when handler returns:
+//
+//	d.Streaming["WatchOrders"] = gapp.StreamHandlerFor(
+//		func(ctx context.Context, req *pb.WatchOrdersRequest, stream *gapp.TypedStream[*pb.Order]) error {
+//			for order := range orders.Watch(ctx, req.UserId) {
+//				if err := stream.Send(order); err != nil {
+//					return err
+//				}
+//			}
+//			return nil
+//		})
+//
+// Requests sent as application/json are decoded as protojson, and the streams
+// support NDJSON, so they can be read with curl:
+//
+//	curl -H 'X-Rpc-Method: WatchOrders' -H 'Accept: application/x-ndjson' \
+//		-H 'Content-Type: application/json' -d '{"userId": "42"}' localhost:8080/rpc
+func StreamHandlerFor[Req, Resp proto.Message](handler func(ctx context.Context, req Req, stream *TypedStream[Resp]) error, opts ...StreamOption) StreamHandler {
+	return func(w http.ResponseWriter, r *http.Request, method string, body []byte) error {
+		var zero Req
+		req := zero.ProtoReflect().New().Interface().(Req)
+		unmarshal := proto.Unmarshal
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+			unmarshal = protojson.Unmarshal
+		}
+		if err := unmarshal(body, req); err != nil {
+			return ErrValidation("invalid request for " + method)
+		}
+
+		sa := NewRequestStreamAdapter(w, r, opts...)
+		defer sa.Close()
+		stream := NewTypedStream[Resp](sa)
+		if err := handler(sa.Context(), req, stream); err != nil {
+			return err
+		}
+		// An empty stream still needs its headers
+		stream.headers.Do(func() { sa.SendHeaders() })
+		return nil
+	}
+}
@@ -0,0 +1,237 @@
+package gapp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// UploadHandler handles an upload RPC. The upload's body is readable until
+// the handler returns, after which any temp file is removed; copy it
+// elsewhere to keep it.
+type UploadHandler func(w http.ResponseWriter, r *http.Request, method string, upload *Upload) ([]byte, error)
+
+// UploadConfig limits and tunes upload handling.
+type UploadConfig struct {
+	MaxSize         int64  // largest accepted file in bytes, default 100 MiB
+	MemoryThreshold int64  // files up to this size are kept in memory instead of a temp file, default 1 MiB
+	TempDir         string // where larger files are spooled, defaults to os.TempDir()
+}
+
+// WithUploadConfig sets the limits for upload RPCs registered in
+// Dispatcher.Uploads.
+func WithUploadConfig(config UploadConfig) DispatcherOption {
+	return dispatcherOption(func(d *Dispatcher) {
+		d.uploads = config
+	})
+}
+
+// Upload is a file received by an upload RPC. Clients send it either as the
+// raw request body, with the file name in an X-Upload-Filename header and an
+// optional base64 proto request in X-Rpc-Request, or as multipart/form-data
+// with one file part, an optional binary "request" part and text fields.
+type Upload struct {
+	Filename    string
+	ContentType string
+	Size        int64
+	Request     []byte            // proto-encoded request message sent alongside the file, if any
+	Fields      map[string]string // text fields of a multipart upload
+
+	body io.Reader
+	file *os.File
+}
+
+// Read reads the uploaded file.
+func (u *Upload) Read(p []byte) (int, error) {
+	return u.body.Read(p)
+}
+
+// close removes the upload's temp file, if any.
+func (u *Upload) close() {
+	if u.file != nil {
+		u.file.Close()
+		os.Remove(u.file.Name())
+	}
+}
+
+const (
+	maxUploadRequestSize = 1 << 20
+	maxUploadFieldSize   = 64 << 10
+	multipartOverhead    = 2 << 20
+)
+
+func (c UploadConfig) withDefaults() UploadConfig {
+	if c.MaxSize <= 0 {
+		c.MaxSize = 100 << 20
+	}
+	if c.MemoryThreshold <= 0 {
+		c.MemoryThreshold = 1 << 20
+	}
+	return c
+}
+
+type uploadCallKeyType struct{}
+
+var uploadCallKey = uploadCallKeyType{}
+
+// isUploadCall reports whether r calls an upload method, whose body reaches
+// the handler as an Upload rather than the middlewares.
+func isUploadCall(r *http.Request) bool {
+	return r.Context().Value(uploadCallKey) != nil
+}
+
+// readUpload reads the file from the request, spooling it to a temp file
+// once it exceeds the memory threshold.
+func (d *Dispatcher) readUpload(r *http.Request) (*Upload, error) {
+	config := d.uploads.withDefaults()
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		if r.ContentLength > config.MaxSize {
+			return nil, errUploadTooLarge(config.MaxSize)
+		}
+		upload := &Upload{ContentType: r.Header.Get("Content-Type")}
+		if name := r.Header.Get("X-Upload-Filename"); name != "" {
+			if unescaped, err := url.PathUnescape(name); err == nil {
+				name = unescaped
+			}
+			upload.Filename = name
+		}
+		if encoded := r.Header.Get("X-Rpc-Request"); encoded != "" {
+			request, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, ErrValidation("X-Rpc-Request must be base64")
+			}
+			upload.Request = request
+		}
+		if err := upload.spool(r.Body, config); err != nil {
+			return nil, err
+		}
+		return upload, nil
+	}
+
+	// Bound the whole form too, since the fields are read into memory
+	r.Body = http.MaxBytesReader(nil, r.Body, config.MaxSize+multipartOverhead)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, ErrValidation("invalid multipart upload")
+	}
+	upload := &Upload{Fields: make(map[string]string)}
+	hasFile := false
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			upload.close()
+			return nil, uploadReadError(err, config.MaxSize)
+		}
+
+		switch {
+		case part.FileName() != "":
+			if hasFile {
+				upload.close()
+				return nil, ErrValidation("only one file per upload is supported")
+			}
+			hasFile = true
+			upload.Filename = part.FileName()
+			upload.ContentType = part.Header.Get("Content-Type")
+			if err := upload.spool(part, config); err != nil {
+				return nil, err
+			}
+		case part.FormName() == "request":
+			upload.Request, err = readLimited(part, maxUploadRequestSize)
+			if err != nil {
+				upload.close()
+				return nil, err
+			}
+		default:
+			value, err := readLimited(part, maxUploadFieldSize)
+			if err != nil {
+				upload.close()
+				return nil, err
+			}
+			upload.Fields[part.FormName()] = string(value)
+		}
+	}
+	if !hasFile {
+		return nil, ErrValidation("upload has no file")
+	}
+	return upload, nil
+}
+
+// spool reads src into memory, moving to a temp file once it grows past the
+// memory threshold. It removes the temp file if it fails.
+func (u *Upload) spool(src io.Reader, config UploadConfig) error {
+	limited := io.LimitReader(src, config.MaxSize+1)
+
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, limited, config.MemoryThreshold+1)
+	if err != nil && err != io.EOF {
+		return uploadReadError(err, config.MaxSize)
+	}
+	if n > config.MaxSize {
+		// Only reachable when MaxSize is below the memory threshold
+		return errUploadTooLarge(config.MaxSize)
+	}
+	if n <= config.MemoryThreshold {
+		u.body = bytes.NewReader(buf.Bytes())
+		u.Size = n
+		return nil
+	}
+
+	file, err := os.CreateTemp(config.TempDir, "gapp-upload-*")
+	if err != nil {
+		return fmt.Errorf("creating upload temp file: %w", err)
+	}
+	u.file = file
+
+	written, err := io.Copy(file, io.MultiReader(&buf, limited))
+	if err != nil {
+		u.close()
+		return uploadReadError(err, config.MaxSize)
+	}
+	if written > config.MaxSize {
+		u.close()
+		return errUploadTooLarge(config.MaxSize)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		u.close()
+		return err
+	}
+	u.body = file
+	u.Size = written
+	return nil
+}
+
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, uploadReadError(err, limit)
+	}
+	if int64(len(data)) > limit {
+		return nil, errUploadTooLarge(limit)
+	}
+	return data, nil
+}
+
+// uploadReadError reports a failed read, distinguishing bodies cut off by
+// the size limit from broken connections.
+func uploadReadError(err error, limit int64) *RpcError {
+	var maxBytes *http.MaxBytesError
+	if errors.As(err, &maxBytes) {
+		return errUploadTooLarge(limit)
+	}
+	return ErrValidation("failed to read upload")
+}
+
+func errUploadTooLarge(limit int64) *RpcError {
+	return ErrPayloadTooLarge(fmt.Sprintf("upload exceeds %d bytes", limit))
+}
@@ -0,0 +1,41 @@
+package gapp
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUploadMaxSizeBelowMemoryThreshold(t *testing.T) {
+	d := NewDispatcher(WithUploadConfig(UploadConfig{MaxSize: 1024}))
+	d.Uploads["Upload"] = func(w http.ResponseWriter, r *http.Request, method string, upload *Upload) ([]byte, error) {
+		t.Errorf("handler got a %d-byte upload over MaxSize", upload.Size)
+		return []byte{}, nil
+	}
+	content := strings.Repeat("x", 5000)
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	part, _ := mw.CreateFormFile("file", "big.txt")
+	part.Write([]byte(content))
+	mw.Close()
+	multipartReq := httptest.NewRequest(http.MethodPost, "/rpc", &form)
+	multipartReq.Header.Set("Content-Type", mw.FormDataContentType())
+
+	// A chunked body has no Content-Length to reject up front
+	rawReq := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(content))
+	rawReq.ContentLength = -1
+	rawReq.Header.Set("X-Upload-Filename", "big.txt")
+
+	for name, r := range map[string]*http.Request{"multipart": multipartReq, "raw": rawReq} {
+		r.Header.Set("X-Rpc-Method", "Upload")
+		rec := httptest.NewRecorder()
+		d.ServeHTTP(rec, r)
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s upload over MaxSize = %d, want %d", name, rec.Code, http.StatusRequestEntityTooLarge)
+		}
+	}
+}